@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/tomkiv/hotword/pkg/audio"
+)
+
+// addAGCFlags registers the automatic gain control flags on a command and binds
+// them to "<name>.agc*" configuration keys. Commands that level whole clips
+// (perClip) use audio.AGC.ProcessClip, which is not causal; the --agc help says so.
+func addAGCFlags(cmd *cobra.Command, name string, perClip bool) {
+	usage := "Enable automatic gain control on the input audio"
+	if perClip {
+		usage += " (not causal: the gain is settled on the whole clip, then applied from its start)"
+	}
+	cmd.Flags().Bool("agc", false, usage)
+	cmd.Flags().Float32("agc-target", 0.1, "AGC target RMS level")
+	cmd.Flags().Float32("agc-max-gain", 20, "AGC maximum gain")
+	cmd.Flags().Float32("agc-attack", 10, "AGC attack time in milliseconds")
+	cmd.Flags().Float32("agc-release", 500, "AGC release time in milliseconds")
+
+	viper.BindPFlag(name+".agc", cmd.Flags().Lookup("agc"))
+	viper.BindPFlag(name+".agc_target", cmd.Flags().Lookup("agc-target"))
+	viper.BindPFlag(name+".agc_max_gain", cmd.Flags().Lookup("agc-max-gain"))
+	viper.BindPFlag(name+".agc_attack", cmd.Flags().Lookup("agc-attack"))
+	viper.BindPFlag(name+".agc_release", cmd.Flags().Lookup("agc-release"))
+}
+
+// newAGCFromConfig creates the AGC configured for a command, or nil if it is disabled.
+// It rejects settings the AGC cannot level with: a target, maximum gain, attack
+// or release time that is not positive, or an invalid sample rate.
+func newAGCFromConfig(name string, sampleRate int) (*audio.AGC, error) {
+	if !viper.GetBool(name + ".agc") {
+		return nil, nil
+	}
+	target := float32(viper.GetFloat64(name + ".agc_target"))
+	maxGain := float32(viper.GetFloat64(name + ".agc_max_gain"))
+	attack := float32(viper.GetFloat64(name + ".agc_attack"))
+	release := float32(viper.GetFloat64(name + ".agc_release"))
+	switch {
+	case sampleRate <= 0:
+		return nil, fmt.Errorf("AGC needs a positive sample rate, got %d", sampleRate)
+	case target <= 0:
+		return nil, fmt.Errorf("--agc-target must be positive, got %g", target)
+	case maxGain <= 0:
+		return nil, fmt.Errorf("--agc-max-gain must be positive, got %g", maxGain)
+	case attack <= 0:
+		return nil, fmt.Errorf("--agc-attack must be a positive time in milliseconds, got %g", attack)
+	case release <= 0:
+		return nil, fmt.Errorf("--agc-release must be a positive time in milliseconds, got %g", release)
+	}
+	return audio.NewAGC(sampleRate, target, maxGain, attack, release), nil
+}
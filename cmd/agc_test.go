@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+func TestAGCValidation(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "agc_validation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	wavFile := filepath.Join(tmpDir, "test.wav")
+	createDummyWAV(wavFile)
+	modelFile := filepath.Join(tmpDir, "model.bin")
+	m := model.NewSequentialModel(
+		model.NewDenseLayer(model.NewTensor([]int{1, 2440}), []float32{0.0}),
+		model.NewSigmoidLayer(),
+	)
+	if err := model.SaveModel(modelFile, m); err != nil {
+		t.Fatalf("Failed to save mock model: %v", err)
+	}
+
+	for _, tc := range []struct {
+		args    []string
+		errText string
+	}{
+		{[]string{"--agc-target", "0"}, "--agc-target"},
+		{[]string{"--agc-target", "-0.1"}, "--agc-target"},
+		{[]string{"--agc-max-gain", "0"}, "--agc-max-gain"},
+		{[]string{"--agc-attack", "0"}, "--agc-attack"},
+		{[]string{"--agc-release", "-500"}, "--agc-release"},
+	} {
+		root := NewRootCmd()
+		root.AddCommand(NewPredictCmd())
+		args := append([]string{"predict", "--file", wavFile, "--model", modelFile, "--agc"}, tc.args...)
+		if _, err := executeCommand(root, args...); err == nil || !strings.Contains(err.Error(), tc.errText) {
+			t.Errorf("%v: expected error containing %q, got %v", tc.args, tc.errText, err)
+		}
+	}
+
+	// Invalid settings are ignored while the AGC is off
+	root := NewRootCmd()
+	root.AddCommand(NewPredictCmd())
+	if _, err := executeCommand(root, "predict", "--file", wavFile, "--model", modelFile, "--agc-target", "0"); err != nil {
+		t.Errorf("Unexpected error with the AGC disabled: %v", err)
+	}
+}
+
+func TestAGCHelp(t *testing.T) {
+	// Only the commands that level whole clips are not causal
+	for _, tc := range []struct {
+		name    string
+		cmd     *cobra.Command
+		perClip bool
+	}{
+		{"listen", NewListenCmd(), false},
+		{"predict", NewPredictCmd(), true},
+		{"verify", NewVerifyCmd(), true},
+	} {
+		usage := tc.cmd.Flags().Lookup("agc").Usage
+		if strings.Contains(usage, "not causal") != tc.perClip {
+			t.Errorf("%s: unexpected --agc help %q", tc.name, usage)
+		}
+	}
+}
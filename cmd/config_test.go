@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/spf13/viper"
+	"github.com/tomkiv/hotword/pkg/model"
 )
 
 func TestInitConfig(t *testing.T) {
@@ -30,3 +31,32 @@ func TestInitConfig(t *testing.T) {
 		t.Errorf("Expected lr 0.99, got %f", viper.GetFloat64("train.lr"))
 	}
 }
+
+func TestExampleConfigs(t *testing.T) {
+	paths, _ := filepath.Glob("../examples/*.yaml")
+	if len(paths) == 0 {
+		t.Fatal("Expected example configs in ../examples")
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			v := viper.New()
+			v.SetConfigFile(path)
+			if err := v.ReadInConfig(); err != nil {
+				t.Fatalf("Failed to read config: %v", err)
+			}
+			var layers []model.LayerConfig
+			if err := v.UnmarshalKey("model.layers", &layers); err != nil {
+				t.Fatalf("Failed to parse model configuration: %v", err)
+			}
+
+			// 1 s of 16 kHz audio with the default frontend: [1, 61, 40]
+			m, err := model.BuildModel(layers, []int{1, 61, 40})
+			if err != nil {
+				t.Fatalf("Failed to build model: %v", err)
+			}
+			if out := m.Forward(model.NewTensor([]int{1, 61, 40})); len(out.Data) != 1 {
+				t.Errorf("Expected a single output, got %d", len(out.Data))
+			}
+		})
+	}
+}
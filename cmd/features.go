@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/tomkiv/hotword/pkg/features"
+	"github.com/tomkiv/hotword/pkg/model"
+	"github.com/tomkiv/hotword/pkg/train"
+)
+
+var featuresBuildData string
+var featuresBuildCache string
+
+// NewFeaturesCmd creates a new features command
+func NewFeaturesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "features",
+		Short: "Manage extracted features",
+	}
+	cmd.AddCommand(NewFeaturesBuildCmd())
+	return cmd
+}
+
+// NewFeaturesBuildCmd creates a new features build command
+func NewFeaturesBuildCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Pre-populate the feature cache used by train",
+		Long: `Extract the features of every sample in a dataset and store them in a feature
+cache file, so "hotword train --feature-cache <file>" skips feature extraction
+for unaugmented samples.
+
+The feature frontend is read from the "features" section of the config file and
+the dataset loading mode (stride, max_len, onset) from the "train" section, so
+the cached entries match what train looks up. Entries are keyed by the content
+hash of each WAV file, the window offset and the frontend configuration; an
+existing cache file is extended.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data := viper.GetString("features_build.data")
+			cachePath := viper.GetString("features_build.cache")
+
+			featCfg, err := featureConfigFromViper()
+			if err != nil {
+				return err
+			}
+			cmd.Printf("Feature frontend: %s\n", featCfg)
+
+			ds, err := loadTrainingDataset(cmd, data, featCfg.SampleRate,
+				viper.GetInt("train.stride"), viper.GetInt("train.max_len"), viper.GetBool("train.onset"),
+				viper.GetStringSlice("train.classes"))
+			if err != nil {
+				return fmt.Errorf("failed to load dataset: %w", err)
+			}
+
+			cache, err := features.OpenCache(cachePath, featCfg)
+			if err != nil {
+				return err
+			}
+			before := cache.Len()
+
+			pb := train.NewProgressBar(len(ds.Samples), "Extracting features")
+			for i, sample := range ds.Samples {
+				extractSample(cache, sample, featCfg)
+				pb.Update(i + 1)
+			}
+			pb.Finish()
+
+			if err := cache.Save(); err != nil {
+				return err
+			}
+			cmd.Printf("Feature cache %s: %d entries (%d new)\n", cachePath, cache.Len(), cache.Len()-before)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&featuresBuildData, "data", "data", "Directory containing 'hotword' and 'background' subdirectories")
+	cmd.Flags().StringVar(&featuresBuildCache, "cache", "features.cache", "Path of the feature cache file")
+
+	viper.BindPFlag("features_build.data", cmd.Flags().Lookup("data"))
+	viper.BindPFlag("features_build.cache", cmd.Flags().Lookup("cache"))
+
+	return cmd
+}
+
+var featuresCmd = NewFeaturesCmd()
+
+func init() {
+	rootCmd.AddCommand(featuresCmd)
+}
+
+// openFeatureCache opens the feature cache selected by the --feature-cache option:
+// "memory" for an in-memory cache, "off" (or empty) for none, otherwise a file path.
+func openFeatureCache(spec string, cfg features.Config) (*features.Cache, error) {
+	switch spec {
+	case "", "off", "none":
+		return nil, nil
+	case "memory":
+		return features.NewCache(cfg), nil
+	default:
+		return features.OpenCache(spec, cfg)
+	}
+}
+
+// extractSample returns the features of an unaugmented sample, through the cache
+// when the sample was loaded from a file.
+func extractSample(cache *features.Cache, sample train.Sample, cfg features.Config) *model.Tensor {
+	if cache != nil && sample.Source != "" {
+		return cache.Extract(sample.Source, sample.Offset, sample.Audio)
+	}
+	return features.ExtractWithConfig(sample.Audio, cfg)
+}
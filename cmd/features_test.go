@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFeaturesBuild(t *testing.T) {
+	tmpDir, cleanup := createDummyData(t)
+	defer cleanup()
+	cachePath := filepath.Join(tmpDir, "features.cache")
+
+	root := NewRootCmd()
+	root.AddCommand(NewFeaturesCmd())
+	output, err := executeCommand(root, "features", "build", "--data", tmpDir, "--cache", cachePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The hotword and background files are identical, so they share one entry
+	if !strings.Contains(output, "1 entries (1 new)") {
+		t.Errorf("Expected one new cache entry, got: %s", output)
+	}
+
+	t.Run("Train Reuses Cache", func(t *testing.T) {
+		root := NewRootCmd()
+		root.AddCommand(NewTrainCmd())
+		output, err := executeCommand(root, "train", "--data", tmpDir, "--epochs", "2", "--threads", "1",
+			"--out", filepath.Join(tmpDir, "model.bin"), "--feature-cache", cachePath)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !strings.Contains(output, "(1 cached entries)") {
+			t.Errorf("Expected the prebuilt cache to be loaded, got: %s", output)
+		}
+		// Two file samples: the normalization pass plus two epochs
+		if !strings.Contains(output, "Feature cache: 6 hits, 0 misses") {
+			t.Errorf("Expected every lookup to hit the cache, got: %s", output)
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -14,7 +15,7 @@ import (
 	"github.com/tomkiv/hotword/pkg/audio"
 	"github.com/tomkiv/hotword/pkg/audio/capture"
 	"github.com/tomkiv/hotword/pkg/engine"
-	"github.com/tomkiv/hotword/pkg/model"
+	"github.com/tomkiv/hotword/pkg/features"
 )
 
 var listenAction string
@@ -27,6 +28,14 @@ var listenDebug bool
 var listenVADEnergy float32
 var listenVADZCR float32
 var listenVADHangover int
+var listenAECDevice string
+var listenAECFIFO string
+var listenAECTaps int
+var listenAECStep float32
+var listenAECDoubleTalk float32
+var listenAECDelay int
+var listenCMVNAdapt float32
+var listenFrameStreaming bool
 
 // NewListenCmd creates a new listen command
 func NewListenCmd() *cobra.Command {
@@ -46,19 +55,69 @@ func NewListenCmd() *cobra.Command {
 			vadZCR := float32(viper.GetFloat64("listen.vad_zcr"))
 			vadHangover := viper.GetInt("listen.vad_hangover")
 
+			// Echo cancellation parameters
+			aecDevice := viper.GetString("listen.aec_device")
+			aecFIFO := viper.GetString("listen.aec_fifo")
+			aecTaps := viper.GetInt("listen.aec_taps")
+			aecStep := float32(viper.GetFloat64("listen.aec_step"))
+			aecDoubleTalk := float32(viper.GetFloat64("listen.aec_double_talk"))
+			aecDelay := viper.GetInt("listen.aec_delay")
+
 			if modelFile == "" {
 				return fmt.Errorf("model file is required (use --model or set in config)")
 			}
 
+			// Optional playback reference for acoustic echo cancellation
+			if aecDevice != "" && aecFIFO != "" {
+				return fmt.Errorf("--aec-device and --aec-fifo are mutually exclusive")
+			}
+			var ec *audio.EchoCanceller
+			if aecDevice != "" || aecFIFO != "" {
+				var err error
+				if ec, err = newEchoCanceller(aecTaps, aecStep, aecDoubleTalk, aecDelay); err != nil {
+					return err
+				}
+			}
+
 			cmd.Printf("Loading model from %s...\n", modelFile)
-			m, err := model.LoadModel(modelFile)
+			m, featCfg, meta, err := features.LoadModelWithMetadata(modelFile)
 			if err != nil {
 				return fmt.Errorf("failed to load model: %w", err)
 			}
+			cmd.Printf("Feature frontend: %s\n", featCfg)
 
-			sampleRate := 16000
-			e := engine.NewEngine(m, sampleRate)
+			sampleRate := featCfg.SampleRate
+			e, err := engine.NewStreamingEngine(m, featCfg)
+			if err != nil {
+				return err
+			}
 			e.SetVAD(audio.NewVAD(vadEnergy, vadZCR, vadHangover))
+			if classes := meta.Classes(); len(classes) > 0 {
+				if err := e.SetClasses(classes); err != nil {
+					return err
+				}
+				cmd.Printf("Classes: %s\n", strings.Join(classes, ", "))
+			}
+			if rate := float32(viper.GetFloat64("listen.cmvn_adapt")); rate > 0 {
+				if err := e.SetAdaptiveCMVN(rate); err != nil {
+					return fmt.Errorf("failed to enable adaptive normalization: %w", err)
+				}
+				cmd.Printf("Adaptive feature normalization enabled (Rate: %.4f)\n", rate)
+			}
+			if viper.GetBool("listen.frame_streaming") {
+				if err := e.SetFrameStreaming(); err != nil {
+					return fmt.Errorf("failed to enable frame streaming: %w", err)
+				}
+				cmd.Println("Frame streaming enabled (one score per hop)")
+			}
+			agc, err := newAGCFromConfig("listen", sampleRate)
+			if err != nil {
+				return err
+			}
+			if agc != nil {
+				e.SetAGC(agc)
+				cmd.Printf("AGC enabled (Target RMS: %.3f, Max Gain: %.1f)\n", agc.TargetRMS, agc.MaxGain)
+			}
 
 			device, err := capture.Open("default", sampleRate)
 			if err != nil {
@@ -66,6 +125,20 @@ func NewListenCmd() *cobra.Command {
 			}
 			defer device.Close()
 
+			var refDevice capture.Device
+			if aecDevice != "" {
+				refDevice, err = capture.Open(aecDevice, sampleRate)
+			} else if aecFIFO != "" {
+				refDevice, err = capture.OpenFIFO(aecFIFO)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to open echo reference: %w", err)
+			}
+			if refDevice != nil {
+				defer refDevice.Close()
+				cmd.Printf("Echo cancellation enabled (Taps: %d, Step: %.2f, Delay: %d)\n", aecTaps, aecStep, aecDelay)
+			}
+
 			cmd.Printf("Listening for hotword (Threshold: %.2f, MinPower: %.4f, Cooldown: %dms)...\n", threshold, minPower, cooldown)
 			cmd.Printf("VAD Gate: Energy > %.4f AND ZCR < %.4f (Hangover: %dms)\n", vadEnergy, vadZCR, vadHangover)
 			cmd.Println("Press Ctrl+C to stop.")
@@ -88,6 +161,25 @@ func NewListenCmd() *cobra.Command {
 				}
 			}()
 
+			if ec != nil {
+				refOut := make(chan []float32, 10)
+				go func() {
+					if err := capture.Stream(ctx, refDevice, refOut); err != nil && err != context.Canceled {
+						fmt.Printf("\nEcho reference stream ended: %v\n", err)
+					}
+				}()
+				go func() {
+					for {
+						select {
+						case <-ctx.Done():
+							return
+						case ref := <-refOut:
+							ec.PushReference(ref)
+						}
+					}
+				}()
+			}
+
 			var detectionCount int
 			var lastDetection time.Time
 
@@ -97,6 +189,13 @@ func NewListenCmd() *cobra.Command {
 					cmd.Println("\nStopped.")
 					return nil
 				case samples := <-out:
+					// Remove speaker echo before level measurement, VAD and features
+					if ec != nil {
+						samples = ec.Cancel(samples)
+					}
+					// Level the input so thresholds do not depend on the microphone gain
+					samples = e.ApplyAGC(samples)
+
 					// Update VU meter and power level
 					_, peak := capture.CalculateLevels(samples)
 					bar := capture.GenerateVUBar(peak, 30)
@@ -112,7 +211,7 @@ func NewListenCmd() *cobra.Command {
 						// Update buffer without running inference or affecting smoothProb
 						e.PushSamples(samples)
 						if debug {
-							fmt.Printf("\n[SILENT] peak=%.4f\n", peak)
+							fmt.Printf("\n[SILENT] peak=%.4f gain=%.2f\n", peak, e.Gain())
 						} else {
 							fmt.Printf("\rVU: %s [SILENT] Detections: %d\033[K", bar, detectionCount)
 						}
@@ -125,8 +224,8 @@ func NewListenCmd() *cobra.Command {
 
 					if debug {
 						// Detailed debug output
-						fmt.Printf("\n[DEBUG] peak=%.4f raw=%.4f smooth=%.4f consec=%d vad=%v detected=%v\n",
-							peak, info.RawProb, info.SmoothProb, info.ConsecutiveHigh, info.VADActive, info.Detected)
+						fmt.Printf("\n[DEBUG] peak=%.4f gain=%.2f raw=%.4f smooth=%.4f consec=%d vad=%v detected=%v class=%q\n",
+							peak, info.Gain, info.RawProb, info.SmoothProb, info.ConsecutiveHigh, info.VADActive, info.Detected, info.Class)
 					} else {
 						status := ""
 						if !info.VADActive && peak >= minPower {
@@ -138,7 +237,11 @@ func NewListenCmd() *cobra.Command {
 					if detected {
 						detectionCount++
 						lastDetection = time.Now()
-						fmt.Printf("\n[%s] *** HOTWORD DETECTED! (Confidence: %.4f) ***\n", lastDetection.Format("15:04:05"), confidence)
+						if info.Class != "" {
+							fmt.Printf("\n[%s] *** KEYWORD %q DETECTED! (Confidence: %.4f) ***\n", lastDetection.Format("15:04:05"), info.Class, confidence)
+						} else {
+							fmt.Printf("\n[%s] *** HOTWORD DETECTED! (Confidence: %.4f) ***\n", lastDetection.Format("15:04:05"), confidence)
+						}
 
 						// Reset engine state to prevent residual probability from affecting next detection
 						e.Reset()
@@ -148,10 +251,10 @@ func NewListenCmd() *cobra.Command {
 						script := viper.GetString("listen.script")
 
 						if action != "" {
-							go executeAction(action)
+							go executeAction(action, info.Class)
 						}
 						if script != "" {
-							go executeScript(script)
+							go executeScript(script, info.Class)
 						}
 					}
 				}
@@ -169,6 +272,14 @@ func NewListenCmd() *cobra.Command {
 	cmd.Flags().Float32Var(&listenVADEnergy, "vad-energy", 0.01, "RMS energy threshold for VAD (speech detection)")
 	cmd.Flags().Float32Var(&listenVADZCR, "vad-zcr", 0.5, "Zero-Crossing Rate threshold for VAD (speech detection)")
 	cmd.Flags().IntVar(&listenVADHangover, "vad-hangover", 300, "VAD hangover period in milliseconds")
+	cmd.Flags().StringVar(&listenAECDevice, "aec-device", "", "Loopback capture device carrying the playback signal for echo cancellation")
+	cmd.Flags().StringVar(&listenAECFIFO, "aec-fifo", "", "FIFO carrying the playback signal (raw s16le mono) for echo cancellation")
+	cmd.Flags().IntVar(&listenAECTaps, "aec-taps", 512, "Echo canceller filter length in samples, > 0 (must cover the echo tail)")
+	cmd.Flags().Float32Var(&listenAECStep, "aec-step", 0.3, "Echo canceller NLMS step size, in (0, 2)")
+	cmd.Flags().Float32Var(&listenAECDoubleTalk, "aec-double-talk", 0.5, "Freeze echo canceller adaptation while |mic| exceeds this fraction of the reference peak (0 = off)")
+	cmd.Flags().IntVar(&listenAECDelay, "aec-delay", 0, "Delay of the echo behind the reference in samples (>= 0), removed before the filter")
+	cmd.Flags().Float32Var(&listenCMVNAdapt, "cmvn-adapt", 0, "Per-frame rate at which the feature normalization mean adapts to the input (0 = fixed)")
+	cmd.Flags().BoolVar(&listenFrameStreaming, "frame-streaming", false, "Score every hop by advancing the recurrent state frame by frame (requires a model trained with --causal)")
 
 	viper.BindPFlag("listen.action", cmd.Flags().Lookup("action"))
 	viper.BindPFlag("listen.script", cmd.Flags().Lookup("script"))
@@ -180,12 +291,24 @@ func NewListenCmd() *cobra.Command {
 	viper.BindPFlag("listen.vad_energy", cmd.Flags().Lookup("vad-energy"))
 	viper.BindPFlag("listen.vad_zcr", cmd.Flags().Lookup("vad-zcr"))
 	viper.BindPFlag("listen.vad_hangover", cmd.Flags().Lookup("vad-hangover"))
+	viper.BindPFlag("listen.aec_device", cmd.Flags().Lookup("aec-device"))
+	viper.BindPFlag("listen.aec_fifo", cmd.Flags().Lookup("aec-fifo"))
+	viper.BindPFlag("listen.aec_taps", cmd.Flags().Lookup("aec-taps"))
+	viper.BindPFlag("listen.aec_step", cmd.Flags().Lookup("aec-step"))
+	viper.BindPFlag("listen.aec_double_talk", cmd.Flags().Lookup("aec-double-talk"))
+	viper.BindPFlag("listen.aec_delay", cmd.Flags().Lookup("aec-delay"))
+	viper.BindPFlag("listen.cmvn_adapt", cmd.Flags().Lookup("cmvn-adapt"))
+	viper.BindPFlag("listen.frame_streaming", cmd.Flags().Lookup("frame-streaming"))
+	addAGCFlags(cmd, "listen", false)
 
 	return cmd
 }
 
-func executeAction(action string) {
+// executeAction runs a shell command on detection. The detected class of a
+// multi-class model is passed in the HOTWORD_CLASS environment variable.
+func executeAction(action, class string) {
 	cmd := exec.Command("sh", "-c", action)
+	cmd.Env = append(os.Environ(), "HOTWORD_CLASS="+class)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
@@ -193,8 +316,10 @@ func executeAction(action string) {
 	}
 }
 
-func executeScript(path string) {
+// executeScript runs a script on detection, with HOTWORD_CLASS set like executeAction.
+func executeScript(path, class string) {
 	cmd := exec.Command(path)
+	cmd.Env = append(os.Environ(), "HOTWORD_CLASS="+class)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
@@ -202,6 +327,32 @@ func executeScript(path string) {
 	}
 }
 
+// newEchoCanceller creates the echo canceller of the --aec-* options, checking
+// them before any audio device is opened.
+func newEchoCanceller(taps int, step, doubleTalk float32, delay int) (*audio.EchoCanceller, error) {
+	if taps <= 0 {
+		return nil, fmt.Errorf("--aec-taps must be positive, got %d", taps)
+	}
+	if !(step > 0 && step < 2) {
+		return nil, fmt.Errorf("--aec-step must be in (0, 2), got %g", step)
+	}
+	if doubleTalk < 0 {
+		return nil, fmt.Errorf("--aec-double-talk must not be negative, got %g", doubleTalk)
+	}
+	if delay < 0 {
+		return nil, fmt.Errorf("--aec-delay must not be negative, got %d", delay)
+	}
+	ec, err := audio.NewEchoCanceller(taps, step)
+	if err != nil {
+		return nil, err
+	}
+	ec.DoubleTalkThreshold = doubleTalk
+	if err := ec.SetDelay(delay); err != nil {
+		return nil, err
+	}
+	return ec, nil
+}
+
 var listenCmd = NewListenCmd()
 
 func init() {
@@ -50,3 +50,25 @@ func TestListenFlags(t *testing.T) {
 		t.Errorf("Expected viper listen.cooldown 5000, got %d", viper.GetInt("listen.cooldown"))
 	}
 }
+
+func TestListenAECValidation(t *testing.T) {
+	for _, tc := range []struct {
+		args    []string
+		errText string
+	}{
+		{[]string{"--aec-taps", "0"}, "--aec-taps"},
+		{[]string{"--aec-taps", "-8"}, "--aec-taps"},
+		{[]string{"--aec-step", "2"}, "--aec-step"},
+		{[]string{"--aec-step", "0"}, "--aec-step"},
+		{[]string{"--aec-double-talk", "-0.5"}, "--aec-double-talk"},
+		{[]string{"--aec-delay", "-1"}, "--aec-delay"},
+	} {
+		root := NewRootCmd()
+		root.AddCommand(NewListenCmd())
+		// The options are checked before the model is loaded or a device opened
+		args := append([]string{"listen", "--model", "missing.bin", "--aec-fifo", "missing.fifo"}, tc.args...)
+		if _, err := executeCommand(root, args...); err == nil || !strings.Contains(err.Error(), tc.errText) {
+			t.Errorf("%v: expected error containing %q, got %v", tc.args, tc.errText, err)
+		}
+	}
+}
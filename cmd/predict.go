@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -22,7 +23,9 @@ func NewPredictCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "predict",
 		Short: "Test a single WAV file against a model",
-		Long:  `Test a single WAV file against a trained hotword model and get a verdict and confidence score.`,
+		Long: `Test a single WAV file against a trained hotword model and get a verdict and confidence score.
+For a multi-class model the most probable class is reported with its probability;
+the "unknown" and "silence" classes are never a detection.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			filePath := viper.GetString("predict.file")
 			modelPath := viper.GetString("predict.model")
@@ -35,10 +38,15 @@ func NewPredictCmd() *cobra.Command {
 
 			// 1. Load Model
 			cmd.Printf("Loading model %s...\n", modelPath)
-			m, err := model.LoadModel(modelPath)
+			m, featCfg, meta, err := features.LoadModelWithMetadata(modelPath)
 			if err != nil {
 				return fmt.Errorf("failed to load model: %w", err)
 			}
+			cmd.Printf("Feature frontend: %s\n", featCfg)
+			classes := meta.Classes()
+			if len(classes) > 0 {
+				cmd.Printf("Classes: %s\n", strings.Join(classes, ", "))
+			}
 
 			// 2. Load WAV
 			f, err := os.Open(filePath)
@@ -52,8 +60,12 @@ func NewPredictCmd() *cobra.Command {
 				return fmt.Errorf("failed to load WAV data: %w", err)
 			}
 
+			if int(sampleRate) != featCfg.SampleRate {
+				cmd.Printf("Warning: file sample rate %dHz differs from model sample rate %dHz\n", sampleRate, featCfg.SampleRate)
+			}
+
 			// 3. Normalize to 1 second (with optional onset detection)
-			const targetLength = 16000
+			targetLength := featCfg.SampleRate
 			var normalized []float32
 
 			if onset {
@@ -64,9 +76,18 @@ func NewPredictCmd() *cobra.Command {
 				copy(normalized, samples)
 			}
 
+			// Optional gain control, applied to the clip as a whole
+			agc, err := newAGCFromConfig("predict", int(sampleRate))
+			if err != nil {
+				return err
+			}
+			if agc != nil {
+				normalized = agc.ProcessClip(normalized)
+			}
+
 			// 4. Extract Features
-			// Note: We use the same parameters as training/verification
-			input := features.Extract(normalized, int(sampleRate), 512, 256, 40)
+			// Note: The frontend is reconstructed from the model so it matches training
+			input := features.ExtractWithConfig(normalized, featCfg)
 			if input == nil {
 				return fmt.Errorf("failed to extract features")
 			}
@@ -75,10 +96,24 @@ func NewPredictCmd() *cobra.Command {
 			output := m.Forward(input)
 			confidence := output.Data[0]
 
+			// A multi-class model is scored by its most probable class; the
+			// background classes never count as a detection
+			predicted := -1
+			if len(classes) > 0 {
+				predicted, err = predictClass(output.Data, len(classes))
+				if err != nil {
+					return err
+				}
+				confidence = output.Data[predicted]
+			}
+
 			// 6. Report
 			verdict := "NOT HOTWORD"
-			if confidence >= threshold {
+			if confidence >= threshold && (predicted < 0 || !model.IsBackgroundClass(classes[predicted])) {
 				verdict = "HOTWORD"
+				if predicted >= 0 {
+					verdict = fmt.Sprintf("KEYWORD %q", classes[predicted])
+				}
 			}
 
 			duration := float64(len(samples)) / float64(sampleRate)
@@ -88,7 +123,13 @@ func NewPredictCmd() *cobra.Command {
 			if onset {
 				cmd.Printf("Preprocessing: Onset detection enabled\n")
 			}
+			if agc != nil {
+				cmd.Printf("Preprocessing: AGC enabled (Gain: %.2f)\n", agc.Gain())
+			}
 			cmd.Printf("--------------------\n")
+			if predicted >= 0 {
+				cmd.Printf("Class:      %s\n", classes[predicted])
+			}
 			cmd.Printf("Confidence: %.4f\n", confidence)
 			cmd.Printf("Verdict:    %s (Threshold: %.2f)\n", verdict, threshold)
 
@@ -105,6 +146,7 @@ func NewPredictCmd() *cobra.Command {
 	viper.BindPFlag("predict.model", cmd.Flags().Lookup("model"))
 	viper.BindPFlag("predict.threshold", cmd.Flags().Lookup("threshold"))
 	viper.BindPFlag("predict.onset", cmd.Flags().Lookup("onset"))
+	addAGCFlags(cmd, "predict", true)
 
 	return cmd
 }
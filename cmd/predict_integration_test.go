@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/tomkiv/hotword/pkg/features"
 	"github.com/tomkiv/hotword/pkg/model"
 )
 
@@ -50,3 +51,139 @@ func TestPredictIntegration(t *testing.T) {
 		t.Errorf("Expected confidence in output, got:\n%s", output)
 	}
 }
+
+func TestPredictFrontendFromModel(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "predict_frontend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	wavFile := filepath.Join(tmpDir, "test.wav")
+	createDummyWAV(wavFile)
+
+	// Non-default frontend: (16000-256)/128+1 = 124 frames x 20 mel bins
+	cfg := features.DefaultConfig()
+	cfg.WindowSize = 256
+	cfg.HopSize = 128
+	cfg.NumMelFilters = 20
+	cfg.Noise = features.DefaultNoiseConfig("wiener")
+
+	modelFile := filepath.Join(tmpDir, "model.bin")
+	m := model.NewSequentialModel(
+		model.NewDenseLayer(model.NewTensor([]int{1, 124 * 20}), []float32{0.0}),
+		model.NewSigmoidLayer(),
+	)
+	if err := features.SaveModel(modelFile, m, cfg); err != nil {
+		t.Fatalf("Failed to save mock model: %v", err)
+	}
+
+	root := NewRootCmd()
+	predict := NewPredictCmd()
+	root.AddCommand(predict)
+
+	output, err := executeCommand(root, "predict", "--file", wavFile, "--model", modelFile, "--threshold", "0.5")
+	if err != nil {
+		t.Fatalf("Predict command failed: %v", err)
+	}
+	if !strings.Contains(output, "window 256, hop 128, 20 mel bins") {
+		t.Errorf("Expected frontend to be reconstructed from the model, got:\n%s", output)
+	}
+	if !strings.Contains(output, "wiener noise suppression") {
+		t.Errorf("Expected noise suppression to be picked up from the model, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Confidence:") {
+		t.Errorf("Expected confidence in output, got:\n%s", output)
+	}
+}
+
+func TestPredictAGC(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "predict_agc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	wavFile := filepath.Join(tmpDir, "test.wav")
+	createDummyWAV(wavFile)
+
+	modelFile := filepath.Join(tmpDir, "model.bin")
+	m := model.NewSequentialModel(
+		model.NewDenseLayer(model.NewTensor([]int{1, 2440}), []float32{0.0}),
+		model.NewSigmoidLayer(),
+	)
+	if err := model.SaveModel(modelFile, m); err != nil {
+		t.Fatalf("Failed to save mock model: %v", err)
+	}
+
+	root := NewRootCmd()
+	predict := NewPredictCmd()
+	root.AddCommand(predict)
+
+	output, err := executeCommand(root, "predict", "--file", wavFile, "--model", modelFile, "--agc", "--agc-max-gain", "4")
+	if err != nil {
+		t.Fatalf("Predict command failed: %v", err)
+	}
+	if !strings.Contains(output, "AGC enabled") {
+		t.Errorf("Expected AGC to be reported, got:\n%s", output)
+	}
+}
+
+func TestPredictClasses(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "predict_classes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	wavFile := filepath.Join(tmpDir, "test.wav")
+	createDummyWAV(wavFile)
+
+	// The bias picks the winning class for any input
+	save := func(name string, bias []float32, classes []string) string {
+		path := filepath.Join(tmpDir, name)
+		m := model.NewSequentialModel(
+			model.NewDenseLayer(model.NewTensor([]int{len(bias), 2440}), bias),
+			model.NewSoftmaxLayer(),
+		)
+		meta := model.Metadata{}
+		meta.SetClasses(classes)
+		if err := features.SaveModelWithMetadata(path, m, features.DefaultConfig(), meta); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	classes := []string{"yes", "no", "unknown"}
+
+	for _, tc := range []struct {
+		name     string
+		bias     []float32
+		expected []string
+	}{
+		{"Keyword", []float32{0, 3, 0}, []string{"Classes: yes, no, unknown", "Class:      no", "Confidence: 0.9094", `Verdict:    KEYWORD "no"`}},
+		{"Background", []float32{0, 0, 3}, []string{"Class:      unknown", "Verdict:    NOT HOTWORD"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := NewRootCmd()
+			root.AddCommand(NewPredictCmd())
+			output, err := executeCommand(root, "predict", "--file", wavFile, "--model", save(tc.name+".bin", tc.bias, classes))
+			if err != nil {
+				t.Fatalf("Predict command failed: %v", err)
+			}
+			for _, expected := range tc.expected {
+				if !strings.Contains(output, expected) {
+					t.Errorf("Expected %q in output, got:\n%s", expected, output)
+				}
+			}
+		})
+	}
+
+	t.Run("Output Mismatch", func(t *testing.T) {
+		root := NewRootCmd()
+		root.AddCommand(NewPredictCmd())
+		_, err := executeCommand(root, "predict", "--file", wavFile, "--model", save("wide.bin", make([]float32, 4), classes))
+		if err == nil || !strings.Contains(err.Error(), "4 outputs but there are 3 classes") {
+			t.Errorf("Expected an output count error, got %v", err)
+		}
+	})
+}
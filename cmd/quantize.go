@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/tomkiv/hotword/pkg/engine"
+	"github.com/tomkiv/hotword/pkg/features"
+	"github.com/tomkiv/hotword/pkg/model"
+	"github.com/tomkiv/hotword/pkg/train"
+)
+
+var quantizeModelFile string
+var quantizeDataDir string
+var quantizeOut string
+var quantizeSamples int
+
+// NewQuantizeCmd creates a new quantize command
+func NewQuantizeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "quantize",
+		Short: "Convert a trained model to int8",
+		Long: `Convert the conv2d, dense, gru and lstm layers of a trained float32 model to
+int8 for small devices.
+
+The input range of every converted layer is calibrated by running the model on
+a sample of the training set (spread evenly over the dataset). Weights are
+quantized per output channel with their own scale and zero point, and the int8
+layers accumulate in int32. Other layers stay float32. The quantized model is
+about a quarter of the size and is used like any other model; run
+"hotword verify --model <int8 model> --float-model <float model>" to see the
+accuracy lost to quantization.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			modelFile := viper.GetString("quantize.model")
+			dataDir := viper.GetString("quantize.data")
+			out := viper.GetString("quantize.out")
+			numSamples := viper.GetInt("quantize.samples")
+			if numSamples <= 0 {
+				return fmt.Errorf("samples must be positive, got %d", numSamples)
+			}
+
+			cmd.Printf("Loading model from %s...\n", modelFile)
+			m, featCfg, meta, err := features.LoadModelWithMetadata(modelFile)
+			if err != nil {
+				return fmt.Errorf("failed to load model: %w", err)
+			}
+			if model.IsQuantized(m) {
+				return fmt.Errorf("%s is already quantized", modelFile)
+			}
+
+			cmd.Printf("Loading calibration dataset from %s...\n", dataDir)
+			var ds *train.Dataset
+			if classes := meta.Classes(); len(classes) > 0 {
+				cmd.Printf("Classes: %s\n", strings.Join(classes, ", "))
+				ds, err = train.LoadClassDataset(dataDir, classes, featCfg.SampleRate, 0, featCfg.SampleRate)
+			} else {
+				ds, err = train.LoadDataset(filepath.Join(dataDir, "hotword"), filepath.Join(dataDir, "background"), featCfg.SampleRate)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load dataset: %w", err)
+			}
+			if len(ds.Samples) == 0 {
+				return fmt.Errorf("no samples found in dataset")
+			}
+			if numSamples > len(ds.Samples) {
+				numSamples = len(ds.Samples)
+			}
+
+			e := engine.NewEngineWithConfig(m, featCfg)
+			calibrator := model.NewCalibrator(m)
+			pb := train.NewProgressBar(numSamples, "Calibrating")
+			for i := 0; i < numSamples; i++ {
+				sample := ds.Samples[i*len(ds.Samples)/numSamples]
+				if input := e.SingleInput(sample.Audio); input != nil {
+					calibrator.Observe(input)
+				}
+				pb.Update(i + 1)
+			}
+			pb.Finish()
+
+			q, quantized, err := model.Quantize(m, calibrator)
+			if err != nil {
+				return err
+			}
+			if quantized == 0 {
+				return fmt.Errorf("the model has no conv2d, dense, gru or lstm layers to quantize")
+			}
+			cmd.Printf("Quantized %d layers to int8 (calibrated on %d samples)\n", quantized, calibrator.Samples())
+
+			if err := features.SaveModelWithMetadata(out, q, featCfg, meta); err != nil {
+				return fmt.Errorf("failed to save model: %w", err)
+			}
+			before, err := os.Stat(modelFile)
+			if err != nil {
+				return err
+			}
+			after, err := os.Stat(out)
+			if err != nil {
+				return err
+			}
+			cmd.Printf("Model saved to %s (%d bytes, %.1f%% of %d bytes)\n",
+				out, after.Size(), float64(after.Size())/float64(before.Size())*100, before.Size())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&quantizeModelFile, "model", "model.bin", "Path to the trained float32 model")
+	cmd.Flags().StringVar(&quantizeDataDir, "data", "data", "Training data used for calibration ('hotword' and 'background' subdirectories, or one per class)")
+	cmd.Flags().StringVar(&quantizeOut, "out", "model_int8.bin", "Path to save the quantized model")
+	cmd.Flags().IntVar(&quantizeSamples, "samples", 200, "Number of samples used to calibrate the activation ranges")
+
+	viper.BindPFlag("quantize.model", cmd.Flags().Lookup("model"))
+	viper.BindPFlag("quantize.data", cmd.Flags().Lookup("data"))
+	viper.BindPFlag("quantize.out", cmd.Flags().Lookup("out"))
+	viper.BindPFlag("quantize.samples", cmd.Flags().Lookup("samples"))
+
+	return cmd
+}
+
+var quantizeCmd = NewQuantizeCmd()
+
+func init() {
+	rootCmd.AddCommand(quantizeCmd)
+}
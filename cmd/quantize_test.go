@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tomkiv/hotword/pkg/features"
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+func TestQuantize(t *testing.T) {
+	tmpDir, cleanup := createDummyData(t)
+	defer cleanup()
+
+	floatFile := filepath.Join(tmpDir, "model.bin")
+	rng := rand.New(rand.NewSource(1))
+	weights := model.NewTensor([]int{1, 2440})
+	for i := range weights.Data {
+		weights.Data[i] = rng.Float32()*0.02 - 0.01
+	}
+	m := model.NewSequentialModel(model.NewDenseLayer(weights, []float32{0.1}), model.NewSigmoidLayer())
+	if err := features.SaveModel(floatFile, m, features.DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	root := NewRootCmd()
+	root.AddCommand(NewQuantizeCmd())
+	out := filepath.Join(tmpDir, "model_int8.bin")
+	output, err := executeCommand(root, "quantize", "--model", floatFile, "--data", tmpDir, "--out", out, "--samples", "10")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "Quantized 1 layers to int8 (calibrated on 10 samples)") {
+		t.Errorf("Expected quantization summary, got: %s", output)
+	}
+
+	q, _, err := features.LoadModel(out)
+	if err != nil {
+		t.Fatalf("Failed to load quantized model: %v", err)
+	}
+	if q.GetLayers()[0].Type() != "dense_int8" {
+		t.Errorf("Expected a dense_int8 layer, got %s", q.GetLayers()[0].Type())
+	}
+
+	root = NewRootCmd()
+	root.AddCommand(NewQuantizeCmd())
+	if _, err := executeCommand(root, "quantize", "--model", out, "--data", tmpDir, "--out", out); err == nil ||
+		!strings.Contains(err.Error(), "already quantized") {
+		t.Errorf("Expected an error for an already quantized model, got %v", err)
+	}
+
+	root = NewRootCmd()
+	root.AddCommand(NewVerifyCmd())
+	output, err = executeCommand(root, "verify", "--model", out, "--data", tmpDir, "--float-model", floatFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{"Float Model Comparison", "Float accuracy:", "Accuracy delta:"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output, got: %s", expected, output)
+		}
+	}
+}
@@ -37,3 +37,16 @@ func createDummyWAV(path string) {
 	binary.Write(f, binary.LittleEndian, dataSize)
 	f.Write(make([]byte, dataSize))
 }
+
+// createClassData creates a multi-class dataset with one WAV file per class directory.
+func createClassData(t *testing.T, classes ...string) (string, func()) {
+	tmpDir, err := os.MkdirTemp("", "hotword_cmd_classes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, class := range classes {
+		os.MkdirAll(filepath.Join(tmpDir, class), 0755)
+		createDummyWAV(filepath.Join(tmpDir, class, "s.wav"))
+	}
+	return tmpDir, func() { os.RemoveAll(tmpDir) }
+}
@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"math/rand"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -20,11 +20,32 @@ var trainLR float32
 var trainStride int
 var trainMaxLen int
 var trainOnset bool
+var trainClasses []string
 var trainAugProb float32
 var trainMaxNoise float32
 var trainMaxShift int
 var trainMaxGain float32
 var trainThreads int
+var trainNoiseSuppression string
+var trainCMVN bool
+var trainSpecProb float32
+var trainTimeMasks int
+var trainTimeMaskWidth int
+var trainFreqMasks int
+var trainFreqMaskWidth int
+var trainTimeWarp int
+var trainFeatureCache string
+var trainWorkers int
+var trainPrefetch int
+var trainSeed int64
+var trainFoldBatchNorm bool
+var trainCausal bool
+var trainPrune float32
+var trainPruneStart int
+var trainPruneEnd int
+var trainTeacher string
+var trainDistillAlpha float32
+var trainDistillTemperature float32
 
 // NewTrainCmd creates a new train command
 func NewTrainCmd() *cobra.Command {
@@ -41,15 +62,81 @@ Variable-length input options:
   --onset: Use onset detection to find where hotword starts in each file.
             Automatically crops audio from the detected onset position.
 
+Multi-class models:
+  --classes: Train one model for several keywords, e.g. --classes yes,no,unknown,silence.
+            The samples of each class are read from <data>/<class>; without a
+            "silence" directory the silence class is filled with synthetic noise.
+            The model must end with a dense layer of one unit per class and a
+            softmax, and is trained with categorical cross-entropy.
+
 Augmentation options:
   --augment-prob: Probability of applying random augmentations to hotword samples (0.0 to 1.0).
   --max-noise: Maximum noise ratio to mix into samples (0.0 to 1.0).
   --max-shift: Maximum random time shift in milliseconds.
   --max-gain: Maximum random gain/volume scaling (e.g. 0.2 for 0.8x-1.2x).
 
+SpecAugment options (applied to the features of every sample):
+  --spec-augment-prob: Probability of applying time/frequency masking and time warping.
+  --time-masks, --time-mask-width: Number of time masks and their maximum width in frames.
+  --freq-masks, --freq-mask-width: Number of frequency masks and their maximum width in bins.
+  --time-warp: Maximum time warp distance in frames (0 = off).
+
+Feature frontend:
+  The frontend (sample rate, window/hop size, mel bins, frequency range,
+  pre-emphasis, log scaling) is read from the "features" section of the config
+  file and stored in the model, so listen, predict and verify reconstruct it.
+  --noise-suppression: Suppress stationary background noise before feature extraction
+            ("spectral" or "wiener"). Training, predict and verify estimate the
+            noise on each clip from scratch; listen keeps one estimate for the
+            whole stream.
+  --feature-cache: Cache the features of unaugmented samples: "memory" (default) reuses
+          them across epochs, a file path also reuses them across runs (see
+          "hotword features build"), "off" disables caching.
+  --cmvn: Normalize features with per-coefficient mean and variance computed over
+          the training set (stored in the model). Enabled by default.
+
+Model options:
+  --fold-batchnorm: Fold batchnorm layers that directly follow a convolution
+            (conv2d, depthwise_conv2d, pointwise_conv2d or conv1d) into the
+            convolution before saving. The saved model computes the same
+            inference output with fewer operations.
+  Training feeds one sample at a time, so batchnorm layers collect running
+  averages of the per-sample statistics. They normalize each sample with its
+  own statistics for the first 100 samples only, then with the running
+  averages, which listen, predict and verify use as well, so training and
+  inference apply the same transform.
+
+Pruning options:
+  --prune: Target fraction of zero weights in dense and gru layers (e.g. 0.8).
+            The smallest weights are zeroed at the start of every epoch, with a
+            sparsity growing from 0 at --prune-start to the target at --prune-end
+            (default the last epoch); pruned weights stay zero while training.
+            Per-layer targets, by layer index or type, are read from
+            train.prune.layers in the config file (e.g. {dense: 0.9, "0": 0}).
+            Layers of at least 50% sparsity are saved in a compressed sparse
+            format and run with sparse kernels.
+
+Distillation options:
+  --teacher: Train a small model from a larger trained model (e.g. a CNN+GRU
+            trained on a desktop). The teacher runs on the audio of every
+            sample, after augmentation, through its own feature frontend, and
+            the model trains on a mix of the hard-label loss and the
+            cross-entropy against the softened teacher outputs. The teacher
+            must have the same classes as the trained model.
+  --distill-alpha: Weight of the teacher loss (0 to 1); the hard-label loss
+            has weight 1 - alpha.
+  --distill-temperature: Temperature softening the teacher and model outputs
+            (1 = unchanged, higher values expose more of the teacher's
+            confidence in the other classes).
+
 Performance options:
   --threads: Number of CPU threads to use for parallel training (sharded SGD).
-             Defaults to number of CPU cores. Set to 1 for sequential.`,
+             Defaults to number of CPU cores. Set to 1 for sequential.
+  --workers: Number of data pipeline workers that shuffle, augment and extract
+             features ahead of the trainer. Defaults to number of CPU cores.
+  --prefetch: Number of examples prepared ahead of the trainer (0 = 2 per worker).
+  --seed: Random seed for model initialization, shuffling and augmentation.
+          With the same seed, data and settings a run is reproducible. 0 = random.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			data := viper.GetString("train.data")
 			out := viper.GetString("train.out")
@@ -66,41 +153,24 @@ Performance options:
 			maxShift := viper.GetInt("train.max_shift")
 			maxGain := float32(viper.GetFloat64("train.max_gain"))
 
-			sampleRate := 16000
-			windowSize := 512
-			hopSize := 256
-			numMelFilters := 40
-			windowLen := 16000 // 1 second at 16kHz
-
-			cmd.Printf("Loading dataset from %s...\n", data)
-			hotwordDir := filepath.Join(data, "hotword")
-			backgroundDir := filepath.Join(data, "background")
-
-			var ds *train.Dataset
-			var err error
-
-			// Choose loading mode based on flags
-			if onset && stride > 0 {
-				// Combined: Onset detection + Window/Stride extraction
-				cmd.Printf("Using onset detection + windowed loading (stride=%d samples, %.2fs)\n", stride, float64(stride)/float64(sampleRate))
-				ds, err = train.LoadDatasetWithOnsetAndStride(hotwordDir, backgroundDir, windowLen, stride, sampleRate, 0.1)
-			} else if onset {
-				// Option 2: Onset detection mode only
-				cmd.Printf("Using onset detection (threshold=0.1)\n")
-				ds, err = train.LoadDatasetWithOnset(hotwordDir, backgroundDir, windowLen, sampleRate, 0.1)
-			} else if stride > 0 {
-				// Option 1: Window/Stride extraction mode
-				cmd.Printf("Using windowed loading (stride=%d samples, %.2fs)\n", stride, float64(stride)/float64(sampleRate))
-				ds, err = train.LoadDatasetWindowed(hotwordDir, backgroundDir, windowLen, stride)
-			} else if maxLen > 0 {
-				// Option 3: Variable length with padding mode
-				cmd.Printf("Using padded loading (max_len=%d samples, %.2fs)\n", maxLen, float64(maxLen)/float64(sampleRate))
-				ds, err = train.LoadDatasetWithPadding(hotwordDir, backgroundDir, maxLen)
-			} else {
-				// Legacy mode: first 1 second only
-				ds, err = train.LoadDataset(hotwordDir, backgroundDir)
+			// Feature frontend (recorded in the model metadata)
+			featCfg, err := featureConfigFromViper()
+			if err != nil {
+				return err
+			}
+			cmd.Printf("Feature frontend: %s\n", featCfg)
+
+			// Seed everything random (synthetic noise, initialization, shuffling, augmentation)
+			seed := viper.GetInt64("train.seed")
+			if seed == 0 {
+				seed = time.Now().UnixNano()
 			}
+			cmd.Printf("Random seed: %d\n", seed)
+			model.ResetRand(uint64(seed))
+			train.SeedNoise(seed)
 
+			classes := viper.GetStringSlice("train.classes")
+			ds, err := loadTrainingDataset(cmd, data, featCfg.SampleRate, stride, maxLen, onset, classes)
 			if err != nil {
 				return fmt.Errorf("failed to load dataset: %w", err)
 			}
@@ -111,15 +181,33 @@ Performance options:
 
 			cmd.Printf("Loaded %d samples\n", len(ds.Samples))
 
-			// Shuffle dataset
-			rand.Seed(time.Now().UnixNano())
-			rand.Shuffle(len(ds.Samples), func(i, j int) {
-				ds.Samples[i], ds.Samples[j] = ds.Samples[j], ds.Samples[i]
-			})
+			// Features of unaugmented samples are cached across epochs (and runs with a file)
+			cache, err := openFeatureCache(viper.GetString("train.feature_cache"), featCfg)
+			if err != nil {
+				return err
+			}
+			if cache != nil {
+				cmd.Printf("Using feature cache %s (%d cached entries)\n", viper.GetString("train.feature_cache"), cache.Len())
+			}
+
+			// Compute feature normalization statistics over the (unaugmented) training set
+			if viper.GetBool("train.cmvn") {
+				var acc features.CMVNAccumulator
+				for _, sample := range ds.Samples {
+					if f := extractSample(cache, sample, featCfg); f != nil {
+						acc.Add(f)
+					}
+				}
+				featCfg.CMVN = acc.CMVN()
+				if cache != nil {
+					cache.SetCMVN(featCfg.CMVN)
+				}
+				cmd.Printf("Computed feature normalization statistics over %d samples\n", len(ds.Samples))
+			}
 
 			// Define feature extractor
 			extractor := func(samples []float32) *model.Tensor {
-				return features.Extract(samples, sampleRate, windowSize, hopSize, numMelFilters)
+				return features.ExtractWithConfig(samples, featCfg)
 			}
 
 			// Get model configuration from Viper
@@ -134,6 +222,18 @@ Performance options:
 					{Type: "dense", Units: 1},
 					{Type: "sigmoid"},
 				}
+				if len(classes) > 0 {
+					modelConfigs = []model.LayerConfig{
+						{Type: "dense", Units: len(classes)},
+						{Type: "softmax"},
+					}
+				}
+			}
+
+			// Causal convolutions match frame streaming inference (listen --frame-streaming)
+			causal := viper.GetBool("train.causal")
+			if causal {
+				modelConfigs = model.CausalConfigs(modelConfigs)
 			}
 
 			// Determine input shape from first sample
@@ -141,10 +241,30 @@ Performance options:
 			inputShape := firstFeatures.Shape
 
 			// Build model with Xavier initialization
-			m, err := model.BuildModelFromConfig(modelConfigs, inputShape)
+			m, err := model.BuildModel(modelConfigs, inputShape)
 			if err != nil {
 				return fmt.Errorf("failed to build model: %w", err)
 			}
+			if len(classes) > 0 {
+				// Cross-entropy training needs one softmax output per class
+				layers := m.GetLayers()
+				if layers[len(layers)-1].Type() != "softmax" {
+					return fmt.Errorf("a multi-class model must end with a softmax layer")
+				}
+				if n := len(m.Forward(firstFeatures).Data); n != len(classes) {
+					return fmt.Errorf("the model has %d outputs but there are %d classes", n, len(classes))
+				}
+			}
+			if maxLen > 0 && stride == 0 && !onset {
+				if err := model.CheckMask(m, inputShape); err != nil {
+					return fmt.Errorf("--max-len: %w", err)
+				}
+			}
+			if causal {
+				if _, err := model.NewFrameStream(m); err != nil {
+					cmd.Printf("Warning: the model cannot be used for frame streaming: %v\n", err)
+				}
+			}
 
 			var t train.AugmentorTrainer
 			if threads > 1 || threads == 0 {
@@ -153,6 +273,38 @@ Performance options:
 				t = train.NewTrainer(m, lr)
 			}
 
+			// Setup magnitude pruning if needed
+			pruneCfg := train.PruneConfig{
+				Sparsity:   float32(viper.GetFloat64("train.prune.sparsity")),
+				StartEpoch: viper.GetInt("train.prune.start_epoch"),
+				EndEpoch:   viper.GetInt("train.prune.end_epoch"),
+			}
+			if err := viper.UnmarshalKey("train.prune.layers", &pruneCfg.Layers); err != nil {
+				return fmt.Errorf("failed to parse pruning configuration: %w", err)
+			}
+			if pruneCfg.Enabled() {
+				pruner, err := train.NewPruner(m, pruneCfg, epochs)
+				if err != nil {
+					return err
+				}
+				cmd.Printf("Using magnitude pruning (Sparsity: %.2f, Layers: %v)\n", pruneCfg.Sparsity, pruneCfg.Layers)
+				t.SetPruner(pruner)
+			}
+
+			// Setup knowledge distillation if needed
+			if teacherFile := viper.GetString("train.teacher"); teacherFile != "" {
+				distillCfg := train.DistillConfig{
+					Alpha:       float32(viper.GetFloat64("train.distill.alpha")),
+					Temperature: float32(viper.GetFloat64("train.distill.temperature")),
+				}
+				teacher, err := loadTeacher(teacherFile, distillCfg, ds, featCfg.SampleRate, len(m.Forward(firstFeatures).Data))
+				if err != nil {
+					return err
+				}
+				cmd.Printf("Distilling from teacher %s (Alpha: %.2f, Temperature: %.1f)\n", teacherFile, distillCfg.Alpha, distillCfg.Temperature)
+				t.SetTeacher(teacher)
+			}
+
 			// Setup dynamic augmentor if needed
 			if augProb > 0 {
 				cmd.Printf("Using dynamic augmentation (Prob: %.2f, MaxNoise: %.2f, MaxShift: %dms, MaxGain: %.2f)\n", augProb, maxNoise, maxShift, maxGain)
@@ -170,15 +322,74 @@ Performance options:
 					MaxNoiseRatio: maxNoise,
 					MaxShiftMs:    maxShift,
 					MaxGainScale:  maxGain,
+					SampleRate:    featCfg.SampleRate,
 				}, noisePool)
 				t.SetAugmentor(aug)
 			}
 
+			// Setup feature-domain augmentation if needed
+			specCfg := train.SpecAugmentConfig{
+				Prob:          float32(viper.GetFloat64("train.spec_augment.prob")),
+				TimeMasks:     viper.GetInt("train.spec_augment.time_masks"),
+				TimeMaskWidth: viper.GetInt("train.spec_augment.time_mask_width"),
+				FreqMasks:     viper.GetInt("train.spec_augment.freq_masks"),
+				FreqMaskWidth: viper.GetInt("train.spec_augment.freq_mask_width"),
+				TimeWarp:      viper.GetInt("train.spec_augment.time_warp"),
+			}
+			if specCfg.Enabled() {
+				cmd.Printf("Using SpecAugment (Prob: %.2f, TimeMasks: %dx%d, FreqMasks: %dx%d, TimeWarp: %d)\n",
+					specCfg.Prob, specCfg.TimeMasks, specCfg.TimeMaskWidth, specCfg.FreqMasks, specCfg.FreqMaskWidth, specCfg.TimeWarp)
+				t.SetSpecAugmenter(train.NewSpecAugmenter(specCfg))
+			}
+
+			if cache != nil {
+				t.SetFeatureCache(cache)
+			}
+			pipelineCfg := train.PipelineConfig{
+				Workers:  viper.GetInt("train.workers"),
+				Prefetch: viper.GetInt("train.prefetch"),
+				Seed:     seed,
+			}
+			if maxLen > 0 && stride == 0 && !onset {
+				// Padded samples: recurrent and pooling layers skip the frames of the padding
+				pipelineCfg.FrameMask = featCfg.FrameMask
+			}
+			t.SetPipelineConfig(pipelineCfg)
+
 			cmd.Printf("Starting training for %d epochs (LR: %f)...\n", epochs, lr)
 			t.Train(ds, epochs, extractor)
 
+			if cache != nil {
+				hits, misses := cache.Stats()
+				cmd.Printf("Feature cache: %d hits, %d misses\n", hits, misses)
+				if err := cache.Save(); err != nil {
+					return err
+				}
+			}
+
+			// Merge batch normalization into the preceding convolutions for faster inference
+			if viper.GetBool("train.fold_batchnorm") {
+				var folded int
+				m, folded = model.FoldBatchNorm(m)
+				cmd.Printf("Folded %d batch normalization layer(s) into convolutions\n", folded)
+			}
+
+			// Store pruned layers sparse for smaller files and faster inference
+			if pruneCfg.Enabled() {
+				for i, l := range m.GetLayers() {
+					if s := model.Sparsity(l); s > 0 {
+						cmd.Printf("Layer %d (%s): %.1f%% sparsity\n", i, l.Type(), s*100)
+					}
+				}
+				var sparse int
+				m, sparse = model.Sparsify(m, 0.5)
+				cmd.Printf("Converted %d pruned layer(s) to sparse storage\n", sparse)
+			}
+
 			cmd.Printf("Saving model to %s...\n", out)
-			if err := model.SaveModel(out, m); err != nil {
+			meta := model.Metadata{}
+			meta.SetClasses(ds.Classes)
+			if err := features.SaveModelWithMetadata(out, m, featCfg, meta); err != nil {
 				return fmt.Errorf("failed to save model: %w", err)
 			}
 
@@ -193,12 +404,34 @@ Performance options:
 	cmd.Flags().Float32Var(&trainLR, "lr", 0.01, "Learning rate")
 	cmd.Flags().IntVar(&trainStride, "stride", 0, "Window stride in samples for overlapping extraction (e.g., 8000 for 50% overlap)")
 	cmd.Flags().IntVar(&trainMaxLen, "max-len", 0, "Max audio length in samples for padding mode (e.g., 32000 for 2 seconds)")
+	cmd.Flags().StringSliceVar(&trainClasses, "classes", nil, "Train a multi-class model on these classes, read from subdirectories of the data directory (e.g. yes,no,unknown,silence)")
 	cmd.Flags().BoolVar(&trainOnset, "onset", false, "Use onset detection to crop hotword files from where audio activity starts")
 	cmd.Flags().Float32Var(&trainAugProb, "augment-prob", 0, "Probability of applying random augmentations to hotword samples")
 	cmd.Flags().Float32Var(&trainMaxNoise, "max-noise", 0.2, "Maximum noise ratio to mix into samples")
 	cmd.Flags().IntVar(&trainMaxShift, "max-shift", 100, "Maximum random time shift in milliseconds")
 	cmd.Flags().Float32Var(&trainMaxGain, "max-gain", 0.1, "Maximum random gain/volume scaling")
 	cmd.Flags().IntVar(&trainThreads, "threads", 0, "Number of CPU threads for parallel training (0 = use all cores)")
+	cmd.Flags().StringVar(&trainNoiseSuppression, "noise-suppression", "", "Noise suppression method applied before feature extraction (spectral, wiener)")
+	cmd.Flags().BoolVar(&trainCMVN, "cmvn", true, "Normalize features with mean/variance statistics computed over the training set")
+
+	cmd.Flags().IntVar(&trainWorkers, "workers", 0, "Number of data pipeline workers (0 = use all cores)")
+	cmd.Flags().IntVar(&trainPrefetch, "prefetch", 0, "Number of examples prepared ahead of the trainer (0 = 2 per worker)")
+	cmd.Flags().Int64Var(&trainSeed, "seed", 0, "Random seed for reproducible training (0 = random)")
+	cmd.Flags().BoolVar(&trainFoldBatchNorm, "fold-batchnorm", false, "Fold batchnorm layers into the preceding conv2d, depthwise, pointwise or conv1d layers before saving")
+	cmd.Flags().BoolVar(&trainCausal, "causal", false, "Build convolutions without time padding so the model can run frame by frame (listen --frame-streaming)")
+	cmd.Flags().Float32Var(&trainPrune, "prune", 0, "Target sparsity of dense and gru layers for magnitude pruning (0 = off)")
+	cmd.Flags().IntVar(&trainPruneStart, "prune-start", 1, "First epoch of magnitude pruning")
+	cmd.Flags().IntVar(&trainPruneEnd, "prune-end", 0, "Epoch reaching the target sparsity (0 = last epoch)")
+	cmd.Flags().StringVar(&trainTeacher, "teacher", "", "Trained teacher model for knowledge distillation")
+	cmd.Flags().Float32Var(&trainDistillAlpha, "distill-alpha", 0.5, "Weight of the teacher loss in knowledge distillation (0 to 1)")
+	cmd.Flags().Float32Var(&trainDistillTemperature, "distill-temperature", 2, "Temperature softening the outputs in knowledge distillation")
+	cmd.Flags().StringVar(&trainFeatureCache, "feature-cache", "memory", "Feature cache for unaugmented samples: memory, off, or a cache file path")
+	cmd.Flags().Float32Var(&trainSpecProb, "spec-augment-prob", 0, "Probability of applying SpecAugment to the features of a sample")
+	cmd.Flags().IntVar(&trainTimeMasks, "time-masks", 2, "Number of SpecAugment time masks")
+	cmd.Flags().IntVar(&trainTimeMaskWidth, "time-mask-width", 5, "Maximum SpecAugment time mask width in frames")
+	cmd.Flags().IntVar(&trainFreqMasks, "freq-masks", 2, "Number of SpecAugment frequency masks")
+	cmd.Flags().IntVar(&trainFreqMaskWidth, "freq-mask-width", 5, "Maximum SpecAugment frequency mask width in bins")
+	cmd.Flags().IntVar(&trainTimeWarp, "time-warp", 0, "Maximum SpecAugment time warp in frames (0 = off)")
 
 	viper.BindPFlag("train.data", cmd.Flags().Lookup("data"))
 	viper.BindPFlag("train.out", cmd.Flags().Lookup("out"))
@@ -207,11 +440,32 @@ Performance options:
 	viper.BindPFlag("train.stride", cmd.Flags().Lookup("stride"))
 	viper.BindPFlag("train.max_len", cmd.Flags().Lookup("max-len"))
 	viper.BindPFlag("train.onset", cmd.Flags().Lookup("onset"))
+	viper.BindPFlag("train.classes", cmd.Flags().Lookup("classes"))
 	viper.BindPFlag("train.augment_prob", cmd.Flags().Lookup("augment-prob"))
 	viper.BindPFlag("train.max_noise", cmd.Flags().Lookup("max-noise"))
 	viper.BindPFlag("train.max_shift", cmd.Flags().Lookup("max-shift"))
 	viper.BindPFlag("train.max_gain", cmd.Flags().Lookup("max-gain"))
 	viper.BindPFlag("train.threads", cmd.Flags().Lookup("threads"))
+	viper.BindPFlag("train.noise_suppression", cmd.Flags().Lookup("noise-suppression"))
+	viper.BindPFlag("train.cmvn", cmd.Flags().Lookup("cmvn"))
+	viper.BindPFlag("train.workers", cmd.Flags().Lookup("workers"))
+	viper.BindPFlag("train.prefetch", cmd.Flags().Lookup("prefetch"))
+	viper.BindPFlag("train.seed", cmd.Flags().Lookup("seed"))
+	viper.BindPFlag("train.fold_batchnorm", cmd.Flags().Lookup("fold-batchnorm"))
+	viper.BindPFlag("train.causal", cmd.Flags().Lookup("causal"))
+	viper.BindPFlag("train.prune.sparsity", cmd.Flags().Lookup("prune"))
+	viper.BindPFlag("train.prune.start_epoch", cmd.Flags().Lookup("prune-start"))
+	viper.BindPFlag("train.prune.end_epoch", cmd.Flags().Lookup("prune-end"))
+	viper.BindPFlag("train.teacher", cmd.Flags().Lookup("teacher"))
+	viper.BindPFlag("train.distill.alpha", cmd.Flags().Lookup("distill-alpha"))
+	viper.BindPFlag("train.distill.temperature", cmd.Flags().Lookup("distill-temperature"))
+	viper.BindPFlag("train.feature_cache", cmd.Flags().Lookup("feature-cache"))
+	viper.BindPFlag("train.spec_augment.prob", cmd.Flags().Lookup("spec-augment-prob"))
+	viper.BindPFlag("train.spec_augment.time_masks", cmd.Flags().Lookup("time-masks"))
+	viper.BindPFlag("train.spec_augment.time_mask_width", cmd.Flags().Lookup("time-mask-width"))
+	viper.BindPFlag("train.spec_augment.freq_masks", cmd.Flags().Lookup("freq-masks"))
+	viper.BindPFlag("train.spec_augment.freq_mask_width", cmd.Flags().Lookup("freq-mask-width"))
+	viper.BindPFlag("train.spec_augment.time_warp", cmd.Flags().Lookup("time-warp"))
 
 	return cmd
 }
@@ -221,3 +475,94 @@ var trainCmd = NewTrainCmd()
 func init() {
 	rootCmd.AddCommand(trainCmd)
 }
+
+// featureConfigFromViper reads the feature frontend from the "features" config section,
+// starting from the default frontend, and applies the --noise-suppression flag.
+func featureConfigFromViper() (features.Config, error) {
+	cfg := features.DefaultConfig()
+	if method := viper.GetString("features.noise.method"); method != "" {
+		cfg.Noise = features.DefaultNoiseConfig(method)
+	}
+	if err := viper.UnmarshalKey("features", &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse feature configuration: %w", err)
+	}
+	if method := viper.GetString("train.noise_suppression"); method != "" {
+		cfg.Noise = features.NoiseConfig{}
+		if method != "none" {
+			cfg.Noise = features.DefaultNoiseConfig(method)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid feature configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadTeacher loads a teacher model for knowledge distillation and checks that
+// it predicts the classes of the dataset with one output per model output.
+func loadTeacher(path string, cfg train.DistillConfig, ds *train.Dataset, sampleRate, outputs int) (*train.Teacher, error) {
+	m, teacherCfg, meta, err := features.LoadModelWithMetadata(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load teacher model: %w", err)
+	}
+	if teacherCfg.SampleRate != sampleRate {
+		return nil, fmt.Errorf("the teacher was trained on %d Hz audio, the dataset is %d Hz", teacherCfg.SampleRate, sampleRate)
+	}
+	if classes := strings.Join(meta.Classes(), ","); classes != strings.Join(ds.Classes, ",") {
+		return nil, fmt.Errorf("the teacher classes %q do not match the training classes %q", classes, strings.Join(ds.Classes, ","))
+	}
+
+	teacher, err := train.NewTeacher(m, func(samples []float32) *model.Tensor {
+		return features.ExtractWithConfig(samples, teacherCfg)
+	}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(teacher.Outputs(ds.Samples[0].Audio)); n != outputs {
+		return nil, fmt.Errorf("the teacher has %d outputs but the model has %d", n, outputs)
+	}
+	return teacher, nil
+}
+
+// loadTrainingDataset loads the hotword and background samples from dataDir using
+// the loading mode selected by the stride, max-len and onset options, or the
+// samples of each class for a multi-class model.
+func loadTrainingDataset(cmd *cobra.Command, dataDir string, sampleRate, stride, maxLen int, onset bool, classes []string) (*train.Dataset, error) {
+	windowLen := sampleRate // 1 second
+
+	cmd.Printf("Loading dataset from %s...\n", dataDir)
+	if len(classes) > 0 {
+		if onset {
+			return nil, fmt.Errorf("onset detection is not supported for multi-class datasets")
+		}
+		if maxLen > 0 && stride == 0 {
+			windowLen = maxLen
+		}
+		cmd.Printf("Using %d classes: %s\n", len(classes), strings.Join(classes, ", "))
+		return train.LoadClassDataset(dataDir, classes, windowLen, stride, sampleRate)
+	}
+
+	hotwordDir := filepath.Join(dataDir, "hotword")
+	backgroundDir := filepath.Join(dataDir, "background")
+
+	// Choose loading mode based on flags
+	if onset && stride > 0 {
+		// Combined: Onset detection + Window/Stride extraction
+		cmd.Printf("Using onset detection + windowed loading (stride=%d samples, %.2fs)\n", stride, float64(stride)/float64(sampleRate))
+		return train.LoadDatasetWithOnsetAndStride(hotwordDir, backgroundDir, windowLen, stride, sampleRate, 0.1)
+	} else if onset {
+		// Option 2: Onset detection mode only
+		cmd.Printf("Using onset detection (threshold=0.1)\n")
+		return train.LoadDatasetWithOnset(hotwordDir, backgroundDir, windowLen, sampleRate, 0.1)
+	} else if stride > 0 {
+		// Option 1: Window/Stride extraction mode
+		cmd.Printf("Using windowed loading (stride=%d samples, %.2fs)\n", stride, float64(stride)/float64(sampleRate))
+		return train.LoadDatasetWindowed(hotwordDir, backgroundDir, windowLen, stride, sampleRate)
+	} else if maxLen > 0 {
+		// Option 3: Variable length with padding mode
+		cmd.Printf("Using padded loading (max_len=%d samples, %.2fs)\n", maxLen, float64(maxLen)/float64(sampleRate))
+		return train.LoadDatasetWithPadding(hotwordDir, backgroundDir, maxLen, sampleRate)
+	}
+	// Legacy mode: first 1 second only
+	return train.LoadDataset(hotwordDir, backgroundDir, sampleRate)
+}
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/tomkiv/hotword/pkg/features"
 )
 
 func TestTrainIntegration(t *testing.T) {
@@ -37,6 +39,15 @@ func TestTrainIntegration(t *testing.T) {
 
 	// 3. Verify model file exists
 	if _, err := os.Stat(modelOut); os.IsNotExist(err) {
-		t.Error("Expected model file to be created, but it doesn't exist")
+		t.Fatal("Expected model file to be created, but it doesn't exist")
+	}
+
+	// 4. Verify the frontend and its normalization statistics were stored
+	_, cfg, err := features.LoadModel(modelOut)
+	if err != nil {
+		t.Fatalf("Failed to load trained model: %v", err)
+	}
+	if !cfg.CMVN.Enabled() {
+		t.Error("Expected normalization statistics to be stored in the model")
 	}
 }
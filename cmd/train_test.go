@@ -1,8 +1,14 @@
 package cmd
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/tomkiv/hotword/pkg/features"
 )
 
 func TestTrainCommand(t *testing.T) {
@@ -28,7 +34,7 @@ func TestTrainExecution(t *testing.T) {
 	train := NewTrainCmd()
 	root.AddCommand(train)
 
-	output, err := executeCommand(root, "train", "--data", tmpDir, "--epochs", "1", "--lr", "0.05")
+	output, err := executeCommand(root, "train", "--data", tmpDir, "--out", filepath.Join(tmpDir, "model.bin"), "--epochs", "1", "--lr", "0.05")
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -40,3 +46,177 @@ func TestTrainExecution(t *testing.T) {
 		t.Errorf("Expected epochs 1, got: %s", output)
 	}
 }
+
+func TestTrainSpecAugment(t *testing.T) {
+	tmpDir, cleanup := createDummyData(t)
+	defer cleanup()
+
+	root := NewRootCmd()
+	train := NewTrainCmd()
+	root.AddCommand(train)
+
+	output, err := executeCommand(root, "train", "--data", tmpDir, "--out", filepath.Join(tmpDir, "model.bin"), "--epochs", "1", "--threads", "2",
+		"--spec-augment-prob", "1", "--time-masks", "1", "--freq-masks", "1", "--time-warp", "2")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(output, "Using SpecAugment (Prob: 1.00, TimeMasks: 1x5, FreqMasks: 1x5, TimeWarp: 2)") {
+		t.Errorf("Expected SpecAugment message, got: %s", output)
+	}
+}
+
+func TestTrainSeedReproducible(t *testing.T) {
+	tmpDir, cleanup := createDummyData(t)
+	defer cleanup()
+
+	var models [][]byte
+	for i := 0; i < 2; i++ {
+		out := filepath.Join(tmpDir, fmt.Sprintf("model%d.bin", i))
+		root := NewRootCmd()
+		root.AddCommand(NewTrainCmd())
+		output, err := executeCommand(root, "train", "--data", tmpDir, "--out", out, "--epochs", "2",
+			"--threads", "2", "--workers", "3", "--seed", "42", "--augment-prob", "0.5")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !strings.Contains(output, "Random seed: 42") {
+			t.Errorf("Expected seed message, got: %s", output)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		models = append(models, data)
+	}
+	if !bytes.Equal(models[0], models[1]) {
+		t.Error("Expected identical models for the same seed")
+	}
+}
+
+func TestTrainCausal(t *testing.T) {
+	tmpDir, cleanup := createDummyData(t)
+	defer cleanup()
+
+	root := NewRootCmd()
+	root.AddCommand(NewTrainCmd())
+
+	// The default dense model has no recurrent layer to stream
+	output, err := executeCommand(root, "train", "--data", tmpDir, "--out", filepath.Join(tmpDir, "model.bin"), "--epochs", "1", "--causal")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "cannot be used for frame streaming") {
+		t.Errorf("Expected frame streaming warning, got: %s", output)
+	}
+}
+
+func TestTrainClasses(t *testing.T) {
+	tmpDir, cleanup := createClassData(t, "yes", "no", "unknown")
+	defer cleanup()
+
+	root := NewRootCmd()
+	root.AddCommand(NewTrainCmd())
+	out := filepath.Join(tmpDir, "model.bin")
+	output, err := executeCommand(root, "train", "--data", tmpDir, "--out", out, "--epochs", "1",
+		"--classes", "yes,no,unknown,silence")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "Using 4 classes: yes, no, unknown, silence") {
+		t.Errorf("Expected classes message, got: %s", output)
+	}
+
+	m, _, meta, err := features.LoadModelWithMetadata(out)
+	if err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+	if classes := meta.Classes(); strings.Join(classes, ",") != "yes,no,unknown,silence" {
+		t.Errorf("Expected the classes in the model metadata, got %v", classes)
+	}
+	if layers := m.GetLayers(); layers[len(layers)-1].Type() != "softmax" {
+		t.Errorf("Expected a softmax output, got %s", layers[len(layers)-1].Type())
+	}
+
+	root = NewRootCmd()
+	root.AddCommand(NewTrainCmd())
+	if _, err := executeCommand(root, "train", "--data", tmpDir, "--out", out, "--epochs", "1",
+		"--classes", "yes,no", "--onset"); err == nil {
+		t.Error("Expected an error for onset detection with classes")
+	}
+}
+
+func TestTrainPrune(t *testing.T) {
+	tmpDir, cleanup := createDummyData(t)
+	defer cleanup()
+
+	root := NewRootCmd()
+	root.AddCommand(NewTrainCmd())
+	out := filepath.Join(tmpDir, "model.bin")
+	output, err := executeCommand(root, "train", "--data", tmpDir, "--out", out, "--epochs", "2", "--prune", "0.8")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{"Using magnitude pruning", "Layer 0 (dense): 80.0% sparsity",
+		"Converted 1 pruned layer(s) to sparse storage"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output, got: %s", expected, output)
+		}
+	}
+
+	m, _, err := features.LoadModel(out)
+	if err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+	if m.GetLayers()[0].Type() != "dense_sparse" {
+		t.Errorf("Expected a dense_sparse layer, got %s", m.GetLayers()[0].Type())
+	}
+
+	root = NewRootCmd()
+	root.AddCommand(NewTrainCmd())
+	if _, err := executeCommand(root, "train", "--data", tmpDir, "--out", out, "--epochs", "2", "--prune", "0.8",
+		"--prune-end", "3"); err == nil {
+		t.Error("Expected an error for a pruning schedule past the last epoch")
+	}
+}
+
+func TestTrainDistill(t *testing.T) {
+	tmpDir, cleanup := createDummyData(t)
+	defer cleanup()
+
+	root := NewRootCmd()
+	root.AddCommand(NewTrainCmd())
+	teacher := filepath.Join(tmpDir, "teacher.bin")
+	if _, err := executeCommand(root, "train", "--data", tmpDir, "--out", teacher, "--epochs", "1"); err != nil {
+		t.Fatalf("Failed to train the teacher: %v", err)
+	}
+
+	root = NewRootCmd()
+	root.AddCommand(NewTrainCmd())
+	out := filepath.Join(tmpDir, "student.bin")
+	output, err := executeCommand(root, "train", "--data", tmpDir, "--out", out, "--epochs", "1",
+		"--teacher", teacher, "--distill-alpha", "0.7", "--distill-temperature", "3")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "Distilling from teacher "+teacher+" (Alpha: 0.70, Temperature: 3.0)") {
+		t.Errorf("Expected distillation message, got: %s", output)
+	}
+	if _, _, err := features.LoadModel(out); err != nil {
+		t.Errorf("Failed to load the student model: %v", err)
+	}
+
+	root = NewRootCmd()
+	root.AddCommand(NewTrainCmd())
+	if _, err := executeCommand(root, "train", "--data", tmpDir, "--out", out, "--epochs", "1",
+		"--teacher", teacher, "--distill-alpha", "2"); err == nil || !strings.Contains(err.Error(), "alpha") {
+		t.Errorf("Expected an error for an invalid alpha, got %v", err)
+	}
+
+	root = NewRootCmd()
+	root.AddCommand(NewTrainCmd())
+	if _, err := executeCommand(root, "train", "--data", tmpDir, "--out", out, "--epochs", "1",
+		"--teacher", filepath.Join(tmpDir, "missing.bin")); err == nil {
+		t.Error("Expected an error for a missing teacher model")
+	}
+}
@@ -2,49 +2,71 @@ package cmd
 
 import (
 	"fmt"
+	"math"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/tomkiv/hotword/pkg/engine"
-	"github.com/tomkiv/hotword/pkg/model"
+	"github.com/tomkiv/hotword/pkg/features"
 	"github.com/tomkiv/hotword/pkg/train"
 )
 
 var verifyModelFile string
 var verifyDataDir string
 var verifyOnset bool
+var verifyFloatModel string
 
 // NewVerifyCmd creates a new verify command
 func NewVerifyCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "verify",
 		Short: "Verify a trained hotword model",
-		Long:  `Verify a trained hotword model against a labeled dataset of WAV samples.`,
+		Long: `Verify a trained hotword model against a labeled dataset of WAV samples.
+
+With --float-model, the float32 model a quantized model was converted from is
+verified on the same samples and the accuracy delta is reported.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			modelFile := viper.GetString("verify.model")
 			dataDir := viper.GetString("verify.data")
 			onset := viper.GetBool("verify.onset")
+			floatModel := viper.GetString("verify.float_model")
 			threshold := float32(0.5) // Default threshold
-			sampleRate := 16000
-			targetLen := 16000 // 1 second
 
 			cmd.Printf("Loading model from %s...\n", modelFile)
-			m, err := model.LoadModel(modelFile)
+			m, featCfg, meta, err := features.LoadModelWithMetadata(modelFile)
 			if err != nil {
 				return fmt.Errorf("failed to load model: %w", err)
 			}
+			cmd.Printf("Feature frontend: %s\n", featCfg)
+			sampleRate := featCfg.SampleRate
+			targetLen := featCfg.SampleRate // 1 second
+			agc, err := newAGCFromConfig("verify", sampleRate)
+			if err != nil {
+				return err
+			}
 
 			cmd.Printf("Loading verification dataset from %s...\n", dataDir)
 			hotwordDir := filepath.Join(dataDir, "hotword")
 			backgroundDir := filepath.Join(dataDir, "background")
 
+			// Multi-class models are verified on a directory per class
+			classes := meta.Classes()
+
 			var ds *train.Dataset
-			if onset {
+			if len(classes) > 0 {
+				if onset {
+					return fmt.Errorf("onset detection is not supported for multi-class models")
+				}
+				cmd.Printf("Classes: %s\n", strings.Join(classes, ", "))
+				ds, err = train.LoadClassDataset(dataDir, classes, targetLen, 0, sampleRate)
+			} else if onset {
 				cmd.Println("Using onset detection...")
 				ds, err = train.LoadDatasetWithOnset(hotwordDir, backgroundDir, targetLen, sampleRate, 0.1)
 			} else {
-				ds, err = train.LoadDataset(hotwordDir, backgroundDir)
+				ds, err = train.LoadDataset(hotwordDir, backgroundDir, sampleRate)
 			}
 			if err != nil {
 				return fmt.Errorf("failed to load dataset: %w", err)
@@ -54,10 +76,28 @@ func NewVerifyCmd() *cobra.Command {
 				return fmt.Errorf("no samples found in dataset")
 			}
 
-			e := engine.NewEngine(m, 16000)
+			e := engine.NewEngineWithConfig(m, featCfg)
+			if agc != nil {
+				e.SetAGC(agc)
+			}
+			if len(classes) > 0 {
+				if err := e.SetClasses(classes); err != nil {
+					return err
+				}
+				correct, err := verifyClasses(cmd, e, ds)
+				if err != nil {
+					return err
+				}
+				if floatModel != "" {
+					return compareFloatModel(cmd, floatModel, ds, correct, threshold)
+				}
+				return nil
+			}
 
 			var tp, tn, fp, fn int
 			var failedSamples []string
+			var gainSum float64
+			minGain, maxGain := float32(math.MaxFloat32), float32(0)
 
 			cmd.Printf("Verifying %d samples...\n", len(ds.Samples))
 			for i, sample := range ds.Samples {
@@ -65,6 +105,15 @@ func NewVerifyCmd() *cobra.Command {
 				prob := e.ProcessSingle(sample.Audio)
 				detected := prob >= threshold
 
+				gain := e.Gain()
+				gainSum += float64(gain)
+				if gain < minGain {
+					minGain = gain
+				}
+				if gain > maxGain {
+					maxGain = gain
+				}
+
 				if sample.IsHotword {
 					if detected {
 						tp++
@@ -91,6 +140,9 @@ func NewVerifyCmd() *cobra.Command {
 			cmd.Printf("Confusion Matrix:\n")
 			cmd.Printf("  TP: %d | FN: %d\n", tp, fn)
 			cmd.Printf("  FP: %d | TN: %d\n", fp, tn)
+			if agc != nil {
+				cmd.Printf("AGC Gain: mean %.2f, min %.2f, max %.2f\n", gainSum/float64(total), minGain, maxGain)
+			}
 
 			if len(failedSamples) > 0 {
 				cmd.Printf("\nFailed Samples:\n")
@@ -99,21 +151,173 @@ func NewVerifyCmd() *cobra.Command {
 				}
 			}
 
+			if floatModel != "" {
+				return compareFloatModel(cmd, floatModel, ds, tp+tn, threshold)
+			}
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&verifyModelFile, "model", "model.bin", "Path to the trained model binary")
-	cmd.Flags().StringVar(&verifyDataDir, "data", "data", "Directory containing 'hotword' and 'background' subdirectories")
+	cmd.Flags().StringVar(&verifyDataDir, "data", "data", "Directory containing 'hotword' and 'background' subdirectories (one subdirectory per class for multi-class models)")
 	cmd.Flags().BoolVar(&verifyOnset, "onset", false, "Use onset detection (match training preprocessing)")
+	cmd.Flags().StringVar(&verifyFloatModel, "float-model", "", "Float32 model to compare the accuracy of a quantized model with")
 
 	viper.BindPFlag("verify.model", cmd.Flags().Lookup("model"))
 	viper.BindPFlag("verify.data", cmd.Flags().Lookup("data"))
 	viper.BindPFlag("verify.onset", cmd.Flags().Lookup("onset"))
+	viper.BindPFlag("verify.float_model", cmd.Flags().Lookup("float-model"))
+	addAGCFlags(cmd, "verify", true)
 
 	return cmd
 }
 
+// verifyClasses evaluates a multi-class model: every sample is assigned its most
+// probable class, and the results are reported as a confusion matrix (rows are
+// the true classes, columns the predicted ones) with per-class precision and
+// recall. Samples too short for a model input count as misclassified. It
+// returns the number of correctly classified samples, or an error if the model
+// outputs do not match the classes.
+func verifyClasses(cmd *cobra.Command, e *engine.Engine, ds *train.Dataset) (int, error) {
+	n := len(ds.Classes)
+	confusion := make([][]int, n)
+	for i := range confusion {
+		confusion[i] = make([]int, n)
+	}
+	var failedSamples []string
+
+	cmd.Printf("Verifying %d samples...\n", len(ds.Samples))
+	for i, sample := range ds.Samples {
+		probs := e.ProcessSingleOutput(sample.Audio)
+		if probs == nil {
+			failedSamples = append(failedSamples, fmt.Sprintf("Sample %d (%s) too short to classify", i, ds.Classes[sample.Label]))
+			continue
+		}
+		predicted, err := predictClass(probs, n)
+		if err != nil {
+			return 0, err
+		}
+		confusion[sample.Label][predicted]++
+		if predicted != sample.Label {
+			failedSamples = append(failedSamples, fmt.Sprintf("Sample %d (%s) classified as %s (%.2f)",
+				i, ds.Classes[sample.Label], ds.Classes[predicted], probs[predicted]))
+		}
+	}
+
+	correct := 0
+	width := 9
+	for i, class := range ds.Classes {
+		correct += confusion[i][i]
+		if len(class) > width {
+			width = len(class)
+		}
+	}
+	total := len(ds.Samples)
+
+	cmd.Printf("\nVerification Results:\n")
+	cmd.Printf("--------------------\n")
+	cmd.Printf("Accuracy: %.2f%% (%d/%d)\n", float32(correct)/float32(total)*100, correct, total)
+	cmd.Printf("Confusion Matrix (rows: actual, columns: predicted):\n")
+	cmd.Printf("  %-*s", width, "")
+	for _, class := range ds.Classes {
+		cmd.Printf(" %*s", width, class)
+	}
+	cmd.Printf(" %9s %9s\n", "Precision", "Recall")
+	for i, class := range ds.Classes {
+		cmd.Printf("  %-*s", width, class)
+		var actual, predicted int
+		for j := range ds.Classes {
+			cmd.Printf(" %*d", width, confusion[i][j])
+			actual += confusion[i][j]
+			predicted += confusion[j][i]
+		}
+		cmd.Printf(" %8.2f%% %8.2f%%\n", percent(confusion[i][i], predicted), percent(confusion[i][i], actual))
+	}
+
+	if len(failedSamples) > 0 {
+		cmd.Printf("\nFailed Samples:\n")
+		for _, msg := range failedSamples {
+			cmd.Printf("  - %s\n", msg)
+		}
+	}
+	return correct, nil
+}
+
+// predictClass returns the most probable of n classes, or an error if there is
+// not one probability per class.
+func predictClass(probs []float32, n int) (int, error) {
+	if len(probs) != n {
+		return 0, fmt.Errorf("the model has %d outputs but there are %d classes", len(probs), n)
+	}
+	predicted := 0
+	for c := range probs {
+		if probs[c] > probs[predicted] {
+			predicted = c
+		}
+	}
+	return predicted, nil
+}
+
+// compareFloatModel verifies the float32 model at path on the samples of ds and
+// reports its accuracy next to correct, the number of samples the verified
+// (quantized) model got right. The float model must have the classes of ds.
+func compareFloatModel(cmd *cobra.Command, path string, ds *train.Dataset, correct int, threshold float32) error {
+	m, featCfg, meta, err := features.LoadModelWithMetadata(path)
+	if err != nil {
+		return fmt.Errorf("failed to load float model: %w", err)
+	}
+	if classes := meta.Classes(); !slices.Equal(classes, ds.Classes) {
+		return fmt.Errorf("the float model classes [%s] do not match the verified model classes [%s]",
+			strings.Join(classes, ", "), strings.Join(ds.Classes, ", "))
+	}
+	e := engine.NewEngineWithConfig(m, featCfg)
+	agc, err := newAGCFromConfig("verify", featCfg.SampleRate)
+	if err != nil {
+		return err
+	}
+	if agc != nil {
+		e.SetAGC(agc)
+	}
+	if len(ds.Classes) > 0 {
+		if err := e.SetClasses(ds.Classes); err != nil {
+			return err
+		}
+	}
+
+	floatCorrect := 0
+	for _, sample := range ds.Samples {
+		if len(ds.Classes) > 0 {
+			probs := e.ProcessSingleOutput(sample.Audio)
+			if probs == nil {
+				continue
+			}
+			predicted, err := predictClass(probs, len(ds.Classes))
+			if err != nil {
+				return fmt.Errorf("float model: %w", err)
+			}
+			if predicted == sample.Label {
+				floatCorrect++
+			}
+		} else if (e.ProcessSingle(sample.Audio) >= threshold) == sample.IsHotword {
+			floatCorrect++
+		}
+	}
+
+	total := len(ds.Samples)
+	cmd.Printf("\nFloat Model Comparison (%s):\n", path)
+	cmd.Printf("  Float accuracy: %.2f%% (%d/%d)\n", percent(floatCorrect, total), floatCorrect, total)
+	cmd.Printf("  Accuracy delta: %+.2f%%\n", percent(correct, total)-percent(floatCorrect, total))
+	return nil
+}
+
+// percent returns part/whole as a percentage, or 0 for an empty whole.
+func percent(part, whole int) float32 {
+	if whole == 0 {
+		return 0
+	}
+	return float32(part) / float32(whole) * 100
+}
+
 var verifyCmd = NewVerifyCmd()
 
 func init() {
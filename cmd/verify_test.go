@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"github.com/tomkiv/hotword/pkg/features"
 	"github.com/tomkiv/hotword/pkg/model"
 )
 
@@ -51,4 +52,88 @@ func TestVerifyExecution(t *testing.T) {
 	if !strings.Contains(output, "Accuracy:") {
 		t.Errorf("Expected accuracy report, got: %s", output)
 	}
-}
\ No newline at end of file
+}
+func TestVerifyClasses(t *testing.T) {
+	tmpDir, cleanup := createClassData(t, "yes", "no", "unknown")
+	defer cleanup()
+
+	// Every clip is classified as "no"
+	modelFile := filepath.Join(tmpDir, "model.bin")
+	m := model.NewSequentialModel(
+		model.NewDenseLayer(model.NewTensor([]int{3, 2440}), []float32{0, 2, 0}),
+		model.NewSoftmaxLayer(),
+	)
+	meta := model.Metadata{}
+	meta.SetClasses([]string{"yes", "no", "unknown"})
+	if err := features.SaveModelWithMetadata(modelFile, m, features.DefaultConfig(), meta); err != nil {
+		t.Fatal(err)
+	}
+
+	root := NewRootCmd()
+	root.AddCommand(NewVerifyCmd())
+	output, err := executeCommand(root, "verify", "--model", modelFile, "--data", tmpDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"Classes: yes, no, unknown",
+		"Accuracy: 33.33% (1/3)",
+		"Confusion Matrix (rows: actual, columns: predicted)",
+		"Sample 0 (yes) classified as no",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in output, got: %s", expected, output)
+		}
+	}
+}
+
+func TestVerifyClassMismatch(t *testing.T) {
+	tmpDir, cleanup := createClassData(t, "yes", "no", "unknown")
+	defer cleanup()
+
+	save := func(name string, outputs int, classes []string) string {
+		path := filepath.Join(tmpDir, name)
+		m := model.NewSequentialModel(
+			model.NewDenseLayer(model.NewTensor([]int{outputs, 2440}), make([]float32, outputs)),
+			model.NewSoftmaxLayer(),
+		)
+		meta := model.Metadata{}
+		meta.SetClasses(classes)
+		if err := features.SaveModelWithMetadata(path, m, features.DefaultConfig(), meta); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	modelFile := save("model.bin", 3, []string{"yes", "no", "unknown"})
+
+	for _, tc := range []struct {
+		name    string
+		args    []string
+		errText string
+	}{
+		// One output more than classes used to be counted as the last class
+		{"Outputs", []string{"--model", save("wide.bin", 4, []string{"yes", "no", "unknown"})}, "4 outputs but there are 3 classes"},
+		{"Float Classes", []string{"--model", modelFile, "--float-model", save("float.bin", 3, []string{"yes", "no", "maybe"})}, "do not match"},
+		{"Float Binary", []string{"--model", modelFile, "--float-model", save("binary.bin", 1, nil)}, "do not match"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := NewRootCmd()
+			root.AddCommand(NewVerifyCmd())
+			_, err := executeCommand(root, append([]string{"verify", "--data", tmpDir}, tc.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tc.errText) {
+				t.Errorf("Expected error containing %q, got %v", tc.errText, err)
+			}
+		})
+	}
+}
+
+func TestPredictClass(t *testing.T) {
+	if c, err := predictClass([]float32{0.2, 0.5, 0.3}, 3); err != nil || c != 1 {
+		t.Errorf("Expected class 1, got %d (%v)", c, err)
+	}
+	for _, probs := range [][]float32{nil, {0.1, 0.2, 0.3, 0.4}} {
+		if _, err := predictClass(probs, 3); err == nil {
+			t.Errorf("Expected an error for %d outputs and 3 classes", len(probs))
+		}
+	}
+}
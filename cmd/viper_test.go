@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -15,7 +16,7 @@ func TestViperBinding(t *testing.T) {
 	root.AddCommand(train)
 
 	// Set args to override defaults
-	root.SetArgs([]string{"train", "--data", tmpDir, "--epochs", "99", "--lr", "0.123"})
+	root.SetArgs([]string{"train", "--data", tmpDir, "--out", filepath.Join(tmpDir, "model.bin"), "--epochs", "99", "--lr", "0.123"})
 	if err := root.Execute(); err != nil {
 		t.Fatalf("Execute failed: %v", err)
 	}
@@ -0,0 +1,200 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// doubleTalkHangover is the number of samples adaptation stays frozen after
+// double-talk is detected (30ms at 16kHz).
+const doubleTalkHangover = 480
+
+// EchoCanceller removes the echo of a playback reference signal from a microphone
+// signal using a Normalized Least Mean Squares (NLMS) adaptive filter.
+// The filter models the acoustic path from the speaker to the microphone; its
+// output is an estimate of the echo which is subtracted from the microphone input.
+type EchoCanceller struct {
+	// StepSize is the NLMS adaptation rate (0 < mu < 2, typically 0.1 - 0.7).
+	StepSize float32
+	// DoubleTalkThreshold freezes adaptation while the near-end talker is active
+	// (Geigel detector). Adaptation stops when |mic| > threshold * max|ref|.
+	// A value of 0 disables double-talk detection.
+	DoubleTalkThreshold float32
+
+	weights []float32
+	history []float32 // Reference history stored twice so history[pos:pos+taps] is contiguous
+	pos     int       // Index of the newest reference sample
+	power   float64   // Running energy of the reference history
+	hold    int       // Remaining samples of double-talk hangover
+
+	mu       sync.Mutex
+	refQueue []float32
+	maxQueue int
+	delay    int // Bulk delay of the reference in samples
+}
+
+// NewEchoCanceller creates a new EchoCanceller with the given filter length (taps)
+// and step size. The filter length must cover the echo tail, e.g. 512 taps = 32ms at 16kHz.
+// It returns an error unless taps is positive and the step size is in (0, 2),
+// the range in which NLMS converges.
+func NewEchoCanceller(taps int, stepSize float32) (*EchoCanceller, error) {
+	if taps <= 0 {
+		return nil, fmt.Errorf("echo canceller filter length must be positive, got %d", taps)
+	}
+	if !(stepSize > 0 && stepSize < 2) {
+		return nil, fmt.Errorf("echo canceller step size must be in (0, 2), got %g", stepSize)
+	}
+	return &EchoCanceller{
+		StepSize:            stepSize,
+		DoubleTalkThreshold: 0,
+		weights:             make([]float32, taps),
+		history:             make([]float32, 2*taps),
+		maxQueue:            16000, // Drop reference samples older than ~1s at 16kHz
+	}, nil
+}
+
+// SetDelay delays the reference by a fixed number of samples before it reaches
+// the filter. Use it when the playback path (output buffering, loopback capture)
+// makes the echo arrive later than the filter length can cover; the taps then
+// only need to model the echo tail after the bulk delay. Apart from this delay,
+// the reference must stay time-aligned with the microphone. A negative delay
+// (an echo arriving before its reference) is an error.
+func (ec *EchoCanceller) SetDelay(samples int) error {
+	if samples < 0 {
+		return fmt.Errorf("echo canceller delay must not be negative, got %d", samples)
+	}
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	diff := samples - ec.delay
+	if diff > 0 {
+		ec.refQueue = append(make([]float32, diff), ec.refQueue...)
+	} else {
+		ec.refQueue = ec.refQueue[min(-diff, len(ec.refQueue)):]
+	}
+	ec.maxQueue += diff
+	ec.delay = samples
+	return nil
+}
+
+// PushReference queues samples of the playback reference signal.
+// It is safe to call from a different goroutine than Cancel.
+func (ec *EchoCanceller) PushReference(ref []float32) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	ec.refQueue = append(ec.refQueue, ref...)
+	// Keep the queue bounded so a stalled microphone does not accumulate drift
+	if len(ec.refQueue) > ec.maxQueue {
+		ec.refQueue = ec.refQueue[len(ec.refQueue)-ec.maxQueue:]
+	}
+}
+
+// Cancel removes the echo from the microphone samples using queued reference samples.
+// One reference sample is consumed per microphone sample; if the queue runs dry
+// the reference is assumed to be silent.
+func (ec *EchoCanceller) Cancel(mic []float32) []float32 {
+	ec.mu.Lock()
+	n := len(mic)
+	ref := make([]float32, n)
+	copied := copy(ref, ec.refQueue)
+	ec.refQueue = ec.refQueue[copied:]
+	ec.mu.Unlock()
+
+	return ec.Process(mic, ref)
+}
+
+// Process removes the echo of ref from mic. Both slices must be time-aligned and
+// of equal length. The filter state carries over between calls.
+func (ec *EchoCanceller) Process(mic, ref []float32) []float32 {
+	taps := len(ec.weights)
+	out := make([]float32, len(mic))
+	const delta = 1e-6 // Regularization to avoid division by zero during silence
+
+	for n := range mic {
+		var x float32
+		if n < len(ref) {
+			x = ref[n]
+		}
+
+		// Insert the new reference sample into the circular history buffer
+		ec.pos--
+		if ec.pos < 0 {
+			ec.pos = taps - 1
+		}
+		old := ec.history[ec.pos]
+		ec.history[ec.pos] = x
+		ec.history[ec.pos+taps] = x
+		ec.power += float64(x)*float64(x) - float64(old)*float64(old)
+		if ec.power < 0 {
+			ec.power = 0
+		}
+
+		// Echo estimate: y = w . x
+		hist := ec.history[ec.pos : ec.pos+taps]
+		var y float32
+		var maxRef float32
+		for k, h := range hist {
+			y += ec.weights[k] * h
+			if a := float32(math.Abs(float64(h))); a > maxRef {
+				maxRef = a
+			}
+		}
+
+		e := mic[n] - y
+		out[n] = e
+
+		// Freeze adaptation during double-talk so the near-end speech is not cancelled.
+		// The detector holds for a short hangover because speech crosses zero constantly.
+		if ec.DoubleTalkThreshold > 0 && float32(math.Abs(float64(mic[n]))) > ec.DoubleTalkThreshold*maxRef {
+			ec.hold = doubleTalkHangover
+		}
+		if ec.hold > 0 {
+			ec.hold--
+			continue
+		}
+
+		// NLMS update: w += mu * e * x / (x.x + delta)
+		g := ec.StepSize * e / float32(ec.power+delta)
+		for k, h := range hist {
+			ec.weights[k] += g * h
+		}
+	}
+
+	return out
+}
+
+// Reset clears the adaptive filter and any queued reference samples.
+func (ec *EchoCanceller) Reset() {
+	for i := range ec.weights {
+		ec.weights[i] = 0
+	}
+	for i := range ec.history {
+		ec.history[i] = 0
+	}
+	ec.pos = 0
+	ec.power = 0
+	ec.hold = 0
+
+	ec.mu.Lock()
+	ec.refQueue = make([]float32, ec.delay)
+	ec.mu.Unlock()
+}
+
+// Convolve convolves the signal with an impulse response and returns an output of
+// the same length as the signal. It can be used to simulate an acoustic echo path.
+func Convolve(signal, impulseResponse []float32) []float32 {
+	out := make([]float32, len(signal))
+	for n := range signal {
+		var sum float32
+		for k, h := range impulseResponse {
+			if n-k < 0 {
+				break
+			}
+			sum += h * signal[n-k]
+		}
+		out[n] = sum
+	}
+	return out
+}
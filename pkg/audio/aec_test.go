@@ -0,0 +1,188 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// createNoiseWAV builds a mono 16-bit WAV file containing pseudo-random
+// "playback" audio (noise shaped by a slow amplitude envelope).
+func createNoiseWAV(numSamples, sampleRate int) []byte {
+	rng := rand.New(rand.NewSource(1))
+	buf := new(bytes.Buffer)
+
+	buf.Write([]byte("RIFF"))
+	binary.Write(buf, binary.LittleEndian, uint32(36+numSamples*2))
+	buf.Write([]byte("WAVE"))
+	buf.Write([]byte("fmt "))
+	binary.Write(buf, binary.LittleEndian, uint32(16))
+	binary.Write(buf, binary.LittleEndian, uint16(1))
+	binary.Write(buf, binary.LittleEndian, uint16(1))
+	binary.Write(buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(buf, binary.LittleEndian, uint32(sampleRate*2))
+	binary.Write(buf, binary.LittleEndian, uint16(2))
+	binary.Write(buf, binary.LittleEndian, uint16(16))
+	buf.Write([]byte("data"))
+	binary.Write(buf, binary.LittleEndian, uint32(numSamples*2))
+	for i := 0; i < numSamples; i++ {
+		env := 0.5 + 0.5*math.Sin(2*math.Pi*float64(i)/float64(sampleRate))
+		v := (rng.Float64()*2 - 1) * 0.3 * env
+		binary.Write(buf, binary.LittleEndian, int16(v*32767))
+	}
+	return buf.Bytes()
+}
+
+func energy(samples []float32) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return sum
+}
+
+func TestEchoCanceller(t *testing.T) {
+	sampleRate := 16000
+	ref, _, err := LoadWAV(bytes.NewReader(createNoiseWAV(sampleRate*3, sampleRate)))
+	if err != nil {
+		t.Fatalf("LoadWAV failed: %v", err)
+	}
+
+	// Known echo path: direct path after 20 samples plus two reflections, with
+	// ~10dB of speaker-to-microphone loss
+	ir := make([]float32, 128)
+	ir[20] = 0.3
+	ir[55] = -0.15
+	ir[110] = 0.075
+	echo := Convolve(ref, ir)
+
+	t.Run("Echo Only", func(t *testing.T) {
+		ec := newTestCanceller(t, 256)
+		out := ec.Process(echo, ref)
+
+		// Measure Echo Return Loss Enhancement on the last second (after convergence)
+		tail := len(out) - sampleRate
+		erle := 10 * math.Log10(energy(echo[tail:])/energy(out[tail:]))
+		if erle < 20 {
+			t.Errorf("Expected ERLE >= 20dB after convergence, got %.1fdB", erle)
+		}
+	})
+
+	t.Run("Near-End Speech Preserved", func(t *testing.T) {
+		ec := newTestCanceller(t, 256)
+		ec.DoubleTalkThreshold = 0.5
+		// Converge on echo only
+		ec.Process(echo[:2*sampleRate], ref[:2*sampleRate])
+
+		// Near-end "speech": a tone that is not present in the reference
+		near := make([]float32, sampleRate)
+		for i := range near {
+			near[i] = 0.2 * float32(math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)))
+		}
+		mic := make([]float32, sampleRate)
+		for i := range mic {
+			mic[i] = echo[2*sampleRate+i] + near[i]
+		}
+		out := ec.Process(mic, ref[2*sampleRate:])
+
+		residual := make([]float32, len(out))
+		for i := range out {
+			residual[i] = out[i] - near[i]
+		}
+		// The output should be much closer to the near-end signal than the raw microphone
+		if energy(residual) > 0.1*energy(echo[2*sampleRate:]) {
+			t.Errorf("Echo not suppressed during near-end speech: residual=%.4f echo=%.4f",
+				energy(residual), energy(echo[2*sampleRate:]))
+		}
+	})
+
+	t.Run("Queued Reference", func(t *testing.T) {
+		ec := newTestCanceller(t, 256)
+		chunk := 512
+		var out []float32
+		for start := 0; start+chunk <= len(echo); start += chunk {
+			ec.PushReference(ref[start : start+chunk])
+			out = append(out, ec.Cancel(echo[start:start+chunk])...)
+		}
+
+		tail := len(out) - sampleRate
+		erle := 10 * math.Log10(energy(echo[tail:len(out)])/energy(out[tail:]))
+		if erle < 20 {
+			t.Errorf("Expected ERLE >= 20dB with queued reference, got %.1fdB", erle)
+		}
+	})
+
+	t.Run("Bulk Delay", func(t *testing.T) {
+		// The echo arrives 400 samples late, beyond the reach of 256 taps
+		late := Convolve(ref, append(make([]float32, 400), ir...))
+		chunk := 512
+		run := func(delay int) float64 {
+			ec := newTestCanceller(t, 256)
+			if err := ec.SetDelay(delay); err != nil {
+				t.Fatalf("SetDelay failed: %v", err)
+			}
+			var out []float32
+			for start := 0; start+chunk <= len(late); start += chunk {
+				ec.PushReference(ref[start : start+chunk])
+				out = append(out, ec.Cancel(late[start:start+chunk])...)
+			}
+			tail := len(out) - sampleRate
+			return 10 * math.Log10(energy(late[tail:len(out)])/energy(out[tail:]))
+		}
+
+		if erle := run(0); erle > 6 {
+			t.Errorf("Expected no cancellation without the bulk delay, got %.1fdB", erle)
+		}
+		if erle := run(400); erle < 20 {
+			t.Errorf("Expected ERLE >= 20dB with the bulk delay, got %.1fdB", erle)
+		}
+	})
+
+	t.Run("Invalid Parameters", func(t *testing.T) {
+		for _, tc := range []struct {
+			taps int
+			step float32
+		}{{0, 0.5}, {-4, 0.5}, {64, 0}, {64, 2}, {64, float32(math.NaN())}} {
+			if _, err := NewEchoCanceller(tc.taps, tc.step); err == nil {
+				t.Errorf("Expected an error for %d taps and step %g", tc.taps, tc.step)
+			}
+		}
+		if err := newTestCanceller(t, 64).SetDelay(-1); err == nil {
+			t.Error("Expected an error for a negative delay")
+		}
+	})
+
+	t.Run("Reference Underrun", func(t *testing.T) {
+		ec := newTestCanceller(t, 64)
+		mic := []float32{0.1, 0.2, 0.3}
+		out := ec.Cancel(mic)
+		for i := range mic {
+			if out[i] != mic[i] {
+				t.Errorf("Expected passthrough without reference, got %f at %d", out[i], i)
+			}
+		}
+	})
+}
+
+func TestConvolve(t *testing.T) {
+	out := Convolve([]float32{1, 0, 0, 0}, []float32{0.5, 0.25})
+	expected := []float32{0.5, 0.25, 0, 0}
+	for i := range expected {
+		if out[i] != expected[i] {
+			t.Errorf("At %d: expected %f, got %f", i, expected[i], out[i])
+		}
+	}
+}
+
+// newTestCanceller creates an echo canceller with the given filter length and a
+// step size of 0.5.
+func newTestCanceller(t *testing.T, taps int) *EchoCanceller {
+	t.Helper()
+	ec, err := NewEchoCanceller(taps, 0.5)
+	if err != nil {
+		t.Fatalf("NewEchoCanceller failed: %v", err)
+	}
+	return ec
+}
@@ -0,0 +1,122 @@
+package audio
+
+import "math"
+
+// AGC is an automatic gain control stage that brings the input level to a target RMS.
+// The input power is measured over a short window and the gain follows the level with
+// separate time constants: it drops quickly when the level rises (attack) and recovers
+// slowly when it falls (release), so loud onsets are tamed at once without pumping on
+// short pauses. A soft limiter keeps the output within full scale.
+type AGC struct {
+	// TargetRMS is the desired output RMS level (e.g. 0.1).
+	TargetRMS float32
+	// MaxGain caps the amplification applied to quiet input (e.g. 20 = +26dB).
+	MaxGain float32
+	// GateRMS freezes the gain while the input level is below it, so silence and
+	// background hiss are not amplified up to the target level.
+	GateRMS float32
+	// Limit is the level above which the limiter starts compressing (0 < Limit < 1).
+	Limit float32
+
+	levelCoef   float64
+	attackCoef  float64
+	releaseCoef float64
+	power       float64 // Short-term input power
+	gain        float32
+}
+
+// agcLevelWindow is the time constant of the level detector in milliseconds.
+const agcLevelWindow = 10
+
+// NewAGC creates a new AGC for the given sample rate. Attack and release times are
+// in milliseconds.
+func NewAGC(sampleRate int, targetRMS, maxGain, attackMs, releaseMs float32) *AGC {
+	a := &AGC{
+		TargetRMS: targetRMS,
+		MaxGain:   maxGain,
+		GateRMS:   0.001,
+		Limit:     0.9,
+	}
+	a.levelCoef = timeConstant(agcLevelWindow, sampleRate)
+	a.attackCoef = timeConstant(attackMs, sampleRate)
+	a.releaseCoef = timeConstant(releaseMs, sampleRate)
+	a.Reset()
+	return a
+}
+
+// timeConstant converts a time in milliseconds into a one-pole smoothing coefficient.
+func timeConstant(ms float32, sampleRate int) float64 {
+	if ms <= 0 {
+		return 0
+	}
+	return math.Exp(-1000 / (float64(ms) * float64(sampleRate)))
+}
+
+// Process applies gain control to the samples. The state carries over between calls,
+// which makes it suitable for a continuous capture stream.
+func (a *AGC) Process(samples []float32) []float32 {
+	out := make([]float32, len(samples))
+	gate := float64(a.GateRMS) * float64(a.GateRMS)
+
+	for i, s := range samples {
+		a.power = a.levelCoef*a.power + (1-a.levelCoef)*float64(s)*float64(s)
+
+		// Only adapt while there is signal to measure
+		if a.power > gate {
+			desired := float64(a.TargetRMS) / math.Sqrt(a.power)
+			if desired > float64(a.MaxGain) {
+				desired = float64(a.MaxGain)
+			}
+			coef := a.releaseCoef
+			if desired < float64(a.gain) {
+				coef = a.attackCoef
+			}
+			a.gain = float32(coef*float64(a.gain) + (1-coef)*desired)
+		}
+
+		out[i] = a.limit(s * a.gain)
+	}
+
+	return out
+}
+
+// ProcessClip applies gain control to a self-contained clip (e.g. a WAV file).
+// The AGC is reset and settled on the clip first, so the result depends only on the
+// clip itself and not on what was processed before.
+//
+// This is not causal: the second pass starts with the gain reached at the end of
+// the clip, so the start of the clip is leveled with a gain that depends on what
+// follows it. Process on a live stream starts each stretch of audio with the gain
+// left by the audio before it instead. The two agree once the level is steady but
+// differ around onsets, e.g. a keyword after silence is leveled at once here and
+// only after the attack or release time live.
+func (a *AGC) ProcessClip(samples []float32) []float32 {
+	a.Reset()
+	a.Process(samples)
+	return a.Process(samples)
+}
+
+// limit soft-clips values above the limiter threshold so the output stays within [-1, 1].
+func (a *AGC) limit(x float32) float32 {
+	ax := float32(math.Abs(float64(x)))
+	if ax <= a.Limit {
+		return x
+	}
+	knee := 1 - a.Limit
+	y := a.Limit + knee*float32(math.Tanh(float64((ax-a.Limit)/knee)))
+	if x < 0 {
+		return -y
+	}
+	return y
+}
+
+// Gain returns the gain currently applied.
+func (a *AGC) Gain() float32 {
+	return a.gain
+}
+
+// Reset returns the AGC to unity gain.
+func (a *AGC) Reset() {
+	a.power = 0
+	a.gain = 1
+}
@@ -0,0 +1,97 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func sine(n, sampleRate int, freq, amp float64) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = float32(amp * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+	}
+	return out
+}
+
+func rms(samples []float32) float64 {
+	return math.Sqrt(energy(samples) / float64(len(samples)))
+}
+
+func TestAGC(t *testing.T) {
+	sampleRate := 16000
+
+	t.Run("Quiet Input Reaches Target", func(t *testing.T) {
+		agc := NewAGC(sampleRate, 0.1, 50, 10, 200)
+		// Peaks around 0.01, like a quiet USB microphone
+		out := agc.Process(sine(2*sampleRate, sampleRate, 440, 0.01))
+
+		level := rms(out[sampleRate:])
+		if math.Abs(level-0.1) > 0.02 {
+			t.Errorf("Expected output RMS near 0.1, got %.4f (gain %.2f)", level, agc.Gain())
+		}
+	})
+
+	t.Run("Max Gain", func(t *testing.T) {
+		agc := NewAGC(sampleRate, 0.1, 5, 10, 200)
+		agc.Process(sine(2*sampleRate, sampleRate, 440, 0.01))
+		if agc.Gain() > 5 || agc.Gain() < 4.99 {
+			t.Errorf("Expected gain capped at 5, got %.2f", agc.Gain())
+		}
+	})
+
+	t.Run("Limiter", func(t *testing.T) {
+		agc := NewAGC(sampleRate, 0.5, 10, 10, 200)
+		out := agc.Process(sine(sampleRate, sampleRate, 440, 0.05))
+		// Sudden loud burst while the gain is still high
+		out = append(out, agc.Process(sine(sampleRate/10, sampleRate, 440, 0.9))...)
+		for i, s := range out {
+			if s > 1 || s < -1 {
+				t.Fatalf("Output clipped at %d: %f", i, s)
+			}
+		}
+	})
+
+	t.Run("Gate Holds Gain In Silence", func(t *testing.T) {
+		agc := NewAGC(sampleRate, 0.1, 20, 10, 200)
+		agc.Process(make([]float32, sampleRate))
+		if agc.Gain() != 1 {
+			t.Errorf("Expected unity gain during silence, got %.2f", agc.Gain())
+		}
+	})
+
+	t.Run("Attack Faster Than Release", func(t *testing.T) {
+		agc := NewAGC(sampleRate, 0.1, 50, 5, 500)
+		agc.Process(sine(sampleRate, sampleRate, 440, 0.01))
+		high := agc.Gain()
+
+		// A loud onset should pull the gain down within a few milliseconds
+		agc.Process(sine(sampleRate/50, sampleRate, 440, 0.5))
+		if agc.Gain() > high/5 {
+			t.Errorf("Expected fast attack: gain %.2f -> %.2f", high, agc.Gain())
+		}
+		low := agc.Gain()
+
+		// ...but recover only gradually once it is quiet again
+		agc.Process(sine(sampleRate/50, sampleRate, 440, 0.01))
+		if agc.Gain() > low*2 {
+			t.Errorf("Expected slow release: gain %.2f -> %.2f", low, agc.Gain())
+		}
+	})
+
+	t.Run("Clip Is Independent Of History", func(t *testing.T) {
+		clip := sine(sampleRate/2, sampleRate, 300, 0.02)
+		agc := NewAGC(sampleRate, 0.1, 20, 10, 200)
+		first := agc.ProcessClip(clip)
+		agc.Process(sine(sampleRate, sampleRate, 440, 0.8))
+		second := agc.ProcessClip(clip)
+		for i := range first {
+			if first[i] != second[i] {
+				t.Fatalf("ProcessClip output differs at %d: %f vs %f", i, first[i], second[i])
+			}
+		}
+		// Settled gain applies from the first sample
+		if level := rms(first[:sampleRate/10]); level < 0.08 {
+			t.Errorf("Expected settled level at clip start, got %.4f", level)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+type fifoDevice struct {
+	file   *os.File
+	buffer []byte
+}
+
+// OpenFIFO opens a named pipe (or regular file) carrying raw 16-bit little-endian
+// mono PCM at the capture sample rate. This is typically used to receive the
+// playback reference signal for echo cancellation, e.g. from
+// `parec --format=s16le --channels=1 --rate=16000 > /tmp/hotword.ref`.
+func OpenFIFO(path string) (Device, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open FIFO %s: %w", path, err)
+	}
+	return &fifoDevice{
+		file:   f,
+		buffer: make([]byte, 1024), // 512 samples * 2 bytes
+	}, nil
+}
+
+func (d *fifoDevice) Read() ([]float32, error) {
+	if d.file == nil {
+		return nil, ErrDeviceClosed
+	}
+	n, err := io.ReadFull(d.file, d.buffer)
+	if err == io.ErrUnexpectedEOF {
+		// Writer closed mid-chunk; return what we have, the next read reports EOF
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	numSamples := n / 2
+	out := make([]float32, numSamples)
+	for i := 0; i < numSamples; i++ {
+		sample := int16(binary.LittleEndian.Uint16(d.buffer[i*2 : i*2+2]))
+		out[i] = float32(sample) / 32768.0
+	}
+	return out, nil
+}
+
+func (d *fifoDevice) Close() error {
+	if d.file != nil {
+		err := d.file.Close()
+		d.file = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package capture
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFIFODevice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ref.pcm")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 600 samples: one full 512-sample chunk and a partial one
+	for i := 0; i < 600; i++ {
+		binary.Write(f, binary.LittleEndian, int16(16384))
+	}
+	f.Close()
+
+	device, err := OpenFIFO(path)
+	if err != nil {
+		t.Fatalf("OpenFIFO failed: %v", err)
+	}
+	defer device.Close()
+
+	samples, err := device.Read()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(samples) != 512 {
+		t.Errorf("Expected 512 samples, got %d", len(samples))
+	}
+	if samples[0] != 0.5 {
+		t.Errorf("Expected sample value 0.5, got %f", samples[0])
+	}
+
+	samples, err = device.Read()
+	if err != nil {
+		t.Fatalf("Read of partial chunk failed: %v", err)
+	}
+	if len(samples) != 88 {
+		t.Errorf("Expected 88 samples in partial chunk, got %d", len(samples))
+	}
+
+	if _, err := device.Read(); err != io.EOF {
+		t.Errorf("Expected io.EOF at end of stream, got %v", err)
+	}
+}
+
+func TestOpenFIFOMissing(t *testing.T) {
+	if _, err := OpenFIFO(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected error for missing FIFO")
+	}
+}
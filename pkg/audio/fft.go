@@ -0,0 +1,238 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// FFTPlan holds the precomputed twiddle factors and bit-reversal permutation for an
+// iterative radix-2 complex FFT of a fixed size. A plan is read-only after creation
+// and can be shared between goroutines.
+type FFTPlan struct {
+	n        int
+	twiddles []complex64 // exp(-2*pi*i*k/n) for k < n/2
+	bitrev   []int32
+}
+
+// NewFFTPlan creates a plan for complex FFTs of size n (a power of 2).
+func NewFFTPlan(n int) (*FFTPlan, error) {
+	if n <= 0 || n&(n-1) != 0 {
+		return nil, fmt.Errorf("FFT size must be a power of 2, got %d", n)
+	}
+	p := &FFTPlan{
+		n:        n,
+		twiddles: make([]complex64, n/2),
+		bitrev:   make([]int32, n),
+	}
+	for k := range p.twiddles {
+		angle := -2 * math.Pi * float64(k) / float64(n)
+		p.twiddles[k] = complex(float32(math.Cos(angle)), float32(math.Sin(angle)))
+	}
+	bits := 0
+	for 1<<bits < n {
+		bits++
+	}
+	for i := range p.bitrev {
+		r := 0
+		for b := 0; b < bits; b++ {
+			if i&(1<<b) != 0 {
+				r |= 1 << (bits - 1 - b)
+			}
+		}
+		p.bitrev[i] = int32(r)
+	}
+	return p, nil
+}
+
+// Size returns the transform size.
+func (p *FFTPlan) Size() int {
+	return p.n
+}
+
+// Transform computes the forward FFT of x in place. len(x) must equal the plan size.
+func (p *FFTPlan) Transform(x []complex64) {
+	n := p.n
+	for i, r := range p.bitrev {
+		if int(r) > i {
+			x[i], x[r] = x[r], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		half := size >> 1
+		step := n / size
+		for start := 0; start < n; start += size {
+			for j := 0; j < half; j++ {
+				t := p.twiddles[j*step] * x[start+j+half]
+				x[start+j+half] = x[start+j] - t
+				x[start+j] += t
+			}
+		}
+	}
+}
+
+// RealFFTPlan computes the FFT of real input of size n with a complex FFT of size
+// n/2: even and odd samples are packed into the real and imaginary parts and the
+// spectrum is untangled afterwards, halving the work of a full complex transform.
+type RealFFTPlan struct {
+	n        int
+	half     *FFTPlan
+	twiddles []complex64 // exp(-2*pi*i*k/n) for k <= n/4
+}
+
+// NewRealFFTPlan creates a plan for real-input FFTs of size n (a power of 2, at least 2).
+func NewRealFFTPlan(n int) (*RealFFTPlan, error) {
+	if n < 2 || n&(n-1) != 0 {
+		return nil, fmt.Errorf("real FFT size must be a power of 2 >= 2, got %d", n)
+	}
+	half, err := NewFFTPlan(n / 2)
+	if err != nil {
+		return nil, err
+	}
+	p := &RealFFTPlan{
+		n:        n,
+		half:     half,
+		twiddles: make([]complex64, n/2),
+	}
+	for k := range p.twiddles {
+		angle := -2 * math.Pi * float64(k) / float64(n)
+		p.twiddles[k] = complex(float32(math.Cos(angle)), float32(math.Sin(angle)))
+	}
+	return p, nil
+}
+
+// Size returns the transform size.
+func (p *RealFFTPlan) Size() int {
+	return p.n
+}
+
+// Transform computes the non-negative frequency bins of the FFT of in (len n) into
+// out (len n/2+1). It does not allocate.
+func (p *RealFFTPlan) Transform(in []float32, out []complex64) {
+	m := p.n / 2
+
+	// Pack even/odd samples: z[k] = x[2k] + i*x[2k+1]
+	z := out[:m]
+	for k := range z {
+		z[k] = complex(in[2*k], in[2*k+1])
+	}
+	p.half.Transform(z)
+
+	// Untangle: X[k] = E[k] + W^k O[k] with E = (Z[k] + conj(Z[m-k]))/2 and
+	// O = -i/2 (Z[k] - conj(Z[m-k])). Bins k and m-k are computed together so the
+	// result can be written in place.
+	z0 := z[0]
+	out[0] = complex(real(z0)+imag(z0), 0)
+	out[m] = complex(real(z0)-imag(z0), 0)
+	for k := 1; k <= m/2; k++ {
+		j := m - k
+		zk, zj := z[k], z[j]
+		e := (zk + conj(zj)) * 0.5
+		o := (zk - conj(zj)) * complex(0, -0.5)
+		out[k] = e + p.twiddles[k]*o
+		if j != k {
+			// W^(m-k) = -conj(W^k)
+			out[j] = conj(e) - conj(p.twiddles[k])*conj(o)
+		}
+	}
+}
+
+func conj(c complex64) complex64 {
+	return complex(real(c), -imag(c))
+}
+
+// Plans and windows are immutable, so they are cached per size and shared.
+var (
+	realPlanCache sync.Map // int -> *RealFFTPlan
+	windowCache   sync.Map // int -> []float32
+)
+
+func cachedRealFFTPlan(n int) (*RealFFTPlan, error) {
+	if p, ok := realPlanCache.Load(n); ok {
+		return p.(*RealFFTPlan), nil
+	}
+	p, err := NewRealFFTPlan(n)
+	if err != nil {
+		return nil, err
+	}
+	realPlanCache.Store(n, p)
+	return p, nil
+}
+
+func cachedHammingWindow(n int) []float32 {
+	if w, ok := windowCache.Load(n); ok {
+		return w.([]float32)
+	}
+	w := HammingWindow(n)
+	windowCache.Store(n, w)
+	return w
+}
+
+// STFTProcessor computes magnitude spectra with reusable buffers. After the first
+// call with a given input length, Process performs no allocations. A processor is
+// not safe for concurrent use; create one per goroutine.
+type STFTProcessor struct {
+	windowSize int
+	hopSize    int
+	window     []float32
+	plan       *RealFFTPlan
+
+	frame    []float32
+	spectrum []complex64
+	data     []float32
+	frames   [][]float32
+}
+
+// NewSTFTProcessor creates an STFT processor with a Hamming window.
+func NewSTFTProcessor(windowSize, hopSize int) (*STFTProcessor, error) {
+	if hopSize <= 0 {
+		return nil, fmt.Errorf("hop size must be positive, got %d", hopSize)
+	}
+	plan, err := cachedRealFFTPlan(windowSize)
+	if err != nil {
+		return nil, err
+	}
+	return &STFTProcessor{
+		windowSize: windowSize,
+		hopSize:    hopSize,
+		window:     cachedHammingWindow(windowSize),
+		plan:       plan,
+		frame:      make([]float32, windowSize),
+		spectrum:   make([]complex64, windowSize/2+1),
+	}, nil
+}
+
+// Magnitude windows a single frame (len windowSize) and writes the magnitudes of
+// its windowSize/2+1 non-negative frequency bins into dst.
+func (p *STFTProcessor) Magnitude(frame, dst []float32) {
+	for j, w := range p.window {
+		p.frame[j] = frame[j] * w
+	}
+	p.plan.Transform(p.frame, p.spectrum)
+	for j, c := range p.spectrum {
+		re, im := real(c), imag(c)
+		dst[j] = float32(math.Sqrt(float64(re*re + im*im)))
+	}
+}
+
+// Process returns the magnitude spectrogram of samples. The returned slices are
+// owned by the processor and are overwritten by the next call.
+func (p *STFTProcessor) Process(samples []float32) [][]float32 {
+	numFrames := (len(samples)-p.windowSize)/p.hopSize + 1
+	if len(samples) < p.windowSize || numFrames <= 0 {
+		return nil
+	}
+	numBins := p.windowSize/2 + 1
+	if len(p.frames) < numFrames {
+		p.data = make([]float32, numFrames*numBins)
+		p.frames = make([][]float32, numFrames)
+		for i := range p.frames {
+			p.frames[i] = p.data[i*numBins : (i+1)*numBins]
+		}
+	}
+	for i := 0; i < numFrames; i++ {
+		start := i * p.hopSize
+		p.Magnitude(samples[start:start+p.windowSize], p.frames[i])
+	}
+	return p.frames[:numFrames]
+}
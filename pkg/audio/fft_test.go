@@ -0,0 +1,206 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// referenceFFT is the original recursive Cooley-Tukey FFT, kept as the reference
+// for correctness tests and benchmarks.
+func referenceFFT(a []complex128) []complex128 {
+	n := len(a)
+	if n <= 1 {
+		return a
+	}
+
+	even := make([]complex128, n/2)
+	odd := make([]complex128, n/2)
+	for i := 0; i < n/2; i++ {
+		even[i] = a[2*i]
+		odd[i] = a[2*i+1]
+	}
+
+	evenFFT := referenceFFT(even)
+	oddFFT := referenceFFT(odd)
+
+	result := make([]complex128, n)
+	for k := 0; k < n/2; k++ {
+		t := cmplx.Exp(complex(0, -2*math.Pi*float64(k)/float64(n))) * oddFFT[k]
+		result[k] = evenFFT[k] + t
+		result[k+n/2] = evenFFT[k] - t
+	}
+	return result
+}
+
+// referenceSTFT is the original STFT: a fresh window and complex128 frame per call.
+func referenceSTFT(samples []float32, windowSize, hopSize int) [][]float32 {
+	numFrames := (len(samples)-windowSize)/hopSize + 1
+	if numFrames <= 0 {
+		return nil
+	}
+
+	window := HammingWindow(windowSize)
+	spectrogram := make([][]float32, numFrames)
+	for i := 0; i < numFrames; i++ {
+		start := i * hopSize
+		frame := make([]complex128, windowSize)
+		for j := 0; j < windowSize; j++ {
+			frame[j] = complex(float64(samples[start+j]*window[j]), 0)
+		}
+		fftResult := referenceFFT(frame)
+		numBins := windowSize/2 + 1
+		magnitudeFrame := make([]float32, numBins)
+		for j := 0; j < numBins; j++ {
+			magnitudeFrame[j] = float32(cmplx.Abs(fftResult[j]))
+		}
+		spectrogram[i] = magnitudeFrame
+	}
+	return spectrogram
+}
+
+func randomSignal(n int, seed int64) []float32 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = rng.Float32()*2 - 1
+	}
+	return out
+}
+
+func TestFFTPlan(t *testing.T) {
+	for _, n := range []int{1, 2, 4, 8, 64, 512} {
+		x := randomSignal(2*n, int64(n))
+		in := make([]complex64, n)
+		ref := make([]complex128, n)
+		for i := range in {
+			in[i] = complex(x[2*i], x[2*i+1])
+			ref[i] = complex(float64(x[2*i]), float64(x[2*i+1]))
+		}
+
+		plan, err := NewFFTPlan(n)
+		if err != nil {
+			t.Fatalf("NewFFTPlan(%d) failed: %v", n, err)
+		}
+		plan.Transform(in)
+		expected := referenceFFT(ref)
+
+		for k := range in {
+			diff := cmplx.Abs(complex128(in[k]) - expected[k])
+			if diff > 1e-4*math.Sqrt(float64(n)) {
+				t.Errorf("n=%d bin %d: expected %v, got %v", n, k, expected[k], in[k])
+			}
+		}
+	}
+
+	if _, err := NewFFTPlan(100); err == nil {
+		t.Error("Expected error for non power of 2 size")
+	}
+}
+
+func TestRealFFTPlan(t *testing.T) {
+	for _, n := range []int{2, 4, 8, 16, 256, 512} {
+		x := randomSignal(n, int64(n))
+		ref := make([]complex128, n)
+		for i, v := range x {
+			ref[i] = complex(float64(v), 0)
+		}
+		expected := referenceFFT(ref)
+
+		plan, err := NewRealFFTPlan(n)
+		if err != nil {
+			t.Fatalf("NewRealFFTPlan(%d) failed: %v", n, err)
+		}
+		out := make([]complex64, n/2+1)
+		plan.Transform(x, out)
+
+		for k := range out {
+			diff := cmplx.Abs(complex128(out[k]) - expected[k])
+			if diff > 1e-4*math.Sqrt(float64(n)) {
+				t.Errorf("n=%d bin %d: expected %v, got %v", n, k, expected[k], out[k])
+			}
+		}
+	}
+}
+
+func TestSTFTMatchesReference(t *testing.T) {
+	samples := randomSignal(16000, 42)
+	for i := range samples {
+		samples[i] = 0.3*samples[i] + 0.5*float32(math.Sin(2*math.Pi*440*float64(i)/16000))
+	}
+
+	expected := referenceSTFT(samples, 512, 256)
+	got := STFT(samples, 512, 256)
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d frames, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			diff := math.Abs(float64(got[i][j] - expected[i][j]))
+			if diff > 1e-3+1e-5*float64(expected[i][j]) {
+				t.Fatalf("Frame %d bin %d: expected %f, got %f", i, j, expected[i][j], got[i][j])
+			}
+		}
+	}
+
+	t.Run("Tone Peak", func(t *testing.T) {
+		// 1kHz at 16kHz with a 512 window lands exactly on bin 32
+		tone := make([]float32, 512)
+		for i := range tone {
+			tone[i] = float32(math.Sin(2 * math.Pi * 1000 * float64(i) / 16000))
+		}
+		mag := MagnitudeSpectrum(tone, HammingWindow(512))
+		peak := 0
+		for j := range mag {
+			if mag[j] > mag[peak] {
+				peak = j
+			}
+		}
+		if peak != 32 {
+			t.Errorf("Expected peak at bin 32, got %d", peak)
+		}
+	})
+
+	t.Run("Processor Does Not Allocate", func(t *testing.T) {
+		p, err := NewSTFTProcessor(512, 256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Process(samples)
+		allocs := testing.AllocsPerRun(10, func() {
+			p.Process(samples)
+		})
+		if allocs != 0 {
+			t.Errorf("Expected no allocations, got %.1f per run", allocs)
+		}
+	})
+}
+
+func BenchmarkSTFTReference(b *testing.B) {
+	samples := randomSignal(16000, 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		referenceSTFT(samples, 512, 256)
+	}
+}
+
+func BenchmarkSTFT(b *testing.B) {
+	samples := randomSignal(16000, 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		STFT(samples, 512, 256)
+	}
+}
+
+func BenchmarkSTFTProcessor(b *testing.B) {
+	samples := randomSignal(16000, 1)
+	p, _ := NewSTFTProcessor(512, 256)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Process(samples)
+	}
+}
@@ -0,0 +1,116 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// Noise suppression methods.
+const (
+	NoiseMethodSpectral = "spectral" // Power spectral subtraction
+	NoiseMethodWiener   = "wiener"   // Wiener filter gain
+)
+
+// Default noise suppression parameters.
+const (
+	DefaultOverSubtraction = 2.0
+	DefaultNoiseFloor      = 0.05
+	DefaultNoiseSmoothing  = 0.1
+	DefaultNoiseInitFrames = 5
+)
+
+// NoiseSuppressor attenuates stationary background noise (fans, air conditioning)
+// in STFT magnitude frames. It keeps a running per-bin noise power estimate that is
+// only updated during frames without speech, so the hotword itself does not leak
+// into the noise profile. Frames before the first non-speech frame pass unchanged.
+type NoiseSuppressor struct {
+	Method string
+	// OverSubtraction scales the noise estimate before subtraction (spectral method).
+	OverSubtraction float32
+	// Floor is the minimum gain applied to any bin, avoiding "musical noise".
+	Floor float32
+	// Smoothing is the update rate of the noise estimate (0 < s <= 1).
+	Smoothing float32
+	// InitFrames non-speech frames are averaged with equal weight to seed the
+	// estimate before the exponential smoothing takes over.
+	InitFrames int
+
+	noise  []float32 // Running noise power estimate per bin
+	frames int       // Non-speech frames seen so far
+}
+
+// NewNoiseSuppressor creates a NoiseSuppressor for the given method
+// ("spectral" or "wiener") with commonly used default parameters.
+func NewNoiseSuppressor(method string) (*NoiseSuppressor, error) {
+	if method != NoiseMethodSpectral && method != NoiseMethodWiener {
+		return nil, fmt.Errorf("unknown noise suppression method: %q", method)
+	}
+	return &NoiseSuppressor{
+		Method:          method,
+		OverSubtraction: DefaultOverSubtraction,
+		Floor:           DefaultNoiseFloor,
+		Smoothing:       DefaultNoiseSmoothing,
+		InitFrames:      DefaultNoiseInitFrames,
+	}, nil
+}
+
+// Process suppresses noise in a single magnitude frame and returns the cleaned frame.
+// speech indicates whether the frame contains voice activity; the noise estimate
+// is only updated when it does not.
+func (ns *NoiseSuppressor) Process(mag []float32, speech bool) []float32 {
+	if !speech {
+		if ns.noise == nil {
+			ns.noise = make([]float32, len(mag))
+		}
+		// Running mean over the seeding frames, exponential smoothing afterwards
+		rate := ns.Smoothing
+		if ns.frames < ns.InitFrames || ns.frames == 0 {
+			rate = 1 / float32(ns.frames+1)
+		}
+		for i, m := range mag {
+			ns.noise[i] = (1-rate)*ns.noise[i] + rate*m*m
+		}
+		ns.frames++
+	}
+	if ns.noise == nil {
+		// No noise estimate yet: a clip starting with speech passes unchanged
+		return append([]float32(nil), mag...)
+	}
+
+	out := make([]float32, len(mag))
+	for i, m := range mag {
+		power := m * m
+		if power <= 0 {
+			continue
+		}
+
+		var gain float32
+		switch ns.Method {
+		case NoiseMethodWiener:
+			// Maximum-likelihood a-priori SNR estimate
+			snr := power/(ns.noise[i]+1e-12) - 1
+			if snr < 0 {
+				snr = 0
+			}
+			gain = snr / (1 + snr)
+		default:
+			clean := power - ns.OverSubtraction*ns.noise[i]
+			if clean < 0 {
+				clean = 0
+			}
+			gain = float32(math.Sqrt(float64(clean / power)))
+		}
+
+		if gain < ns.Floor {
+			gain = ns.Floor
+		}
+		out[i] = m * gain
+	}
+	return out
+}
+
+// Reset clears the noise estimate.
+func (ns *NoiseSuppressor) Reset() {
+	ns.noise = nil
+	ns.frames = 0
+}
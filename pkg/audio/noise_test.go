@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNoiseSuppressor(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	numBins := 16
+
+	// Stationary noise with a strong low-frequency component (fan hum)
+	noiseFrame := func() []float32 {
+		mag := make([]float32, numBins)
+		for i := range mag {
+			level := float32(0.05)
+			if i < 4 {
+				level = 0.5
+			}
+			mag[i] = level * (0.9 + 0.2*rng.Float32())
+		}
+		return mag
+	}
+
+	for _, method := range []string{NoiseMethodSpectral, NoiseMethodWiener} {
+		t.Run(method, func(t *testing.T) {
+			ns, err := NewNoiseSuppressor(method)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Learn the noise profile
+			for i := 0; i < 50; i++ {
+				ns.Process(noiseFrame(), false)
+			}
+
+			// Noise-only frames should be strongly attenuated
+			in := noiseFrame()
+			out := ns.Process(in, false)
+			if energy(out) > 0.1*energy(in) {
+				t.Errorf("Expected noise attenuation, in=%.4f out=%.4f", energy(in), energy(out))
+			}
+
+			// A strong tonal component on top of noise should survive
+			speech := noiseFrame()
+			speech[8] += 2.0
+			out = ns.Process(speech, true)
+			if out[8] < 1.5 {
+				t.Errorf("Expected speech bin to be preserved, got %.4f", out[8])
+			}
+		})
+	}
+
+	t.Run("Speech Frames Do Not Update Estimate", func(t *testing.T) {
+		ns, _ := NewNoiseSuppressor(NoiseMethodSpectral)
+		ns.InitFrames = 0
+		ns.Process(noiseFrame(), false)
+		before := append([]float32(nil), ns.noise...)
+
+		loud := make([]float32, numBins)
+		for i := range loud {
+			loud[i] = 5
+		}
+		ns.Process(loud, true)
+		for i := range before {
+			if math.Abs(float64(ns.noise[i]-before[i])) > 1e-9 {
+				t.Fatalf("Noise estimate changed during speech at bin %d", i)
+			}
+		}
+	})
+
+	t.Run("Speech Onset Does Not Seed Estimate", func(t *testing.T) {
+		ns, _ := NewNoiseSuppressor(NoiseMethodSpectral)
+		loud := noiseFrame()
+		loud[8] += 2.0
+
+		// A clip starting with speech passes unchanged until noise is observed
+		for i := 0; i < 2*ns.InitFrames; i++ {
+			out := ns.Process(loud, true)
+			for j := range loud {
+				if out[j] != loud[j] {
+					t.Fatalf("Frame %d: expected passthrough before any noise frame, got %v", i, out)
+				}
+			}
+		}
+
+		for i := 0; i < ns.InitFrames; i++ {
+			ns.Process(noiseFrame(), false)
+		}
+		if ns.noise[8] > 0.01 {
+			t.Errorf("Expected the estimate to hold noise only, got %.4f at the speech bin", ns.noise[8])
+		}
+		if out := ns.Process(loud, true); out[8] < 1.5 {
+			t.Errorf("Expected speech bin to be preserved, got %.4f", out[8])
+		}
+	})
+
+	t.Run("Unknown Method", func(t *testing.T) {
+		if _, err := NewNoiseSuppressor("bogus"); err == nil {
+			t.Error("Expected error for unknown method")
+		}
+	})
+}
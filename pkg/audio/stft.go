@@ -2,7 +2,7 @@ package audio
 
 import (
 	"math"
-	"math/cmplx"
+	"sync"
 )
 
 // HammingWindow returns a Hamming window of the specified size.
@@ -16,62 +16,74 @@ func HammingWindow(size int) []float32 {
 
 // STFT performs Short-Time Fourier Transform on the input samples.
 // It returns a 2D slice where each inner slice is the magnitude spectrum of a frame.
+// Use an STFTProcessor to avoid allocating a new spectrogram on every call.
 func STFT(samples []float32, windowSize, hopSize int) [][]float32 {
-	numFrames := (len(samples) - windowSize) / hopSize + 1
-	if numFrames <= 0 {
+	p, pool, err := acquireSTFTProcessor(windowSize, hopSize)
+	if err != nil {
+		return nil
+	}
+	defer pool.Put(p)
+	frames := p.Process(samples)
+	if frames == nil {
 		return nil
 	}
 
-	window := HammingWindow(windowSize)
-	spectrogram := make([][]float32, numFrames)
-
-	for i := 0; i < numFrames; i++ {
-		start := i * hopSize
-		frame := make([]complex128, windowSize)
-		for j := 0; j < windowSize; j++ {
-			frame[j] = complex(float64(samples[start+j]*window[j]), 0)
-		}
-
-		fftResult := fft(frame)
-		
-		// Take magnitude of the first half (positive frequencies)
-		numBins := windowSize/2 + 1
-		magnitudeFrame := make([]float32, numBins)
-		for j := 0; j < numBins; j++ {
-			magnitudeFrame[j] = float32(cmplx.Abs(fftResult[j]))
-		}
-		spectrogram[i] = magnitudeFrame
+	// Hand out a spectrogram the caller owns
+	numBins := windowSize/2 + 1
+	data := make([]float32, len(frames)*numBins)
+	spectrogram := make([][]float32, len(frames))
+	for i, frame := range frames {
+		spectrogram[i] = data[i*numBins : (i+1)*numBins]
+		copy(spectrogram[i], frame)
 	}
 
 	return spectrogram
 }
 
-// fft performs a basic Cooley-Tukey FFT.
-// Input size must be a power of 2.
-func fft(a []complex128) []complex128 {
-	n := len(a)
-	if n <= 1 {
-		return a
-	}
-
-	// Pad to power of 2 if necessary (though STFT windowSize is usually power of 2)
-	// For this implementation, we assume n is a power of 2.
+// stftPools holds idle processors per window and hop size, so STFT reuses
+// their frame buffers across calls.
+var stftPools sync.Map // [2]int -> *sync.Pool
 
-	even := make([]complex128, n/2)
-	odd := make([]complex128, n/2)
-	for i := 0; i < n/2; i++ {
-		even[i] = a[2*i]
-		odd[i] = a[2*i+1]
+// acquireSTFTProcessor returns a processor for the given framing and the pool
+// to return it to.
+func acquireSTFTProcessor(windowSize, hopSize int) (*STFTProcessor, *sync.Pool, error) {
+	key := [2]int{windowSize, hopSize}
+	p, ok := stftPools.Load(key)
+	if !ok {
+		// Validate before creating a pool for the key
+		proc, err := NewSTFTProcessor(windowSize, hopSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		p, _ = stftPools.LoadOrStore(key, &sync.Pool{})
+		return proc, p.(*sync.Pool), nil
 	}
+	pool := p.(*sync.Pool)
+	if proc, ok := pool.Get().(*STFTProcessor); ok {
+		return proc, pool, nil
+	}
+	proc, err := NewSTFTProcessor(windowSize, hopSize)
+	return proc, pool, err
+}
 
-	evenFFT := fft(even)
-	oddFFT := fft(odd)
+// MagnitudeSpectrum applies the window to a single frame and returns the magnitude
+// of its positive-frequency FFT bins (len(frame)/2 + 1 values).
+func MagnitudeSpectrum(frame, window []float32) []float32 {
+	windowSize := len(frame)
+	plan, err := cachedRealFFTPlan(windowSize)
+	if err != nil {
+		return nil
+	}
+	windowed := make([]float32, windowSize)
+	for j := range windowed {
+		windowed[j] = frame[j] * window[j]
+	}
+	spectrum := make([]complex64, windowSize/2+1)
+	plan.Transform(windowed, spectrum)
 
-	result := make([]complex128, n)
-	for k := 0; k < n/2; k++ {
-		t := cmplx.Exp(complex(0, -2*math.Pi*float64(k)/float64(n))) * oddFFT[k]
-		result[k] = evenFFT[k] + t
-		result[k+n/2] = evenFFT[k] - t
+	magnitudeFrame := make([]float32, len(spectrum))
+	for j, c := range spectrum {
+		magnitudeFrame[j] = float32(math.Sqrt(float64(real(c)*real(c) + imag(c)*imag(c))))
 	}
-	return result
+	return magnitudeFrame
 }
@@ -43,4 +43,32 @@ func TestSTFT(t *testing.T) {
 			t.Errorf("Expected %d bins, got %d", windowSize/2+1, len(spectrogram[0]))
 		}
 	})
+
+	t.Run("Pooled Processor", func(t *testing.T) {
+		samples := make([]float32, 4000)
+		for i := range samples {
+			samples[i] = float32(math.Sin(2 * math.Pi * 1000 * float64(i) / 16000))
+		}
+		p, _ := NewSTFTProcessor(256, 128)
+		expected := p.Process(samples)
+
+		// A processor used for a longer input before gives the same frames
+		STFT(make([]float32, 8000), 256, 128)
+		got := STFT(samples, 256, 128)
+		if len(got) != len(expected) {
+			t.Fatalf("Expected %d frames, got %d", len(expected), len(got))
+		}
+		for i := range got {
+			for j := range got[i] {
+				if got[i][j] != expected[i][j] {
+					t.Fatalf("Frame %d bin %d: expected %f, got %f", i, j, expected[i][j], got[i][j])
+				}
+			}
+		}
+
+		// Only the returned spectrogram is allocated
+		if allocs := testing.AllocsPerRun(20, func() { STFT(samples, 256, 128) }); allocs > 2 {
+			t.Errorf("Expected 2 allocations per call, got %.0f", allocs)
+		}
+	})
 }
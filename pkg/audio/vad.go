@@ -30,14 +30,7 @@ func (v *VAD) IsSpeech(samples []float32) bool {
 		return false
 	}
 
-	rms := CalculateRMS(samples)
-	zcr := CalculateZCR(samples)
-
-	// Speech usually has high energy and low ZCR (low frequency components)
-	// Noise (like a fan) often has low energy or high ZCR (high frequency components)
-	isCurrentlySpeech := rms >= v.EnergyThreshold && zcr < v.ZCRThreshold
-
-	if isCurrentlySpeech {
+	if IsSpeechFrame(samples, v.EnergyThreshold, v.ZCRThreshold) {
 		v.lastSpeechTime = time.Now()
 		return true
 	}
@@ -50,6 +43,21 @@ func (v *VAD) IsSpeech(samples []float32) bool {
 	return false
 }
 
+// IsSpeechFrame applies the energy/ZCR speech test to a single frame without any
+// hangover state. It is used where a deterministic per-frame decision is needed,
+// such as updating noise estimates during offline feature extraction.
+func IsSpeechFrame(samples []float32, energyThreshold, zcrThreshold float32) bool {
+	if len(samples) == 0 {
+		return false
+	}
+	rms := CalculateRMS(samples)
+	zcr := CalculateZCR(samples)
+
+	// Speech usually has high energy and low ZCR (low frequency components)
+	// Noise (like a fan) often has low energy or high ZCR (high frequency components)
+	return rms >= energyThreshold && zcr < zcrThreshold
+}
+
 // CalculateRMS calculates the Root Mean Square energy of the samples.
 func CalculateRMS(samples []float32) float32 {
 	if len(samples) == 0 {
@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"fmt"
+
 	"github.com/tomkiv/hotword/pkg/audio"
 	"github.com/tomkiv/hotword/pkg/features"
 	"github.com/tomkiv/hotword/pkg/model"
@@ -10,28 +12,61 @@ import (
 type Engine struct {
 	model           model.Model
 	vad             *audio.VAD
+	agc             *audio.AGC
 	sampleRate      int
-	windowSize      int
-	hopSize         int
-	numMelFilters   int
+	features        features.Config
+	streamer        *features.Streamer // Stateful frontends only (e.g. PCEN, noise suppression)
+	adaptive        *features.AdaptiveCMVN
+	frames          [][]float32        // Most recent streamed feature frames
+	stream          *model.FrameStream // Frame streaming mode only
+	hopScores       []float32          // Scores of the hops pushed since the last Process
+	classes         []string           // Output class names of a multi-class model
+	class           int                // Keyword class the smoothed probability follows
+	windowFrames    int                // Number of frames in one window
 	windowBuffer    []float32
 	smoothProb      float32
 	consecutiveHigh int // Count of consecutive frames above threshold
 	samplesIngested int // Track how many samples have been ingested (for warmup)
 }
 
-// NewEngine creates a new inference engine.
+// NewEngine creates a new inference engine using the default feature frontend.
 func NewEngine(m model.Model, sampleRate int) *Engine {
-	return &Engine{
+	cfg := features.DefaultConfig()
+	cfg.SampleRate = sampleRate
+	return NewEngineWithConfig(m, cfg)
+}
+
+// NewEngineWithConfig creates a new inference engine with the given feature frontend,
+// typically the one returned by features.LoadModel.
+// Stateful frontends (see features.Config.Stateful) are computed incrementally so
+// their state follows the live stream instead of restarting on every window.
+func NewEngineWithConfig(m model.Model, cfg features.Config) *Engine {
+	e := &Engine{
 		model: m,
 		// Default VAD settings (can be calibrated via CLI later)
-		vad:           audio.NewVAD(0.01, 0.5, 300),
-		sampleRate:    sampleRate,
-		windowSize:    512,
-		hopSize:       256,
-		numMelFilters: 40,
-		windowBuffer:  make([]float32, sampleRate), // 1 second buffer
+		vad:          audio.NewVAD(0.01, 0.5, 300),
+		sampleRate:   cfg.SampleRate,
+		features:     cfg,
+		windowFrames: cfg.NumFrames(cfg.SampleRate),
+		windowBuffer: make([]float32, cfg.SampleRate), // 1 second buffer
+		class:        -1,
 	}
+	if cfg.Stateful() {
+		if s, err := features.NewStreamer(cfg); err == nil {
+			e.streamer = s
+		}
+	}
+	return e
+}
+
+// NewStreamingEngine is like NewEngineWithConfig but rejects models that
+// cannot run on a live stream, such as models with bidirectional layers.
+// Such models can still be evaluated clip by clip with ProcessSingle.
+func NewStreamingEngine(m model.Model, cfg features.Config) (*Engine, error) {
+	if err := model.CheckStreamable(m); err != nil {
+		return nil, fmt.Errorf("model cannot be used for streaming: %w", err)
+	}
+	return NewEngineWithConfig(m, cfg), nil
 }
 
 // SetVAD updates the VAD configuration for the engine.
@@ -39,6 +74,118 @@ func (e *Engine) SetVAD(v *audio.VAD) {
 	e.vad = v
 }
 
+// SetAdaptiveCMVN lets the normalization mean follow the live input at the given
+// per-frame rate. It requires a model trained with normalization statistics.
+// The adapted mean describes the microphone, so it is kept across Reset.
+func (e *Engine) SetAdaptiveCMVN(rate float32) error {
+	a, err := features.NewAdaptiveCMVN(e.features, rate)
+	if err != nil {
+		return err
+	}
+	if e.streamer == nil {
+		s, err := features.NewStreamer(e.features)
+		if err != nil {
+			return err
+		}
+		e.streamer = s
+	}
+	e.adaptive = a
+	return nil
+}
+
+// SetFrameStreaming switches the engine from re-evaluating the whole window on
+// every chunk to frame streaming: each new feature frame runs through the
+// convolutional front on a small causal context, advances the recurrent state
+// by one step and is scored, so Process smooths one score per hop. The model
+// must have been trained with causal convolutions (see model.NewFrameStream)
+// and the frontend must not use deltas, which look ahead in time.
+func (e *Engine) SetFrameStreaming() error {
+	if e.features.Deltas > 0 {
+		return fmt.Errorf("frame streaming does not support delta features")
+	}
+	if len(e.classes) > 0 {
+		return fmt.Errorf("frame streaming does not support multi-class models")
+	}
+	s, err := model.NewFrameStream(e.model)
+	if err != nil {
+		return err
+	}
+	if e.streamer == nil {
+		st, err := features.NewStreamer(e.features)
+		if err != nil {
+			return err
+		}
+		e.streamer = st
+	}
+	e.stream = s
+	return nil
+}
+
+// SetClasses names the outputs of a multi-class model (see model.Metadata.Classes).
+// Each window is then scored by its most probable keyword class, which
+// DebugInfo reports; the "unknown" and "silence" classes never trigger a
+// detection. Frame streaming does not support multi-class models.
+func (e *Engine) SetClasses(classes []string) error {
+	if e.stream != nil && len(classes) > 0 {
+		return fmt.Errorf("frame streaming does not support multi-class models")
+	}
+	e.classes = classes
+	return nil
+}
+
+// score returns the detection score of a model output and the class it belongs
+// to: the probability of a binary model (class -1), or the probability of the
+// most probable keyword class of a multi-class model.
+func (e *Engine) score(output []float32) (float32, int) {
+	if len(e.classes) == 0 {
+		return output[0], -1
+	}
+	best := -1
+	for i, p := range output {
+		if i < len(e.classes) && model.IsBackgroundClass(e.classes[i]) {
+			continue
+		}
+		if best < 0 || p > output[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0, -1
+	}
+	return output[best], best
+}
+
+// className returns the name of output class i, or "" for none.
+func (e *Engine) className(i int) string {
+	if i < 0 || i >= len(e.classes) {
+		return ""
+	}
+	return e.classes[i]
+}
+
+// SetAGC enables automatic gain control. Streaming callers pass captured audio
+// through ApplyAGC; ProcessSingle applies it to each clip on its own.
+func (e *Engine) SetAGC(a *audio.AGC) {
+	e.agc = a
+}
+
+// ApplyAGC runs the samples through the engine's AGC (if enabled) and returns the result.
+// It should be called on captured audio before level checks and Process.
+func (e *Engine) ApplyAGC(samples []float32) []float32 {
+	if e.agc == nil {
+		return samples
+	}
+	return e.agc.Process(samples)
+}
+
+// Gain returns the gain currently applied by the AGC (1 when disabled).
+func (e *Engine) Gain() float32 {
+	if e.agc == nil {
+		return 1
+	}
+	return e.agc.Gain()
+}
+
 // Reset clears the engine's state, resetting the probability smoother and buffer.
 // Call this after a detection or when starting a new listening session.
 // Buffer is initialized with low-level noise to prevent onset false positives
@@ -49,6 +196,15 @@ func (e *Engine) Reset() {
 	e.samplesIngested = 0
 	e.windowBuffer = make([]float32, e.sampleRate)
 	e.model.ResetState()
+	if e.streamer != nil {
+		e.streamer.Reset()
+		e.frames = nil
+	}
+	if e.stream != nil {
+		e.stream.Reset()
+		e.hopScores = nil
+	}
+
 	// Fill with low-level noise to mimic ambient silence
 	for i := range e.windowBuffer {
 		// Low amplitude pseudo-random noise using a simple formula
@@ -67,24 +223,81 @@ func (e *Engine) PushSamples(samples []float32) {
 		copy(e.windowBuffer[len(e.windowBuffer)-len(samples):], samples)
 	}
 	e.samplesIngested += len(samples)
+
+	if e.streamer != nil {
+		frames := e.streamer.Push(samples)
+		if e.adaptive != nil {
+			e.adaptive.Update(frames)
+		}
+		e.frames = append(e.frames, frames...)
+		if len(e.frames) > e.windowFrames {
+			e.frames = e.frames[len(e.frames)-e.windowFrames:]
+		}
+
+		// The recurrent state has to see every frame, including silence
+		if e.stream != nil && len(frames) > 0 {
+			cfg := e.features
+			if e.adaptive != nil {
+				cfg = e.adaptive.Config()
+			}
+			e.hopScores = append(e.hopScores, e.stream.Push(cfg.Tensor(frames))...)
+		}
+	}
 }
 
-// ProcessSingle evaluates a complete audio sample and returns the raw probability.
+// windowFeatures returns the model input for the current window. Streamed frames are
+// used once a full window is available; otherwise the window is extracted in one go.
+func (e *Engine) windowFeatures() *model.Tensor {
+	cfg := e.features
+	if e.adaptive != nil {
+		cfg = e.adaptive.Config()
+	}
+	if e.streamer != nil && len(e.frames) == e.windowFrames {
+		return cfg.Tensor(e.frames)
+	}
+	return features.ExtractWithConfig(e.windowBuffer, cfg)
+}
+
+// ProcessSingle evaluates a complete audio sample and returns the raw probability
+// (of the most probable keyword class for a multi-class model).
 // Use this for verification/testing where each sample is evaluated independently.
 // This does NOT use smoothing - it's meant for single-shot evaluation.
 func (e *Engine) ProcessSingle(samples []float32) float32 {
-	// Fill buffer with the complete sample
-	e.PushSamples(samples)
+	output := e.ProcessSingleOutput(samples)
+	if output == nil {
+		return 0
+	}
+	score, _ := e.score(output)
+	return score
+}
 
-	// Audio Preprocessing (Log-Mel Spectrogram)
-	input := features.Extract(e.windowBuffer, e.sampleRate, e.windowSize, e.hopSize, e.numMelFilters)
+// ProcessSingleOutput is like ProcessSingle but returns the whole model output,
+// e.g. the probabilities of all classes of a multi-class model.
+func (e *Engine) ProcessSingleOutput(samples []float32) []float32 {
+	input := e.SingleInput(samples)
 	if input == nil {
-		return 0
+		return nil
 	}
 
-	// Inference - return raw probability
-	output := e.model.ForwardStateful(input)
-	return output.Data[0]
+	// Inference - return raw probabilities. The clip is evaluated on its own, so
+	// no recurrent state is carried over and offline-only models (e.g.
+	// bidirectional layers) work too.
+	return e.model.Forward(input).Data
+}
+
+// SingleInput returns the model input that ProcessSingle computes for a complete
+// audio sample, e.g. to calibrate the quantization of a model.
+func (e *Engine) SingleInput(samples []float32) *model.Tensor {
+	// Level the clip independently of previously processed clips
+	if e.agc != nil {
+		samples = e.agc.ProcessClip(samples)
+	}
+
+	// Fill buffer with the complete sample
+	e.PushSamples(samples)
+
+	// Audio Preprocessing (Log-Mel Spectrogram)
+	return features.ExtractWithConfig(e.windowBuffer, e.features)
 }
 
 // DebugInfo contains detailed information about engine state for debugging
@@ -96,6 +309,10 @@ type DebugInfo struct {
 	WarmupComplete  bool
 	VADActive       bool
 	Detected        bool
+	Gain            float32   // Gain applied by the AGC
+	HopScores       []float32 // Raw score of every hop in frame streaming mode
+	Class           string    // Winning keyword class of a multi-class model (see SetClasses)
+	ClassProbs      []float32 // Probabilities of all classes of a multi-class model
 }
 
 // ProcessDebug is like Process but returns detailed debug information
@@ -112,6 +329,7 @@ func (e *Engine) ProcessDebug(samples []float32, threshold float32) DebugInfo {
 	if !isSpeech {
 		// If no speech and not warming up, skip heavy NN forward pass
 		if warmupComplete {
+			e.hopScores = nil
 			e.smoothProb = e.smoothProb * 0.5 // Fast decay
 			e.consecutiveHigh = 0
 			return DebugInfo{
@@ -119,40 +337,59 @@ func (e *Engine) ProcessDebug(samples []float32, threshold float32) DebugInfo {
 				VADActive:       false,
 				SamplesIngested: e.samplesIngested,
 				SmoothProb:      e.smoothProb,
+				Gain:            e.Gain(),
 			}
 		}
 	}
 
-	// 3. Audio Preprocessing
-	input := features.Extract(e.windowBuffer, e.sampleRate, e.windowSize, e.hopSize, e.numMelFilters)
-	if input == nil {
-		return DebugInfo{
-			WarmupComplete:  warmupComplete,
-			VADActive:       isSpeech,
-			SamplesIngested: e.samplesIngested,
+	// 3-4. Inference: one score per hop in frame streaming mode (computed as
+	// the samples were pushed), otherwise one score for the whole window
+	var scores, hopScores, classProbs []float32
+	if e.stream != nil {
+		scores, hopScores, e.hopScores = e.hopScores, e.hopScores, nil
+	} else {
+		input := e.windowFeatures()
+		if input == nil {
+			return DebugInfo{
+				WarmupComplete:  warmupComplete,
+				VADActive:       isSpeech,
+				SamplesIngested: e.samplesIngested,
+				Gain:            e.Gain(),
+			}
+		}
+		output := e.model.ForwardStateful(input)
+		score, class := e.score(output.Data)
+		if class != e.class {
+			// Smoothing follows one keyword; start over when another one wins
+			e.smoothProb = 0
+			e.consecutiveHigh = 0
+			e.class = class
+		}
+		scores = []float32{score}
+		if len(e.classes) > 0 {
+			classProbs = output.Data
 		}
 	}
 
-	// 4. Inference
-	output := e.model.ForwardStateful(input)
-	rawProb := output.Data[0]
-
 	// 5. Probability Smoothing
 	const alpha = 0.3
 	const decayFactor = 0.5
 	const requiredConsecutive = 5
 	const highProbThreshold = float32(0.9)
 
-	if rawProb < highProbThreshold {
-		e.smoothProb = e.smoothProb * decayFactor
-		e.consecutiveHigh = 0
-	} else {
-		e.smoothProb = alpha*rawProb + (1-alpha)*e.smoothProb
-		e.consecutiveHigh++
+	var rawProb float32
+	detected := false
+	for _, rawProb = range scores {
+		if rawProb < highProbThreshold {
+			e.smoothProb = e.smoothProb * decayFactor
+			e.consecutiveHigh = 0
+		} else {
+			e.smoothProb = alpha*rawProb + (1-alpha)*e.smoothProb
+			e.consecutiveHigh++
+		}
+		detected = detected || (warmupComplete && e.consecutiveHigh >= requiredConsecutive && e.smoothProb >= threshold)
 	}
 
-	detected := warmupComplete && e.consecutiveHigh >= requiredConsecutive && e.smoothProb >= threshold
-
 	return DebugInfo{
 		RawProb:         rawProb,
 		SmoothProb:      e.smoothProb,
@@ -161,6 +398,10 @@ func (e *Engine) ProcessDebug(samples []float32, threshold float32) DebugInfo {
 		WarmupComplete:  warmupComplete,
 		VADActive:       true,
 		Detected:        detected,
+		Gain:            e.Gain(),
+		HopScores:       hopScores,
+		Class:           e.className(e.class),
+		ClassProbs:      classProbs,
 	}
 }
 
@@ -1,7 +1,10 @@
 package engine
 
 import (
+	"math"
 	"testing"
+	"github.com/tomkiv/hotword/pkg/audio"
+	"github.com/tomkiv/hotword/pkg/features"
 	"github.com/tomkiv/hotword/pkg/model"
 )
 
@@ -45,4 +48,305 @@ func TestEngine(t *testing.T) {
 		// If detected is true or false depends on weights, but it should be consistent
 		_ = detected
 	})
+	t.Run("AGC", func(t *testing.T) {
+		sampleRate := 16000
+		numFrames := (sampleRate-512)/256 + 1
+		weights := model.NewTensor([]int{1, numFrames * 40})
+		m := model.NewSequentialModel(
+			model.NewDenseLayer(weights, []float32{0.0}),
+			model.NewSigmoidLayer(),
+		)
+		e := NewEngine(m, sampleRate)
+		if e.Gain() != 1 {
+			t.Errorf("Expected unity gain without AGC, got %f", e.Gain())
+		}
+
+		e.SetAGC(audio.NewAGC(sampleRate, 0.1, 20, 10, 200))
+		quiet := make([]float32, sampleRate)
+		for i := range quiet {
+			quiet[i] = 0.01 * float32(math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)))
+		}
+		e.ProcessSingle(quiet)
+		if e.Gain() < 5 {
+			t.Errorf("Expected AGC to boost quiet clip, gain %f", e.Gain())
+		}
+
+		info := e.ProcessDebug(e.ApplyAGC(quiet), 0.5)
+		if info.Gain != e.Gain() {
+			t.Errorf("Expected debug info to report gain %f, got %f", e.Gain(), info.Gain)
+		}
+	})
+
+	t.Run("Graph Model", func(t *testing.T) {
+		sampleRate := 16000
+		numFrames := (sampleRate-512)/256 + 1
+		m, err := model.BuildModel([]model.LayerConfig{
+			{Type: "conv2d", Name: "conv", Filters: 1, KernelSize: 3, Padding: 1},
+			{Type: "add", Inputs: []string{"input", "conv"}},
+			{Type: "dense", Units: 1},
+			{Type: "sigmoid"},
+		}, []int{1, numFrames, 40})
+		if err != nil {
+			t.Fatalf("Failed to build graph model: %v", err)
+		}
+		e := NewEngine(m, sampleRate)
+
+		clip := make([]float32, sampleRate)
+		for i := range clip {
+			clip[i] = 0.1 * float32(math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)))
+		}
+		prob := e.ProcessSingle(clip)
+		if expected := m.Forward(e.windowFeatures()).Data[0]; prob != expected {
+			t.Errorf("Expected probability %f, got %f", expected, prob)
+		}
+		if prob <= 0 || prob >= 1 {
+			t.Errorf("Expected a probability in (0, 1), got %f", prob)
+		}
+	})
+
+	t.Run("Streaming PCEN Frontend", func(t *testing.T) {
+		cfg := features.DefaultConfig()
+		cfg.LogScale = features.LogScalePCEN
+		numFrames := cfg.NumFrames(cfg.SampleRate)
+		m := model.NewSequentialModel(
+			model.NewDenseLayer(model.NewTensor([]int{1, numFrames * 40}), []float32{0.0}),
+			model.NewSigmoidLayer(),
+		)
+		e := NewEngineWithConfig(m, cfg)
+		if e.streamer == nil {
+			t.Fatal("Expected a streaming frontend for PCEN")
+		}
+
+		chunk := make([]float32, 512)
+		for i := range chunk {
+			chunk[i] = 0.1 * float32(math.Sin(2*math.Pi*1000*float64(i)/16000))
+		}
+		for i := 0; i < 40; i++ {
+			e.Process(chunk, 0.5)
+		}
+		if len(e.frames) != numFrames {
+			t.Errorf("Expected %d streamed frames, got %d", numFrames, len(e.frames))
+		}
+		if input := e.windowFeatures(); input.Shape[1] != numFrames || input.Shape[2] != 40 {
+			t.Errorf("Unexpected streamed input shape %v", input.Shape)
+		}
+
+		e.Reset()
+		if len(e.frames) != 0 {
+			t.Errorf("Expected Reset to clear streamed frames")
+		}
+	})
+
+	t.Run("Streaming Noise Suppression", func(t *testing.T) {
+		cfg := features.DefaultConfig()
+		cfg.Noise = features.DefaultNoiseConfig("spectral")
+		numFrames := cfg.NumFrames(cfg.SampleRate)
+		m := model.NewSequentialModel(
+			model.NewDenseLayer(model.NewTensor([]int{1, numFrames * 40}), []float32{0.0}),
+			model.NewSigmoidLayer(),
+		)
+		e := NewEngineWithConfig(m, cfg)
+		if e.streamer == nil {
+			t.Fatal("Expected a streaming frontend for noise suppression")
+		}
+
+		// Quiet noise that grows louder, so the estimate of the whole stream
+		// differs from one seeded on the last window alone
+		stream := make([]float32, 3*cfg.SampleRate)
+		state := uint32(1)
+		for i := range stream {
+			state = state*1664525 + 1013904223
+			stream[i] = (0.001 + 0.002*float32(i)/float32(len(stream))) * (float32(state>>8)/float32(1<<24) - 0.5)
+		}
+		for i := 0; i+512 <= len(stream); i += 512 {
+			e.PushSamples(stream[i : i+512])
+		}
+
+		// The window is the tail of one continuous stream, not a fresh extraction
+		s, err := features.NewStreamer(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all := s.Push(stream[:len(stream)/512*512])
+		expected := cfg.Tensor(all[len(all)-numFrames:])
+		got := e.windowFeatures()
+		for i := range expected.Data {
+			if got.Data[i] != expected.Data[i] {
+				t.Fatalf("Index %d: expected streamed feature %f, got %f", i, expected.Data[i], got.Data[i])
+			}
+		}
+		fresh := features.ExtractWithConfig(e.windowBuffer, cfg)
+		same := true
+		for i := range fresh.Data {
+			if fresh.Data[i] != got.Data[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			t.Error("Expected the noise estimate to carry over from earlier windows")
+		}
+	})
+
+	t.Run("Adaptive CMVN", func(t *testing.T) {
+		cfg := features.DefaultConfig()
+		numFrames := cfg.NumFrames(cfg.SampleRate)
+		m := model.NewSequentialModel(
+			model.NewDenseLayer(model.NewTensor([]int{1, numFrames * 40}), []float32{0.0}),
+			model.NewSigmoidLayer(),
+		)
+		if err := NewEngineWithConfig(m, cfg).SetAdaptiveCMVN(0.01); err == nil {
+			t.Error("Expected error for a model without normalization statistics")
+		}
+
+		cfg.CMVN = features.CMVN{Mean: make([]float32, 40), Std: make([]float32, 40)}
+		for i := range cfg.CMVN.Std {
+			cfg.CMVN.Std[i] = 1
+		}
+		e := NewEngineWithConfig(m, cfg)
+		if err := e.SetAdaptiveCMVN(0.01); err != nil {
+			t.Fatalf("SetAdaptiveCMVN failed: %v", err)
+		}
+
+		chunk := make([]float32, 512)
+		for i := range chunk {
+			chunk[i] = 0.1 * float32(math.Sin(2*math.Pi*1000*float64(i)/16000))
+		}
+		for i := 0; i < 100; i++ {
+			e.PushSamples(chunk)
+		}
+		// A stationary input is normalized towards zero once the mean has adapted
+		input := e.windowFeatures()
+		var sum float64
+		for _, v := range input.Data {
+			sum += math.Abs(float64(v))
+		}
+		if mean := sum / float64(len(input.Data)); mean > 0.1 {
+			t.Errorf("Expected adapted features near zero, mean magnitude %f", mean)
+		}
+	})
+	t.Run("Bidirectional Model", func(t *testing.T) {
+		cfg := features.DefaultConfig()
+		m, err := model.BuildModel([]model.LayerConfig{
+			{Type: "bigru", Units: 4},
+			{Type: "dense", Units: 1},
+			{Type: "sigmoid"},
+		}, []int{1, cfg.NumFrames(cfg.SampleRate), cfg.NumMelFilters})
+		if err != nil {
+			t.Fatalf("Failed to build model: %v", err)
+		}
+		if _, err := NewStreamingEngine(m, cfg); err == nil {
+			t.Error("Expected a bidirectional model to be rejected for streaming")
+		}
+
+		// Clip-by-clip evaluation does not need streaming
+		e := NewEngineWithConfig(m, cfg)
+		samples := make([]float32, cfg.SampleRate)
+		for i := range samples {
+			samples[i] = 0.1 * float32(math.Sin(2*math.Pi*440*float64(i)/16000))
+		}
+		if first, second := e.ProcessSingle(samples), e.ProcessSingle(samples); first != second || first <= 0 {
+			t.Errorf("Expected the same probability for the same clip, got %f and %f", first, second)
+		}
+	})
+	t.Run("Frame Streaming", func(t *testing.T) {
+		cfg := features.DefaultConfig()
+		numFrames := cfg.NumFrames(cfg.SampleRate)
+		layers := []model.LayerConfig{
+			{Type: "conv2d", Filters: 2, KernelSize: 3, Padding: 1},
+			{Type: "relu"},
+			{Type: "gru", Units: 4},
+			{Type: "dense", Units: 1},
+			{Type: "sigmoid"},
+		}
+		padded, err := model.BuildModel(layers, []int{1, numFrames, cfg.NumMelFilters})
+		if err != nil {
+			t.Fatalf("Failed to build model: %v", err)
+		}
+		if err := NewEngineWithConfig(padded, cfg).SetFrameStreaming(); err == nil {
+			t.Error("Expected a model with time padding to be rejected")
+		}
+
+		m, err := model.BuildModel(model.CausalConfigs(layers), []int{1, numFrames, cfg.NumMelFilters})
+		if err != nil {
+			t.Fatalf("Failed to build model: %v", err)
+		}
+		e := NewEngineWithConfig(m, cfg)
+		if err := e.SetFrameStreaming(); err != nil {
+			t.Fatalf("SetFrameStreaming failed: %v", err)
+		}
+
+		samples := make([]float32, cfg.SampleRate)
+		for i := range samples {
+			samples[i] = 0.1 * float32(math.Sin(2*math.Pi*440*float64(i)/16000))
+		}
+		var scores []float32
+		for i := 0; i < len(samples); i += 1000 {
+			scores = append(scores, e.ProcessDebug(samples[i:i+1000], 0.5).HopScores...)
+		}
+
+		// One score per hop once the 3-frame context is filled; the last one
+		// matches the whole second evaluated at once
+		if len(scores) != numFrames-2 {
+			t.Fatalf("Expected %d hop scores, got %d", numFrames-2, len(scores))
+		}
+		expected := m.Forward(features.ExtractWithConfig(samples, cfg)).Data[0]
+		if got := scores[len(scores)-1]; math.Abs(float64(got-expected)) > 1e-4 {
+			t.Errorf("Expected last hop score %f, got %f", expected, got)
+		}
+	})
+	t.Run("Multi-Class Model", func(t *testing.T) {
+		cfg := features.DefaultConfig()
+		m, err := model.BuildModel([]model.LayerConfig{
+			{Type: "dense", Units: 4},
+			{Type: "softmax"},
+		}, []int{1, cfg.NumFrames(cfg.SampleRate), cfg.NumMelFilters})
+		if err != nil {
+			t.Fatalf("Failed to build model: %v", err)
+		}
+		dense := m.GetLayers()[0].(*model.DenseLayer)
+		for i := range dense.Weights.Data {
+			dense.Weights.Data[i] = 0
+		}
+		// "unknown" is the most probable class, "no" the most probable keyword
+		copy(dense.Bias, []float32{0, 3, 5, 1})
+
+		e := NewEngineWithConfig(m, cfg)
+		if err := e.SetClasses([]string{"yes", "no", model.ClassUnknown, model.ClassSilence}); err != nil {
+			t.Fatalf("SetClasses failed: %v", err)
+		}
+		if err := e.SetFrameStreaming(); err == nil {
+			t.Error("Expected frame streaming to reject a multi-class model")
+		}
+
+		samples := make([]float32, cfg.SampleRate)
+		for i := range samples {
+			samples[i] = 0.5 * float32(math.Sin(2*math.Pi*440*float64(i)/16000))
+		}
+		probs := e.ProcessSingleOutput(samples)
+		if len(probs) != 4 {
+			t.Fatalf("Expected 4 class probabilities, got %v", probs)
+		}
+		if got := e.ProcessSingle(samples); got != probs[1] {
+			t.Errorf("Expected the probability of \"no\" (%f), got %f", probs[1], got)
+		}
+
+		info := e.ProcessDebug(samples, 0.5)
+		if info.Class != "no" || len(info.ClassProbs) != 4 {
+			t.Errorf("Expected class \"no\" with 4 probabilities, got %q and %v", info.Class, info.ClassProbs)
+		}
+
+		// A confident keyword builds up, and another keyword starts over
+		copy(dense.Bias, []float32{0, 10, 0, 0})
+		for i := 0; i < 3; i++ {
+			info = e.ProcessDebug(samples, 0.5)
+		}
+		if info.ConsecutiveHigh != 3 {
+			t.Errorf("Expected 3 consecutive high windows, got %d", info.ConsecutiveHigh)
+		}
+		copy(dense.Bias, []float32{10, 0, 0, 0})
+		if info = e.ProcessDebug(samples, 0.5); info.Class != "yes" || info.ConsecutiveHigh != 1 {
+			t.Errorf("Expected class \"yes\" with 1 high window, got %q with %d", info.Class, info.ConsecutiveHigh)
+		}
+	})
 }
@@ -0,0 +1,294 @@
+package features
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+const (
+	cacheMagic   = "HWFC"
+	cacheVersion = uint16(1)
+)
+
+// CacheKey identifies cached features: the SHA-256 of the source file, the window
+// offset and length within it, and the frontend configuration.
+type CacheKey [sha256.Size]byte
+
+// Cache stores extracted feature tensors so unaugmented samples are only processed
+// once. Tensors are kept before CMVN, so the cache stays valid when normalization
+// statistics are recomputed; the current statistics are applied on every lookup.
+// With a path the cache is persisted to a compact binary file and reused across
+// runs. It is safe for concurrent use.
+type Cache struct {
+	cfg     Config // Frontend without CMVN, used for extraction
+	cmvn    CMVN
+	cfgHash [sha256.Size]byte
+	path    string
+
+	mu      sync.RWMutex
+	entries map[CacheKey]*model.Tensor
+	dirty   bool
+	hits    int
+	misses  int
+}
+
+// NewCache creates an in-memory feature cache for the given frontend.
+func NewCache(cfg Config) *Cache {
+	c := &Cache{
+		cfg:     cfg,
+		cmvn:    cfg.CMVN,
+		entries: make(map[CacheKey]*model.Tensor),
+	}
+	c.cfg.CMVN = CMVN{}
+	c.cfgHash = configHash(c.cfg)
+	return c
+}
+
+// OpenCache creates a feature cache backed by the file at path, loading the
+// entries it already holds. A missing file yields an empty cache.
+func OpenCache(path string, cfg Config) (*Cache, error) {
+	c := NewCache(cfg)
+	c.path = path
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feature cache: %w", err)
+	}
+	defer f.Close()
+
+	if err := c.read(bufio.NewReader(f)); err != nil {
+		return nil, fmt.Errorf("failed to read feature cache %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// configHash hashes every frontend setting that affects the extracted values.
+func configHash(cfg Config) [sha256.Size]byte {
+	meta := model.Metadata{}
+	cfg.WriteMetadata(meta)
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k + "=" + meta[k] + "\n")
+	}
+	return sha256.Sum256([]byte(sb.String()))
+}
+
+// Key returns the cache key of a window of length samples starting at offset in
+// the file whose content hash is source.
+func (c *Cache) Key(source string, offset, length int) CacheKey {
+	h := sha256.New()
+	h.Write(c.cfgHash[:])
+	fmt.Fprintf(h, "%s:%d:%d", source, offset, length)
+	var key CacheKey
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+// SetCMVN sets the normalization statistics applied to returned tensors.
+func (c *Cache) SetCMVN(cmvn CMVN) {
+	c.mu.Lock()
+	c.cmvn = cmvn
+	c.mu.Unlock()
+}
+
+// Extract returns the normalized features of samples, a window starting at offset
+// in the file with content hash source. Features are computed on the first request
+// and served from the cache afterwards. The returned tensor is owned by the caller.
+func (c *Cache) Extract(source string, offset int, samples []float32) *model.Tensor {
+	key := c.Key(source, offset, len(samples))
+
+	c.mu.RLock()
+	cached, ok := c.entries[key]
+	cmvn := c.cmvn
+	c.mu.RUnlock()
+
+	if !ok {
+		cached = ExtractWithConfig(samples, c.cfg)
+		if cached == nil {
+			return nil
+		}
+		c.mu.Lock()
+		c.entries[key] = cached
+		c.dirty = true
+		c.misses++
+		c.mu.Unlock()
+	} else {
+		c.mu.Lock()
+		c.hits++
+		c.mu.Unlock()
+	}
+
+	out := model.NewTensor(cached.Shape)
+	copy(out.Data, cached.Data)
+	cmvn.Apply(out)
+	return out
+}
+
+// Len returns the number of cached tensors.
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// Stats returns the number of lookups served from the cache and computed.
+func (c *Cache) Stats() (hits, misses int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hits, c.misses
+}
+
+// Save writes the cache to its file if it has new entries. In-memory caches are
+// not saved. The file is replaced atomically.
+func (c *Cache) Save() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.path == "" || !c.dirty {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create feature cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := c.write(w); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write feature cache: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write feature cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write feature cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to write feature cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// write serializes the entries: magic, version, count, then for each entry its key,
+// shape and float32 data (little endian). Entries are sorted by key.
+func (c *Cache) write(w io.Writer) error {
+	if _, err := w.Write([]byte(cacheMagic)); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, cacheVersion); err != nil {
+		return err
+	}
+	keys := make([]CacheKey, 0, len(c.entries))
+	for k := range c.entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return string(keys[i][:]) < string(keys[j][:]) })
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(keys))); err != nil {
+		return err
+	}
+
+	var buf []byte
+	for _, k := range keys {
+		t := c.entries[k]
+		if _, err := w.Write(k[:]); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(t.Shape))); err != nil {
+			return err
+		}
+		for _, d := range t.Shape {
+			if err := binary.Write(w, binary.LittleEndian, uint32(d)); err != nil {
+				return err
+			}
+		}
+		if cap(buf) < 4*len(t.Data) {
+			buf = make([]byte, 4*len(t.Data))
+		}
+		buf = buf[:4*len(t.Data)]
+		for i, v := range t.Data {
+			binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read loads entries written by write.
+func (c *Cache) read(r io.Reader) error {
+	magic := make([]byte, len(cacheMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return err
+	}
+	if string(magic) != cacheMagic {
+		return fmt.Errorf("invalid magic bytes: %s", string(magic))
+	}
+	var version uint16
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+	if version != cacheVersion {
+		return fmt.Errorf("unsupported feature cache version: %d", version)
+	}
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return err
+	}
+
+	var buf []byte
+	for i := uint32(0); i < count; i++ {
+		var key CacheKey
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return err
+		}
+		var ndims uint32
+		if err := binary.Read(r, binary.LittleEndian, &ndims); err != nil {
+			return err
+		}
+		shape := make([]int, ndims)
+		for j := range shape {
+			var d uint32
+			if err := binary.Read(r, binary.LittleEndian, &d); err != nil {
+				return err
+			}
+			shape[j] = int(d)
+		}
+		t := model.NewTensor(shape)
+		if cap(buf) < 4*len(t.Data) {
+			buf = make([]byte, 4*len(t.Data))
+		}
+		buf = buf[:4*len(t.Data)]
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return err
+		}
+		for j := range t.Data {
+			t.Data[j] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*j:]))
+		}
+		c.entries[key] = t
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package features
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	samples := make([]float32, 16000)
+	for i := range samples {
+		samples[i] = float32(math.Sin(2 * math.Pi * 440 * float64(i) / 16000))
+	}
+
+	cfg := DefaultConfig()
+	var acc CMVNAccumulator
+	acc.Add(ExtractWithConfig(samples, cfg))
+	cfg.CMVN = acc.CMVN()
+
+	t.Run("Matches Extraction", func(t *testing.T) {
+		cache := NewCache(cfg)
+		expected := ExtractWithConfig(samples, cfg)
+		for i := 0; i < 2; i++ {
+			got := cache.Extract("abc", 0, samples)
+			for j := range expected.Data {
+				if got.Data[j] != expected.Data[j] {
+					t.Fatalf("Lookup %d: mismatch at %d: %f vs %f", i, j, got.Data[j], expected.Data[j])
+				}
+			}
+			// Callers own the returned tensor
+			got.Data[0] = 1e9
+		}
+		if hits, misses := cache.Stats(); hits != 1 || misses != 1 {
+			t.Errorf("Expected 1 hit and 1 miss, got %d and %d", hits, misses)
+		}
+	})
+
+	t.Run("Normalization Applied On Lookup", func(t *testing.T) {
+		plain := cfg
+		plain.CMVN = CMVN{}
+		cache := NewCache(plain)
+		raw := cache.Extract("abc", 0, samples)
+
+		cache.SetCMVN(cfg.CMVN)
+		normalized := cache.Extract("abc", 0, samples)
+		expected := ExtractWithConfig(samples, cfg)
+		for j := range expected.Data {
+			if normalized.Data[j] != expected.Data[j] {
+				t.Fatalf("Mismatch at %d: %f vs %f", j, normalized.Data[j], expected.Data[j])
+			}
+		}
+		if raw.Data[0] == normalized.Data[0] {
+			t.Error("Expected statistics to change the returned features")
+		}
+		if cache.Len() != 1 {
+			t.Errorf("Expected a single entry, got %d", cache.Len())
+		}
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		cache := NewCache(cfg)
+		base := cache.Key("abc", 0, 16000)
+		if cache.Key("abd", 0, 16000) == base || cache.Key("abc", 8000, 16000) == base || cache.Key("abc", 0, 32000) == base {
+			t.Error("Expected source, offset and length to change the key")
+		}
+
+		other := cfg
+		other.NumMelFilters = 20
+		if NewCache(other).Key("abc", 0, 16000) == base {
+			t.Error("Expected the frontend configuration to change the key")
+		}
+
+		// Normalization statistics do not invalidate cached features
+		plain := cfg
+		plain.CMVN = CMVN{}
+		if NewCache(plain).Key("abc", 0, 16000) != base {
+			t.Error("Expected CMVN statistics not to change the key")
+		}
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		tmpDir, err := os.MkdirTemp("", "feature_cache")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tmpDir)
+		path := filepath.Join(tmpDir, "features.cache")
+
+		cache, err := OpenCache(path, cfg)
+		if err != nil {
+			t.Fatalf("OpenCache failed: %v", err)
+		}
+		first := cache.Extract("abc", 0, samples)
+		cache.Extract("abc", 256, samples[256:])
+		if err := cache.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		reopened, err := OpenCache(path, cfg)
+		if err != nil {
+			t.Fatalf("Reopen failed: %v", err)
+		}
+		if reopened.Len() != 2 {
+			t.Fatalf("Expected 2 entries, got %d", reopened.Len())
+		}
+		// Served from the file, not recomputed: even a different signal returns the cached features
+		got := reopened.Extract("abc", 0, make([]float32, len(samples)))
+		for j := range first.Data {
+			if got.Data[j] != first.Data[j] {
+				t.Fatalf("Mismatch at %d: %f vs %f", j, got.Data[j], first.Data[j])
+			}
+		}
+		if hits, misses := reopened.Stats(); hits != 1 || misses != 0 {
+			t.Errorf("Expected 1 hit and no misses, got %d and %d", hits, misses)
+		}
+
+		if err := os.WriteFile(path, []byte("junk"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenCache(path, cfg); err == nil {
+			t.Error("Expected error for a corrupt cache file")
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package features
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+// cmvnMinStd keeps normalization finite for coefficients that never vary.
+const cmvnMinStd = 1e-3
+
+// CMVN holds per-coefficient cepstral mean and variance normalization statistics.
+// Mean and Std are indexed by channel*NumCoefficients + coefficient. They are
+// computed over the training set and stored in the model, so every inference path
+// normalizes its input exactly like training did.
+type CMVN struct {
+	Mean []float32
+	Std  []float32
+}
+
+// Enabled reports whether normalization statistics are present.
+func (c CMVN) Enabled() bool {
+	return len(c.Mean) > 0
+}
+
+// Apply normalizes a [channels, frames, coefficients] tensor in place.
+func (c CMVN) Apply(t *model.Tensor) {
+	if !c.Enabled() {
+		return
+	}
+	numChannels, numFrames, numCoeffs := t.Shape[0], t.Shape[1], t.Shape[2]
+	for ch := 0; ch < numChannels; ch++ {
+		for i := 0; i < numFrames; i++ {
+			row := t.Data[(ch*numFrames+i)*numCoeffs : (ch*numFrames+i+1)*numCoeffs]
+			for d := range row {
+				k := ch*numCoeffs + d
+				row[d] = (row[d] - c.Mean[k]) / c.Std[k]
+			}
+		}
+	}
+}
+
+// CMVNAccumulator collects feature statistics over many tensors.
+type CMVNAccumulator struct {
+	sum   []float64
+	sumSq []float64
+	count int
+}
+
+// Add accumulates every frame of a [channels, frames, coefficients] tensor.
+func (a *CMVNAccumulator) Add(t *model.Tensor) {
+	numChannels, numFrames, numCoeffs := t.Shape[0], t.Shape[1], t.Shape[2]
+	if a.sum == nil {
+		a.sum = make([]float64, numChannels*numCoeffs)
+		a.sumSq = make([]float64, numChannels*numCoeffs)
+	}
+	for ch := 0; ch < numChannels; ch++ {
+		for i := 0; i < numFrames; i++ {
+			row := t.Data[(ch*numFrames+i)*numCoeffs : (ch*numFrames+i+1)*numCoeffs]
+			for d, v := range row {
+				k := ch*numCoeffs + d
+				a.sum[k] += float64(v)
+				a.sumSq[k] += float64(v) * float64(v)
+			}
+		}
+	}
+	a.count += numFrames
+}
+
+// CMVN returns the mean and standard deviation of everything added so far.
+func (a *CMVNAccumulator) CMVN() CMVN {
+	if a.count == 0 {
+		return CMVN{}
+	}
+	c := CMVN{
+		Mean: make([]float32, len(a.sum)),
+		Std:  make([]float32, len(a.sum)),
+	}
+	n := float64(a.count)
+	for k := range a.sum {
+		mean := a.sum[k] / n
+		variance := a.sumSq[k]/n - mean*mean
+		c.Mean[k] = float32(mean)
+		c.Std[k] = float32(math.Max(math.Sqrt(math.Max(variance, 0)), cmvnMinStd))
+	}
+	return c
+}
+
+// AdaptiveCMVN slowly adapts the mean of the static coefficients to the live input,
+// starting from the training statistics. This compensates for microphones whose
+// response shifts the features away from the training distribution. Delta channels
+// are insensitive to a constant offset and keep their trained statistics.
+type AdaptiveCMVN struct {
+	cfg  Config
+	rate float32
+	mean []float32
+}
+
+// NewAdaptiveCMVN creates an adaptive normalizer. rate is the per-frame update
+// weight of the running mean (e.g. 0.001 adapts over a few hundred frames).
+func NewAdaptiveCMVN(cfg Config, rate float32) (*AdaptiveCMVN, error) {
+	if !cfg.CMVN.Enabled() {
+		return nil, fmt.Errorf("model has no normalization statistics")
+	}
+	if rate <= 0 || rate >= 1 {
+		return nil, fmt.Errorf("adaptation rate must be in (0, 1), got %f", rate)
+	}
+	a := &AdaptiveCMVN{cfg: cfg, rate: rate}
+	a.Reset()
+	return a, nil
+}
+
+// Update adapts the running mean to compressed Mel frames as returned by Streamer.Push.
+func (a *AdaptiveCMVN) Update(frames [][]float32) {
+	if len(frames) == 0 {
+		return
+	}
+	if a.cfg.Type == TypeMFCC {
+		frames = MFCC(frames, a.cfg.NumCepstra, a.cfg.Lifter)
+	}
+	for _, frame := range frames {
+		for d, v := range frame {
+			a.mean[d] += a.rate * (v - a.mean[d])
+		}
+	}
+}
+
+// Config returns the frontend configuration with the adapted mean.
+func (a *AdaptiveCMVN) Config() Config {
+	cfg := a.cfg
+	mean := make([]float32, len(cfg.CMVN.Mean))
+	copy(mean, cfg.CMVN.Mean)
+	copy(mean, a.mean)
+	cfg.CMVN = CMVN{Mean: mean, Std: cfg.CMVN.Std}
+	return cfg
+}
+
+// Reset returns the running mean to the training statistics.
+func (a *AdaptiveCMVN) Reset() {
+	n := a.cfg.NumCoefficients()
+	a.mean = make([]float32, n)
+	copy(a.mean, a.cfg.CMVN.Mean[:n])
+}
+
+// Metadata keys used to persist CMVN in model files.
+const (
+	metaCMVNMean = "features.cmvn.mean"
+	metaCMVNStd  = "features.cmvn.std"
+)
+
+// WriteMetadata stores the statistics in the model metadata.
+func (c CMVN) WriteMetadata(meta model.Metadata) {
+	if !c.Enabled() {
+		return
+	}
+	meta[metaCMVNMean] = formatFloats(c.Mean)
+	meta[metaCMVNStd] = formatFloats(c.Std)
+}
+
+// CMVNFromMetadata reads the statistics from model metadata. Models without
+// statistics return a disabled CMVN.
+func CMVNFromMetadata(meta model.Metadata) (CMVN, error) {
+	var c CMVN
+	var err error
+	if v, ok := meta[metaCMVNMean]; ok {
+		if c.Mean, err = parseFloats(metaCMVNMean, v); err != nil {
+			return c, err
+		}
+	}
+	if v, ok := meta[metaCMVNStd]; ok {
+		if c.Std, err = parseFloats(metaCMVNStd, v); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+func formatFloats(values []float32) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = formatFloat(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseFloats(key, s string) ([]float32, error) {
+	parts := strings.Split(s, ",")
+	values := make([]float32, len(parts))
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(p, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		values[i] = float32(f)
+	}
+	return values, nil
+}
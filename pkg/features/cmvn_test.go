@@ -0,0 +1,103 @@
+package features
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+func TestCMVN(t *testing.T) {
+	t.Run("Statistics", func(t *testing.T) {
+		// Two channels, three coefficients
+		a := model.NewTensor([]int{2, 2, 3})
+		copy(a.Data, []float32{1, 2, 3, 3, 2, 1, 0, 0, 0, 2, 2, 2})
+		var acc CMVNAccumulator
+		acc.Add(a)
+		stats := acc.CMVN()
+
+		expectedMean := []float32{2, 2, 2, 1, 1, 1}
+		expectedStd := []float32{1, cmvnMinStd, 1, 1, 1, 1}
+		for k := range expectedMean {
+			if math.Abs(float64(stats.Mean[k]-expectedMean[k])) > 1e-6 {
+				t.Errorf("Mean[%d]: expected %f, got %f", k, expectedMean[k], stats.Mean[k])
+			}
+			if math.Abs(float64(stats.Std[k]-expectedStd[k])) > 1e-6 {
+				t.Errorf("Std[%d]: expected %f, got %f", k, expectedStd[k], stats.Std[k])
+			}
+		}
+
+		stats.Apply(a)
+		if a.Data[0] != -1 || a.Data[2] != 1 || a.Data[1] != 0 || a.Data[6] != -1 {
+			t.Errorf("Unexpected normalized values %v", a.Data)
+		}
+	})
+
+	t.Run("Applied By Extraction", func(t *testing.T) {
+		samples := make([]float32, 16000)
+		for i := range samples {
+			samples[i] = 0.1 * float32(math.Sin(2*math.Pi*700*float64(i)/16000))
+		}
+		cfg := DefaultConfig()
+		var acc CMVNAccumulator
+		acc.Add(ExtractWithConfig(samples, cfg))
+		cfg.CMVN = acc.CMVN()
+
+		normalized := ExtractWithConfig(samples, cfg)
+		var acc2 CMVNAccumulator
+		acc2.Add(normalized)
+		for k, m := range acc2.CMVN().Mean {
+			if math.Abs(float64(m)) > 1e-3 {
+				t.Errorf("Expected zero mean after normalization at %d, got %f", k, m)
+			}
+		}
+	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.NumMelFilters = 3
+		cfg.CMVN = CMVN{Mean: []float32{0.5, -1.25, 3}, Std: []float32{1, 0.1, 2.5}}
+		meta := model.Metadata{}
+		cfg.WriteMetadata(meta)
+		loaded, err := ConfigFromMetadata(meta)
+		if err != nil {
+			t.Fatalf("ConfigFromMetadata failed: %v", err)
+		}
+		if !reflect.DeepEqual(loaded.CMVN, cfg.CMVN) {
+			t.Errorf("Expected %+v, got %+v", cfg.CMVN, loaded.CMVN)
+		}
+
+		cfg.NumMelFilters = 4
+		if cfg.Validate() == nil {
+			t.Error("Expected validation error for mismatched statistics size")
+		}
+	})
+
+	t.Run("Adaptive Mean", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.NumMelFilters = 2
+		cfg.CMVN = CMVN{Mean: []float32{0, 0}, Std: []float32{1, 1}}
+		if _, err := NewAdaptiveCMVN(DefaultConfig(), 0.01); err == nil {
+			t.Error("Expected error without statistics")
+		}
+
+		a, err := NewAdaptiveCMVN(cfg, 0.01)
+		if err != nil {
+			t.Fatalf("NewAdaptiveCMVN failed: %v", err)
+		}
+		// A microphone with a constant +1 offset
+		frames := make([][]float32, 1000)
+		for i := range frames {
+			frames[i] = []float32{1, 1}
+		}
+		a.Update(frames)
+		adapted := a.Config().CMVN
+		if adapted.Mean[0] < 0.99 || adapted.Mean[1] < 0.99 {
+			t.Errorf("Expected mean to adapt towards 1, got %v", adapted.Mean)
+		}
+		if cfg.CMVN.Mean[0] != 0 {
+			t.Error("Adaptation must not modify the trained statistics")
+		}
+	})
+}
@@ -0,0 +1,363 @@
+package features
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+// Log scaling modes applied to the Mel energies.
+const (
+	LogScaleLog1p = "log1p" // log(1 + gain*x)
+	LogScaleLog   = "log"   // log(x + 1e-6)
+	LogScaleNone  = "none"  // linear energies
+	LogScalePCEN  = "pcen"  // per-channel energy normalization (see PCENConfig)
+)
+
+// Config describes the feature frontend. A model only works with the frontend it
+// was trained with, so the configuration is stored in the model file (see SaveModel).
+type Config struct {
+	// Type selects the features: TypeMel (log-Mel spectrogram) or TypeMFCC.
+	Type          string  `mapstructure:"type"`
+	SampleRate    int     `mapstructure:"sample_rate"`
+	WindowSize    int     `mapstructure:"window_size"` // FFT and analysis window size, a power of 2
+	HopSize       int     `mapstructure:"hop_size"`
+	NumMelFilters int     `mapstructure:"mel_bins"`
+	MinFreq       float64 `mapstructure:"fmin"`
+	MaxFreq       float64 `mapstructure:"fmax"`         // 0 means Nyquist
+	PreEmphasis   float32 `mapstructure:"pre_emphasis"` // 0 disables pre-emphasis
+	LogScale      string  `mapstructure:"log_scale"`
+	LogGain       float32 `mapstructure:"log_gain"` // Gain inside log1p scaling
+
+	PCEN PCENConfig `mapstructure:"pcen"` // Used when LogScale is LogScalePCEN
+
+	// MFCC settings (TypeMFCC only)
+	NumCepstra int `mapstructure:"num_cepstra"`
+	Lifter     int `mapstructure:"lifter"` // 0 disables liftering
+
+	// Deltas stacks delta (1) or delta and delta-delta (2) coefficients as extra channels.
+	Deltas     int `mapstructure:"deltas"`
+	DeltaWidth int `mapstructure:"delta_width"` // Regression window in frames on each side
+
+	Noise NoiseConfig `mapstructure:"noise"`
+
+	// CMVN holds normalization statistics computed at training time.
+	CMVN CMVN `mapstructure:"-"`
+}
+
+// DefaultConfig returns the frontend used by models that predate Config:
+// 16kHz, 512-point window, 256 hop, 40 Mel bins up to Nyquist, pre-emphasis 0.97
+// and log(1 + 1000x) compression.
+func DefaultConfig() Config {
+	return Config{
+		Type:          TypeMel,
+		SampleRate:    16000,
+		WindowSize:    512,
+		HopSize:       256,
+		NumMelFilters: 40,
+		MinFreq:       0,
+		MaxFreq:       0,
+		PreEmphasis:   0.97,
+		LogScale:      LogScaleLog1p,
+		LogGain:       1000,
+		PCEN:          DefaultPCENConfig(),
+		NumCepstra:    13,
+		Lifter:        22,
+		Deltas:        0,
+		DeltaWidth:    2,
+	}
+}
+
+// maxFreq returns the upper edge of the Mel filterbank in Hz.
+func (c Config) maxFreq() float64 {
+	if c.MaxFreq <= 0 {
+		return float64(c.SampleRate / 2)
+	}
+	return c.MaxFreq
+}
+
+// NumCoefficients returns the number of coefficients per frame.
+func (c Config) NumCoefficients() int {
+	if c.Type == TypeMFCC {
+		return c.NumCepstra
+	}
+	return c.NumMelFilters
+}
+
+// NumChannels returns the number of feature channels (static plus deltas).
+func (c Config) NumChannels() int {
+	return 1 + c.Deltas
+}
+
+// NumFrames returns the number of feature frames produced for numSamples samples.
+func (c Config) NumFrames(numSamples int) int {
+	if numSamples < c.WindowSize {
+		return 0
+	}
+	return (numSamples-c.WindowSize)/c.HopSize + 1
+}
+
+// FrameMask returns the frame mask (see model.MaskedLayer) of the features of
+// numSamples samples of which only the first actualLen hold audio and the rest
+// is zero padding. A frame holds audio if its window starts before actualLen.
+func (c Config) FrameMask(actualLen, numSamples int) []bool {
+	valid := 0
+	if actualLen > 0 {
+		valid = (actualLen-1)/c.HopSize + 1
+	}
+	return model.PrefixMask(c.NumFrames(numSamples), valid)
+}
+
+// Stateful reports whether the frontend carries state from frame to frame (the
+// PCEN smoother or the noise estimate of noise suppression), in which case a
+// streaming consumer should use a Streamer instead of re-extracting windows.
+func (c Config) Stateful() bool {
+	return c.LogScale == LogScalePCEN || c.Noise.Enabled()
+}
+
+// Validate checks that the configuration describes a usable frontend.
+func (c Config) Validate() error {
+	if c.SampleRate <= 0 {
+		return fmt.Errorf("sample rate must be positive, got %d", c.SampleRate)
+	}
+	if c.WindowSize <= 0 || c.WindowSize&(c.WindowSize-1) != 0 {
+		return fmt.Errorf("window size must be a power of 2, got %d", c.WindowSize)
+	}
+	if c.HopSize <= 0 {
+		return fmt.Errorf("hop size must be positive, got %d", c.HopSize)
+	}
+	if c.NumMelFilters <= 0 {
+		return fmt.Errorf("number of mel bins must be positive, got %d", c.NumMelFilters)
+	}
+	if c.MinFreq < 0 || c.MinFreq >= c.maxFreq() {
+		return fmt.Errorf("invalid frequency range %.0f-%.0f Hz", c.MinFreq, c.maxFreq())
+	}
+	if c.maxFreq() > float64(c.SampleRate)/2 {
+		return fmt.Errorf("fmax %.0f Hz exceeds Nyquist frequency %d Hz", c.MaxFreq, c.SampleRate/2)
+	}
+	if c.PreEmphasis < 0 || c.PreEmphasis >= 1 {
+		return fmt.Errorf("pre-emphasis must be in [0, 1), got %f", c.PreEmphasis)
+	}
+	switch c.LogScale {
+	case LogScaleLog1p, LogScaleLog, LogScaleNone:
+	case LogScalePCEN:
+		if err := c.PCEN.Validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown log scaling %q", c.LogScale)
+	}
+	switch c.Type {
+	case TypeMel:
+	case TypeMFCC:
+		if c.NumCepstra <= 0 || c.NumCepstra > c.NumMelFilters {
+			return fmt.Errorf("number of cepstra must be in [1, %d], got %d", c.NumMelFilters, c.NumCepstra)
+		}
+		if c.Lifter < 0 {
+			return fmt.Errorf("lifter must not be negative, got %d", c.Lifter)
+		}
+	default:
+		return fmt.Errorf("unknown feature type %q", c.Type)
+	}
+	if c.Deltas < 0 || c.Deltas > 2 {
+		return fmt.Errorf("deltas must be 0, 1 or 2, got %d", c.Deltas)
+	}
+	if c.Deltas > 0 && c.DeltaWidth <= 0 {
+		return fmt.Errorf("delta width must be positive, got %d", c.DeltaWidth)
+	}
+	if c.CMVN.Enabled() {
+		size := c.NumChannels() * c.NumCoefficients()
+		if len(c.CMVN.Mean) != size || len(c.CMVN.Std) != size {
+			return fmt.Errorf("normalization statistics have %d/%d values, expected %d", len(c.CMVN.Mean), len(c.CMVN.Std), size)
+		}
+		for _, std := range c.CMVN.Std {
+			if std <= 0 {
+				return fmt.Errorf("normalization standard deviation must be positive")
+			}
+		}
+	}
+	return c.Noise.Validate()
+}
+
+// String returns a short human readable description of the frontend.
+func (c Config) String() string {
+	s := fmt.Sprintf("%dHz, window %d, hop %d, %d mel bins (%.0f-%.0f Hz), %s",
+		c.SampleRate, c.WindowSize, c.HopSize, c.NumMelFilters, c.MinFreq, c.maxFreq(), c.LogScale)
+	if c.Type == TypeMFCC {
+		s += fmt.Sprintf(", %d MFCCs (lifter %d)", c.NumCepstra, c.Lifter)
+	}
+	if c.Deltas > 0 {
+		s += fmt.Sprintf(", %d delta channel(s)", c.Deltas)
+	}
+	if c.Noise.Enabled() {
+		s += ", " + c.Noise.Method + " noise suppression"
+	}
+	if c.CMVN.Enabled() {
+		s += ", CMVN"
+	}
+	return s
+}
+
+// Metadata keys used to persist Config in model files.
+const (
+	metaType          = "features.type"
+	metaSampleRate    = "features.sample_rate"
+	metaWindowSize    = "features.window_size"
+	metaHopSize       = "features.hop_size"
+	metaNumMelFilters = "features.mel_bins"
+	metaMinFreq       = "features.fmin"
+	metaMaxFreq       = "features.fmax"
+	metaPreEmphasis   = "features.pre_emphasis"
+	metaLogScale      = "features.log_scale"
+	metaLogGain       = "features.log_gain"
+	metaNumCepstra    = "features.num_cepstra"
+	metaLifter        = "features.lifter"
+	metaDeltas        = "features.deltas"
+	metaDeltaWidth    = "features.delta_width"
+)
+
+// WriteMetadata stores the frontend configuration in the model metadata.
+func (c Config) WriteMetadata(meta model.Metadata) {
+	meta[metaType] = c.Type
+	meta[metaSampleRate] = strconv.Itoa(c.SampleRate)
+	meta[metaWindowSize] = strconv.Itoa(c.WindowSize)
+	meta[metaHopSize] = strconv.Itoa(c.HopSize)
+	meta[metaNumMelFilters] = strconv.Itoa(c.NumMelFilters)
+	meta[metaMinFreq] = strconv.FormatFloat(c.MinFreq, 'g', -1, 64)
+	meta[metaMaxFreq] = strconv.FormatFloat(c.MaxFreq, 'g', -1, 64)
+	meta[metaPreEmphasis] = formatFloat(c.PreEmphasis)
+	meta[metaLogScale] = c.LogScale
+	meta[metaLogGain] = formatFloat(c.LogGain)
+	if c.LogScale == LogScalePCEN {
+		c.PCEN.WriteMetadata(meta)
+	}
+	meta[metaNumCepstra] = strconv.Itoa(c.NumCepstra)
+	meta[metaLifter] = strconv.Itoa(c.Lifter)
+	meta[metaDeltas] = strconv.Itoa(c.Deltas)
+	meta[metaDeltaWidth] = strconv.Itoa(c.DeltaWidth)
+	c.Noise.WriteMetadata(meta)
+	c.CMVN.WriteMetadata(meta)
+}
+
+// ConfigFromMetadata reconstructs the frontend configuration from model metadata.
+// Missing keys fall back to DefaultConfig, so models saved before the frontend
+// was recorded load with the settings they were trained with.
+func ConfigFromMetadata(meta model.Metadata) (Config, error) {
+	c := DefaultConfig()
+
+	if v, ok := meta[metaType]; ok {
+		c.Type = v
+	}
+	var err error
+	if c.SampleRate, err = parseInt(meta, metaSampleRate, c.SampleRate); err != nil {
+		return c, err
+	}
+	if c.WindowSize, err = parseInt(meta, metaWindowSize, c.WindowSize); err != nil {
+		return c, err
+	}
+	if c.HopSize, err = parseInt(meta, metaHopSize, c.HopSize); err != nil {
+		return c, err
+	}
+	if c.NumMelFilters, err = parseInt(meta, metaNumMelFilters, c.NumMelFilters); err != nil {
+		return c, err
+	}
+	if c.MinFreq, err = parseFloat64(meta, metaMinFreq, c.MinFreq); err != nil {
+		return c, err
+	}
+	if c.MaxFreq, err = parseFloat64(meta, metaMaxFreq, c.MaxFreq); err != nil {
+		return c, err
+	}
+	if c.PreEmphasis, err = parseFloat(meta, metaPreEmphasis, c.PreEmphasis); err != nil {
+		return c, err
+	}
+	if v, ok := meta[metaLogScale]; ok {
+		c.LogScale = v
+	}
+	if c.LogGain, err = parseFloat(meta, metaLogGain, c.LogGain); err != nil {
+		return c, err
+	}
+	if c.PCEN, err = PCENConfigFromMetadata(meta); err != nil {
+		return c, err
+	}
+	if c.NumCepstra, err = parseInt(meta, metaNumCepstra, c.NumCepstra); err != nil {
+		return c, err
+	}
+	if c.Lifter, err = parseInt(meta, metaLifter, c.Lifter); err != nil {
+		return c, err
+	}
+	if c.Deltas, err = parseInt(meta, metaDeltas, c.Deltas); err != nil {
+		return c, err
+	}
+	if c.DeltaWidth, err = parseInt(meta, metaDeltaWidth, c.DeltaWidth); err != nil {
+		return c, err
+	}
+	if c.Noise, err = NoiseConfigFromMetadata(meta); err != nil {
+		return c, err
+	}
+	if c.CMVN, err = CMVNFromMetadata(meta); err != nil {
+		return c, err
+	}
+	return c, c.Validate()
+}
+
+// parseInt reads an integer metadata value, returning def when the key is absent.
+func parseInt(meta model.Metadata, key string, def int) (int, error) {
+	v, ok := meta[key]
+	if !ok {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return i, nil
+}
+
+// parseFloat64 reads a float64 metadata value, returning def when the key is absent.
+func parseFloat64(meta model.Metadata, key string, def float64) (float64, error) {
+	v, ok := meta[key]
+	if !ok {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// SaveModel saves a model together with the frontend configuration it was trained with.
+func SaveModel(path string, m model.Model, cfg Config) error {
+	return SaveModelWithMetadata(path, m, cfg, nil)
+}
+
+// SaveModelWithMetadata is SaveModel with additional metadata, such as the
+// class names of a multi-class model.
+func SaveModelWithMetadata(path string, m model.Model, cfg Config, meta model.Metadata) error {
+	all := model.Metadata{}
+	for k, v := range meta {
+		all[k] = v
+	}
+	cfg.WriteMetadata(all)
+	return model.SaveModelWithMetadata(path, m, all)
+}
+
+// LoadModel loads a model and the frontend configuration needed to feed it.
+func LoadModel(path string) (model.Model, Config, error) {
+	m, cfg, _, err := LoadModelWithMetadata(path)
+	return m, cfg, err
+}
+
+// LoadModelWithMetadata is LoadModel that also returns the model metadata.
+func LoadModelWithMetadata(path string) (model.Model, Config, model.Metadata, error) {
+	m, meta, err := model.LoadModelWithMetadata(path)
+	if err != nil {
+		return nil, Config{}, nil, err
+	}
+	cfg, err := ConfigFromMetadata(meta)
+	if err != nil {
+		return nil, cfg, nil, fmt.Errorf("invalid feature configuration in model: %w", err)
+	}
+	return m, cfg, meta, nil
+}
@@ -0,0 +1,161 @@
+package features
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("Default Matches Legacy Extract", func(t *testing.T) {
+		samples := make([]float32, 16000)
+		for i := range samples {
+			samples[i] = float32(math.Sin(2 * math.Pi * 440 * float64(i) / 16000))
+		}
+		legacy := Extract(samples, 16000, 512, 256, 40)
+		configured := ExtractWithConfig(samples, DefaultConfig())
+		for i := range legacy.Data {
+			if legacy.Data[i] != configured.Data[i] {
+				t.Fatalf("Mismatch at %d: %f vs %f", i, legacy.Data[i], configured.Data[i])
+			}
+		}
+	})
+
+	t.Run("Custom Frontend Shape", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.SampleRate = 8000
+		cfg.WindowSize = 256
+		cfg.HopSize = 80
+		cfg.NumMelFilters = 24
+		cfg.MinFreq = 100
+		cfg.MaxFreq = 3800
+
+		tensor := ExtractWithConfig(make([]float32, 8000), cfg)
+		frames := cfg.NumFrames(8000)
+		if frames != (8000-256)/80+1 {
+			t.Errorf("Unexpected frame count %d", frames)
+		}
+		if tensor.Shape[1] != frames || tensor.Shape[2] != 24 {
+			t.Errorf("Expected shape [1, %d, 24], got %v", frames, tensor.Shape)
+		}
+	})
+
+	t.Run("Frame Mask", func(t *testing.T) {
+		cfg := DefaultConfig()
+		// 16000 samples give 61 frames; the 24th window starts at 5888
+		mask := cfg.FrameMask(6000, 16000)
+		if !reflect.DeepEqual(mask, model.PrefixMask(61, 24)) {
+			t.Errorf("Expected 24 of 61 frames, got %v", mask)
+		}
+		if mask := cfg.FrameMask(0, 16000); len(mask) != 61 || mask[0] {
+			t.Errorf("Expected no valid frames without audio, got %v", mask)
+		}
+	})
+
+	t.Run("Log Scaling", func(t *testing.T) {
+		cfg := DefaultConfig()
+		if got := cfg.compress(1); math.Abs(float64(got)-math.Log1p(1000)) > 1e-5 {
+			t.Errorf("log1p: got %f", got)
+		}
+		cfg.LogScale = LogScaleLog
+		if got := cfg.compress(1); math.Abs(float64(got)) > 1e-5 {
+			t.Errorf("log: got %f", got)
+		}
+		cfg.LogScale = LogScaleNone
+		if got := cfg.compress(2); got != 2 {
+			t.Errorf("none: got %f", got)
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		bad := []func(*Config){
+			func(c *Config) { c.WindowSize = 500 },
+			func(c *Config) { c.HopSize = 0 },
+			func(c *Config) { c.MaxFreq = 9000 },
+			func(c *Config) { c.MinFreq = 8000 },
+			func(c *Config) { c.PreEmphasis = 1 },
+			func(c *Config) { c.LogScale = "sqrt" },
+			func(c *Config) { c.Noise.Method = "bogus" },
+			func(c *Config) { c.Type = "plp" },
+			func(c *Config) { c.Type = TypeMFCC; c.NumCepstra = 41 },
+			func(c *Config) { c.Deltas = 3 },
+			func(c *Config) { c.Deltas = 1; c.DeltaWidth = 0 },
+		}
+		for i, mutate := range bad {
+			cfg := DefaultConfig()
+			mutate(&cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Errorf("Case %d: expected validation error for %+v", i, cfg)
+			}
+		}
+		if err := DefaultConfig().Validate(); err != nil {
+			t.Errorf("Default config should be valid: %v", err)
+		}
+	})
+}
+
+func TestConfigMetadata(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SampleRate = 8000
+	cfg.WindowSize = 256
+	cfg.HopSize = 128
+	cfg.NumMelFilters = 32
+	cfg.MinFreq = 60
+	cfg.MaxFreq = 3800
+	cfg.PreEmphasis = 0
+	cfg.LogScale = LogScaleLog
+	cfg.Type = TypeMFCC
+	cfg.NumCepstra = 10
+	cfg.Lifter = 0
+	cfg.Deltas = 1
+	cfg.DeltaWidth = 3
+	cfg.Noise = DefaultNoiseConfig("spectral")
+
+	meta := model.Metadata{}
+	cfg.WriteMetadata(meta)
+	loaded, err := ConfigFromMetadata(meta)
+	if err != nil {
+		t.Fatalf("ConfigFromMetadata failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, cfg) {
+		t.Errorf("Round trip mismatch:\nexpected %+v\ngot      %+v", cfg, loaded)
+	}
+
+	// Models saved before the frontend was recorded use the legacy frontend
+	legacy, err := ConfigFromMetadata(model.Metadata{})
+	if err != nil || !reflect.DeepEqual(legacy, DefaultConfig()) {
+		t.Errorf("Expected default config for empty metadata, got %+v (err=%v)", legacy, err)
+	}
+
+	if _, err := ConfigFromMetadata(model.Metadata{metaHopSize: "abc"}); err == nil {
+		t.Error("Expected error for malformed hop size")
+	}
+}
+
+func TestSaveLoadModel(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "features_model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cfg := DefaultConfig()
+	cfg.NumMelFilters = 20
+	m := model.NewSequentialModel(model.NewSigmoidLayer())
+
+	path := filepath.Join(tmpDir, "model.bin")
+	if err := SaveModel(path, m, cfg); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+	_, loaded, err := LoadModel(path)
+	if err != nil {
+		t.Fatalf("LoadModel failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, cfg) {
+		t.Errorf("Expected %+v, got %+v", cfg, loaded)
+	}
+}
@@ -1,39 +1,77 @@
 package features
 
 import (
+	"fmt"
 	"math"
+	"sync"
 
-	"github.com/tomkiv/hotword/pkg/audio"
 	"github.com/tomkiv/hotword/pkg/model"
 )
 
-// Extract converts raw audio samples into a flattened Mel-Spectrogram feature tensor.
+// Extract converts raw audio samples into a flattened Mel-Spectrogram feature tensor
+// using the default frontend with the given framing parameters.
 func Extract(samples []float32, sampleRate, windowSize, hopSize, numMelFilters int) *model.Tensor {
-	// 0. Pre-emphasis
-	// Apply pre-emphasis to filter out low-frequency noise (DC offset, hum)
-	// and flatten the spectral tilt.
-	samples = audio.PreEmphasis(samples, 0.97)
+	cfg := DefaultConfig()
+	cfg.SampleRate = sampleRate
+	cfg.WindowSize = windowSize
+	cfg.HopSize = hopSize
+	cfg.NumMelFilters = numMelFilters
+	return ExtractWithConfig(samples, cfg)
+}
 
-	// 1. STFT
-	stft := audio.STFT(samples, windowSize, hopSize)
-	if len(stft) == 0 {
+// ExtractWithConfig converts raw audio samples into a feature tensor of shape
+// [NumChannels, numFrames, NumCoefficients] using the given frontend configuration.
+// Every call starts from a fresh state (noise estimate, PCEN smoother) so training
+// and single-shot inference see identical processing.
+func ExtractWithConfig(samples []float32, cfg Config) *model.Tensor {
+	s, pool, err := acquireStreamer(cfg)
+	if err != nil {
 		return nil
 	}
+	frames := s.Push(samples)
+	s.Reset()
+	pool.Put(s)
+	if len(frames) == 0 {
+		return nil
+	}
+	return cfg.Tensor(frames)
+}
 
-	// 2. Mel-Spectrogram
-	melSpec := audio.MelSpectrogram(stft, numMelFilters, windowSize, sampleRate, 0, float64(sampleRate/2))
-
-	// 3. Reshape into 3D Tensor [1, numFrames, numMelFilters] and apply Log-scaling
-	numFrames := len(melSpec)
-	tensor := model.NewTensor([]int{1, numFrames, numMelFilters})
+// streamerPools holds idle streamers per frontend configuration, so repeated
+// extraction reuses their STFT buffers and filterbank. Streamers do not use
+// the normalization statistics, which are left out of the key.
+var streamerPools sync.Map // formatted Config -> *sync.Pool
 
-	for i := 0; i < numFrames; i++ {
-		for j := 0; j < numMelFilters; j++ {
-			// Apply Log-scaling: log(1 + 1000*x)
-			val := melSpec[i][j]
-			tensor.Set([]int{0, i, j}, float32(math.Log1p(float64(val)*1000.0)))
-		}
+// acquireStreamer returns a streamer in its initial state for cfg and the pool
+// to return it to.
+func acquireStreamer(cfg Config) (*Streamer, *sync.Pool, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
 	}
+	frontend := cfg
+	frontend.CMVN = CMVN{}
+	key := fmt.Sprintf("%+v", frontend)
+	p, ok := streamerPools.Load(key)
+	if !ok {
+		p, _ = streamerPools.LoadOrStore(key, &sync.Pool{})
+	}
+	pool := p.(*sync.Pool)
+	if s, ok := pool.Get().(*Streamer); ok {
+		return s, pool, nil
+	}
+	s, err := NewStreamer(frontend)
+	return s, pool, err
+}
 
-	return tensor
+// compress applies the configured log scaling to a Mel energy.
+func (c Config) compress(val float32) float32 {
+	switch c.LogScale {
+	case LogScaleLog:
+		return float32(math.Log(float64(val) + 1e-6))
+	case LogScaleNone:
+		return val
+	default:
+		// log(1 + gain*x)
+		return float32(math.Log1p(float64(val) * float64(c.LogGain)))
+	}
 }
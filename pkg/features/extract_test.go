@@ -1,6 +1,7 @@
 package features
 
 import (
+	"math"
 	"testing"
 )
 
@@ -34,3 +35,40 @@ func TestExtract(t *testing.T) {
 		t.Errorf("Expected shape [1, %d, %d], got %v", expectedFrames, numMelFilters, tensor.Shape)
 	}
 }
+
+func TestExtractReusesFrontend(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LogScale = LogScalePCEN
+	cfg.Noise = DefaultNoiseConfig("wiener")
+
+	samples := make([]float32, 8000)
+	loud := make([]float32, 16000)
+	for i := range samples {
+		samples[i] = 0.1 * float32(math.Sin(2*math.Pi*300*float64(i)/16000))
+	}
+	for i := range loud {
+		loud[i] = 0.8 * float32(math.Sin(2*math.Pi*1200*float64(i)/16000))
+	}
+
+	s, _ := NewStreamer(cfg)
+	expected := cfg.Tensor(s.Push(samples))
+
+	// A pooled frontend that processed other audio starts from a fresh state
+	ExtractWithConfig(loud, cfg)
+	got := ExtractWithConfig(samples, cfg)
+	if len(got.Data) != len(expected.Data) {
+		t.Fatalf("Expected shape %v, got %v", expected.Shape, got.Shape)
+	}
+	for i := range got.Data {
+		if got.Data[i] != expected.Data[i] {
+			t.Fatalf("Mismatch at %d: expected %f, got %f", i, expected.Data[i], got.Data[i])
+		}
+	}
+
+	if allocs, fresh := testing.AllocsPerRun(10, func() { ExtractWithConfig(samples, cfg) }), testing.AllocsPerRun(10, func() {
+		s, _ := NewStreamer(cfg)
+		cfg.Tensor(s.Push(samples))
+	}); allocs >= fresh {
+		t.Errorf("Expected fewer allocations than a new frontend: %.0f vs %.0f", allocs, fresh)
+	}
+}
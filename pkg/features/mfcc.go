@@ -0,0 +1,111 @@
+package features
+
+import "math"
+
+// Feature types produced by the frontend.
+const (
+	TypeMel  = "mel"  // Log-Mel spectrogram
+	TypeMFCC = "mfcc" // Mel-frequency cepstral coefficients
+)
+
+// dctMatrix returns the first numCoeffs rows of the orthonormal DCT-II basis for
+// inputs of length n, so that y[k] = sum_i basis[k][i] * x[i].
+func dctMatrix(numCoeffs, n int) [][]float32 {
+	basis := make([][]float32, numCoeffs)
+	for k := range basis {
+		scale := math.Sqrt(2.0 / float64(n))
+		if k == 0 {
+			scale = math.Sqrt(1.0 / float64(n))
+		}
+		row := make([]float32, n)
+		for i := range row {
+			row[i] = float32(scale * math.Cos(math.Pi*float64(k)*float64(2*i+1)/float64(2*n)))
+		}
+		basis[k] = row
+	}
+	return basis
+}
+
+// DCT returns the first numCoeffs coefficients of the orthonormal DCT-II of x
+// (the same normalization as scipy's dct(type=2, norm="ortho")).
+func DCT(x []float32, numCoeffs int) []float32 {
+	return applyDCT(dctMatrix(numCoeffs, len(x)), x)
+}
+
+func applyDCT(basis [][]float32, x []float32) []float32 {
+	out := make([]float32, len(basis))
+	for k, row := range basis {
+		var sum float32
+		for i, b := range row {
+			sum += b * x[i]
+		}
+		out[k] = sum
+	}
+	return out
+}
+
+// lifterWeights returns the sinusoidal lifter 1 + (L/2)*sin(pi*n/L) for n coefficients.
+func lifterWeights(n, l int) []float32 {
+	w := make([]float32, n)
+	for i := range w {
+		w[i] = float32(1 + float64(l)/2*math.Sin(math.Pi*float64(i)/float64(l)))
+	}
+	return w
+}
+
+// Lifter applies sinusoidal liftering to cepstral coefficients, boosting the higher
+// coefficients so they have a comparable range. A lifter of 0 returns the input unchanged.
+func Lifter(cepstra []float32, l int) []float32 {
+	if l <= 0 {
+		return cepstra
+	}
+	out := make([]float32, len(cepstra))
+	for i, w := range lifterWeights(len(cepstra), l) {
+		out[i] = cepstra[i] * w
+	}
+	return out
+}
+
+// MFCC converts log-Mel frames into liftered cepstral coefficients.
+func MFCC(logMel [][]float32, numCepstra, lifter int) [][]float32 {
+	if len(logMel) == 0 {
+		return nil
+	}
+	basis := dctMatrix(numCepstra, len(logMel[0]))
+	out := make([][]float32, len(logMel))
+	for t, frame := range logMel {
+		out[t] = Lifter(applyDCT(basis, frame), lifter)
+	}
+	return out
+}
+
+// Deltas computes the regression deltas of a [frames][coeffs] feature matrix over
+// +-width frames:
+//
+//	d[t] = sum_{n=1..width} n * (c[t+n] - c[t-n]) / (2 * sum_{n=1..width} n^2)
+//
+// Frames beyond the edges repeat the first/last frame.
+func Deltas(feats [][]float32, width int) [][]float32 {
+	numFrames := len(feats)
+	var denom float32
+	for n := 1; n <= width; n++ {
+		denom += float32(2 * n * n)
+	}
+
+	out := make([][]float32, numFrames)
+	for t := range feats {
+		row := make([]float32, len(feats[t]))
+		for n := 1; n <= width; n++ {
+			next := feats[min(t+n, numFrames-1)]
+			prev := feats[max(t-n, 0)]
+			for d := range row {
+				row[d] += float32(n) * (next[d] - prev[d])
+			}
+		}
+		for d := range row {
+			row[d] /= denom
+		}
+		out[t] = row
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+package features
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"testing"
+)
+
+// mfccReference holds values produced by testdata/gen_mfcc.py.
+type mfccReference struct {
+	NumCepstra int         `json:"num_cepstra"`
+	Lifter     int         `json:"lifter"`
+	DeltaWidth int         `json:"delta_width"`
+	LogMel     [][]float32 `json:"log_mel"`
+	MFCC       [][]float32 `json:"mfcc"`
+	Delta      [][]float32 `json:"delta"`
+	DeltaDelta [][]float32 `json:"delta_delta"`
+}
+
+func loadMFCCReference(t *testing.T) mfccReference {
+	data, err := os.ReadFile("testdata/mfcc_reference.json")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	var ref mfccReference
+	if err := json.Unmarshal(data, &ref); err != nil {
+		t.Fatalf("Failed to parse fixture: %v", err)
+	}
+	return ref
+}
+
+func assertMatrixClose(t *testing.T, name string, expected, got [][]float32, tol float64) {
+	t.Helper()
+	if len(expected) != len(got) {
+		t.Fatalf("%s: expected %d frames, got %d", name, len(expected), len(got))
+	}
+	for i := range expected {
+		if len(expected[i]) != len(got[i]) {
+			t.Fatalf("%s: frame %d: expected %d coefficients, got %d", name, i, len(expected[i]), len(got[i]))
+		}
+		for j := range expected[i] {
+			if math.Abs(float64(expected[i][j]-got[i][j])) > tol {
+				t.Errorf("%s[%d][%d]: expected %f, got %f", name, i, j, expected[i][j], got[i][j])
+			}
+		}
+	}
+}
+
+func TestMFCC(t *testing.T) {
+	ref := loadMFCCReference(t)
+
+	t.Run("Cepstra", func(t *testing.T) {
+		got := MFCC(ref.LogMel, ref.NumCepstra, ref.Lifter)
+		assertMatrixClose(t, "mfcc", ref.MFCC, got, 1e-4)
+	})
+
+	t.Run("Deltas", func(t *testing.T) {
+		d1 := Deltas(ref.MFCC, ref.DeltaWidth)
+		assertMatrixClose(t, "delta", ref.Delta, d1, 1e-4)
+		d2 := Deltas(d1, ref.DeltaWidth)
+		assertMatrixClose(t, "delta_delta", ref.DeltaDelta, d2, 1e-4)
+	})
+
+	t.Run("DCT Orthonormal", func(t *testing.T) {
+		// A full orthonormal DCT preserves energy
+		x := ref.LogMel[0]
+		y := DCT(x, len(x))
+		var ex, ey float64
+		for i := range x {
+			ex += float64(x[i]) * float64(x[i])
+			ey += float64(y[i]) * float64(y[i])
+		}
+		if math.Abs(ex-ey) > 1e-3*ex {
+			t.Errorf("Energy not preserved: %f vs %f", ex, ey)
+		}
+	})
+
+	t.Run("DCT Known Values", func(t *testing.T) {
+		// scipy.fftpack.dct(norm='ortho'): a constant c maps to c*sqrt(N) in c0
+		// and a cosine basis vector k maps to sqrt(N/2) in ck
+		const n = 40
+		constant := make([]float32, n)
+		basis := make([]float32, n)
+		for i := range constant {
+			constant[i] = 2
+			basis[i] = float32(math.Cos(math.Pi * 3 * float64(2*i+1) / (2 * n)))
+		}
+		y := DCT(constant, 13)
+		if math.Abs(float64(y[0])-2*math.Sqrt(n)) > 1e-4 {
+			t.Errorf("Expected c0 = %f, got %f", 2*math.Sqrt(n), y[0])
+		}
+		for k := 1; k < len(y); k++ {
+			if math.Abs(float64(y[k])) > 1e-4 {
+				t.Errorf("Expected c%d = 0 for a constant, got %f", k, y[k])
+			}
+		}
+		y = DCT(basis, 13)
+		for k := range y {
+			expected := 0.0
+			if k == 3 {
+				expected = math.Sqrt(n / 2)
+			}
+			if math.Abs(float64(y[k])-expected) > 1e-4 {
+				t.Errorf("Expected c%d = %f for the basis vector, got %f", k, expected, y[k])
+			}
+		}
+	})
+
+	t.Run("Lifter Known Values", func(t *testing.T) {
+		// 1 + (L/2)*sin(pi*n/L) for L = 22
+		ones := make([]float32, 13)
+		for i := range ones {
+			ones[i] = 1
+		}
+		w := Lifter(ones, 22)
+		for n, expected := range map[int]float64{0: 1, 1: 2.5654632, 10: 11.8880359, 11: 12, 12: 11.8880359} {
+			if math.Abs(float64(w[n])-expected) > 1e-4 {
+				t.Errorf("Expected lifter weight %f at %d, got %f", expected, n, w[n])
+			}
+		}
+	})
+
+	t.Run("Deltas Known Values", func(t *testing.T) {
+		// Width 2 regression with repeated edge frames: a ramp a*t has delta a
+		// inside and 0.5a, 0.8a at the edges; t^2 has delta 2t and delta-delta 2
+		const a = 3
+		ramp := make([][]float32, 10)
+		square := make([][]float32, 10)
+		for i := range ramp {
+			ramp[i] = []float32{a * float32(i)}
+			square[i] = []float32{float32(i * i)}
+		}
+		d := Deltas(ramp, 2)
+		last := len(d) - 1
+		expected := map[int]float32{0: 0.5 * a, 1: 0.8 * a, 4: a, last - 1: 0.8 * a, last: 0.5 * a}
+		for i, e := range expected {
+			if math.Abs(float64(d[i][0]-e)) > 1e-5 {
+				t.Errorf("Ramp delta at %d: expected %f, got %f", i, e, d[i][0])
+			}
+		}
+		d1 := Deltas(square, 2)
+		d2 := Deltas(d1, 2)
+		for i := 4; i <= 5; i++ {
+			if math.Abs(float64(d1[i][0])-2*float64(i)) > 1e-4 {
+				t.Errorf("Square delta at %d: expected %d, got %f", i, 2*i, d1[i][0])
+			}
+			if math.Abs(float64(d2[i][0])-2) > 1e-4 {
+				t.Errorf("Square delta-delta at %d: expected 2, got %f", i, d2[i][0])
+			}
+		}
+	})
+
+	t.Run("No Lifter", func(t *testing.T) {
+		c := []float32{1, 2, 3}
+		out := Lifter(c, 0)
+		for i := range c {
+			if out[i] != c[i] {
+				t.Errorf("Expected unchanged coefficient at %d", i)
+			}
+		}
+	})
+}
+
+func TestExtractMFCC(t *testing.T) {
+	samples := make([]float32, 16000)
+	for i := range samples {
+		// 1kHz completes a whole number of cycles per hop, so every frame is identical
+		samples[i] = 0.3 * float32(math.Sin(2*math.Pi*1000*float64(i)/16000))
+	}
+
+	cfg := DefaultConfig()
+	cfg.Type = TypeMFCC
+	cfg.Deltas = 2
+	tensor := ExtractWithConfig(samples, cfg)
+
+	frames := cfg.NumFrames(len(samples))
+	if tensor.Shape[0] != 3 || tensor.Shape[1] != frames || tensor.Shape[2] != 13 {
+		t.Fatalf("Expected shape [3, %d, 13], got %v", frames, tensor.Shape)
+	}
+
+	// Channel 0 must be the MFCC of the log-Mel features
+	melCfg := cfg
+	melCfg.Type = TypeMel
+	melCfg.Deltas = 0
+	mel := ExtractWithConfig(samples, melCfg)
+	logMel := make([][]float32, frames)
+	for i := range logMel {
+		logMel[i] = mel.Data[i*40 : (i+1)*40]
+	}
+	expected := MFCC(logMel, 13, 22)
+	for i := 0; i < frames; i++ {
+		for j := 0; j < 13; j++ {
+			if got := tensor.Data[i*13+j]; math.Abs(float64(got-expected[i][j])) > 1e-5 {
+				t.Fatalf("MFCC mismatch at frame %d coeff %d: %f vs %f", i, j, got, expected[i][j])
+			}
+		}
+	}
+
+	// A stationary tone has (nearly) zero deltas away from the edges
+	mid := frames / 2
+	for j := 0; j < 13; j++ {
+		if d := tensor.Data[(frames+mid)*13+j]; math.Abs(float64(d)) > 1e-3 {
+			t.Errorf("Expected ~0 delta for stationary input, got %f at coeff %d", d, j)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package features
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/tomkiv/hotword/pkg/audio"
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+// NoiseConfig controls the optional noise suppression stage that runs on the
+// STFT magnitudes before the Mel filterbank. The same settings must be used at
+// training and inference time, so they are stored in the model metadata.
+type NoiseConfig struct {
+	// Method is "spectral", "wiener", or empty to disable suppression.
+	Method          string  `mapstructure:"method"`
+	OverSubtraction float32 `mapstructure:"over_subtraction"`
+	Floor           float32 `mapstructure:"floor"`
+	Smoothing       float32 `mapstructure:"smoothing"`
+	InitFrames      int     `mapstructure:"init_frames"`
+	// VADEnergy and VADZCR decide per frame whether the noise estimate is updated.
+	VADEnergy float32 `mapstructure:"vad_energy"`
+	VADZCR    float32 `mapstructure:"vad_zcr"`
+}
+
+// DefaultNoiseConfig returns a NoiseConfig for the given method with default parameters.
+func DefaultNoiseConfig(method string) NoiseConfig {
+	return NoiseConfig{
+		Method:          method,
+		OverSubtraction: audio.DefaultOverSubtraction,
+		Floor:           audio.DefaultNoiseFloor,
+		Smoothing:       audio.DefaultNoiseSmoothing,
+		InitFrames:      audio.DefaultNoiseInitFrames,
+		VADEnergy:       0.01,
+		VADZCR:          0.5,
+	}
+}
+
+// Enabled reports whether noise suppression is active.
+func (c NoiseConfig) Enabled() bool {
+	return c.Method != "" && c.Method != "none"
+}
+
+// newSuppressor creates a NoiseSuppressor configured from c.
+func (c NoiseConfig) newSuppressor() (*audio.NoiseSuppressor, error) {
+	ns, err := audio.NewNoiseSuppressor(c.Method)
+	if err != nil {
+		return nil, err
+	}
+	ns.OverSubtraction = c.OverSubtraction
+	ns.Floor = c.Floor
+	ns.Smoothing = c.Smoothing
+	ns.InitFrames = c.InitFrames
+	return ns, nil
+}
+
+// Validate checks that the configuration can be used to build a suppressor.
+func (c NoiseConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	_, err := c.newSuppressor()
+	return err
+}
+
+// Metadata keys used to persist NoiseConfig in model files.
+const (
+	metaNoiseMethod          = "features.noise.method"
+	metaNoiseOverSubtraction = "features.noise.over_subtraction"
+	metaNoiseFloor           = "features.noise.floor"
+	metaNoiseSmoothing       = "features.noise.smoothing"
+	metaNoiseInitFrames      = "features.noise.init_frames"
+	metaNoiseVADEnergy       = "features.noise.vad_energy"
+	metaNoiseVADZCR          = "features.noise.vad_zcr"
+)
+
+// WriteMetadata stores the noise configuration in the model metadata.
+func (c NoiseConfig) WriteMetadata(meta model.Metadata) {
+	if !c.Enabled() {
+		return
+	}
+	meta[metaNoiseMethod] = c.Method
+	meta[metaNoiseOverSubtraction] = formatFloat(c.OverSubtraction)
+	meta[metaNoiseFloor] = formatFloat(c.Floor)
+	meta[metaNoiseSmoothing] = formatFloat(c.Smoothing)
+	meta[metaNoiseInitFrames] = strconv.Itoa(c.InitFrames)
+	meta[metaNoiseVADEnergy] = formatFloat(c.VADEnergy)
+	meta[metaNoiseVADZCR] = formatFloat(c.VADZCR)
+}
+
+// NoiseConfigFromMetadata reads the noise configuration from model metadata.
+// Models without noise settings return a disabled configuration.
+func NoiseConfigFromMetadata(meta model.Metadata) (NoiseConfig, error) {
+	method, ok := meta[metaNoiseMethod]
+	if !ok {
+		return NoiseConfig{}, nil
+	}
+	c := DefaultNoiseConfig(method)
+
+	var err error
+	if c.OverSubtraction, err = parseFloat(meta, metaNoiseOverSubtraction, c.OverSubtraction); err != nil {
+		return c, err
+	}
+	if c.Floor, err = parseFloat(meta, metaNoiseFloor, c.Floor); err != nil {
+		return c, err
+	}
+	if c.Smoothing, err = parseFloat(meta, metaNoiseSmoothing, c.Smoothing); err != nil {
+		return c, err
+	}
+	if c.VADEnergy, err = parseFloat(meta, metaNoiseVADEnergy, c.VADEnergy); err != nil {
+		return c, err
+	}
+	if c.VADZCR, err = parseFloat(meta, metaNoiseVADZCR, c.VADZCR); err != nil {
+		return c, err
+	}
+	if c.InitFrames, err = parseInt(meta, metaNoiseInitFrames, c.InitFrames); err != nil {
+		return c, err
+	}
+	return c, c.Validate()
+}
+
+func formatFloat(v float32) string {
+	return strconv.FormatFloat(float64(v), 'g', -1, 32)
+}
+
+// parseFloat reads a float metadata value, returning def when the key is absent.
+func parseFloat(meta model.Metadata, key string, def float32) (float32, error) {
+	v, ok := meta[key]
+	if !ok {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 32)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return float32(f), nil
+}
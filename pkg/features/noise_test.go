@@ -0,0 +1,69 @@
+package features
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+func TestExtractWithNoiseSuppression(t *testing.T) {
+	sampleRate := 16000
+	rng := rand.New(rand.NewSource(3))
+
+	// Fan-like noise: low-frequency hum plus broadband hiss, below the VAD energy threshold
+	samples := make([]float32, sampleRate)
+	for i := range samples {
+		hum := 0.004 * math.Sin(2*math.Pi*120*float64(i)/float64(sampleRate))
+		samples[i] = float32(hum) + (rng.Float32()*2-1)*0.002
+	}
+
+	plain := Extract(samples, sampleRate, 512, 256, 40)
+	for _, method := range []string{"spectral", "wiener"} {
+		cfg := DefaultConfig()
+		cfg.Noise = DefaultNoiseConfig(method)
+		cleaned := ExtractWithConfig(samples, cfg)
+		if cleaned == nil {
+			t.Fatalf("%s: expected tensor, got nil", method)
+		}
+		if len(cleaned.Data) != len(plain.Data) {
+			t.Fatalf("%s: shape mismatch %v vs %v", method, cleaned.Shape, plain.Shape)
+		}
+
+		var sumPlain, sumCleaned float64
+		for i := range plain.Data {
+			sumPlain += float64(plain.Data[i])
+			sumCleaned += float64(cleaned.Data[i])
+		}
+		if sumCleaned >= 0.7*sumPlain {
+			t.Errorf("%s: expected stationary noise to be suppressed (plain=%.2f, cleaned=%.2f)", method, sumPlain, sumCleaned)
+		}
+	}
+}
+
+func TestNoiseConfigMetadata(t *testing.T) {
+	cfg := DefaultNoiseConfig("wiener")
+	cfg.Floor = 0.1
+
+	meta := model.Metadata{}
+	cfg.WriteMetadata(meta)
+
+	loaded, err := NoiseConfigFromMetadata(meta)
+	if err != nil {
+		t.Fatalf("NoiseConfigFromMetadata failed: %v", err)
+	}
+	if loaded != cfg {
+		t.Errorf("Round trip mismatch: expected %+v, got %+v", cfg, loaded)
+	}
+
+	// Models trained without suppression stay disabled
+	disabled, err := NoiseConfigFromMetadata(model.Metadata{})
+	if err != nil || disabled.Enabled() {
+		t.Errorf("Expected disabled config for empty metadata, got %+v (err=%v)", disabled, err)
+	}
+
+	if _, err := NoiseConfigFromMetadata(model.Metadata{metaNoiseMethod: "bogus"}); err == nil {
+		t.Error("Expected error for unknown method")
+	}
+}
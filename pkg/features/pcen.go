@@ -0,0 +1,136 @@
+package features
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+// pcenEpsilon keeps the normalization finite for silent bins.
+const pcenEpsilon = 1e-6
+
+// PCENConfig holds the Per-Channel Energy Normalization parameters:
+//
+//	M[t] = (1-s) * M[t-1] + s * E[t]
+//	PCEN[t] = (E[t] / (eps + M[t])^alpha + delta)^r - delta^r
+//
+// where E are the Mel energies and M is their per-channel smoothed version.
+type PCENConfig struct {
+	Smoothing float32 `mapstructure:"smoothing"` // s, IIR smoothing coefficient
+	Gain      float32 `mapstructure:"gain"`      // alpha, normalization strength
+	Bias      float32 `mapstructure:"bias"`      // delta
+	Root      float32 `mapstructure:"root"`      // r, compression exponent
+}
+
+// DefaultPCENConfig returns the commonly used PCEN parameters.
+func DefaultPCENConfig() PCENConfig {
+	return PCENConfig{
+		Smoothing: 0.025,
+		Gain:      0.98,
+		Bias:      2,
+		Root:      0.5,
+	}
+}
+
+// Validate checks the PCEN parameters.
+func (c PCENConfig) Validate() error {
+	if c.Smoothing <= 0 || c.Smoothing > 1 {
+		return fmt.Errorf("pcen smoothing must be in (0, 1], got %f", c.Smoothing)
+	}
+	if c.Gain < 0 || c.Bias < 0 {
+		return fmt.Errorf("pcen gain and bias must not be negative")
+	}
+	if c.Root <= 0 || c.Root > 1 {
+		return fmt.Errorf("pcen root must be in (0, 1], got %f", c.Root)
+	}
+	return nil
+}
+
+// PCEN applies Per-Channel Energy Normalization frame by frame. It keeps the
+// smoothed energy of each channel, so consecutive calls continue the same stream.
+type PCEN struct {
+	cfg    PCENConfig
+	smooth []float64
+}
+
+// NewPCEN creates a streaming PCEN stage.
+func NewPCEN(cfg PCENConfig) *PCEN {
+	return &PCEN{cfg: cfg}
+}
+
+// Process normalizes one frame of Mel energies. The smoother is initialized with
+// the first frame so the output does not start with a large transient.
+func (p *PCEN) Process(frame []float32) []float32 {
+	if p.smooth == nil {
+		p.smooth = make([]float64, len(frame))
+		for i, e := range frame {
+			p.smooth[i] = float64(e)
+		}
+	}
+
+	s := float64(p.cfg.Smoothing)
+	alpha := float64(p.cfg.Gain)
+	delta := float64(p.cfg.Bias)
+	r := float64(p.cfg.Root)
+	offset := math.Pow(delta, r)
+
+	out := make([]float32, len(frame))
+	for i, e := range frame {
+		p.smooth[i] = (1-s)*p.smooth[i] + s*float64(e)
+		norm := float64(e) / math.Pow(pcenEpsilon+p.smooth[i], alpha)
+		out[i] = float32(math.Pow(norm+delta, r) - offset)
+	}
+	return out
+}
+
+// Reset clears the smoothing state.
+func (p *PCEN) Reset() {
+	p.smooth = nil
+}
+
+// ApplyPCEN normalizes a whole [frames][channels] matrix starting from a fresh state.
+// It produces exactly the same values as feeding the frames one by one to a new PCEN.
+func ApplyPCEN(frames [][]float32, cfg PCENConfig) [][]float32 {
+	p := NewPCEN(cfg)
+	out := make([][]float32, len(frames))
+	for t, frame := range frames {
+		out[t] = p.Process(frame)
+	}
+	return out
+}
+
+// Metadata keys used to persist PCENConfig in model files.
+const (
+	metaPCENSmoothing = "features.pcen.smoothing"
+	metaPCENGain      = "features.pcen.gain"
+	metaPCENBias      = "features.pcen.bias"
+	metaPCENRoot      = "features.pcen.root"
+)
+
+// WriteMetadata stores the PCEN parameters in the model metadata.
+func (c PCENConfig) WriteMetadata(meta model.Metadata) {
+	meta[metaPCENSmoothing] = formatFloat(c.Smoothing)
+	meta[metaPCENGain] = formatFloat(c.Gain)
+	meta[metaPCENBias] = formatFloat(c.Bias)
+	meta[metaPCENRoot] = formatFloat(c.Root)
+}
+
+// PCENConfigFromMetadata reads the PCEN parameters, using defaults for missing keys.
+func PCENConfigFromMetadata(meta model.Metadata) (PCENConfig, error) {
+	c := DefaultPCENConfig()
+	var err error
+	if c.Smoothing, err = parseFloat(meta, metaPCENSmoothing, c.Smoothing); err != nil {
+		return c, err
+	}
+	if c.Gain, err = parseFloat(meta, metaPCENGain, c.Gain); err != nil {
+		return c, err
+	}
+	if c.Bias, err = parseFloat(meta, metaPCENBias, c.Bias); err != nil {
+		return c, err
+	}
+	if c.Root, err = parseFloat(meta, metaPCENRoot, c.Root); err != nil {
+		return c, err
+	}
+	return c, nil
+}
@@ -0,0 +1,75 @@
+package features
+
+import (
+	"math"
+	"testing"
+)
+
+func melFrames(numFrames, numMels int, scale float32) [][]float32 {
+	frames := make([][]float32, numFrames)
+	for t := range frames {
+		frames[t] = make([]float32, numMels)
+		for m := range frames[t] {
+			frames[t][m] = scale * float32(1+math.Sin(0.2*float64(t)+0.5*float64(m)))
+		}
+	}
+	return frames
+}
+
+func TestPCEN(t *testing.T) {
+	cfg := DefaultPCENConfig()
+
+	t.Run("Streaming Matches Batch", func(t *testing.T) {
+		frames := melFrames(50, 40, 10)
+		batch := ApplyPCEN(frames, cfg)
+
+		p := NewPCEN(cfg)
+		for i, frame := range frames {
+			out := p.Process(frame)
+			for j := range out {
+				if out[j] != batch[i][j] {
+					t.Fatalf("Mismatch at frame %d bin %d: %f vs %f", i, j, out[j], batch[i][j])
+				}
+			}
+		}
+	})
+
+	t.Run("Gain Invariance", func(t *testing.T) {
+		// With alpha close to 1, scaling the input barely changes the output
+		quiet := ApplyPCEN(melFrames(200, 10, 1), cfg)
+		loud := ApplyPCEN(melFrames(200, 10, 100), cfg)
+		last := len(quiet) - 1
+		for j := range quiet[last] {
+			if math.Abs(float64(quiet[last][j]-loud[last][j])) > 0.1 {
+				t.Errorf("Bin %d: expected similar output for 40dB gain change, got %f vs %f", j, quiet[last][j], loud[last][j])
+			}
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		frames := melFrames(10, 5, 3)
+		p := NewPCEN(cfg)
+		first := p.Process(frames[0])
+		p.Process(frames[5])
+		p.Reset()
+		again := p.Process(frames[0])
+		for j := range first {
+			if first[j] != again[j] {
+				t.Errorf("Expected identical output after reset at bin %d", j)
+			}
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		bad := []PCENConfig{
+			{Smoothing: 0, Gain: 0.98, Bias: 2, Root: 0.5},
+			{Smoothing: 0.025, Gain: -1, Bias: 2, Root: 0.5},
+			{Smoothing: 0.025, Gain: 0.98, Bias: 2, Root: 0},
+		}
+		for i, c := range bad {
+			if c.Validate() == nil {
+				t.Errorf("Case %d: expected validation error", i)
+			}
+		}
+	})
+}
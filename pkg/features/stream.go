@@ -0,0 +1,163 @@
+package features
+
+import (
+	"github.com/tomkiv/hotword/pkg/audio"
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+// Streamer computes feature frames incrementally from a continuous audio stream.
+// All stateful stages (pre-emphasis, noise suppression, PCEN) carry their state
+// across calls, so pushing a signal in arbitrary chunks yields exactly the frames
+// ExtractWithConfig computes for the whole signal.
+type Streamer struct {
+	cfg        Config
+	stft       *audio.STFTProcessor
+	mag        []float32 // Reused magnitude buffer
+	filterbank [][]float32
+	noise      *audio.NoiseSuppressor
+	pcen       *PCEN
+
+	emphasized []float32 // Pre-emphasized samples not yet consumed by a frame
+	raw        []float32 // Matching raw samples (for the noise suppression VAD)
+	skip       int       // Samples to drop before the next frame when hop > window
+	prev       float32   // Last raw sample (pre-emphasis state)
+	started    bool
+}
+
+// NewStreamer creates a streaming frontend for the given configuration.
+func NewStreamer(cfg Config) (*Streamer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	stft, err := audio.NewSTFTProcessor(cfg.WindowSize, cfg.HopSize)
+	if err != nil {
+		return nil, err
+	}
+	s := &Streamer{
+		cfg:        cfg,
+		stft:       stft,
+		mag:        make([]float32, cfg.WindowSize/2+1),
+		filterbank: audio.CreateMelFilterbank(cfg.NumMelFilters, cfg.WindowSize, cfg.SampleRate, cfg.MinFreq, cfg.maxFreq()),
+	}
+	if cfg.Noise.Enabled() {
+		ns, err := cfg.Noise.newSuppressor()
+		if err != nil {
+			return nil, err
+		}
+		s.noise = ns
+	}
+	if cfg.LogScale == LogScalePCEN {
+		s.pcen = NewPCEN(cfg.PCEN)
+	}
+	return s, nil
+}
+
+// Push feeds samples into the frontend and returns the compressed Mel frames that
+// became complete. MFCC and deltas are applied afterwards by Config.Tensor.
+func (s *Streamer) Push(samples []float32) [][]float32 {
+	for _, x := range samples {
+		// Pre-emphasis: y[n] = x[n] - coeff * x[n-1], first sample passes through
+		y := x
+		if s.started && s.cfg.PreEmphasis > 0 {
+			y = x - s.cfg.PreEmphasis*s.prev
+		}
+		s.prev = x
+		s.started = true
+
+		if s.skip > 0 {
+			s.skip--
+			continue
+		}
+		s.emphasized = append(s.emphasized, y)
+		s.raw = append(s.raw, x)
+	}
+
+	var frames [][]float32
+	for len(s.emphasized) >= s.cfg.WindowSize {
+		frames = append(frames, s.frame(s.emphasized[:s.cfg.WindowSize], s.raw[:s.cfg.WindowSize]))
+
+		hop := s.cfg.HopSize
+		if hop > len(s.emphasized) {
+			s.skip = hop - len(s.emphasized)
+			hop = len(s.emphasized)
+		}
+		s.emphasized = s.emphasized[hop:]
+		s.raw = s.raw[hop:]
+	}
+	return frames
+}
+
+// frame computes one compressed Mel frame.
+func (s *Streamer) frame(emphasized, raw []float32) []float32 {
+	// 1. STFT
+	// The magnitude buffer is reused; the following stages return new slices.
+	s.stft.Magnitude(emphasized, s.mag)
+	mag := s.mag
+
+	// 1.1 Noise Suppression
+	// The noise profile is only updated on frames the VAD considers non-speech.
+	if s.noise != nil {
+		speech := audio.IsSpeechFrame(raw, s.cfg.Noise.VADEnergy, s.cfg.Noise.VADZCR)
+		mag = s.noise.Process(mag, speech)
+	}
+
+	// 2. Mel-Spectrogram
+	mel := audio.ApplyFilterbank(mag, s.filterbank)
+
+	// 3. Compression
+	if s.pcen != nil {
+		return s.pcen.Process(mel)
+	}
+	for j, val := range mel {
+		mel[j] = s.cfg.compress(val)
+	}
+	return mel
+}
+
+// Reset clears all streaming state.
+func (s *Streamer) Reset() {
+	s.emphasized = s.emphasized[:0]
+	s.raw = s.raw[:0]
+	s.skip = 0
+	s.prev = 0
+	s.started = false
+	if s.noise != nil {
+		s.noise.Reset()
+	}
+	if s.pcen != nil {
+		s.pcen.Reset()
+	}
+}
+
+// Tensor turns compressed Mel frames (as returned by Streamer.Push) into the model
+// input of shape [NumChannels, numFrames, NumCoefficients], applying the cepstral
+// transform, stacking deltas and normalizing with the CMVN statistics.
+func (c Config) Tensor(frames [][]float32) *model.Tensor {
+	feats := frames
+
+	// 4. Optional cepstral transform
+	if c.Type == TypeMFCC {
+		feats = MFCC(feats, c.NumCepstra, c.Lifter)
+	}
+
+	// 5. Stack static, delta and delta-delta features as channels
+	channels := [][][]float32{feats}
+	for d := 0; d < c.Deltas; d++ {
+		channels = append(channels, Deltas(channels[len(channels)-1], c.DeltaWidth))
+	}
+
+	// 6. Reshape into 3D Tensor [channels, numFrames, numCoefficients]
+	numFrames := len(feats)
+	numCoeffs := c.NumCoefficients()
+	tensor := model.NewTensor([]int{len(channels), numFrames, numCoeffs})
+	for ch, rows := range channels {
+		for i, row := range rows {
+			copy(tensor.Data[(ch*numFrames+i)*numCoeffs:], row)
+		}
+	}
+
+	// 7. Mean and variance normalization
+	c.CMVN.Apply(tensor)
+
+	return tensor
+}
@@ -0,0 +1,65 @@
+package features
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestStreamer(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	samples := make([]float32, 16000)
+	for i := range samples {
+		samples[i] = 0.2*float32(math.Sin(2*math.Pi*440*float64(i)/16000)) + (rng.Float32()-0.5)*0.01
+	}
+
+	pcen := DefaultConfig()
+	pcen.LogScale = LogScalePCEN
+	pcen.Noise = DefaultNoiseConfig("spectral")
+
+	wideHop := DefaultConfig()
+	wideHop.WindowSize = 256
+	wideHop.HopSize = 400
+
+	for name, cfg := range map[string]Config{"PCEN": pcen, "Hop Larger Than Window": wideHop} {
+		t.Run(name, func(t *testing.T) {
+			batch := ExtractWithConfig(samples, cfg)
+
+			for _, chunk := range []int{1, 100, 512, 3000} {
+				s, err := NewStreamer(cfg)
+				if err != nil {
+					t.Fatalf("NewStreamer failed: %v", err)
+				}
+				var frames [][]float32
+				for start := 0; start < len(samples); start += chunk {
+					end := min(start+chunk, len(samples))
+					frames = append(frames, s.Push(samples[start:end])...)
+				}
+				streamed := cfg.Tensor(frames)
+
+				if len(streamed.Data) != len(batch.Data) {
+					t.Fatalf("chunk %d: expected %v, got %v", chunk, batch.Shape, streamed.Shape)
+				}
+				for i := range batch.Data {
+					if streamed.Data[i] != batch.Data[i] {
+						t.Fatalf("chunk %d: mismatch at %d: %f vs %f", chunk, i, streamed.Data[i], batch.Data[i])
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("Reset", func(t *testing.T) {
+		s, _ := NewStreamer(pcen)
+		first := s.Push(samples[:4000])
+		s.Reset()
+		again := s.Push(samples[:4000])
+		for i := range first {
+			for j := range first[i] {
+				if first[i][j] != again[i][j] {
+					t.Fatalf("Expected identical frames after reset at %d/%d", i, j)
+				}
+			}
+		}
+	})
+}
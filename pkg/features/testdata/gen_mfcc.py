@@ -0,0 +1,72 @@
+#!/usr/bin/env python3
+"""Generates mfcc_reference.json for the MFCC tests with python_speech_features.
+
+Pinned versions:
+    numpy==1.26.4
+    scipy==1.11.4
+    python_speech_features==0.6
+
+Usage:
+    pip install numpy==1.26.4 scipy==1.11.4 python_speech_features==0.6
+    python3 gen_mfcc.py > mfcc_reference.json
+
+The cepstra follow python_speech_features.mfcc after its filter bank: the
+log filter bank energies go through scipy.fftpack.dct(type=2, norm='ortho'),
+keep numcep coefficients and are liftered with python_speech_features.lifter.
+The deltas come from python_speech_features.delta. The input is a fixed
+log-Mel matrix rather than audio, so differences in framing, FFT and Mel
+filter banks between the libraries do not enter the comparison.
+
+Convention differences:
+  - python_speech_features.mfcc replaces c0 by the log frame energy by
+    default (appendEnergy=True). The Go frontend keeps the DCT c0, so the
+    reference does too (appendEnergy=False).
+  - python_speech_features takes the natural log of the filter bank
+    energies, as the Go frontend does. librosa.feature.mfcc expects a
+    power spectrogram in dB (10*log10) and does not lifter by default
+    (lifter=0), so its coefficients differ by a scale factor.
+  - python_speech_features.delta repeats the first and last frames at the
+    edges, as the Go Deltas does. librosa.feature.delta fits a
+    Savitzky-Golay filter instead (mode='interp'), which gives other values
+    for the first and last width frames.
+"""
+import json
+import math
+
+import numpy as np
+from python_speech_features import base as psf
+from scipy.fftpack import dct
+
+NUM_FRAMES = 12
+NUM_MELS = 40
+NUM_CEP = 13
+LIFTER = 22
+DELTA_WIDTH = 2
+
+# Deterministic log-mel-like input: smooth spectral envelope that drifts over
+# time. It is rounded first so the Go test reads the exact input used here.
+log_mel = np.round(np.array([
+    [math.log1p(1000 * (0.01 + 0.005 * (1 + math.sin(0.3 * m + 0.5 * t)) * math.exp(-m / 25.0)))
+     for m in range(NUM_MELS)]
+    for t in range(NUM_FRAMES)
+]), 7)
+
+# python_speech_features.mfcc with appendEnergy=False, from the log energies on
+mfcc = psf.lifter(dct(log_mel, type=2, axis=1, norm='ortho')[:, :NUM_CEP], LIFTER)
+d1 = psf.delta(mfcc, DELTA_WIDTH)
+d2 = psf.delta(d1, DELTA_WIDTH)
+
+
+def rnd(m):
+    return np.round(m, 7).tolist()
+
+
+print(json.dumps({
+    "num_cepstra": NUM_CEP,
+    "lifter": LIFTER,
+    "delta_width": DELTA_WIDTH,
+    "log_mel": rnd(log_mel),
+    "mfcc": rnd(mfcc),
+    "delta": rnd(d1),
+    "delta_delta": rnd(d2),
+}, indent=1))
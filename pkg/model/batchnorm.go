@@ -0,0 +1,349 @@
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+// Default batch normalization hyperparameters.
+const (
+	DefaultBatchNormMomentum = float32(0.1)
+	DefaultBatchNormEpsilon  = float32(1e-5)
+	DefaultBatchNormWarmup   = 100
+)
+
+// TrainingModeSetter is implemented by layers that behave differently during
+// training and inference (e.g. batch normalization).
+type TrainingModeSetter interface {
+	SetTraining(training bool)
+}
+
+// BatchNormLayer normalizes each channel of a [channels, height, width] (or
+// [channels, length]) input and applies a learnable scale (Gamma) and shift (Beta).
+//
+// The trainer feeds one sample at a time, so in training mode the running mean
+// and variance are updated with Momentum from the statistics of each sample,
+// computed per channel over its positions. For the first WarmupSteps updates,
+// while the running statistics are still far from the data, the sample is
+// normalized with its own statistics. After that it is normalized with the
+// running statistics as they were before the update, exactly as in inference
+// mode, so training learns the same fixed per-channel affine transform that
+// inference applies and that can be folded into the preceding convolution.
+// Backward treats the running statistics as constants and uses the statistics
+// of the most recent Forward call.
+type BatchNormLayer struct {
+	Gamma       []float32
+	Beta        []float32
+	RunningMean []float32
+	RunningVar  []float32
+	Momentum    float32
+	Epsilon     float32
+	// WarmupSteps is the number of running statistics updates during which
+	// training normalizes with per-sample statistics.
+	WarmupSteps int
+	// Updates counts the running statistics updates made in training mode.
+	Updates int
+
+	training bool
+	// Statistics used by the most recent Forward call in training mode
+	mean, variance []float32
+	perSample      bool
+}
+
+// NewBatchNormLayer creates a batch normalization layer for the given number of
+// channels with unit scale, zero shift and unit running variance.
+func NewBatchNormLayer(channels int) *BatchNormLayer {
+	l := &BatchNormLayer{
+		Gamma:       make([]float32, channels),
+		Beta:        make([]float32, channels),
+		RunningMean: make([]float32, channels),
+		RunningVar:  make([]float32, channels),
+		Momentum:    DefaultBatchNormMomentum,
+		Epsilon:     DefaultBatchNormEpsilon,
+		WarmupSteps: DefaultBatchNormWarmup,
+	}
+	for c := 0; c < channels; c++ {
+		l.Gamma[c] = 1
+		l.RunningVar[c] = 1
+	}
+	return l
+}
+
+// SetTraining switches between training mode, which updates the running
+// statistics (and normalizes with per-sample statistics during the warm-up),
+// and inference mode, which only uses the running statistics.
+func (l *BatchNormLayer) SetTraining(training bool) {
+	l.training = training
+	l.mean, l.variance = nil, nil
+}
+
+// Training reports whether the layer is in training mode.
+func (l *BatchNormLayer) Training() bool {
+	return l.training
+}
+
+// channelStats returns the mean and biased variance of each channel of input.
+func channelStats(input *Tensor) (mean, variance []float32) {
+	channels := input.Shape[0]
+	size := len(input.Data) / channels
+	mean = make([]float32, channels)
+	variance = make([]float32, channels)
+	for c := 0; c < channels; c++ {
+		var sum, sumSq float64
+		for _, v := range input.Data[c*size : (c+1)*size] {
+			sum += float64(v)
+			sumSq += float64(v) * float64(v)
+		}
+		m := sum / float64(size)
+		mean[c] = float32(m)
+		variance[c] = float32(math.Max(sumSq/float64(size)-m*m, 0))
+	}
+	return mean, variance
+}
+
+// stats returns the statistics with which Forward normalized input in the
+// current mode and whether they are the statistics of input itself. In training
+// mode these are the ones of the most recent Forward call, or the warm-up choice
+// if there was none.
+func (l *BatchNormLayer) stats(input *Tensor) (mean, variance []float32, perSample bool) {
+	switch {
+	case !l.training:
+		return l.RunningMean, l.RunningVar, false
+	case l.mean != nil:
+		return l.mean, l.variance, l.perSample
+	case l.Updates < l.WarmupSteps:
+		mean, variance = channelStats(input)
+		return mean, variance, true
+	default:
+		return l.RunningMean, l.RunningVar, false
+	}
+}
+
+func (l *BatchNormLayer) Forward(input *Tensor) *Tensor {
+	mean, variance := l.RunningMean, l.RunningVar
+
+	if l.training {
+		sampleMean, sampleVar := channelStats(input)
+		perSample := l.Updates < l.WarmupSteps
+		if perSample {
+			mean, variance = sampleMean, sampleVar
+		} else {
+			// Normalize with the running statistics before this sample's update
+			mean = append([]float32(nil), l.RunningMean...)
+			variance = append([]float32(nil), l.RunningVar...)
+		}
+		l.mean, l.variance, l.perSample = mean, variance, perSample
+
+		// Update running statistics with the unbiased variance
+		size := len(input.Data) / len(l.Gamma)
+		correction := float32(1)
+		if size > 1 {
+			correction = float32(size) / float32(size-1)
+		}
+		for c := range l.RunningMean {
+			l.RunningMean[c] = (1-l.Momentum)*l.RunningMean[c] + l.Momentum*sampleMean[c]
+			l.RunningVar[c] = (1-l.Momentum)*l.RunningVar[c] + l.Momentum*sampleVar[c]*correction
+		}
+		l.Updates++
+	}
+
+	channels := len(l.Gamma)
+	size := len(input.Data) / channels
+	output := NewTensor(input.Shape)
+	for c := 0; c < channels; c++ {
+		scale := l.Gamma[c] / float32(math.Sqrt(float64(variance[c]+l.Epsilon)))
+		shift := l.Beta[c] - mean[c]*scale
+		in := input.Data[c*size : (c+1)*size]
+		out := output.Data[c*size : (c+1)*size]
+		for i, v := range in {
+			out[i] = v*scale + shift
+		}
+	}
+	return output
+}
+
+func (l *BatchNormLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *BatchNormLayer) ResetState() {}
+
+// Backward returns the input gradient and the gradients of Gamma (as a [channels]
+// tensor) and Beta. During the warm-up the gradient flows through the per-sample
+// statistics as well.
+func (l *BatchNormLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	mean, variance, perSample := l.stats(input)
+
+	channels := len(l.Gamma)
+	size := len(input.Data) / channels
+	gradInput := NewTensor(input.Shape)
+	gradGamma := NewTensor([]int{channels})
+	gradBeta := make([]float32, channels)
+
+	for c := 0; c < channels; c++ {
+		invStd := 1 / float32(math.Sqrt(float64(variance[c]+l.Epsilon)))
+		in := input.Data[c*size : (c+1)*size]
+		dy := gradOutput.Data[c*size : (c+1)*size]
+		dx := gradInput.Data[c*size : (c+1)*size]
+
+		var sumDy, sumDyXhat float32
+		for i, v := range in {
+			xhat := (v - mean[c]) * invStd
+			sumDy += dy[i]
+			sumDyXhat += dy[i] * xhat
+		}
+		gradGamma.Data[c] = sumDyXhat
+		gradBeta[c] = sumDy
+
+		if !perSample {
+			for i := range dx {
+				dx[i] = dy[i] * l.Gamma[c] * invStd
+			}
+			continue
+		}
+
+		// dx = gamma/(m*std) * (m*dy - sum(dy) - xhat*sum(dy*xhat))
+		m := float32(size)
+		k := l.Gamma[c] * invStd / m
+		for i, v := range in {
+			xhat := (v - mean[c]) * invStd
+			dx[i] = k * (m*dy[i] - sumDy - xhat*sumDyXhat)
+		}
+	}
+	return gradInput, gradGamma, gradBeta
+}
+
+// Params returns Gamma as a [channels] tensor and Beta as the bias. The tensor
+// shares its data with the layer.
+func (l *BatchNormLayer) Params() (*Tensor, []float32) {
+	return &Tensor{Data: l.Gamma, Shape: []int{len(l.Gamma)}}, l.Beta
+}
+
+func (l *BatchNormLayer) SetParams(weights *Tensor, bias []float32) {
+	l.Gamma = weights.Data
+	l.Beta = bias
+}
+
+func (l *BatchNormLayer) Type() string {
+	return "batchnorm"
+}
+
+// FoldBatchNorm returns a model in which every batch normalization layer that
+// directly follows a convolution (conv2d, depthwise_conv2d, pointwise_conv2d or
+// conv1d) is merged into that convolution, using the running statistics:
+//
+//	W'[f] = W[f] * gamma[f] / sqrt(var[f] + eps)
+//	b'[f] = (b[f] - mean[f]) * gamma[f] / sqrt(var[f] + eps) + beta[f]
+//
+// The folded model computes the same inference output with fewer operations.
+// Other layers are shared with the original model. It also returns the number of
+// folded layers. In a GraphModel a convolution is only folded if the batch
+// normalization is its sole consumer.
+func FoldBatchNorm(m Model) (Model, int) {
+	if g, ok := m.(*GraphModel); ok {
+		return foldGraphBatchNorm(g)
+	}
+
+	layers := m.GetLayers()
+	var out []Layer
+	folded := 0
+	for i := 0; i < len(layers); i++ {
+		if i+1 < len(layers) {
+			if bn, isBN := layers[i+1].(*BatchNormLayer); isBN {
+				if conv := foldInto(layers[i], bn); conv != nil {
+					out = append(out, conv)
+					folded++
+					i++ // Skip the batch normalization layer
+					continue
+				}
+			}
+		}
+		out = append(out, layers[i])
+	}
+	return NewSequentialModel(out...), folded
+}
+
+// foldGraphBatchNorm folds batch normalization nodes of a graph. The folded
+// convolution takes over the name of the batch normalization node, so its
+// consumers are unchanged.
+func foldGraphBatchNorm(g *GraphModel) (Model, int) {
+	consumers := make(map[string]int)
+	for _, n := range g.Nodes {
+		for _, in := range n.Inputs {
+			consumers[in]++
+		}
+	}
+
+	byName := make(map[string]*Node, len(g.Nodes))
+	for _, n := range g.Nodes {
+		byName[n.Name] = n
+	}
+
+	replaced := make(map[string]*Node)
+	removed := make(map[string]bool)
+	for _, n := range g.Nodes {
+		bn, isBN := n.Layer.(*BatchNormLayer)
+		if !isBN || n.Inputs[0] == GraphInput {
+			continue
+		}
+		src := byName[n.Inputs[0]]
+		if consumers[src.Name] != 1 || src.Name == g.Output {
+			continue
+		}
+		if conv := foldInto(src.Layer, bn); conv != nil {
+			replaced[n.Name] = &Node{Name: n.Name, Layer: conv, Inputs: src.Inputs}
+			removed[src.Name] = true
+		}
+	}
+
+	var nodes []*Node
+	for _, n := range g.Nodes {
+		switch {
+		case removed[n.Name]:
+		case replaced[n.Name] != nil:
+			nodes = append(nodes, replaced[n.Name])
+		default:
+			nodes = append(nodes, n)
+		}
+	}
+	folded, err := NewGraphModel(nodes, g.Output)
+	if err != nil {
+		// Folding preserves the topology, so this cannot happen
+		panic(fmt.Sprintf("FoldBatchNorm: %v", err))
+	}
+	return folded, len(replaced)
+}
+
+// foldInto returns a copy of the convolution l with bn folded into its weights,
+// or nil if l is not a convolution.
+func foldInto(l Layer, bn *BatchNormLayer) Layer {
+	switch l.(type) {
+	case *Conv2DLayer, *DepthwiseConv2DLayer, *PointwiseConv2DLayer, *Conv1DLayer:
+	default:
+		return nil
+	}
+
+	// All convolution weights are laid out as [out_channels, ...]
+	w, b := l.Params()
+	weights := NewTensor(w.Shape)
+	bias := make([]float32, len(b))
+	perFilter := len(w.Data) / len(b)
+	for f := range bias {
+		scale := bn.Gamma[f] / float32(math.Sqrt(float64(bn.RunningVar[f]+bn.Epsilon)))
+		for j := f * perFilter; j < (f+1)*perFilter; j++ {
+			weights.Data[j] = w.Data[j] * scale
+		}
+		bias[f] = (b[f]-bn.RunningMean[f])*scale + bn.Beta[f]
+	}
+
+	switch conv := l.(type) {
+	case *Conv2DLayer:
+		return NewConv2DLayerWithGeometry(weights, bias, conv.ConvGeometry)
+	case *DepthwiseConv2DLayer:
+		return NewDepthwiseConv2DLayer(weights, bias, conv.ConvGeometry)
+	case *Conv1DLayer:
+		return NewConv1DLayer(weights, bias, conv.Stride, conv.Padding, conv.Dilation)
+	default:
+		return NewPointwiseConv2DLayer(weights, bias)
+	}
+}
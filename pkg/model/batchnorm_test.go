@@ -0,0 +1,255 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func randomTensor(shape []int, rng *rand.Rand) *Tensor {
+	t := NewTensor(shape)
+	for i := range t.Data {
+		t.Data[i] = rng.Float32()*4 - 1
+	}
+	return t
+}
+
+func TestBatchNormForward(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	input := randomTensor([]int{2, 4, 5}, rng)
+
+	t.Run("Training Normalizes Per Channel", func(t *testing.T) {
+		bn := NewBatchNormLayer(2)
+		bn.SetTraining(true)
+		out := bn.Forward(input)
+		mean, variance := channelStats(out)
+		for c := 0; c < 2; c++ {
+			if math.Abs(float64(mean[c])) > 1e-5 || math.Abs(float64(variance[c])-1) > 1e-3 {
+				t.Errorf("Channel %d: expected mean 0 and variance 1, got %f and %f", c, mean[c], variance[c])
+			}
+		}
+
+		// Running statistics move towards the sample statistics
+		inMean, _ := channelStats(input)
+		for c := 0; c < 2; c++ {
+			expected := DefaultBatchNormMomentum * inMean[c]
+			if math.Abs(float64(bn.RunningMean[c]-expected)) > 1e-6 {
+				t.Errorf("Channel %d: expected running mean %f, got %f", c, expected, bn.RunningMean[c])
+			}
+		}
+	})
+
+	t.Run("Inference Uses Running Statistics", func(t *testing.T) {
+		bn := NewBatchNormLayer(2)
+		bn.RunningMean = []float32{1, -1}
+		bn.RunningVar = []float32{4, 0.25}
+		bn.Gamma = []float32{2, 1}
+		bn.Beta = []float32{0.5, 0}
+		out := bn.Forward(input)
+		for i, v := range input.Data {
+			c := i / 20
+			std := math.Sqrt(float64(bn.RunningVar[c] + bn.Epsilon))
+			expected := float64(bn.Gamma[c])*(float64(v)-float64(bn.RunningMean[c]))/std + float64(bn.Beta[c])
+			if math.Abs(float64(out.Data[i])-expected) > 1e-5 {
+				t.Fatalf("Index %d: expected %f, got %f", i, expected, out.Data[i])
+			}
+		}
+		if bn.RunningMean[0] != 1 {
+			t.Error("Running statistics must not change in inference mode")
+		}
+	})
+
+	t.Run("Training Matches Inference After Warm-up", func(t *testing.T) {
+		// Samples with channel means 3.5 and 1 (randomTensor draws from [-1, 3))
+		sample := func() *Tensor {
+			s := randomTensor([]int{2, 4, 5}, rng)
+			for i := range s.Data {
+				if i < 20 {
+					s.Data[i] = 3 + 0.5*s.Data[i]
+				} else {
+					s.Data[i] = -1 + 2*s.Data[i]
+				}
+			}
+			return s
+		}
+
+		bn := NewBatchNormLayer(2)
+		bn.Gamma = []float32{1.5, 0.7}
+		bn.Beta = []float32{0.1, -0.2}
+		bn.SetTraining(true)
+		for i := 0; i < bn.WarmupSteps+100; i++ {
+			bn.Forward(sample())
+		}
+
+		for i := 0; i < 5; i++ {
+			x := sample()
+			bn.SetTraining(false)
+			expected := bn.Forward(x)
+			bn.SetTraining(true)
+			got := bn.Forward(x)
+			for j := range expected.Data {
+				if math.Abs(float64(got.Data[j]-expected.Data[j])) > 1e-5 {
+					t.Fatalf("Sample %d index %d: training output %f, inference output %f", i, j, got.Data[j], expected.Data[j])
+				}
+			}
+		}
+
+		// The running statistics track the data the layer was trained on
+		if math.Abs(float64(bn.RunningMean[0])-3.5) > 0.3 || math.Abs(float64(bn.RunningMean[1])-1) > 0.5 {
+			t.Errorf("Expected running means near 3.5 and 1, got %v", bn.RunningMean)
+		}
+	})
+
+	t.Run("Warm-up Uses Sample Statistics", func(t *testing.T) {
+		bn := NewBatchNormLayer(2)
+		bn.WarmupSteps = 3
+		bn.SetTraining(true)
+		for i := 0; i < 3; i++ {
+			out := bn.Forward(input)
+			mean, _ := channelStats(out)
+			if math.Abs(float64(mean[0])) > 1e-5 {
+				t.Fatalf("Step %d: expected a zero-mean output during the warm-up, got %f", i, mean[0])
+			}
+		}
+		if bn.Updates != 3 {
+			t.Errorf("Expected 3 updates, got %d", bn.Updates)
+		}
+		out := bn.Forward(input)
+		if mean, _ := channelStats(out); math.Abs(float64(mean[0])) < 1e-3 {
+			t.Error("Expected the running statistics to be used after the warm-up")
+		}
+	})
+}
+
+func TestBatchNormBackward(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	input := randomTensor([]int{2, 3, 4}, rng)
+	weights := randomTensor([]int{2, 3, 4}, rng) // loss = sum(out * weights)
+
+	for _, mode := range []string{"Training", "Training After Warm-up", "Inference"} {
+		t.Run(mode, func(t *testing.T) {
+			bn := NewBatchNormLayer(2)
+			bn.Gamma = []float32{1.5, 0.7}
+			bn.Beta = []float32{0.1, -0.2}
+			bn.RunningMean = []float32{0.3, 0.2}
+			bn.RunningVar = []float32{0.9, 1.3}
+			bn.Momentum = 0 // keep the running statistics fixed while probing
+			bn.SetTraining(mode != "Inference")
+			if mode == "Training After Warm-up" {
+				bn.Updates = bn.WarmupSteps
+			} else {
+				bn.WarmupSteps = math.MaxInt // keep probing the warm-up
+			}
+
+			loss := func() float64 {
+				out := bn.Forward(input)
+				var sum float64
+				for i, v := range out.Data {
+					sum += float64(v * weights.Data[i])
+				}
+				return sum
+			}
+
+			gradInput, gradGamma, gradBeta := bn.Backward(input, weights)
+
+			const eps = 1e-2
+			check := func(label string, param []float32, idx int, analytic float32) {
+				orig := param[idx]
+				param[idx] = orig + eps
+				plus := loss()
+				param[idx] = orig - eps
+				minus := loss()
+				param[idx] = orig
+				numeric := (plus - minus) / (2 * eps)
+				if math.Abs(numeric-float64(analytic)) > 2e-2*math.Max(1, math.Abs(numeric)) {
+					t.Errorf("%s[%d]: analytic %f, numeric %f", label, idx, analytic, numeric)
+				}
+			}
+			for i := range input.Data {
+				check("input", input.Data, i, gradInput.Data[i])
+			}
+			for c := 0; c < 2; c++ {
+				check("gamma", bn.Gamma, c, gradGamma.Data[c])
+				check("beta", bn.Beta, c, gradBeta[c])
+			}
+		})
+	}
+}
+
+func TestBatchNormModel(t *testing.T) {
+	configs := []LayerConfig{
+		{Type: "conv2d", Filters: 3, KernelSize: 3, Stride: 1, Padding: 1},
+		{Type: "batchnorm"},
+		{Type: "relu"},
+		{Type: "dense", Units: 1},
+		{Type: "sigmoid"},
+	}
+	m, err := BuildModelFromConfig(configs, []int{1, 6, 5})
+	if err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+	bn := m.Layers[1].(*BatchNormLayer)
+
+	// Populate running statistics and parameters with non-trivial values
+	rng := rand.New(rand.NewSource(3))
+	m.SetTraining(true)
+	if !bn.Training() {
+		t.Fatal("Expected SetTraining to reach the batchnorm layer")
+	}
+	for i := 0; i < 20; i++ {
+		m.Forward(randomTensor([]int{1, 6, 5}, rng))
+	}
+	m.SetTraining(false)
+	bn.Gamma = []float32{1.2, 0.8, -0.5}
+	bn.Beta = []float32{0.1, 0.2, 0.3}
+
+	input := randomTensor([]int{1, 6, 5}, rng)
+	expected := m.Forward(input).Data[0]
+
+	t.Run("Persistence", func(t *testing.T) {
+		tmpDir, err := os.MkdirTemp("", "batchnorm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tmpDir)
+		path := filepath.Join(tmpDir, "model.bin")
+		if err := SaveModel(path, m); err != nil {
+			t.Fatalf("SaveModel failed: %v", err)
+		}
+		loaded, err := LoadModel(path)
+		if err != nil {
+			t.Fatalf("LoadModel failed: %v", err)
+		}
+		loadedBN := loaded.GetLayers()[1].(*BatchNormLayer)
+		if loadedBN.Training() {
+			t.Error("Loaded models should start in inference mode")
+		}
+		if got := loaded.Forward(input).Data[0]; got != expected {
+			t.Errorf("Expected %f after reload, got %f", expected, got)
+		}
+	})
+
+	t.Run("Fold Into Conv", func(t *testing.T) {
+		folded, n := FoldBatchNorm(m)
+		if n != 1 || len(folded.GetLayers()) != 4 {
+			t.Fatalf("Expected 1 folded layer and 4 remaining, got %d and %d", n, len(folded.GetLayers()))
+		}
+		for _, l := range folded.GetLayers() {
+			if l.Type() == "batchnorm" {
+				t.Fatal("Folded model still contains batchnorm")
+			}
+		}
+		if got := folded.Forward(input).Data[0]; math.Abs(float64(got-expected)) > 1e-5 {
+			t.Errorf("Expected %f from folded model, got %f", expected, got)
+		}
+	})
+
+	t.Run("Requires Spatial Input", func(t *testing.T) {
+		_, err := BuildModelFromConfig([]LayerConfig{{Type: "dense", Units: 4}, {Type: "batchnorm"}}, []int{1, 6, 5})
+		if err == nil {
+			t.Error("Expected error for batchnorm after dense")
+		}
+	})
+}
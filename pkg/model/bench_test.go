@@ -1,6 +1,7 @@
 package model
 
 import (
+	"math/rand"
 	"testing"
 )
 
@@ -36,3 +37,35 @@ func BenchmarkMaxPool2D(b *testing.B) {
 		MaxPool2D(input, 2, 2)
 	}
 }
+
+func BenchmarkSparseDense(b *testing.B) {
+	// 128x2440 weights, 90% pruned
+	rng := rand.New(rand.NewSource(1))
+	dense := NewDenseLayer(randomTensor([]int{128, 2440}, rng), make([]float32, 128))
+	zeroWeights(dense, 0.9, rng)
+	input := randomTensor([]int{2440}, rng)
+
+	for _, l := range []Layer{dense, NewSparseDenseLayer(dense)} {
+		b.Run(l.Type(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				l.Forward(input)
+			}
+		})
+	}
+}
+
+func BenchmarkSparseGRU(b *testing.B) {
+	// 100 frames of 40 features, 64 units, 90% pruned
+	rng := rand.New(rand.NewSource(1))
+	gru := NewGRULayer(40, 64)
+	zeroWeights(gru, 0.9, rng)
+	input := randomTensor([]int{100, 40}, rng)
+
+	for _, l := range []Layer{gru, NewSparseGRULayer(gru)} {
+		b.Run(l.Type(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				l.Forward(input)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package model
+
+// BidirectionalLayer runs one recurrent layer (GRU or LSTM) over the frames in
+// order and a second one over the frames in reverse, and concatenates their
+// outputs: [2*hidden] final states, or [seqLen, 2*hidden] with ReturnSequences,
+// where row t holds both directions' hidden states for frame t. The reverse
+// direction needs the whole input, so bidirectional layers cannot stream.
+type BidirectionalLayer struct {
+	Fwd, Bwd        Layer // *GRULayer or *LSTMLayer
+	ReturnSequences bool
+
+	lastInput *Tensor // [seqLen, inputSize] view of the last input
+}
+
+// NewBidirectionalLayer wraps two recurrent layers of the same type and size.
+func NewBidirectionalLayer(fwd, bwd Layer, returnSequences bool) *BidirectionalLayer {
+	for _, l := range []Layer{fwd, bwd} {
+		switch r := l.(type) {
+		case *GRULayer:
+			r.ReturnSequences = returnSequences
+		case *LSTMLayer:
+			r.ReturnSequences = returnSequences
+		}
+	}
+	return &BidirectionalLayer{Fwd: fwd, Bwd: bwd, ReturnSequences: returnSequences}
+}
+
+// returnsSequences reports whether a GRU or LSTM layer outputs every hidden state.
+func returnsSequences(l Layer) bool {
+	switch r := l.(type) {
+	case *GRULayer:
+		return r.ReturnSequences
+	case *LSTMLayer:
+		return r.ReturnSequences
+	case *QuantizedGRULayer:
+		return r.ReturnSequences
+	case *QuantizedLSTMLayer:
+		return r.ReturnSequences
+	case *SparseGRULayer:
+		return r.ReturnSequences
+	}
+	return false
+}
+
+// NewBiGRULayer creates a bidirectional GRU.
+func NewBiGRULayer(inputSize, hiddenSize int, returnSequences bool) *BidirectionalLayer {
+	return NewBidirectionalLayer(NewGRULayer(inputSize, hiddenSize), NewGRULayer(inputSize, hiddenSize), returnSequences)
+}
+
+// NewBiLSTMLayer creates a bidirectional LSTM.
+func NewBiLSTMLayer(inputSize, hiddenSize int, returnSequences bool) *BidirectionalLayer {
+	return NewBidirectionalLayer(NewLSTMLayer(inputSize, hiddenSize), NewLSTMLayer(inputSize, hiddenSize), returnSequences)
+}
+
+// sequence returns the input as [seqLen, features], reading [channels, height,
+// width] CNN outputs the same way GRULayer does.
+func sequence(input *Tensor) *Tensor {
+	if len(input.Shape) != 3 {
+		return input
+	}
+	t := Transpose(input, []int{1, 0, 2})
+	return &Tensor{Data: t.Data, Shape: []int{t.Shape[0], t.Shape[1] * t.Shape[2]}}
+}
+
+// reverseRows returns a copy of a [rows, cols] tensor with the rows in reverse order.
+func reverseRows(x *Tensor) *Tensor {
+	rows, cols := x.Shape[0], len(x.Data)/x.Shape[0]
+	out := &Tensor{Data: make([]float32, len(x.Data)), Shape: x.Shape}
+	for t := 0; t < rows; t++ {
+		copy(out.Data[(rows-1-t)*cols:(rows-t)*cols], x.Data[t*cols:(t+1)*cols])
+	}
+	return out
+}
+
+// reverseMask returns mask in reverse order (nil for nil).
+func reverseMask(mask []bool) []bool {
+	if mask == nil {
+		return nil
+	}
+	out := make([]bool, len(mask))
+	for t, v := range mask {
+		out[len(mask)-1-t] = v
+	}
+	return out
+}
+
+func (b *BidirectionalLayer) Forward(input *Tensor) *Tensor {
+	return b.ForwardMasked(input, nil)
+}
+
+// ForwardMasked skips the frames with mask[t] false in both directions, so the
+// reverse direction starts at the last unmasked frame.
+func (b *BidirectionalLayer) ForwardMasked(input *Tensor, mask []bool) *Tensor {
+	x := sequence(input)
+	b.lastInput = x
+	fwd := b.Fwd.(MaskedLayer).ForwardMasked(x, mask)
+	bwd := b.Bwd.(MaskedLayer).ForwardMasked(reverseRows(x), reverseMask(mask))
+
+	if !b.ReturnSequences {
+		output := NewTensor([]int{len(fwd.Data) + len(bwd.Data)})
+		copy(output.Data, fwd.Data)
+		copy(output.Data[len(fwd.Data):], bwd.Data)
+		return output
+	}
+
+	// Align the reverse outputs with the frames they belong to
+	bwd = reverseRows(bwd)
+	seqLen, hidden := fwd.Shape[0], fwd.Shape[1]
+	output := NewTensor([]int{seqLen, 2 * hidden})
+	for t := 0; t < seqLen; t++ {
+		copy(output.Data[t*2*hidden:], fwd.Data[t*hidden:(t+1)*hidden])
+		copy(output.Data[t*2*hidden+hidden:], bwd.Data[t*hidden:(t+1)*hidden])
+	}
+	return output
+}
+
+// ForwardStateful panics: the reverse direction needs the complete input, so a
+// bidirectional layer has no state to carry between chunks. Use CheckStreamable
+// to reject such models before streaming.
+func (b *BidirectionalLayer) ForwardStateful(input *Tensor) *Tensor {
+	panic(ErrNotStreamable{Type: b.Type()}.Error())
+}
+
+func (b *BidirectionalLayer) ResetState() {
+	b.Fwd.ResetState()
+	b.Bwd.ResetState()
+}
+
+// Backward splits the output gradient between both directions, runs their BPTT
+// and sums the input gradients, undoing the time reversal of the reverse layer.
+func (b *BidirectionalLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	x := b.lastInput
+	var gradFwd, gradBwd *Tensor
+	if b.ReturnSequences {
+		seqLen, hidden := gradOutput.Shape[0], gradOutput.Shape[1]/2
+		gradFwd = NewTensor([]int{seqLen, hidden})
+		gradBwd = NewTensor([]int{seqLen, hidden})
+		for t := 0; t < seqLen; t++ {
+			copy(gradFwd.Data[t*hidden:(t+1)*hidden], gradOutput.Data[t*2*hidden:])
+			copy(gradBwd.Data[t*hidden:(t+1)*hidden], gradOutput.Data[t*2*hidden+hidden:])
+		}
+		gradBwd = reverseRows(gradBwd)
+	} else {
+		hidden := len(gradOutput.Data) / 2
+		gradFwd = &Tensor{Data: gradOutput.Data[:hidden], Shape: []int{hidden}}
+		gradBwd = &Tensor{Data: gradOutput.Data[hidden:], Shape: []int{hidden}}
+	}
+
+	dxFwd, dwFwd, dbFwd := b.Fwd.Backward(x, gradFwd)
+	dxBwd, dwBwd, dbBwd := b.Bwd.Backward(reverseRows(x), gradBwd)
+	dxBwd = reverseRows(dxBwd)
+	gradX := NewTensor(x.Shape)
+	for i := range gradX.Data {
+		gradX.Data[i] = dxFwd.Data[i] + dxBwd.Data[i]
+	}
+
+	gradWeights := &Tensor{Data: append(append([]float32(nil), dwFwd.Data...), dwBwd.Data...), Shape: []int{len(dwFwd.Data) + len(dwBwd.Data)}}
+	gradBias := append(append([]float32(nil), dbFwd...), dbBwd...)
+
+	if len(input.Shape) == 3 {
+		grad := &Tensor{Data: gradX.Data, Shape: []int{input.Shape[1], input.Shape[0], input.Shape[2]}}
+		return Transpose(grad, []int{1, 0, 2}), gradWeights, gradBias
+	}
+	return gradX, gradWeights, gradBias
+}
+
+// Params returns the weights and biases of the forward layer followed by those
+// of the reverse layer.
+func (b *BidirectionalLayer) Params() (*Tensor, []float32) {
+	wFwd, bFwd := b.Fwd.Params()
+	wBwd, bBwd := b.Bwd.Params()
+	weights := &Tensor{Data: append(append([]float32(nil), wFwd.Data...), wBwd.Data...), Shape: []int{len(wFwd.Data) + len(wBwd.Data)}}
+	return weights, append(append([]float32(nil), bFwd...), bBwd...)
+}
+
+func (b *BidirectionalLayer) SetParams(weights *Tensor, bias []float32) {
+	wFwd, bFwd := b.Fwd.Params()
+	nw, nb := len(wFwd.Data), len(bFwd)
+	b.Fwd.SetParams(&Tensor{Data: weights.Data[:nw], Shape: []int{nw}}, bias[:nb])
+	b.Bwd.SetParams(&Tensor{Data: weights.Data[nw:], Shape: []int{len(weights.Data) - nw}}, bias[nb:])
+}
+
+func (b *BidirectionalLayer) Type() string {
+	return "bi" + b.Fwd.Type()
+}
+
+// CheckStreamable returns an ErrNotStreamable error if the model contains layers
+// that need the complete input, such as bidirectional recurrent layers, and so
+// cannot run with ForwardStateful.
+func CheckStreamable(m Model) error {
+	for _, l := range m.GetLayers() {
+		if _, ok := l.(*BidirectionalLayer); ok {
+			return ErrNotStreamable{Type: l.Type()}
+		}
+	}
+	return nil
+}
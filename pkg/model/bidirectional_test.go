@@ -0,0 +1,125 @@
+package model
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestBidirectionalForward(t *testing.T) {
+	rng := rand.New(rand.NewSource(13))
+	ResetRand(13)
+	input := randomTensor([]int{5, 3}, rng)
+
+	for _, seq := range []bool{false, true} {
+		b := NewBiGRULayer(3, 4, seq)
+		out := b.Forward(input)
+
+		// The forward half is a plain GRU, the reverse half a GRU over the reversed frames
+		fwd := *b.Fwd.(*GRULayer)
+		bwd := *b.Bwd.(*GRULayer)
+		expFwd := fwd.Forward(input)
+		expBwd := bwd.Forward(reverseRows(input))
+		if !seq {
+			if !reflect.DeepEqual(out.Shape, []int{8}) {
+				t.Fatalf("Expected shape [8], got %v", out.Shape)
+			}
+			if !reflect.DeepEqual(out.Data[:4], expFwd.Data) || !reflect.DeepEqual(out.Data[4:], expBwd.Data) {
+				t.Errorf("Expected the final states of both directions, got %v", out.Data)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(out.Shape, []int{5, 8}) {
+			t.Fatalf("Expected shape [5 8], got %v", out.Shape)
+		}
+		// Row t holds the reverse state computed after seeing frames T-1..t
+		expBwd = reverseRows(expBwd)
+		for step := 0; step < 5; step++ {
+			row := out.Data[step*8 : (step+1)*8]
+			if !reflect.DeepEqual(row[:4], expFwd.Data[step*4:(step+1)*4]) || !reflect.DeepEqual(row[4:], expBwd.Data[step*4:(step+1)*4]) {
+				t.Errorf("Frame %d: unexpected output %v", step, row)
+			}
+		}
+	}
+}
+
+func TestBidirectionalBackward(t *testing.T) {
+	rng := rand.New(rand.NewSource(14))
+	ResetRand(14)
+	checkRecurrentGradients(t, []Layer{NewBiGRULayer(3, 4, false)}, randomTensor([]int{5, 3}, rng), rng)
+	checkRecurrentGradients(t, []Layer{NewBiLSTMLayer(3, 2, true)}, randomTensor([]int{4, 3}, rng), rng)
+	checkRecurrentGradients(t, []Layer{NewBiGRULayer(6, 2, false)}, randomTensor([]int{2, 4, 3}, rng), rng)
+	checkRecurrentGradients(t, []Layer{NewBiLSTMLayer(3, 2, true), NewBiGRULayer(4, 2, false)}, randomTensor([]int{4, 3}, rng), rng)
+}
+
+func TestBidirectionalModel(t *testing.T) {
+	rng := rand.New(rand.NewSource(15))
+	ResetRand(15)
+	for _, rnn := range []string{"bigru", "bilstm"} {
+		t.Run(rnn, func(t *testing.T) {
+			m, err := BuildModelFromConfig([]LayerConfig{
+				{Type: rnn, Units: 3, ReturnSequences: true},
+				{Type: rnn, Units: 2},
+				{Type: "dense", Units: 1},
+				{Type: "sigmoid"},
+			}, []int{2, 6, 3})
+			if err != nil {
+				t.Fatalf("Failed to build model: %v", err)
+			}
+			if m.Layers[1].Type() != rnn {
+				t.Errorf("Expected type %s, got %s", rnn, m.Layers[1].Type())
+			}
+			if dense := m.Layers[2].(*DenseLayer); dense.Weights.Shape[1] != 4 {
+				t.Errorf("Expected 4 dense inputs, got %d", dense.Weights.Shape[1])
+			}
+			input := randomTensor([]int{2, 6, 3}, rng)
+			if out := m.Layers[0].Forward(input); !reflect.DeepEqual(out.Shape, []int{6, 6}) {
+				t.Errorf("Expected [6 6] sequence output, got %v", out.Shape)
+			}
+			expected := m.Forward(input).Data[0]
+
+			tmpFile := "test_model_bidirectional.bin"
+			if err := SaveModel(tmpFile, m); err != nil {
+				t.Fatalf("SaveModel failed: %v", err)
+			}
+			defer os.Remove(tmpFile)
+			loaded, err := LoadModel(tmpFile)
+			if err != nil {
+				t.Fatalf("LoadModel failed: %v", err)
+			}
+			// Layers hold cached forward state, so compare their parameters
+			for i, l := range loaded.GetLayers() {
+				w, b := l.Params()
+				origW, origB := m.Layers[i].Params()
+				if l.Type() != m.Layers[i].Type() || !reflect.DeepEqual(w, origW) || !reflect.DeepEqual(b, origB) {
+					t.Errorf("Layer %d (%s) differs after reload", i, l.Type())
+				}
+			}
+			if !loaded.GetLayers()[0].(*BidirectionalLayer).ReturnSequences {
+				t.Error("Expected return_sequences to survive a reload")
+			}
+			if got := loaded.Forward(input).Data[0]; got != expected {
+				t.Errorf("Expected %f after reload, got %f", expected, got)
+			}
+
+			var notStreamable ErrNotStreamable
+			if err := CheckStreamable(m); !errors.As(err, &notStreamable) || notStreamable.Type != rnn {
+				t.Errorf("Expected ErrNotStreamable for %s, got %v", rnn, err)
+			}
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Error("Expected ForwardStateful to panic")
+					}
+				}()
+				m.ForwardStateful(input)
+			}()
+		})
+	}
+
+	if err := CheckStreamable(NewSequentialModel(NewGRULayer(3, 2))); err != nil {
+		t.Errorf("Expected a GRU model to be streamable, got %v", err)
+	}
+}
@@ -1,18 +1,161 @@
 package model
 
 import (
+	"fmt"
 	"math"
-	"math/rand"
 )
 
 // LayerConfig defines the configuration for a single layer.
 type LayerConfig struct {
-	Type       string `mapstructure:"type"`
-	Filters    int    `mapstructure:"filters"`
-	KernelSize int    `mapstructure:"kernel"`
-	Stride     int    `mapstructure:"stride"`
-	Padding    int    `mapstructure:"padding"`
-	Units      int    `mapstructure:"units"`
+	Type       string  `mapstructure:"type"`
+	Filters    int     `mapstructure:"filters"`
+	KernelSize int     `mapstructure:"kernel"`
+	Stride     int     `mapstructure:"stride"`
+	Padding    int     `mapstructure:"padding"`
+	Units      int     `mapstructure:"units"`
+	Rate       float32 `mapstructure:"rate"` // Dropout probability
+
+	// Non-square convolutions: the per-axis values (height = time, width =
+	// frequency) override KernelSize, Stride and Padding when set
+	KernelH  int `mapstructure:"kernel_h"`
+	KernelW  int `mapstructure:"kernel_w"`
+	StrideH  int `mapstructure:"stride_h"`
+	StrideW  int `mapstructure:"stride_w"`
+	PaddingH int `mapstructure:"padding_h"`
+	PaddingW int `mapstructure:"padding_w"`
+
+	// Causal drops the zero padding of conv2d and depthwise_conv2d along time,
+	// so every output frame only depends on input frames that have already
+	// arrived and the model can run frame by frame (see NewFrameStream)
+	Causal bool `mapstructure:"causal"`
+
+	Dilation int   `mapstructure:"dilation"` // conv1d tap spacing, default 1
+	Shape    []int `mapstructure:"shape"`    // reshape target, -1 = inferred
+	Perm     []int `mapstructure:"perm"`     // transpose axis order
+
+	ReturnSequences bool `mapstructure:"return_sequences"` // gru/lstm: output every timestep
+
+	// Graph models: a layer reads the previous layer unless Inputs names other
+	// layers (or "input" for the model input); add and concat take several
+	Name   string   `mapstructure:"name"`
+	Inputs []string `mapstructure:"inputs"`
+	Axis   int      `mapstructure:"axis"` // concat axis, default 0 (channels)
+}
+
+// orDefault returns value if it is set (> 0), otherwise fallback.
+func orDefault(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// kernel returns the convolution kernel height and width.
+func (c LayerConfig) kernel() (int, int) {
+	return orDefault(c.KernelH, c.KernelSize), orDefault(c.KernelW, c.KernelSize)
+}
+
+// geometry returns the convolution strides (default 1) and padding.
+func (c LayerConfig) geometry() ConvGeometry {
+	stride := orDefault(c.Stride, 1)
+	g := ConvGeometry{
+		StrideH:  orDefault(c.StrideH, stride),
+		StrideW:  orDefault(c.StrideW, stride),
+		PaddingH: orDefault(c.PaddingH, c.Padding),
+		PaddingW: orDefault(c.PaddingW, c.Padding),
+	}
+	if c.Causal {
+		g.PaddingH = 0
+	}
+	return g
+}
+
+// CausalConfigs returns a copy of configs with Causal set on every layer, the
+// training regime that matches frame-by-frame streaming inference.
+func CausalConfigs(configs []LayerConfig) []LayerConfig {
+	causal := make([]LayerConfig, len(configs))
+	for i, c := range configs {
+		c.Causal = true
+		causal[i] = c
+	}
+	return causal
+}
+
+// convOutputShape validates a convolution input shape and returns the output
+// [channels, height, width].
+func convOutputShape(cfg LayerConfig, inputShape []int, outChannels int) ([]int, error) {
+	if len(inputShape) != 3 {
+		return nil, ErrUnsupportedLayer{Type: cfg.Type + " (requires [channels, height, width] input)"}
+	}
+	kernelHeight, kernelWidth := cfg.kernel()
+	if kernelHeight <= 0 || kernelWidth <= 0 {
+		return nil, fmt.Errorf("invalid %s kernel %dx%d", cfg.Type, kernelHeight, kernelWidth)
+	}
+	outHeight, outWidth := cfg.geometry().OutputSize(inputShape[1], inputShape[2], kernelHeight, kernelWidth)
+	if outHeight <= 0 || outWidth <= 0 {
+		return nil, fmt.Errorf("%s kernel %dx%d does not fit input %dx%d", cfg.Type, kernelHeight, kernelWidth, inputShape[1], inputShape[2])
+	}
+	return []int{outChannels, outHeight, outWidth}, nil
+}
+
+// requires1D returns an error unless shape is [channels, length].
+func requires1D(cfg LayerConfig, shape []int) error {
+	if len(shape) != 2 {
+		return ErrUnsupportedLayer{Type: cfg.Type + " (requires [channels, length] input, see reshape and transpose)"}
+	}
+	return nil
+}
+
+// reshapeTarget resolves a reshape target with at most one -1 (inferred) dimension.
+func reshapeTarget(shape, inputShape []int) ([]int, error) {
+	size := 1
+	for _, dim := range inputShape {
+		size *= dim
+	}
+	target := make([]int, len(shape))
+	known, inferred := 1, -1
+	for i, dim := range shape {
+		switch {
+		case dim == -1 && inferred < 0:
+			inferred = i
+		case dim > 0:
+			known *= dim
+		default:
+			return nil, fmt.Errorf("invalid reshape target %v", shape)
+		}
+		target[i] = dim
+	}
+	if inferred >= 0 && known > 0 && size%known == 0 {
+		target[inferred] = size / known
+		known = size
+	}
+	if len(shape) == 0 || known != size {
+		return nil, fmt.Errorf("cannot reshape %v to %v", inputShape, shape)
+	}
+	return target, nil
+}
+
+// isPermutation reports whether perm contains each of 0..n-1 exactly once.
+func isPermutation(perm []int, n int) bool {
+	if len(perm) != n {
+		return false
+	}
+	seen := make([]bool, n)
+	for _, p := range perm {
+		if p < 0 || p >= n || seen[p] {
+			return false
+		}
+		seen[p] = true
+	}
+	return true
+}
+
+// xavierInit fills weights uniformly in [-limit, limit] with the Xavier/Glorot limit.
+func xavierInit(weights *Tensor, fanIn, fanOut int) {
+	scale := float32(math.Sqrt(6.0 / float64(fanIn+fanOut)))
+	for i := range weights.Data {
+		weights.Data[i] = (randFloat32()*2 - 1) * scale
+	}
 }
 
 // BuildModelFromConfig constructs a SequentialModel from a list of layer configurations.
@@ -21,89 +164,333 @@ func BuildModelFromConfig(configs []LayerConfig, inputShape []int) (*SequentialM
 	currentShape := inputShape
 
 	for _, cfg := range configs {
-		var layer Layer
-		switch cfg.Type {
-		case "conv2d":
-			// weights: [num_filters, input_channels, kernel_height, kernel_width]
-			inChannels := currentShape[0]
-			weightsShape := []int{cfg.Filters, inChannels, cfg.KernelSize, cfg.KernelSize}
-			weights := NewTensor(weightsShape)
-
-			// Xavier initialization
-			fanIn := inChannels * cfg.KernelSize * cfg.KernelSize
-			fanOut := cfg.Filters * cfg.KernelSize * cfg.KernelSize
-			scale := float32(math.Sqrt(6.0 / float64(fanIn+fanOut)))
-			for i := range weights.Data {
-				weights.Data[i] = (rand.Float32()*2 - 1) * scale
-			}
+		layer, outShape, err := buildLayer(cfg, currentShape)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+		currentShape = outShape
+	}
 
-			bias := make([]float32, cfg.Filters)
-			layer = NewConv2DLayer(weights, bias, cfg.Stride, cfg.Padding)
-
-			// Update shape
-			outHeight := (currentShape[1]+2*cfg.Padding-cfg.KernelSize)/cfg.Stride + 1
-			outWidth := (currentShape[2]+2*cfg.Padding-cfg.KernelSize)/cfg.Stride + 1
-			currentShape = []int{cfg.Filters, outHeight, outWidth}
-
-		case "relu":
-			layer = NewReLULayer()
-		case "sigmoid":
-			layer = NewSigmoidLayer()
-		case "maxpool2d":
-			layer = NewMaxPool2DLayer(cfg.KernelSize, cfg.Stride)
-			// Update shape
-			outHeight := (currentShape[1]-cfg.KernelSize)/cfg.Stride + 1
-			outWidth := (currentShape[2]-cfg.KernelSize)/cfg.Stride + 1
-			currentShape = []int{currentShape[0], outHeight, outWidth}
-
-		case "dense":
-			inSize := 1
-			for _, dim := range currentShape {
-				inSize *= dim
-			}
-			weightsShape := []int{cfg.Units, inSize}
-			weights := NewTensor(weightsShape)
+	return NewSequentialModel(layers...), nil
+}
+
+// BuildModel constructs a SequentialModel, or a GraphModel if any layer is named,
+// lists its inputs or merges several inputs.
+func BuildModel(configs []LayerConfig, inputShape []int) (Model, error) {
+	for _, cfg := range configs {
+		if cfg.Name != "" || len(cfg.Inputs) > 0 || cfg.Type == "add" || cfg.Type == "concat" {
+			return BuildGraphFromConfig(configs, inputShape)
+		}
+	}
+	return BuildModelFromConfig(configs, inputShape)
+}
 
-			// Xavier initialization
-			scale := float32(math.Sqrt(6.0 / float64(inSize+cfg.Units)))
-			for i := range weights.Data {
-				weights.Data[i] = (rand.Float32()*2 - 1) * scale
+// BuildGraphFromConfig constructs a GraphModel from a list of layer
+// configurations. Unnamed layers are called "<type>_<index>", layers without
+// Inputs read the previous layer, and the last layer is the model output.
+func BuildGraphFromConfig(configs []LayerConfig, inputShape []int) (*GraphModel, error) {
+	nodes := make([]*Node, len(configs))
+	byName := make(map[string]int, len(configs))
+	for i, cfg := range configs {
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("%s_%d", cfg.Type, i)
+		}
+		inputs := cfg.Inputs
+		if len(inputs) == 0 {
+			inputs = []string{GraphInput}
+			if i > 0 {
+				inputs = []string{nodes[i-1].Name}
 			}
+		}
+		nodes[i] = &Node{Name: name, Inputs: inputs}
+		byName[name] = i
+	}
+
+	// Build the layers in dependency order so input shapes are known
+	sorted, err := sortNodes(nodes)
+	if err != nil {
+		return nil, err
+	}
+	shapes := map[string][]int{GraphInput: inputShape}
+	for _, n := range sorted {
+		cfg := configs[byName[n.Name]]
+		inShapes := make([][]int, len(n.Inputs))
+		for j, in := range n.Inputs {
+			inShapes[j] = shapes[in]
+		}
 
-			bias := make([]float32, cfg.Units)
-			layer = NewDenseLayer(weights, bias)
-			currentShape = []int{cfg.Units}
-
-		case "gru", "lstm":
-			// GRU/LSTM expect input from CNN: [channels, height, width]
-			// They will reshape to [height, channels*width] internally
-			var inputSize int
-			if len(currentShape) == 3 {
-				// From CNN: [channels, height, width]
-				inputSize = currentShape[0] * currentShape[2] // channels * width
-			} else if len(currentShape) == 1 {
-				inputSize = currentShape[0]
+		var outShape []int
+		switch cfg.Type {
+		case "add", "concat":
+			if len(n.Inputs) < 2 {
+				return nil, fmt.Errorf("%s layer %q needs at least two inputs", cfg.Type, n.Name)
+			}
+			if outShape, err = mergeShape(cfg, inShapes); err != nil {
+				return nil, fmt.Errorf("%s layer %q: %w", cfg.Type, n.Name, err)
+			}
+			if cfg.Type == "add" {
+				n.Layer = NewAddLayer()
 			} else {
-				return nil, ErrUnsupportedLayer{Type: cfg.Type + " (invalid input shape)"}
+				n.Layer = NewConcatLayer(cfg.Axis)
 			}
-
-			hiddenSize := cfg.Units
-			if hiddenSize == 0 {
-				hiddenSize = 32 // Default hidden size
+		default:
+			if len(n.Inputs) != 1 {
+				return nil, fmt.Errorf("%s layer %q takes exactly one input", cfg.Type, n.Name)
+			}
+			if n.Layer, outShape, err = buildLayer(cfg, inShapes[0]); err != nil {
+				return nil, err
 			}
+		}
+		shapes[n.Name] = outShape
+	}
 
-			if cfg.Type == "gru" {
-				layer = NewGRULayer(inputSize, hiddenSize)
-			} else {
-				layer = NewLSTMLayer(inputSize, hiddenSize)
+	return NewGraphModel(nodes, nodes[len(nodes)-1].Name)
+}
+
+// mergeShape returns the output shape of an add or concat layer.
+func mergeShape(cfg LayerConfig, shapes [][]int) ([]int, error) {
+	out := append([]int(nil), shapes[0]...)
+	if cfg.Type == "concat" && (cfg.Axis < 0 || cfg.Axis >= len(out)) {
+		return nil, fmt.Errorf("invalid axis %d for input %v", cfg.Axis, out)
+	}
+	for _, shape := range shapes[1:] {
+		if len(shape) != len(out) {
+			return nil, fmt.Errorf("input shapes %v and %v differ", shapes[0], shape)
+		}
+		for d := range shape {
+			switch {
+			case cfg.Type == "concat" && d == cfg.Axis:
+				out[d] += shape[d]
+			case shape[d] != out[d]:
+				return nil, fmt.Errorf("input shapes %v and %v differ", shapes[0], shape)
 			}
-			currentShape = []int{hiddenSize} // Output is just the final hidden state
+		}
+	}
+	return out, nil
+}
+
+// buildLayer creates a freshly initialized layer for an input of the given shape
+// and returns it with its output shape.
+func buildLayer(cfg LayerConfig, currentShape []int) (Layer, []int, error) {
+	var layer Layer
+	switch cfg.Type {
+	case "conv2d":
+		outShape, err := convOutputShape(cfg, currentShape, cfg.Filters)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// weights: [num_filters, input_channels, kernel_height, kernel_width]
+		inChannels := currentShape[0]
+		kernelHeight, kernelWidth := cfg.kernel()
+		weights := NewTensor([]int{cfg.Filters, inChannels, kernelHeight, kernelWidth})
+		xavierInit(weights, inChannels*kernelHeight*kernelWidth, cfg.Filters*kernelHeight*kernelWidth)
+
+		bias := make([]float32, cfg.Filters)
+		layer = NewConv2DLayerWithGeometry(weights, bias, cfg.geometry())
+		currentShape = outShape
+
+	case "depthwise_conv2d":
+		outShape, err := convOutputShape(cfg, currentShape, currentShape[0])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// weights: [channels, 1, kernel_height, kernel_width]
+		channels := currentShape[0]
+		kernelHeight, kernelWidth := cfg.kernel()
+		weights := NewTensor([]int{channels, 1, kernelHeight, kernelWidth})
+		xavierInit(weights, kernelHeight*kernelWidth, kernelHeight*kernelWidth)
+
+		layer = NewDepthwiseConv2DLayer(weights, make([]float32, channels), cfg.geometry())
+		currentShape = outShape
+
+	case "pointwise_conv2d":
+		if len(currentShape) != 3 {
+			return nil, nil, ErrUnsupportedLayer{Type: cfg.Type + " (requires [channels, height, width] input)"}
+		}
+
+		// weights: [num_filters, input_channels, 1, 1]
+		inChannels := currentShape[0]
+		weights := NewTensor([]int{cfg.Filters, inChannels, 1, 1})
+		xavierInit(weights, inChannels, cfg.Filters)
+
+		layer = NewPointwiseConv2DLayer(weights, make([]float32, cfg.Filters))
+		currentShape = []int{cfg.Filters, currentShape[1], currentShape[2]}
+
+	case "conv1d":
+		if err := requires1D(cfg, currentShape); err != nil {
+			return nil, nil, err
+		}
+		stride, dilation := orDefault(cfg.Stride, 1), orDefault(cfg.Dilation, 1)
+		outLength := Conv1DOutputLength(currentShape[1], cfg.KernelSize, stride, cfg.Padding, dilation)
+		if cfg.KernelSize <= 0 || outLength <= 0 {
+			return nil, nil, fmt.Errorf("conv1d kernel %d (dilation %d) does not fit input length %d", cfg.KernelSize, dilation, currentShape[1])
+		}
+
+		// weights: [num_filters, input_channels, kernel]
+		inChannels := currentShape[0]
+		weights := NewTensor([]int{cfg.Filters, inChannels, cfg.KernelSize})
+		xavierInit(weights, inChannels*cfg.KernelSize, cfg.Filters*cfg.KernelSize)
+
+		layer = NewConv1DLayer(weights, make([]float32, cfg.Filters), stride, cfg.Padding, dilation)
+		currentShape = []int{cfg.Filters, outLength}
+
+	case "maxpool1d", "avgpool1d":
+		if err := requires1D(cfg, currentShape); err != nil {
+			return nil, nil, err
+		}
+		stride := orDefault(cfg.Stride, cfg.KernelSize)
+		if cfg.KernelSize <= 0 || cfg.KernelSize > currentShape[1] {
+			return nil, nil, fmt.Errorf("%s kernel %d does not fit input length %d", cfg.Type, cfg.KernelSize, currentShape[1])
+		}
+		if cfg.Type == "maxpool1d" {
+			layer = NewMaxPool1DLayer(cfg.KernelSize, stride)
+		} else {
+			layer = NewAvgPool1DLayer(cfg.KernelSize, stride)
+		}
+		currentShape = []int{currentShape[0], (currentShape[1]-cfg.KernelSize)/stride + 1}
+
+	case "global_avgpool", "global_maxpool":
+		if len(currentShape) < 2 {
+			return nil, nil, ErrUnsupportedLayer{Type: cfg.Type + " (requires [channels, ...] input)"}
+		}
+		if cfg.Type == "global_maxpool" {
+			layer = NewGlobalMaxPoolLayer()
+		} else {
+			layer = NewGlobalAvgPoolLayer()
+		}
+		currentShape = []int{currentShape[0]}
 
+	case "attention_pool":
+		var dim int
+		switch len(currentShape) {
+		case 2: // [channels, frames]
+			dim = currentShape[0]
+		case 3: // [channels, frames, width]
+			dim = currentShape[0] * currentShape[2]
 		default:
-			return nil, ErrUnsupportedLayer{Type: cfg.Type}
+			return nil, nil, ErrUnsupportedLayer{Type: cfg.Type + " (requires [channels, frames] or [channels, frames, width] input)"}
 		}
-		layers = append(layers, layer)
-	}
+		weights := NewTensor([]int{1, dim})
+		xavierInit(weights, dim, 1)
+		layer = NewAttentionPoolLayer(weights)
+		currentShape = []int{dim}
 
-	return NewSequentialModel(layers...), nil
+	case "reshape":
+		target, err := reshapeTarget(cfg.Shape, currentShape)
+		if err != nil {
+			return nil, nil, err
+		}
+		layer = NewReshapeLayer(cfg.Shape)
+		currentShape = target
+
+	case "transpose":
+		if !isPermutation(cfg.Perm, len(currentShape)) {
+			return nil, nil, fmt.Errorf("invalid transpose perm %v for input %v", cfg.Perm, currentShape)
+		}
+		layer = NewTransposeLayer(cfg.Perm)
+		outShape := make([]int, len(cfg.Perm))
+		for i, p := range cfg.Perm {
+			outShape[i] = currentShape[p]
+		}
+		currentShape = outShape
+
+	case "relu":
+		layer = NewReLULayer()
+	case "sigmoid":
+		layer = NewSigmoidLayer()
+	case "softmax":
+		layer = NewSoftmaxLayer()
+	case "maxpool2d":
+		layer = NewMaxPool2DLayer(cfg.KernelSize, cfg.Stride)
+		// Update shape
+		outHeight := (currentShape[1]-cfg.KernelSize)/cfg.Stride + 1
+		outWidth := (currentShape[2]-cfg.KernelSize)/cfg.Stride + 1
+		currentShape = []int{currentShape[0], outHeight, outWidth}
+
+	case "batchnorm":
+		if len(currentShape) != 2 && len(currentShape) != 3 {
+			return nil, nil, ErrUnsupportedLayer{Type: cfg.Type + " (requires [channels, height, width] or [channels, length] input)"}
+		}
+		layer = NewBatchNormLayer(currentShape[0])
+
+	case "dropout", "spatial_dropout":
+		if cfg.Rate < 0 || cfg.Rate >= 1 {
+			return nil, nil, fmt.Errorf("invalid %s rate %g: must be in [0, 1)", cfg.Type, cfg.Rate)
+		}
+		if cfg.Type == "dropout" {
+			layer = NewDropoutLayer(cfg.Rate)
+			break
+		}
+		if len(currentShape) != 3 {
+			return nil, nil, ErrUnsupportedLayer{Type: cfg.Type + " (requires [channels, height, width] input)"}
+		}
+		layer = NewSpatialDropoutLayer(cfg.Rate)
+
+	case "dense":
+		inSize := 1
+		for _, dim := range currentShape {
+			inSize *= dim
+		}
+		weights := NewTensor([]int{cfg.Units, inSize})
+		xavierInit(weights, inSize, cfg.Units)
+
+		bias := make([]float32, cfg.Units)
+		layer = NewDenseLayer(weights, bias)
+		currentShape = []int{cfg.Units}
+
+	case "gru", "lstm", "bigru", "bilstm":
+		// GRU/LSTM expect input from CNN: [channels, height, width]
+		// They will reshape to [height, channels*width] internally.
+		// Stacked recurrent layers read [seqLen, features] sequences.
+		var inputSize, seqLen int
+		if len(currentShape) == 3 {
+			// From CNN: [channels, height, width]
+			inputSize = currentShape[0] * currentShape[2] // channels * width
+			seqLen = currentShape[1]
+		} else if len(currentShape) == 2 {
+			seqLen, inputSize = currentShape[0], currentShape[1]
+		} else if len(currentShape) == 1 {
+			inputSize = currentShape[0]
+		} else {
+			return nil, nil, ErrUnsupportedLayer{Type: cfg.Type + " (invalid input shape)"}
+		}
+
+		hiddenSize := cfg.Units
+		if hiddenSize == 0 {
+			hiddenSize = 32 // Default hidden size
+		}
+
+		outSize := hiddenSize
+		switch cfg.Type {
+		case "gru":
+			gru := NewGRULayer(inputSize, hiddenSize)
+			gru.ReturnSequences = cfg.ReturnSequences
+			layer = gru
+		case "lstm":
+			lstm := NewLSTMLayer(inputSize, hiddenSize)
+			lstm.ReturnSequences = cfg.ReturnSequences
+			layer = lstm
+		case "bigru":
+			layer = NewBiGRULayer(inputSize, hiddenSize, cfg.ReturnSequences)
+			outSize = 2 * hiddenSize // Both directions are concatenated
+		case "bilstm":
+			layer = NewBiLSTMLayer(inputSize, hiddenSize, cfg.ReturnSequences)
+			outSize = 2 * hiddenSize
+		}
+		currentShape = []int{outSize} // Output is just the final hidden state
+		if cfg.ReturnSequences {
+			if seqLen == 0 {
+				return nil, nil, ErrUnsupportedLayer{Type: cfg.Type + " (return_sequences requires a sequence input)"}
+			}
+			currentShape = []int{seqLen, outSize}
+		}
+
+	default:
+		return nil, nil, ErrUnsupportedLayer{Type: cfg.Type}
+	}
+	return layer, currentShape, nil
 }
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"math"
+	"os"
 	"testing"
 )
 
@@ -50,3 +52,81 @@ func TestBuildModelFromConfig(t *testing.T) {
 		t.Errorf("Expected probability in [0, 1], got %f", output.Data[0])
 	}
 }
+
+func TestBuildDSCNN(t *testing.T) {
+	configs := []LayerConfig{
+		{Type: "conv2d", Filters: 8, KernelH: 10, KernelW: 4, StrideH: 2, StrideW: 2, PaddingH: 4, PaddingW: 1},
+		{Type: "batchnorm"},
+		{Type: "relu"},
+		{Type: "depthwise_conv2d", KernelSize: 3, Padding: 1},
+		{Type: "batchnorm"},
+		{Type: "relu"},
+		{Type: "pointwise_conv2d", Filters: 6},
+		{Type: "batchnorm"},
+		{Type: "relu"},
+		{Type: "dense", Units: 1},
+		{Type: "sigmoid"},
+	}
+	inputShape := []int{1, 61, 40}
+	m, err := BuildModelFromConfig(configs, inputShape)
+	if err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+
+	// (61+8-10)/2+1 = 30 frames, (40+2-4)/2+1 = 20 bins
+	input := NewTensor(inputShape)
+	for i := range input.Data {
+		input.Data[i] = float32(i%7) * 0.1
+	}
+	x := input
+	for i, l := range m.Layers[:9] {
+		x = l.Forward(x)
+		if i == 0 && (x.Shape[0] != 8 || x.Shape[1] != 30 || x.Shape[2] != 20) {
+			t.Fatalf("Expected conv output [8 30 20], got %v", x.Shape)
+		}
+	}
+	if x.Shape[0] != 6 || x.Shape[1] != 30 || x.Shape[2] != 20 {
+		t.Fatalf("Expected pointwise output [6 30 20], got %v", x.Shape)
+	}
+
+	// Give the normalization layers non-trivial statistics
+	for _, l := range m.Layers {
+		if bn, ok := l.(*BatchNormLayer); ok {
+			for c := range bn.RunningMean {
+				bn.RunningMean[c] = 0.1 * float32(c)
+				bn.RunningVar[c] = 1 + 0.2*float32(c)
+				bn.Gamma[c] = 1 - 0.1*float32(c)
+			}
+		}
+	}
+	expected := m.Forward(input).Data[0]
+
+	tmpFile := "test_model_dscnn.bin"
+	if err := SaveModel(tmpFile, m); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+	defer os.Remove(tmpFile)
+	loaded, err := LoadModel(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadModel failed: %v", err)
+	}
+	if conv := loaded.GetLayers()[0].(*Conv2DLayer); conv.ConvGeometry != m.Layers[0].(*Conv2DLayer).ConvGeometry {
+		t.Errorf("Expected conv geometry %+v after reload, got %+v", m.Layers[0].(*Conv2DLayer).ConvGeometry, conv.ConvGeometry)
+	}
+	if got := loaded.Forward(input).Data[0]; got != expected {
+		t.Errorf("Expected %f after reload, got %f", expected, got)
+	}
+
+	folded, n := FoldBatchNorm(m)
+	if n != 3 {
+		t.Errorf("Expected 3 folded layers, got %d", n)
+	}
+	if got := folded.Forward(input).Data[0]; math.Abs(float64(got-expected)) > 1e-5 {
+		t.Errorf("Expected %f from folded model, got %f", expected, got)
+	}
+
+	// Kernels larger than the padded input are rejected
+	if _, err := BuildModelFromConfig([]LayerConfig{{Type: "depthwise_conv2d", KernelH: 70, KernelW: 3}}, inputShape); err == nil {
+		t.Error("Expected error for a kernel taller than the input")
+	}
+}
@@ -0,0 +1,36 @@
+package model
+
+import "strings"
+
+// Names of the classes of a multi-class model that are not keywords: speech
+// that is not a keyword, and background noise or silence.
+const (
+	ClassUnknown = "unknown"
+	ClassSilence = "silence"
+)
+
+const metaClasses = "classes"
+
+// IsBackgroundClass reports whether the named class is not a keyword.
+func IsBackgroundClass(name string) bool {
+	return name == ClassUnknown || name == ClassSilence
+}
+
+// SetClasses stores the class names of a multi-class model, in output order.
+func (m Metadata) SetClasses(classes []string) {
+	if len(classes) == 0 {
+		delete(m, metaClasses)
+		return
+	}
+	m[metaClasses] = strings.Join(classes, ",")
+}
+
+// Classes returns the class names of a multi-class model, or nil for a binary
+// model.
+func (m Metadata) Classes() []string {
+	v, ok := m[metaClasses]
+	if !ok || v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestClassesMetadata(t *testing.T) {
+	m, err := BuildModelFromConfig([]LayerConfig{
+		{Type: "dense", Units: 4},
+		{Type: "softmax"},
+	}, []int{1, 3, 2})
+	if err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+	classes := []string{"yes", "no", ClassUnknown, ClassSilence}
+	meta := Metadata{}
+	meta.SetClasses(classes)
+
+	tmpFile := "test_model_classes.bin"
+	if err := SaveModelWithMetadata(tmpFile, m, meta); err != nil {
+		t.Fatalf("SaveModelWithMetadata failed: %v", err)
+	}
+	defer os.Remove(tmpFile)
+	loaded, loadedMeta, err := LoadModelWithMetadata(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadModelWithMetadata failed: %v", err)
+	}
+	if got := loadedMeta.Classes(); !reflect.DeepEqual(got, classes) {
+		t.Errorf("Expected classes %v, got %v", classes, got)
+	}
+	if typ := loaded.GetLayers()[1].Type(); typ != "softmax" {
+		t.Errorf("Expected a softmax output layer, got %s", typ)
+	}
+	input := NewTensor([]int{1, 3, 2})
+	input.Data[0] = 1
+	if got, expected := loaded.Forward(input).Data, m.Forward(input).Data; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v after reload, got %v", expected, got)
+	}
+
+	if got := (Metadata{}).Classes(); got != nil {
+		t.Errorf("Expected no classes for a binary model, got %v", got)
+	}
+	for _, name := range classes {
+		if IsBackgroundClass(name) != (name == ClassUnknown || name == ClassSilence) {
+			t.Errorf("Unexpected background class result for %s", name)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package model
+
+import "sync"
+
+// ConvGeometry holds the strides and zero padding of a 2D convolution along the
+// height (time) and width (frequency) axes of a [channels, height, width] input.
+type ConvGeometry struct {
+	StrideH  int
+	StrideW  int
+	PaddingH int
+	PaddingW int
+}
+
+// SquareGeometry returns a geometry with the same stride and padding on both axes.
+func SquareGeometry(stride, padding int) ConvGeometry {
+	return ConvGeometry{StrideH: stride, StrideW: stride, PaddingH: padding, PaddingW: padding}
+}
+
+// OutputSize returns the output height and width for an input and kernel size.
+func (g ConvGeometry) OutputSize(inHeight, inWidth, kernelHeight, kernelWidth int) (int, int) {
+	outHeight := (inHeight+2*g.PaddingH-kernelHeight)/g.StrideH + 1
+	outWidth := (inWidth+2*g.PaddingW-kernelWidth)/g.StrideW + 1
+	return outHeight, outWidth
+}
+
+// DepthwiseConv2DLayer convolves every input channel with its own kernel.
+// Weights have shape [channels, 1, kernel_height, kernel_width] and the output
+// has as many channels as the input. Followed by a PointwiseConv2DLayer it forms
+// a depthwise-separable convolution, which needs a fraction of the operations
+// and parameters of a full Conv2DLayer.
+type DepthwiseConv2DLayer struct {
+	Weights *Tensor
+	Bias    []float32
+	ConvGeometry
+}
+
+func NewDepthwiseConv2DLayer(weights *Tensor, bias []float32, g ConvGeometry) *DepthwiseConv2DLayer {
+	return &DepthwiseConv2DLayer{
+		Weights:      weights,
+		Bias:         bias,
+		ConvGeometry: g,
+	}
+}
+
+func (l *DepthwiseConv2DLayer) Forward(input *Tensor) *Tensor {
+	return DepthwiseConv2D(input, l.Weights, l.Bias, l.ConvGeometry)
+}
+
+func (l *DepthwiseConv2DLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *DepthwiseConv2DLayer) ResetState() {}
+
+func (l *DepthwiseConv2DLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	return DepthwiseConv2DBackward(input, l.Weights, gradOutput, l.ConvGeometry)
+}
+
+func (l *DepthwiseConv2DLayer) Params() (*Tensor, []float32) {
+	return l.Weights, l.Bias
+}
+
+func (l *DepthwiseConv2DLayer) SetParams(weights *Tensor, bias []float32) {
+	l.Weights = weights
+	l.Bias = bias
+}
+
+func (l *DepthwiseConv2DLayer) Type() string {
+	return "depthwise_conv2d"
+}
+
+// PointwiseConv2DLayer is a 1x1 convolution that mixes channels at every
+// position. Weights have shape [filters, channels, 1, 1].
+type PointwiseConv2DLayer struct {
+	Weights *Tensor
+	Bias    []float32
+}
+
+func NewPointwiseConv2DLayer(weights *Tensor, bias []float32) *PointwiseConv2DLayer {
+	return &PointwiseConv2DLayer{
+		Weights: weights,
+		Bias:    bias,
+	}
+}
+
+func (l *PointwiseConv2DLayer) Forward(input *Tensor) *Tensor {
+	return PointwiseConv2D(input, l.Weights, l.Bias)
+}
+
+func (l *PointwiseConv2DLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *PointwiseConv2DLayer) ResetState() {}
+
+func (l *PointwiseConv2DLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	return PointwiseConv2DBackward(input, l.Weights, gradOutput)
+}
+
+func (l *PointwiseConv2DLayer) Params() (*Tensor, []float32) {
+	return l.Weights, l.Bias
+}
+
+func (l *PointwiseConv2DLayer) SetParams(weights *Tensor, bias []float32) {
+	l.Weights = weights
+	l.Bias = bias
+}
+
+func (l *PointwiseConv2DLayer) Type() string {
+	return "pointwise_conv2d"
+}
+
+// DepthwiseConv2D convolves each channel of a [channels, height, width] input
+// with the matching [1, kernel_height, kernel_width] kernel.
+func DepthwiseConv2D(input, weights *Tensor, bias []float32, g ConvGeometry) *Tensor {
+	channels, inHeight, inWidth := input.Shape[0], input.Shape[1], input.Shape[2]
+	kernelHeight, kernelWidth := weights.Shape[2], weights.Shape[3]
+	outHeight, outWidth := g.OutputSize(inHeight, inWidth, kernelHeight, kernelWidth)
+
+	output := NewTensor([]int{channels, outHeight, outWidth})
+
+	var wg sync.WaitGroup
+	wg.Add(channels)
+	for c := 0; c < channels; c++ {
+		go func(c int) {
+			defer wg.Done()
+			in := input.Data[c*inHeight*inWidth : (c+1)*inHeight*inWidth]
+			kernel := weights.Data[c*kernelHeight*kernelWidth : (c+1)*kernelHeight*kernelWidth]
+			out := output.Data[c*outHeight*outWidth : (c+1)*outHeight*outWidth]
+			for i := 0; i < outHeight; i++ {
+				for j := 0; j < outWidth; j++ {
+					sum := bias[c]
+					for ki := 0; ki < kernelHeight; ki++ {
+						ii := i*g.StrideH - g.PaddingH + ki
+						if ii < 0 || ii >= inHeight {
+							continue
+						}
+						for kj := 0; kj < kernelWidth; kj++ {
+							jj := j*g.StrideW - g.PaddingW + kj
+							if jj >= 0 && jj < inWidth {
+								sum += in[ii*inWidth+jj] * kernel[ki*kernelWidth+kj]
+							}
+						}
+					}
+					out[i*outWidth+j] = sum
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return output
+}
+
+// DepthwiseConv2DBackward calculates the gradients of DepthwiseConv2D. Channels
+// are independent, so they are processed in parallel.
+func DepthwiseConv2DBackward(input, weights, gradOutput *Tensor, g ConvGeometry) (*Tensor, *Tensor, []float32) {
+	channels, inHeight, inWidth := input.Shape[0], input.Shape[1], input.Shape[2]
+	kernelHeight, kernelWidth := weights.Shape[2], weights.Shape[3]
+	outHeight, outWidth := gradOutput.Shape[1], gradOutput.Shape[2]
+
+	gradInput := NewTensor(input.Shape)
+	gradWeights := NewTensor(weights.Shape)
+	gradBias := make([]float32, channels)
+
+	var wg sync.WaitGroup
+	wg.Add(channels)
+	for c := 0; c < channels; c++ {
+		go func(c int) {
+			defer wg.Done()
+			in := input.Data[c*inHeight*inWidth : (c+1)*inHeight*inWidth]
+			dIn := gradInput.Data[c*inHeight*inWidth : (c+1)*inHeight*inWidth]
+			kernel := weights.Data[c*kernelHeight*kernelWidth : (c+1)*kernelHeight*kernelWidth]
+			dKernel := gradWeights.Data[c*kernelHeight*kernelWidth : (c+1)*kernelHeight*kernelWidth]
+			dOut := gradOutput.Data[c*outHeight*outWidth : (c+1)*outHeight*outWidth]
+			for i := 0; i < outHeight; i++ {
+				for j := 0; j < outWidth; j++ {
+					goVal := dOut[i*outWidth+j]
+					gradBias[c] += goVal
+					for ki := 0; ki < kernelHeight; ki++ {
+						ii := i*g.StrideH - g.PaddingH + ki
+						if ii < 0 || ii >= inHeight {
+							continue
+						}
+						for kj := 0; kj < kernelWidth; kj++ {
+							jj := j*g.StrideW - g.PaddingW + kj
+							if jj >= 0 && jj < inWidth {
+								dKernel[ki*kernelWidth+kj] += in[ii*inWidth+jj] * goVal
+								dIn[ii*inWidth+jj] += kernel[ki*kernelWidth+kj] * goVal
+							}
+						}
+					}
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return gradInput, gradWeights, gradBias
+}
+
+// PointwiseConv2D applies a 1x1 convolution to a [channels, height, width] input.
+func PointwiseConv2D(input, weights *Tensor, bias []float32) *Tensor {
+	channels := input.Shape[0]
+	size := input.Shape[1] * input.Shape[2]
+	numFilters := weights.Shape[0]
+
+	output := NewTensor([]int{numFilters, input.Shape[1], input.Shape[2]})
+
+	var wg sync.WaitGroup
+	wg.Add(numFilters)
+	for f := 0; f < numFilters; f++ {
+		go func(f int) {
+			defer wg.Done()
+			out := output.Data[f*size : (f+1)*size]
+			for p := range out {
+				out[p] = bias[f]
+			}
+			for c := 0; c < channels; c++ {
+				w := weights.Data[f*channels+c]
+				for p, v := range input.Data[c*size : (c+1)*size] {
+					out[p] += w * v
+				}
+			}
+		}(f)
+	}
+	wg.Wait()
+
+	return output
+}
+
+// PointwiseConv2DBackward calculates the gradients of PointwiseConv2D.
+func PointwiseConv2DBackward(input, weights, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	channels := input.Shape[0]
+	size := input.Shape[1] * input.Shape[2]
+	numFilters := weights.Shape[0]
+
+	gradInput := NewTensor(input.Shape)
+	gradWeights := NewTensor(weights.Shape)
+	gradBias := make([]float32, numFilters)
+
+	var wg sync.WaitGroup
+	wg.Add(numFilters)
+	for f := 0; f < numFilters; f++ {
+		go func(f int) {
+			defer wg.Done()
+			dOut := gradOutput.Data[f*size : (f+1)*size]
+			for _, goVal := range dOut {
+				gradBias[f] += goVal
+			}
+			for c := 0; c < channels; c++ {
+				var sum float32
+				for p, v := range input.Data[c*size : (c+1)*size] {
+					sum += v * dOut[p]
+				}
+				gradWeights.Data[f*channels+c] = sum
+			}
+		}(f)
+	}
+	wg.Wait()
+
+	// Every filter contributes to every input position; accumulate per channel
+	wg.Add(channels)
+	for c := 0; c < channels; c++ {
+		go func(c int) {
+			defer wg.Done()
+			dIn := gradInput.Data[c*size : (c+1)*size]
+			for f := 0; f < numFilters; f++ {
+				w := weights.Data[f*channels+c]
+				for p, goVal := range gradOutput.Data[f*size : (f+1)*size] {
+					dIn[p] += w * goVal
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return gradInput, gradWeights, gradBias
+}
@@ -0,0 +1,277 @@
+package model
+
+import "sync"
+
+// Conv1DLayer is a temporal convolution over a [channels, length] input, e.g.
+// mel bins as channels and frames as length (TC-ResNet). Weights have shape
+// [filters, channels, kernel]. Dilation spaces the kernel taps, which widens the
+// receptive field without adding parameters.
+type Conv1DLayer struct {
+	Weights  *Tensor
+	Bias     []float32
+	Stride   int
+	Padding  int
+	Dilation int
+}
+
+func NewConv1DLayer(weights *Tensor, bias []float32, stride, padding, dilation int) *Conv1DLayer {
+	return &Conv1DLayer{
+		Weights:  weights,
+		Bias:     bias,
+		Stride:   stride,
+		Padding:  padding,
+		Dilation: dilation,
+	}
+}
+
+func (l *Conv1DLayer) Forward(input *Tensor) *Tensor {
+	return Conv1D(input, l.Weights, l.Bias, l.Stride, l.Padding, l.Dilation)
+}
+
+func (l *Conv1DLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *Conv1DLayer) ResetState() {}
+
+func (l *Conv1DLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	return Conv1DBackward(input, l.Weights, gradOutput, l.Stride, l.Padding, l.Dilation)
+}
+
+func (l *Conv1DLayer) Params() (*Tensor, []float32) {
+	return l.Weights, l.Bias
+}
+
+func (l *Conv1DLayer) SetParams(weights *Tensor, bias []float32) {
+	l.Weights = weights
+	l.Bias = bias
+}
+
+func (l *Conv1DLayer) Type() string {
+	return "conv1d"
+}
+
+// Pool1DLayer pools each channel of a [channels, length] input over windows of
+// KernelSize positions, taking the maximum (maxpool1d) or the mean (avgpool1d).
+type Pool1DLayer struct {
+	KernelSize int
+	Stride     int
+	Average    bool
+}
+
+// NewMaxPool1DLayer creates a 1D max pooling layer.
+func NewMaxPool1DLayer(kernelSize, stride int) *Pool1DLayer {
+	return &Pool1DLayer{KernelSize: kernelSize, Stride: stride}
+}
+
+// NewAvgPool1DLayer creates a 1D average pooling layer.
+func NewAvgPool1DLayer(kernelSize, stride int) *Pool1DLayer {
+	return &Pool1DLayer{KernelSize: kernelSize, Stride: stride, Average: true}
+}
+
+func (l *Pool1DLayer) Forward(input *Tensor) *Tensor {
+	if l.Average {
+		return AvgPool1D(input, l.KernelSize, l.Stride)
+	}
+	return MaxPool1D(input, l.KernelSize, l.Stride)
+}
+
+func (l *Pool1DLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *Pool1DLayer) ResetState() {}
+
+func (l *Pool1DLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	if l.Average {
+		return AvgPool1DBackward(input, gradOutput, l.KernelSize, l.Stride), nil, nil
+	}
+	return MaxPool1DBackward(input, gradOutput, l.KernelSize, l.Stride), nil, nil
+}
+
+func (l *Pool1DLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *Pool1DLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *Pool1DLayer) Type() string {
+	if l.Average {
+		return "avgpool1d"
+	}
+	return "maxpool1d"
+}
+
+// Conv1DOutputLength returns the output length of a 1D convolution.
+func Conv1DOutputLength(length, kernelSize, stride, padding, dilation int) int {
+	return (length+2*padding-dilation*(kernelSize-1)-1)/stride + 1
+}
+
+// Conv1D performs a dilated 1D convolution of a [channels, length] input.
+func Conv1D(input, weights *Tensor, bias []float32, stride, padding, dilation int) *Tensor {
+	inChannels, inLength := input.Shape[0], input.Shape[1]
+	numFilters, kernelSize := weights.Shape[0], weights.Shape[2]
+	outLength := Conv1DOutputLength(inLength, kernelSize, stride, padding, dilation)
+
+	output := NewTensor([]int{numFilters, outLength})
+
+	var wg sync.WaitGroup
+	wg.Add(numFilters)
+	for f := 0; f < numFilters; f++ {
+		go func(f int) {
+			defer wg.Done()
+			out := output.Data[f*outLength : (f+1)*outLength]
+			for t := range out {
+				sum := bias[f]
+				for c := 0; c < inChannels; c++ {
+					in := input.Data[c*inLength : (c+1)*inLength]
+					kernel := weights.Data[(f*inChannels+c)*kernelSize : (f*inChannels+c+1)*kernelSize]
+					for k, w := range kernel {
+						pos := t*stride - padding + k*dilation
+						if pos >= 0 && pos < inLength {
+							sum += in[pos] * w
+						}
+					}
+				}
+				out[t] = sum
+			}
+		}(f)
+	}
+	wg.Wait()
+
+	return output
+}
+
+// Conv1DBackward calculates the gradients of Conv1D.
+func Conv1DBackward(input, weights, gradOutput *Tensor, stride, padding, dilation int) (*Tensor, *Tensor, []float32) {
+	inChannels, inLength := input.Shape[0], input.Shape[1]
+	numFilters, kernelSize := weights.Shape[0], weights.Shape[2]
+	outLength := gradOutput.Shape[1]
+
+	gradInput := NewTensor(input.Shape)
+	gradWeights := NewTensor(weights.Shape)
+	gradBias := make([]float32, numFilters)
+
+	var wg sync.WaitGroup
+	wg.Add(numFilters)
+	for f := 0; f < numFilters; f++ {
+		go func(f int) {
+			defer wg.Done()
+			dOut := gradOutput.Data[f*outLength : (f+1)*outLength]
+			for _, goVal := range dOut {
+				gradBias[f] += goVal
+			}
+			for c := 0; c < inChannels; c++ {
+				in := input.Data[c*inLength : (c+1)*inLength]
+				dKernel := gradWeights.Data[(f*inChannels+c)*kernelSize : (f*inChannels+c+1)*kernelSize]
+				for t, goVal := range dOut {
+					for k := range dKernel {
+						pos := t*stride - padding + k*dilation
+						if pos >= 0 && pos < inLength {
+							dKernel[k] += in[pos] * goVal
+						}
+					}
+				}
+			}
+		}(f)
+	}
+	wg.Wait()
+
+	// Every filter contributes to every input channel; accumulate per channel
+	wg.Add(inChannels)
+	for c := 0; c < inChannels; c++ {
+		go func(c int) {
+			defer wg.Done()
+			dIn := gradInput.Data[c*inLength : (c+1)*inLength]
+			for f := 0; f < numFilters; f++ {
+				kernel := weights.Data[(f*inChannels+c)*kernelSize : (f*inChannels+c+1)*kernelSize]
+				for t, goVal := range gradOutput.Data[f*outLength : (f+1)*outLength] {
+					for k, w := range kernel {
+						pos := t*stride - padding + k*dilation
+						if pos >= 0 && pos < inLength {
+							dIn[pos] += w * goVal
+						}
+					}
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return gradInput, gradWeights, gradBias
+}
+
+// MaxPool1D takes the maximum over windows of each channel of a [channels, length] input.
+func MaxPool1D(input *Tensor, kernelSize, stride int) *Tensor {
+	channels, inLength := input.Shape[0], input.Shape[1]
+	outLength := (inLength-kernelSize)/stride + 1
+	output := NewTensor([]int{channels, outLength})
+	for c := 0; c < channels; c++ {
+		in := input.Data[c*inLength : (c+1)*inLength]
+		for t := 0; t < outLength; t++ {
+			maxVal := in[t*stride]
+			for _, v := range in[t*stride+1 : t*stride+kernelSize] {
+				if v > maxVal {
+					maxVal = v
+				}
+			}
+			output.Data[c*outLength+t] = maxVal
+		}
+	}
+	return output
+}
+
+// MaxPool1DBackward routes each output gradient to the maximum of its window.
+func MaxPool1DBackward(input, gradOutput *Tensor, kernelSize, stride int) *Tensor {
+	channels, inLength := input.Shape[0], input.Shape[1]
+	outLength := gradOutput.Shape[1]
+	gradInput := NewTensor(input.Shape)
+	for c := 0; c < channels; c++ {
+		in := input.Data[c*inLength : (c+1)*inLength]
+		for t := 0; t < outLength; t++ {
+			maxIdx := t * stride
+			for i := maxIdx + 1; i < t*stride+kernelSize; i++ {
+				if in[i] > in[maxIdx] {
+					maxIdx = i
+				}
+			}
+			gradInput.Data[c*inLength+maxIdx] += gradOutput.Data[c*outLength+t]
+		}
+	}
+	return gradInput
+}
+
+// AvgPool1D averages windows of each channel of a [channels, length] input.
+func AvgPool1D(input *Tensor, kernelSize, stride int) *Tensor {
+	channels, inLength := input.Shape[0], input.Shape[1]
+	outLength := (inLength-kernelSize)/stride + 1
+	output := NewTensor([]int{channels, outLength})
+	for c := 0; c < channels; c++ {
+		in := input.Data[c*inLength : (c+1)*inLength]
+		for t := 0; t < outLength; t++ {
+			var sum float32
+			for _, v := range in[t*stride : t*stride+kernelSize] {
+				sum += v
+			}
+			output.Data[c*outLength+t] = sum / float32(kernelSize)
+		}
+	}
+	return output
+}
+
+// AvgPool1DBackward spreads each output gradient evenly over its window.
+func AvgPool1DBackward(input, gradOutput *Tensor, kernelSize, stride int) *Tensor {
+	channels, inLength := input.Shape[0], input.Shape[1]
+	outLength := gradOutput.Shape[1]
+	gradInput := NewTensor(input.Shape)
+	for c := 0; c < channels; c++ {
+		dIn := gradInput.Data[c*inLength : (c+1)*inLength]
+		for t := 0; t < outLength; t++ {
+			g := gradOutput.Data[c*outLength+t] / float32(kernelSize)
+			for i := t * stride; i < t*stride+kernelSize; i++ {
+				dIn[i] += g
+			}
+		}
+	}
+	return gradInput
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestConv1D(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	input := randomTensor([]int{3, 20}, rng)
+
+	t.Run("Dilated Forward", func(t *testing.T) {
+		weights := randomTensor([]int{2, 3, 3}, rng)
+		bias := []float32{0.5, -0.5}
+		output := Conv1D(input, weights, bias, 2, 2, 3)
+
+		// (20+4-3*2-1)/2+1 = 9
+		if output.Shape[0] != 2 || output.Shape[1] != 9 {
+			t.Fatalf("Expected [2 9] output, got %v", output.Shape)
+		}
+		expected := bias[1]
+		for c := 0; c < 3; c++ {
+			for k := 0; k < 3; k++ {
+				if pos := 4*2 - 2 + k*3; pos < 20 {
+					expected += input.Get([]int{c, pos}) * weights.Get([]int{1, c, k})
+				}
+			}
+		}
+		if got := output.Get([]int{1, 4}); math.Abs(float64(got-expected)) > 1e-5 {
+			t.Errorf("Expected %f, got %f", expected, got)
+		}
+	})
+
+	t.Run("Gradients", func(t *testing.T) {
+		checkGradients(t, NewConv1DLayer(randomTensor([]int{2, 3, 3}, rng), []float32{0.1, 0.2}, 2, 2, 3), input, rng)
+		checkGradients(t, NewConv1DLayer(randomTensor([]int{4, 3, 5}, rng), []float32{0, 0, 0, 0}, 1, 0, 1), input, rng)
+	})
+}
+
+func TestPool1D(t *testing.T) {
+	input := &Tensor{Data: []float32{1, 3, 2, 0, 5, 4, 2, 8, 6, 0, -1, -2}, Shape: []int{2, 6}}
+
+	t.Run("MaxPool1D", func(t *testing.T) {
+		l := NewMaxPool1DLayer(3, 2)
+		out := l.Forward(input)
+		if !reflect.DeepEqual(out.Shape, []int{2, 2}) || !reflect.DeepEqual(out.Data, []float32{3, 5, 8, 6}) {
+			t.Fatalf("Expected [3 5 8 6] with shape [2 2], got %v with shape %v", out.Data, out.Shape)
+		}
+		grad, _, _ := l.Backward(input, &Tensor{Data: []float32{1, 2, 3, 4}, Shape: []int{2, 2}})
+		expected := []float32{0, 1, 0, 0, 2, 0, 0, 3, 4, 0, 0, 0}
+		if !reflect.DeepEqual(grad.Data, expected) {
+			t.Errorf("Expected gradient %v, got %v", expected, grad.Data)
+		}
+	})
+
+	t.Run("AvgPool1D", func(t *testing.T) {
+		l := NewAvgPool1DLayer(2, 2)
+		out := l.Forward(input)
+		expected := []float32{2, 1, 4.5, 5, 3, -1.5}
+		if !reflect.DeepEqual(out.Data, expected) {
+			t.Fatalf("Expected %v, got %v", expected, out.Data)
+		}
+		grad, _, _ := l.Backward(input, onesTensor([]int{2, 3}))
+		for i, g := range grad.Data {
+			if g != 0.5 {
+				t.Fatalf("Index %d: expected gradient 0.5, got %f", i, g)
+			}
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -42,3 +44,51 @@ func TestConv2DBackward(t *testing.T) {
 		t.Errorf("gradWeights[0]: expected 12.0, got %f", gradWeights.Data[0])
 	}
 }
+
+// checkGradients compares the analytic gradients of a parameterized layer with
+// central differences of loss = sum(output * lossWeights).
+func checkGradients(t *testing.T, layer Layer, input *Tensor, rng *rand.Rand) {
+	t.Helper()
+	out := layer.Forward(input)
+	lossWeights := randomTensor(out.Shape, rng)
+	loss := func() float64 {
+		var sum float64
+		for i, v := range layer.Forward(input).Data {
+			sum += float64(v * lossWeights.Data[i])
+		}
+		return sum
+	}
+
+	gradInput, gradWeights, gradBias := layer.Backward(input, lossWeights)
+	weights, bias := layer.Params()
+
+	const eps = 1e-2
+	check := func(label string, param []float32, analytic []float32) {
+		for i := range param {
+			orig := param[i]
+			param[i] = orig + eps
+			plus := loss()
+			param[i] = orig - eps
+			minus := loss()
+			param[i] = orig
+			numeric := (plus - minus) / (2 * eps)
+			if math.Abs(numeric-float64(analytic[i])) > 1e-2*math.Max(1, math.Abs(numeric)) {
+				t.Errorf("%s %s[%d]: analytic %f, numeric %f", layer.Type(), label, i, analytic[i], numeric)
+				return
+			}
+		}
+	}
+	check("input", input.Data, gradInput.Data)
+	check("weights", weights.Data, gradWeights.Data)
+	check("bias", bias, gradBias)
+}
+
+func TestSeparableConvBackward(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	input := randomTensor([]int{3, 9, 7}, rng)
+	g := ConvGeometry{StrideH: 2, StrideW: 1, PaddingH: 2, PaddingW: 1}
+
+	checkGradients(t, NewConv2DLayerWithGeometry(randomTensor([]int{2, 3, 5, 3}, rng), []float32{0.1, -0.1}, g), input, rng)
+	checkGradients(t, NewDepthwiseConv2DLayer(randomTensor([]int{3, 1, 5, 3}, rng), []float32{0.1, 0.2, 0.3}, g), input, rng)
+	checkGradients(t, NewPointwiseConv2DLayer(randomTensor([]int{4, 3, 1, 1}, rng), []float32{0.1, 0.2, 0.3, 0.4}), input, rng)
+}
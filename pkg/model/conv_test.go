@@ -1,6 +1,8 @@
 package model
 
 import (
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -57,3 +59,72 @@ func TestConv2D(t *testing.T) {
 		}
 	})
 }
+
+func TestConvGeometry(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	input := randomTensor([]int{3, 12, 9}, rng)
+	g := ConvGeometry{StrideH: 2, StrideW: 1, PaddingH: 4, PaddingW: 1}
+
+	t.Run("Non-Square Kernel And Stride", func(t *testing.T) {
+		weights := randomTensor([]int{2, 3, 10, 4}, rng)
+		bias := []float32{0.5, -0.5}
+		output := Conv2DWithGeometry(input, weights, bias, g)
+
+		// (12+8-10)/2+1 = 6 frames, (9+2-4)/1+1 = 8 bins
+		if output.Shape[1] != 6 || output.Shape[2] != 8 {
+			t.Fatalf("Expected 6x8 output, got %dx%d", output.Shape[1], output.Shape[2])
+		}
+
+		// Output (1, 3, 2) covers input rows 3*2-4.. and columns 2-1..
+		var expected float32
+		for c := 0; c < 3; c++ {
+			for ki := 0; ki < 10; ki++ {
+				for kj := 0; kj < 4; kj++ {
+					ii, jj := 3*2-4+ki, 2-1+kj
+					if ii >= 0 && ii < 12 && jj >= 0 && jj < 9 {
+						expected += input.Get([]int{c, ii, jj}) * weights.Get([]int{1, c, ki, kj})
+					}
+				}
+			}
+		}
+		expected += bias[1]
+		if got := output.Get([]int{1, 3, 2}); math.Abs(float64(got-expected)) > 1e-4 {
+			t.Errorf("Expected %f, got %f", expected, got)
+		}
+	})
+
+	t.Run("Depthwise Matches Grouped Conv2D", func(t *testing.T) {
+		weights := randomTensor([]int{3, 1, 3, 2}, rng)
+		bias := []float32{0.1, 0.2, 0.3}
+
+		// The equivalent dense convolution only connects filter c to channel c
+		dense := NewTensor([]int{3, 3, 3, 2})
+		for c := 0; c < 3; c++ {
+			copy(dense.Data[(c*3+c)*6:(c*3+c+1)*6], weights.Data[c*6:(c+1)*6])
+		}
+		assertTensorsClose(t, DepthwiseConv2D(input, weights, bias, g), Conv2DWithGeometry(input, dense, bias, g))
+	})
+
+	t.Run("Pointwise Matches 1x1 Conv2D", func(t *testing.T) {
+		weights := randomTensor([]int{4, 3, 1, 1}, rng)
+		bias := []float32{0.1, 0.2, 0.3, 0.4}
+		assertTensorsClose(t, PointwiseConv2D(input, weights, bias), Conv2D(input, weights, bias, 1, 0))
+	})
+}
+
+func assertTensorsClose(t *testing.T, got, expected *Tensor) {
+	t.Helper()
+	if len(got.Shape) != len(expected.Shape) {
+		t.Fatalf("Expected shape %v, got %v", expected.Shape, got.Shape)
+	}
+	for i := range expected.Shape {
+		if got.Shape[i] != expected.Shape[i] {
+			t.Fatalf("Expected shape %v, got %v", expected.Shape, got.Shape)
+		}
+	}
+	for i := range expected.Data {
+		if math.Abs(float64(got.Data[i]-expected.Data[i])) > 1e-4 {
+			t.Fatalf("Index %d: expected %f, got %f", i, expected.Data[i], got.Data[i])
+		}
+	}
+}
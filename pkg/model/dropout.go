@@ -0,0 +1,129 @@
+package model
+
+// DropoutLayer randomly zeroes activations during training and scales the kept
+// ones by 1/(1-Rate), so the expected output is unchanged (inverted dropout).
+// With Spatial set, whole channels (the first dimension of the input) are
+// dropped together, which suits convolution outputs whose neighbouring values are
+// strongly correlated.
+//
+// The layer is the identity in inference mode and in ForwardStateful. Masks are
+// drawn from the layer's own random source, started from Seed, so training runs
+// are reproducible. Backward applies the mask of the most recent Forward call.
+type DropoutLayer struct {
+	Rate    float32
+	Spatial bool
+	Seed    uint64
+
+	training bool
+	state    uint64
+	mask     []float32
+}
+
+// NewDropoutLayer creates a dropout layer that drops single activations with the
+// given probability. The seed is taken from the model random source (see ResetRand).
+func NewDropoutLayer(rate float32) *DropoutLayer {
+	l := &DropoutLayer{Rate: rate}
+	l.SetSeed(nextSeed())
+	return l
+}
+
+// NewSpatialDropoutLayer creates a dropout layer that drops whole channels with
+// the given probability.
+func NewSpatialDropoutLayer(rate float32) *DropoutLayer {
+	l := NewDropoutLayer(rate)
+	l.Spatial = true
+	return l
+}
+
+// nextSeed draws a seed for a layer-local random source from the model random source.
+func nextSeed() uint64 {
+	randFloat32()
+	return randState
+}
+
+// SetSeed restarts the random source of the layer from seed.
+func (l *DropoutLayer) SetSeed(seed uint64) {
+	l.Seed = seed
+	l.state = seed
+}
+
+// SetTraining enables (training) or disables (inference) dropout.
+func (l *DropoutLayer) SetTraining(training bool) {
+	l.training = training
+}
+
+// Training reports whether the layer is in training mode.
+func (l *DropoutLayer) Training() bool {
+	return l.training
+}
+
+// keep returns the mask value of one unit: 0 if dropped, 1/(1-Rate) if kept.
+func (l *DropoutLayer) keep() float32 {
+	l.state = l.state*6364136223846793005 + 1442695040888963407
+	if float32(l.state>>33)/float32(1<<31) < l.Rate {
+		return 0
+	}
+	return 1 / (1 - l.Rate)
+}
+
+func (l *DropoutLayer) Forward(input *Tensor) *Tensor {
+	if !l.training || l.Rate <= 0 {
+		l.mask = nil
+		return input
+	}
+
+	if cap(l.mask) < len(input.Data) {
+		l.mask = make([]float32, len(input.Data))
+	}
+	l.mask = l.mask[:len(input.Data)]
+
+	if l.Spatial && len(input.Shape) > 1 {
+		size := len(input.Data) / input.Shape[0]
+		for c := 0; c < input.Shape[0]; c++ {
+			k := l.keep()
+			for i := c * size; i < (c+1)*size; i++ {
+				l.mask[i] = k
+			}
+		}
+	} else {
+		for i := range l.mask {
+			l.mask[i] = l.keep()
+		}
+	}
+
+	output := NewTensor(input.Shape)
+	for i, v := range input.Data {
+		output.Data[i] = v * l.mask[i]
+	}
+	return output
+}
+
+func (l *DropoutLayer) ForwardStateful(input *Tensor) *Tensor {
+	return input
+}
+
+func (l *DropoutLayer) ResetState() {}
+
+func (l *DropoutLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	if l.mask == nil || len(l.mask) != len(gradOutput.Data) {
+		return gradOutput, nil, nil
+	}
+	gradInput := NewTensor(gradOutput.Shape)
+	for i, g := range gradOutput.Data {
+		gradInput.Data[i] = g * l.mask[i]
+	}
+	return gradInput, nil, nil
+}
+
+func (l *DropoutLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *DropoutLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *DropoutLayer) Type() string {
+	if l.Spatial {
+		return "spatial_dropout"
+	}
+	return "dropout"
+}
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func onesTensor(shape []int) *Tensor {
+	t := NewTensor(shape)
+	for i := range t.Data {
+		t.Data[i] = 1
+	}
+	return t
+}
+
+func TestDropoutLayer(t *testing.T) {
+	input := onesTensor([]int{4, 25, 10})
+
+	t.Run("Identity In Inference", func(t *testing.T) {
+		l := NewDropoutLayer(0.5)
+		if out := l.Forward(input); !reflect.DeepEqual(out.Data, input.Data) {
+			t.Error("Expected identity output in inference mode")
+		}
+		l.SetTraining(true)
+		if out := l.ForwardStateful(input); !reflect.DeepEqual(out.Data, input.Data) {
+			t.Error("Expected identity output from ForwardStateful")
+		}
+	})
+
+	t.Run("Drops And Rescales In Training", func(t *testing.T) {
+		l := NewDropoutLayer(0.25)
+		l.SetTraining(true)
+		out := l.Forward(input)
+		dropped := 0
+		var sum float64
+		for _, v := range out.Data {
+			switch {
+			case v == 0:
+				dropped++
+			case math.Abs(float64(v)-1/0.75) > 1e-6:
+				t.Fatalf("Expected kept values scaled to %f, got %f", 1/0.75, v)
+			}
+			sum += float64(v)
+		}
+		rate := float64(dropped) / float64(len(out.Data))
+		if math.Abs(rate-0.25) > 0.05 {
+			t.Errorf("Expected about 25%% dropped, got %.1f%%", rate*100)
+		}
+		if mean := sum / float64(len(out.Data)); math.Abs(mean-1) > 0.1 {
+			t.Errorf("Expected mean activation near 1, got %f", mean)
+		}
+
+		// Backward applies the same mask
+		gradInput, gradW, _ := l.Backward(input, input)
+		if gradW != nil || !reflect.DeepEqual(gradInput.Data, out.Data) {
+			t.Error("Expected gradient masked like the forward output")
+		}
+	})
+
+	t.Run("Spatial Drops Whole Channels", func(t *testing.T) {
+		l := NewSpatialDropoutLayer(0.5)
+		l.SetSeed(7)
+		l.SetTraining(true)
+		out := l.Forward(input)
+		size := 25 * 10
+		for c := 0; c < 4; c++ {
+			channel := out.Data[c*size : (c+1)*size]
+			for _, v := range channel {
+				if v != channel[0] {
+					t.Fatalf("Channel %d is partially dropped", c)
+				}
+			}
+		}
+	})
+
+	t.Run("Seeded Masks", func(t *testing.T) {
+		a, b := NewDropoutLayer(0.5), NewDropoutLayer(0.5)
+		if a.Seed == b.Seed {
+			t.Error("Expected layers to get different seeds")
+		}
+		b.SetSeed(a.Seed)
+		a.SetTraining(true)
+		b.SetTraining(true)
+		if !reflect.DeepEqual(a.Forward(input).Data, b.Forward(input).Data) {
+			t.Error("Expected identical masks for identical seeds")
+		}
+	})
+}
+
+func TestDropoutModel(t *testing.T) {
+	configs := []LayerConfig{
+		{Type: "conv2d", Filters: 2, KernelSize: 3, Stride: 1, Padding: 1},
+		{Type: "spatial_dropout", Rate: 0.2},
+		{Type: "dense", Units: 4},
+		{Type: "dropout", Rate: 0.5},
+		{Type: "dense", Units: 1},
+		{Type: "sigmoid"},
+	}
+	m, err := BuildModelFromConfig(configs, []int{1, 6, 5})
+	if err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "dropout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "model.bin")
+	if err := SaveModel(path, m); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+	loaded, err := LoadModel(path)
+	if err != nil {
+		t.Fatalf("LoadModel failed: %v", err)
+	}
+	for _, i := range []int{1, 3} {
+		orig := m.Layers[i].(*DropoutLayer)
+		got, ok := loaded.GetLayers()[i].(*DropoutLayer)
+		if !ok || got.Type() != orig.Type() || got.Rate != orig.Rate || got.Seed != orig.Seed {
+			t.Errorf("Layer %d: expected %+v after reload, got %+v", i, orig, loaded.GetLayers()[i])
+		}
+	}
+
+	input := onesTensor([]int{1, 6, 5})
+	if a, b := m.Forward(input).Data[0], loaded.Forward(input).Data[0]; a != b {
+		t.Errorf("Expected identical inference output after reload, got %f and %f", a, b)
+	}
+
+	for _, cfg := range []LayerConfig{{Type: "dropout", Rate: 1}, {Type: "dropout", Rate: -0.1}} {
+		if _, err := BuildModelFromConfig([]LayerConfig{cfg}, []int{4}); err == nil {
+			t.Errorf("Expected error for rate %g", cfg.Rate)
+		}
+	}
+	if _, err := BuildModelFromConfig([]LayerConfig{{Type: "spatial_dropout", Rate: 0.1}}, []int{4}); err == nil {
+		t.Error("Expected error for spatial_dropout on 1D input")
+	}
+}
@@ -10,3 +10,13 @@ type ErrUnsupportedLayer struct {
 func (e ErrUnsupportedLayer) Error() string {
 	return fmt.Sprintf("unsupported layer type: %s", e.Type)
 }
+
+// ErrNotStreamable is returned for models that cannot process a stream chunk
+// by chunk, such as models with bidirectional recurrent layers.
+type ErrNotStreamable struct {
+	Type string
+}
+
+func (e ErrNotStreamable) Error() string {
+	return fmt.Sprintf("%s layers need the complete input and cannot be used for streaming", e.Type)
+}
@@ -0,0 +1,430 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GraphInput is the name by which graph nodes refer to the model input.
+const GraphInput = "input"
+
+// Node is a named layer of a GraphModel together with the names of the nodes
+// (or GraphInput) whose outputs it consumes.
+type Node struct {
+	Name   string
+	Layer  Layer
+	Inputs []string
+}
+
+// MergeLayer is implemented by layers that combine the outputs of several nodes,
+// such as residual additions and concatenations.
+type MergeLayer interface {
+	Layer
+	// ForwardMulti combines the inputs into a single output.
+	ForwardMulti(inputs []*Tensor) *Tensor
+	// BackwardMulti returns the gradient of every input.
+	BackwardMulti(inputs []*Tensor, gradOutput *Tensor) []*Tensor
+}
+
+// GraphModel is a model whose layers form a directed acyclic graph, which allows
+// skip connections (ResNet) and multi-branch architectures. Nodes are kept in
+// topological order, so forward passes run in node order and backward passes in
+// reverse node order.
+type GraphModel struct {
+	Nodes  []*Node
+	Output string
+
+	inputIdx  [][]int // Node index of every node input, -1 for the model input
+	outputIdx int
+}
+
+// NewGraphModel validates the graph, sorts the nodes topologically and returns
+// the model. The output defaults to the last node.
+func NewGraphModel(nodes []*Node, output string) (*GraphModel, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("graph model has no nodes")
+	}
+	if output == "" {
+		output = nodes[len(nodes)-1].Name
+	}
+	sorted, err := sortNodes(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &GraphModel{Nodes: sorted, Output: output, outputIdx: -1}
+	index := make(map[string]int, len(sorted))
+	for i, n := range sorted {
+		index[n.Name] = i
+		if n.Name == output {
+			m.outputIdx = i
+		}
+		_, isMerge := n.Layer.(MergeLayer)
+		if !isMerge && len(n.Inputs) != 1 {
+			return nil, fmt.Errorf("node %q (%s) needs exactly one input, got %d", n.Name, n.Layer.Type(), len(n.Inputs))
+		}
+		idx := make([]int, len(n.Inputs))
+		for j, in := range n.Inputs {
+			idx[j] = -1
+			if in != GraphInput {
+				idx[j] = index[in]
+			}
+		}
+		m.inputIdx = append(m.inputIdx, idx)
+	}
+	if m.outputIdx < 0 {
+		return nil, fmt.Errorf("unknown graph output %q", output)
+	}
+	return m, nil
+}
+
+// sortNodes returns the nodes in a topological order that keeps the given order
+// where possible. It fails on duplicate names, unknown inputs and cycles.
+func sortNodes(nodes []*Node) ([]*Node, error) {
+	byName := make(map[string]*Node, len(nodes))
+	for _, n := range nodes {
+		if n.Name == "" || n.Name == GraphInput {
+			return nil, fmt.Errorf("invalid node name %q", n.Name)
+		}
+		if byName[n.Name] != nil {
+			return nil, fmt.Errorf("duplicate node name %q", n.Name)
+		}
+		byName[n.Name] = n
+	}
+	for _, n := range nodes {
+		if len(n.Inputs) == 0 {
+			return nil, fmt.Errorf("node %q has no inputs", n.Name)
+		}
+		for _, in := range n.Inputs {
+			if in != GraphInput && byName[in] == nil {
+				return nil, fmt.Errorf("node %q: unknown input %q", n.Name, in)
+			}
+		}
+	}
+
+	var sorted []*Node
+	done := make(map[string]bool, len(nodes))
+	done[GraphInput] = true
+	for len(sorted) < len(nodes) {
+		progress := false
+		for _, n := range nodes {
+			if done[n.Name] {
+				continue
+			}
+			ready := true
+			for _, in := range n.Inputs {
+				ready = ready && done[in]
+			}
+			if ready {
+				sorted = append(sorted, n)
+				done[n.Name] = true
+				progress = true
+			}
+		}
+		if !progress {
+			var cycle []string
+			for _, n := range nodes {
+				if !done[n.Name] {
+					cycle = append(cycle, n.Name)
+				}
+			}
+			return nil, fmt.Errorf("graph has a cycle through %s", strings.Join(cycle, ", "))
+		}
+	}
+	return sorted, nil
+}
+
+// nodeInputs returns the input tensors of node i given the outputs computed so far.
+func (m *GraphModel) nodeInputs(i int, input *Tensor, outputs []*Tensor) []*Tensor {
+	ins := make([]*Tensor, len(m.inputIdx[i]))
+	for j, idx := range m.inputIdx[i] {
+		if idx < 0 {
+			ins[j] = input
+		} else {
+			ins[j] = outputs[idx]
+		}
+	}
+	return ins
+}
+
+// forward computes the output of every node. A frame mask of the input (nil
+// for none) follows the first input of every node. It also returns the first
+// layer combining frames that the mask did not reach (see Trace).
+func (m *GraphModel) forward(input *Tensor, stateful bool, mask []bool) ([]*Tensor, Layer) {
+	outputs := make([]*Tensor, len(m.Nodes))
+	masks := make([]*frames, len(m.Nodes))
+	lost := make([]bool, len(m.Nodes))
+	var unmasked Layer
+	for i, n := range m.Nodes {
+		ins := m.nodeInputs(i, input, outputs)
+		inMask, inLost := inputFrames(input, mask), false
+		if idx := m.inputIdx[i][0]; idx >= 0 {
+			inMask, inLost = masks[idx], lost[idx]
+		}
+		switch l := n.Layer.(type) {
+		case MergeLayer:
+			outputs[i] = l.ForwardMulti(ins)
+			if inMask != nil && len(outputs[i].Shape) > inMask.axis && outputs[i].Shape[inMask.axis] == len(inMask.valid) {
+				masks[i] = inMask // Merged along another axis
+			}
+			lost[i] = inLost || maskLost(inMask, masks[i], false)
+		default:
+			var received bool
+			if stateful {
+				outputs[i] = l.ForwardStateful(ins[0])
+			} else {
+				outputs[i], received = forwardMasked(l, ins[0], inMask)
+			}
+			if _, ok := l.(MaskedLayer); ok && !stateful && !received && (inMask != nil || inLost) && unmasked == nil {
+				unmasked = l
+			}
+			masks[i] = propagateMask(l, inMask, ins[0], outputs[i])
+			lost[i] = inLost || maskLost(inMask, masks[i], received)
+		}
+	}
+	return outputs, unmasked
+}
+
+// Forward performs the forward pass through all nodes.
+func (m *GraphModel) Forward(input *Tensor) *Tensor {
+	outputs, _ := m.forward(input, false, nil)
+	return outputs[m.outputIdx]
+}
+
+// ForwardStateful performs the forward pass through all nodes, maintaining state in RNNs.
+func (m *GraphModel) ForwardStateful(input *Tensor) *Tensor {
+	outputs, _ := m.forward(input, true, nil)
+	return outputs[m.outputIdx]
+}
+
+// ResetState resets the state of all recurrent layers in the model.
+func (m *GraphModel) ResetState() {
+	for _, n := range m.Nodes {
+		n.Layer.ResetState()
+	}
+}
+
+// SetTraining switches all layers that distinguish training from inference.
+func (m *GraphModel) SetTraining(training bool) {
+	for _, n := range m.Nodes {
+		if l, ok := n.Layer.(TrainingModeSetter); ok {
+			l.SetTraining(training)
+		}
+	}
+}
+
+// GetLayers returns the layers in node order.
+func (m *GraphModel) GetLayers() []Layer {
+	layers := make([]Layer, len(m.Nodes))
+	for i, n := range m.Nodes {
+		layers[i] = n.Layer
+	}
+	return layers
+}
+
+// ForwardTrace performs the forward pass, keeping the output of every node.
+func (m *GraphModel) ForwardTrace(input *Tensor) *Trace {
+	return m.ForwardTraceMasked(input, nil)
+}
+
+// ForwardTraceMasked performs the forward pass of a padded input, keeping the
+// output of every node.
+func (m *GraphModel) ForwardTraceMasked(input *Tensor, mask []bool) *Trace {
+	outputs, unmasked := m.forward(input, false, mask)
+	return &Trace{input: input, outputs: outputs, output: outputs[m.outputIdx], unmasked: unmasked}
+}
+
+// BackwardTrace backpropagates through the nodes in reverse topological order.
+// Gradients of nodes feeding several consumers are summed.
+func (m *GraphModel) BackwardTrace(trace *Trace, gradOutput *Tensor, skipActivation bool, update func(l Layer, gradWeights *Tensor, gradBias []float32)) {
+	grads := make([]*Tensor, len(m.Nodes))
+	accumulate := func(idx int, g *Tensor) {
+		if idx < 0 {
+			return // Gradient of the model input
+		}
+		if grads[idx] == nil {
+			grads[idx] = g
+			return
+		}
+		sum := NewTensor(grads[idx].Shape)
+		for k := range sum.Data {
+			sum.Data[k] = grads[idx].Data[k] + g.Data[k]
+		}
+		grads[idx] = sum
+	}
+
+	out := m.Nodes[m.outputIdx]
+	if _, isMerge := out.Layer.(MergeLayer); skipActivation && !isMerge && isOutputActivation(out.Layer) {
+		accumulate(m.inputIdx[m.outputIdx][0], gradOutput)
+	} else {
+		grads[m.outputIdx] = gradOutput
+	}
+
+	for i := len(m.Nodes) - 1; i >= 0; i-- {
+		if grads[i] == nil {
+			continue
+		}
+		ins := m.nodeInputs(i, trace.input, trace.outputs)
+		switch l := m.Nodes[i].Layer.(type) {
+		case MergeLayer:
+			for j, g := range l.BackwardMulti(ins, grads[i]) {
+				accumulate(m.inputIdx[i][j], g)
+			}
+		default:
+			gradInput, gradWeights, gradBias := l.Backward(ins[0], grads[i])
+			if gradWeights != nil {
+				update(l, gradWeights, gradBias)
+			}
+			accumulate(m.inputIdx[i][0], gradInput)
+		}
+	}
+}
+
+// WithLayers returns a model with the structure of m whose layers are replaced,
+// in GetLayers order, by layers. It is used to copy models layer by layer.
+func WithLayers(m Model, layers []Layer) Model {
+	g, ok := m.(*GraphModel)
+	if !ok {
+		return NewSequentialModel(layers...)
+	}
+	nodes := make([]*Node, len(g.Nodes))
+	for i, n := range g.Nodes {
+		nodes[i] = &Node{Name: n.Name, Layer: layers[i], Inputs: n.Inputs}
+	}
+	return &GraphModel{Nodes: nodes, Output: g.Output, inputIdx: g.inputIdx, outputIdx: g.outputIdx}
+}
+
+// AddLayer sums its inputs element-wise, e.g. for residual connections. All
+// inputs must have the same shape.
+type AddLayer struct{}
+
+func NewAddLayer() *AddLayer {
+	return &AddLayer{}
+}
+
+func (l *AddLayer) ForwardMulti(inputs []*Tensor) *Tensor {
+	output := NewTensor(inputs[0].Shape)
+	for _, in := range inputs {
+		if len(in.Data) != len(output.Data) {
+			panic(fmt.Sprintf("AddLayer: input shape mismatch: %v vs %v", in.Shape, output.Shape))
+		}
+		for i, v := range in.Data {
+			output.Data[i] += v
+		}
+	}
+	return output
+}
+
+func (l *AddLayer) BackwardMulti(inputs []*Tensor, gradOutput *Tensor) []*Tensor {
+	grads := make([]*Tensor, len(inputs))
+	for i := range grads {
+		grads[i] = gradOutput
+	}
+	return grads
+}
+
+func (l *AddLayer) Forward(input *Tensor) *Tensor {
+	return input
+}
+
+func (l *AddLayer) ForwardStateful(input *Tensor) *Tensor {
+	return input
+}
+
+func (l *AddLayer) ResetState() {}
+
+func (l *AddLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	return gradOutput, nil, nil
+}
+
+func (l *AddLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *AddLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *AddLayer) Type() string {
+	return "add"
+}
+
+// ConcatLayer joins its inputs along Axis (0 = channels). The inputs must agree
+// on all other dimensions.
+type ConcatLayer struct {
+	Axis int
+}
+
+func NewConcatLayer(axis int) *ConcatLayer {
+	return &ConcatLayer{Axis: axis}
+}
+
+// concatBlocks returns the number of blocks before the axis and the size of one
+// slice along the axis.
+func concatBlocks(shape []int, axis int) (outer, inner int) {
+	outer, inner = 1, 1
+	for _, dim := range shape[:axis] {
+		outer *= dim
+	}
+	for _, dim := range shape[axis:] {
+		inner *= dim
+	}
+	return outer, inner
+}
+
+func (l *ConcatLayer) ForwardMulti(inputs []*Tensor) *Tensor {
+	shape := append([]int(nil), inputs[0].Shape...)
+	shape[l.Axis] = 0
+	for _, in := range inputs {
+		shape[l.Axis] += in.Shape[l.Axis]
+	}
+	output := NewTensor(shape)
+
+	outer, _ := concatBlocks(shape, l.Axis)
+	pos := 0
+	for b := 0; b < outer; b++ {
+		for _, in := range inputs {
+			_, inner := concatBlocks(in.Shape, l.Axis)
+			pos += copy(output.Data[pos:], in.Data[b*inner:(b+1)*inner])
+		}
+	}
+	return output
+}
+
+func (l *ConcatLayer) BackwardMulti(inputs []*Tensor, gradOutput *Tensor) []*Tensor {
+	grads := make([]*Tensor, len(inputs))
+	for i, in := range inputs {
+		grads[i] = NewTensor(in.Shape)
+	}
+	outer, _ := concatBlocks(gradOutput.Shape, l.Axis)
+	pos := 0
+	for b := 0; b < outer; b++ {
+		for i, in := range inputs {
+			_, inner := concatBlocks(in.Shape, l.Axis)
+			pos += copy(grads[i].Data[b*inner:(b+1)*inner], gradOutput.Data[pos:])
+		}
+	}
+	return grads
+}
+
+func (l *ConcatLayer) Forward(input *Tensor) *Tensor {
+	return input
+}
+
+func (l *ConcatLayer) ForwardStateful(input *Tensor) *Tensor {
+	return input
+}
+
+func (l *ConcatLayer) ResetState() {}
+
+func (l *ConcatLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	return gradOutput, nil, nil
+}
+
+func (l *ConcatLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *ConcatLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *ConcatLayer) Type() string {
+	return "concat"
+}
@@ -0,0 +1,214 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// residualConfig is a small ResNet-style block followed by a branch that is
+// concatenated with its own input. The block uses a smooth activation so
+// numeric gradients are not disturbed by ReLU kinks.
+func residualConfig() []LayerConfig {
+	return []LayerConfig{
+		{Type: "conv2d", Name: "stem", Filters: 2, KernelSize: 3, Padding: 1},
+		{Type: "sigmoid"},
+		{Type: "conv2d", Filters: 2, KernelSize: 3, Padding: 1},
+		{Type: "add", Name: "res", Inputs: []string{"stem", "conv2d_2"}},
+		{Type: "pointwise_conv2d", Name: "branch", Filters: 1},
+		{Type: "concat", Inputs: []string{"res", "branch"}},
+		{Type: "dense", Units: 1},
+		{Type: "sigmoid"},
+	}
+}
+
+func TestBuildGraph(t *testing.T) {
+	m, err := BuildModel(residualConfig(), []int{1, 4, 5})
+	if err != nil {
+		t.Fatalf("Failed to build graph: %v", err)
+	}
+	g, ok := m.(*GraphModel)
+	if !ok {
+		t.Fatalf("Expected a *GraphModel, got %T", m)
+	}
+	if g.Output != "sigmoid_7" {
+		t.Errorf("Expected output sigmoid_7, got %s", g.Output)
+	}
+
+	// Concatenating 2 + 1 channels of 4x5 feeds a dense layer with 60 inputs
+	dense := g.Nodes[6].Layer.(*DenseLayer)
+	if dense.Weights.Shape[1] != 60 {
+		t.Errorf("Expected 60 dense inputs, got %d", dense.Weights.Shape[1])
+	}
+
+	// Configs without names or merge layers still build sequential models
+	seq, err := BuildModel([]LayerConfig{{Type: "dense", Units: 1}}, []int{3})
+	if err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+	if _, ok := seq.(*SequentialModel); !ok {
+		t.Errorf("Expected a *SequentialModel, got %T", seq)
+	}
+
+	for _, tc := range []struct {
+		configs []LayerConfig
+		errText string
+	}{
+		{[]LayerConfig{{Type: "relu", Inputs: []string{"missing"}}}, "unknown input"},
+		{[]LayerConfig{{Type: "relu", Name: "a", Inputs: []string{"b"}}, {Type: "relu", Name: "b", Inputs: []string{"a"}}}, "cycle"},
+		{[]LayerConfig{{Type: "relu", Name: "a"}, {Type: "relu", Name: "a"}}, "duplicate"},
+		{[]LayerConfig{{Type: "add", Inputs: []string{"input"}}}, "at least two"},
+		{[]LayerConfig{{Type: "relu", Name: "a"}, {Type: "relu", Inputs: []string{"a", "input"}}}, "exactly one"},
+		{[]LayerConfig{{Type: "dense", Name: "a", Units: 2}, {Type: "add", Inputs: []string{"a", "input"}}}, "differ"},
+		{[]LayerConfig{{Type: "concat", Axis: 3, Inputs: []string{"input", "input"}}}, "axis"},
+	} {
+		if _, err := BuildModel(tc.configs, []int{1, 4, 5}); err == nil || !strings.Contains(err.Error(), tc.errText) {
+			t.Errorf("Expected error containing %q for %+v, got %v", tc.errText, tc.configs, err)
+		}
+	}
+}
+
+func TestGraphBackward(t *testing.T) {
+	ResetRand(3)
+	m, err := BuildModel(residualConfig(), []int{1, 4, 5})
+	if err != nil {
+		t.Fatalf("Failed to build graph: %v", err)
+	}
+	rng := rand.New(rand.NewSource(3))
+	input := randomTensor([]int{1, 4, 5}, rng)
+
+	// loss = logit, so the gradient with respect to the output is 1
+	loss := func() float64 {
+		p := float64(m.Forward(input).Data[0])
+		return math.Log(p / (1 - p))
+	}
+
+	grads := map[Layer]*Tensor{}
+	trace := m.ForwardTrace(input)
+	if trace.Output().Data[0] != m.Forward(input).Data[0] {
+		t.Fatal("Expected ForwardTrace to match Forward")
+	}
+	m.BackwardTrace(trace, &Tensor{Data: []float32{1}, Shape: []int{1}}, true, func(l Layer, gw *Tensor, gb []float32) {
+		grads[l] = gw
+	})
+	if len(grads) != 4 {
+		t.Fatalf("Expected gradients for 4 layers, got %d", len(grads))
+	}
+
+	// The stem receives gradients from both the residual path and the skip connection
+	const eps = 1e-2
+	for l, gw := range grads {
+		weights, _ := l.Params()
+		for i := 0; i < len(weights.Data); i += 3 {
+			orig := weights.Data[i]
+			weights.Data[i] = orig + eps
+			plus := loss()
+			weights.Data[i] = orig - eps
+			minus := loss()
+			weights.Data[i] = orig
+			numeric := (plus - minus) / (2 * eps)
+			if math.Abs(numeric-float64(gw.Data[i])) > 1e-2*math.Max(1, math.Abs(numeric)) {
+				t.Errorf("%s weights[%d]: analytic %f, numeric %f", l.Type(), i, gw.Data[i], numeric)
+				break
+			}
+		}
+	}
+}
+
+func TestConcatAxis(t *testing.T) {
+	a := &Tensor{Data: []float32{1, 2, 3, 4}, Shape: []int{2, 2}}
+	b := &Tensor{Data: []float32{5, 6}, Shape: []int{2, 1}}
+	l := NewConcatLayer(1)
+	out := l.ForwardMulti([]*Tensor{a, b})
+	if !reflect.DeepEqual(out.Shape, []int{2, 3}) || !reflect.DeepEqual(out.Data, []float32{1, 2, 5, 3, 4, 6}) {
+		t.Fatalf("Expected [1 2 5 3 4 6] with shape [2 3], got %v with shape %v", out.Data, out.Shape)
+	}
+	grads := l.BackwardMulti([]*Tensor{a, b}, out)
+	if !reflect.DeepEqual(grads[0].Data, a.Data) || !reflect.DeepEqual(grads[1].Data, b.Data) {
+		t.Errorf("Expected the gradient to be split back, got %v and %v", grads[0].Data, grads[1].Data)
+	}
+}
+
+func TestGraphPersistence(t *testing.T) {
+	configs := residualConfig()
+	configs[1] = LayerConfig{Type: "batchnorm"}
+	m, err := BuildModel(configs, []int{1, 4, 5})
+	if err != nil {
+		t.Fatalf("Failed to build graph: %v", err)
+	}
+	bn := m.GetLayers()[1].(*BatchNormLayer)
+	bn.RunningMean[0], bn.RunningVar[1] = 0.3, 2
+
+	rng := rand.New(rand.NewSource(8))
+	input := randomTensor([]int{1, 4, 5}, rng)
+	expected := m.Forward(input).Data[0]
+
+	tmpFile := "test_model_graph.bin"
+	if err := SaveModel(tmpFile, m); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+	defer os.Remove(tmpFile)
+	loaded, err := LoadModel(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadModel failed: %v", err)
+	}
+	g, ok := loaded.(*GraphModel)
+	if !ok {
+		t.Fatalf("Expected a *GraphModel after reload, got %T", loaded)
+	}
+	for i, n := range m.(*GraphModel).Nodes {
+		if g.Nodes[i].Name != n.Name || !reflect.DeepEqual(g.Nodes[i].Inputs, n.Inputs) {
+			t.Errorf("Node %d: expected %s%v, got %s%v", i, n.Name, n.Inputs, g.Nodes[i].Name, g.Nodes[i].Inputs)
+		}
+	}
+	if got := loaded.Forward(input).Data[0]; got != expected {
+		t.Errorf("Expected %f after reload, got %f", expected, got)
+	}
+
+	// The batch normalization follows a conv that also feeds the skip
+	// connection, so it cannot be folded into it
+	if _, n := FoldBatchNorm(m); n != 0 {
+		t.Errorf("Expected no folded layers, got %d", n)
+	}
+}
+
+func TestFoldGraphBatchNorm(t *testing.T) {
+	m, err := BuildModel([]LayerConfig{
+		{Type: "conv2d", Filters: 2, KernelSize: 3, Padding: 1},
+		{Type: "batchnorm"},
+		{Type: "relu", Name: "act"},
+		{Type: "conv2d", Filters: 2, KernelSize: 3, Padding: 1},
+		{Type: "batchnorm"},
+		{Type: "add", Inputs: []string{"act", "batchnorm_4"}},
+		{Type: "dense", Units: 1},
+		{Type: "sigmoid"},
+	}, []int{1, 4, 5})
+	if err != nil {
+		t.Fatalf("Failed to build graph: %v", err)
+	}
+	for i, l := range m.GetLayers() {
+		if bn, ok := l.(*BatchNormLayer); ok {
+			bn.RunningMean[0], bn.RunningVar[1] = 0.1*float32(i), 1+0.2*float32(i)
+			bn.Gamma[1], bn.Beta[0] = 1.5, -0.2
+		}
+	}
+
+	rng := rand.New(rand.NewSource(9))
+	input := randomTensor([]int{1, 4, 5}, rng)
+	expected := m.Forward(input).Data[0]
+
+	folded, n := FoldBatchNorm(m)
+	if n != 2 {
+		t.Fatalf("Expected 2 folded layers, got %d", n)
+	}
+	g := folded.(*GraphModel)
+	if len(g.Nodes) != 6 || g.Nodes[2].Name != "batchnorm_4" || g.Nodes[2].Layer.Type() != "conv2d" {
+		t.Errorf("Expected the second conv to take the place of batchnorm_4, got %+v", g.Nodes[2])
+	}
+	if got := folded.Forward(input).Data[0]; math.Abs(float64(got-expected)) > 1e-5 {
+		t.Errorf("Expected %f from folded graph, got %f", expected, got)
+	}
+}
@@ -5,7 +5,9 @@ import (
 )
 
 // GRULayer implements a Gated Recurrent Unit layer for sequence processing.
-// It processes input sequences and learns temporal dependencies.
+// It processes input sequences and learns temporal dependencies. By default it
+// outputs the final hidden state; with ReturnSequences it outputs the hidden
+// state of every timestep, so GRUs can be stacked or feed a per-frame head.
 type GRULayer struct {
 	// Input weights [3, hiddenSize, inputSize] for z, r, h gates
 	Wz, Wr, Wh *Tensor
@@ -14,8 +16,9 @@ type GRULayer struct {
 	// Biases [hiddenSize] for each gate
 	Bz, Br, Bh []float32
 
-	InputSize  int
-	HiddenSize int
+	InputSize       int
+	HiddenSize      int
+	ReturnSequences bool
 
 	// Stateful inference
 	hiddenState []float32
@@ -27,6 +30,7 @@ type GRULayer struct {
 	zSeq               []*Tensor // Update gate values
 	rSeq               []*Tensor // Reset gate values
 	hCandSeq           []*Tensor // Candidate hidden values
+	mask               []bool    // Frames skipped as padding (nil = none)
 }
 
 // NewGRULayer creates a new GRU layer with the specified dimensions.
@@ -73,9 +77,17 @@ func NewGRULayer(inputSize, hiddenSize int) *GRULayer {
 
 // Forward processes a sequence through the GRU.
 // Input shape: [seqLen, inputSize]
-// Output: final hidden state [hiddenSize]
+// Output: final hidden state [hiddenSize], or [seqLen, hiddenSize] with ReturnSequences
 func (g *GRULayer) Forward(input *Tensor) *Tensor {
-	return g.forwardInternal(input, nil)
+	return g.forwardInternal(input, nil, nil)
+}
+
+// ForwardMasked is Forward for a padded sequence: timesteps with mask[t] false
+// leave the hidden state unchanged, output zeros with ReturnSequences and are
+// skipped by Backward, so neither the output nor the gradients depend on how
+// much padding there is.
+func (g *GRULayer) ForwardMasked(input *Tensor, mask []bool) *Tensor {
+	return g.forwardInternal(input, nil, mask)
 }
 
 // ForwardStateful processes a sequence maintaining internal hidden state.
@@ -83,8 +95,8 @@ func (g *GRULayer) ForwardStateful(input *Tensor) *Tensor {
 	if g.hiddenState == nil {
 		g.hiddenState = make([]float32, g.HiddenSize)
 	}
-	out := g.forwardInternal(input, g.hiddenState)
-	copy(g.hiddenState, out.Data)
+	out := g.forwardInternal(input, g.hiddenState, nil)
+	copy(g.hiddenState, g.hiddenSeq[len(g.hiddenSeq)-1].Data)
 	return out
 }
 
@@ -93,7 +105,7 @@ func (g *GRULayer) ResetState() {
 	g.hiddenState = nil
 }
 
-func (g *GRULayer) forwardInternal(input *Tensor, initialH []float32) *Tensor {
+func (g *GRULayer) forwardInternal(input *Tensor, initialH []float32, mask []bool) *Tensor {
 	// Flatten input if it's 3D (from CNN output)
 	var flatInput *Tensor
 	var seqLen int
@@ -128,6 +140,7 @@ func (g *GRULayer) forwardInternal(input *Tensor, initialH []float32) *Tensor {
 	}
 
 	g.lastInput = flatInput
+	g.mask = mask
 
 	// Initialize hidden state
 	h := make([]float32, g.HiddenSize)
@@ -149,6 +162,11 @@ func (g *GRULayer) forwardInternal(input *Tensor, initialH []float32) *Tensor {
 
 	// Process sequence
 	for t := 0; t < seqLen; t++ {
+		if skipped(mask, t) {
+			g.hiddenSeq[t+1] = g.hiddenSeq[t]
+			continue
+		}
+
 		// Get input at timestep t
 		xt := flatInput.Data[t*inputSize : (t+1)*inputSize]
 
@@ -206,8 +224,15 @@ func (g *GRULayer) forwardInternal(input *Tensor, initialH []float32) *Tensor {
 		h = newH
 	}
 
-	// Return final hidden state
-	return &Tensor{Data: h, Shape: []int{g.HiddenSize}}
+	return g.output()
+}
+
+// output returns the final hidden state, or all hidden states with ReturnSequences.
+func (g *GRULayer) output() *Tensor {
+	if g.ReturnSequences {
+		return stackStates(g.hiddenSeq[1:], g.HiddenSize, g.mask)
+	}
+	return g.hiddenSeq[len(g.hiddenSeq)-1]
 }
 
 // ForwardWithMask processes a sequence through the GRU up to actualSeqLen timesteps.
@@ -215,36 +240,17 @@ func (g *GRULayer) forwardInternal(input *Tensor, initialH []float32) *Tensor {
 // and returning the hidden state at that position (ignoring padded positions).
 // Input shape: [seqLen, inputSize] (same as Forward)
 // actualSeqLen: the actual number of valid timesteps (before padding)
-// Output: hidden state at timestep actualSeqLen-1 [hiddenSize]
+// Output: hidden state at timestep actualSeqLen-1 [hiddenSize], or the first
+// actualSeqLen hidden states [actualSeqLen, hiddenSize] with ReturnSequences
 func (g *GRULayer) ForwardWithMask(input *Tensor, actualSeqLen int) *Tensor {
-	// Flatten input if it's 3D (from CNN output)
-	var flatInput *Tensor
+	// The sequence runs along the height of 3D (CNN) inputs
 	var seqLen int
-
-	if len(input.Shape) == 3 {
-		// Input is [channels, height, width] - treat height as sequence length
-		g.originalInputShape = input.Shape
-		channels := input.Shape[0]
-		height := input.Shape[1]
-		width := input.Shape[2]
-		seqLen = height
-		featureSize := channels * width
-
-		flatInput = NewTensor([]int{seqLen, featureSize})
-		for t := 0; t < seqLen; t++ {
-			for c := 0; c < channels; c++ {
-				for w := 0; w < width; w++ {
-					srcIdx := c*height*width + t*width + w
-					dstIdx := t*featureSize + c*width + w
-					flatInput.Data[dstIdx] = input.Data[srcIdx]
-				}
-			}
-		}
-	} else if len(input.Shape) == 2 {
-		g.originalInputShape = nil
-		flatInput = input
+	switch len(input.Shape) {
+	case 3:
+		seqLen = input.Shape[1]
+	case 2:
 		seqLen = input.Shape[0]
-	} else {
+	default:
 		return nil
 	}
 
@@ -256,82 +262,11 @@ func (g *GRULayer) ForwardWithMask(input *Tensor, actualSeqLen int) *Tensor {
 		actualSeqLen = seqLen
 	}
 
-	g.lastInput = flatInput
-
-	// Initialize hidden state
-	h := make([]float32, g.HiddenSize)
-
-	// Store sequences for backward pass (only up to actualSeqLen)
-	g.hiddenSeq = make([]*Tensor, actualSeqLen+1)
-	g.zSeq = make([]*Tensor, actualSeqLen)
-	g.rSeq = make([]*Tensor, actualSeqLen)
-	g.hCandSeq = make([]*Tensor, actualSeqLen)
-
-	g.hiddenSeq[0] = &Tensor{Data: make([]float32, g.HiddenSize), Shape: []int{g.HiddenSize}}
-	copy(g.hiddenSeq[0].Data, h)
-
-	inputSize := flatInput.Shape[1]
-
-	// Process sequence only up to actualSeqLen (ignore padding)
-	for t := 0; t < actualSeqLen; t++ {
-		xt := flatInput.Data[t*inputSize : (t+1)*inputSize]
-
-		// Update gate: z = sigmoid(Wz*x + Uz*h + bz)
-		z := make([]float32, g.HiddenSize)
-		for i := 0; i < g.HiddenSize; i++ {
-			sum := g.Bz[i]
-			for j := 0; j < inputSize; j++ {
-				sum += g.Wz.Data[i*inputSize+j] * xt[j]
-			}
-			for j := 0; j < g.HiddenSize; j++ {
-				sum += g.Uz.Data[i*g.HiddenSize+j] * h[j]
-			}
-			z[i] = sigmoid32(sum)
-		}
-
-		// Reset gate: r = sigmoid(Wr*x + Ur*h + br)
-		r := make([]float32, g.HiddenSize)
-		for i := 0; i < g.HiddenSize; i++ {
-			sum := g.Br[i]
-			for j := 0; j < inputSize; j++ {
-				sum += g.Wr.Data[i*inputSize+j] * xt[j]
-			}
-			for j := 0; j < g.HiddenSize; j++ {
-				sum += g.Ur.Data[i*g.HiddenSize+j] * h[j]
-			}
-			r[i] = sigmoid32(sum)
-		}
-
-		// Candidate hidden: h_cand = tanh(Wh*x + Uh*(r*h) + bh)
-		hCand := make([]float32, g.HiddenSize)
-		for i := 0; i < g.HiddenSize; i++ {
-			sum := g.Bh[i]
-			for j := 0; j < inputSize; j++ {
-				sum += g.Wh.Data[i*inputSize+j] * xt[j]
-			}
-			for j := 0; j < g.HiddenSize; j++ {
-				sum += g.Uh.Data[i*g.HiddenSize+j] * (r[j] * h[j])
-			}
-			hCand[i] = tanh32(sum)
-		}
-
-		// New hidden state: h = (1-z)*h + z*h_cand
-		newH := make([]float32, g.HiddenSize)
-		for i := 0; i < g.HiddenSize; i++ {
-			newH[i] = (1-z[i])*h[i] + z[i]*hCand[i]
-		}
-
-		// Store for backward pass
-		g.zSeq[t] = &Tensor{Data: z, Shape: []int{g.HiddenSize}}
-		g.rSeq[t] = &Tensor{Data: r, Shape: []int{g.HiddenSize}}
-		g.hCandSeq[t] = &Tensor{Data: hCand, Shape: []int{g.HiddenSize}}
-		g.hiddenSeq[t+1] = &Tensor{Data: newH, Shape: []int{g.HiddenSize}}
-
-		h = newH
+	out := g.ForwardMasked(input, PrefixMask(seqLen, actualSeqLen))
+	if g.ReturnSequences {
+		return &Tensor{Data: out.Data[:actualSeqLen*g.HiddenSize], Shape: []int{actualSeqLen, g.HiddenSize}}
 	}
-
-	// Return hidden state at actualSeqLen (not the padded end)
-	return &Tensor{Data: h, Shape: []int{g.HiddenSize}}
+	return out
 }
 
 // Backward computes gradients for the GRU layer using BPTT.
@@ -350,15 +285,27 @@ func (g *GRULayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []floa
 	dBr := make([]float32, g.HiddenSize)
 	dBh := make([]float32, g.HiddenSize)
 
-	// Gradient of hidden state (starts from output gradient)
+	// Gradient of hidden state (starts from output gradient; with
+	// ReturnSequences every timestep adds its own output gradient below)
 	dh := make([]float32, g.HiddenSize)
-	copy(dh, gradOutput.Data)
+	if !g.ReturnSequences {
+		copy(dh, gradOutput.Data)
+	}
 
 	// Gradient w.r.t. input
 	dInput := NewTensor(g.lastInput.Shape)
 
-	// Backprop through time
+	// Backprop through time; skipped timesteps pass dh through unchanged
 	for t := seqLen - 1; t >= 0; t-- {
+		if skipped(g.mask, t) {
+			continue
+		}
+		if g.ReturnSequences {
+			for i, v := range gradOutput.Data[t*g.HiddenSize : (t+1)*g.HiddenSize] {
+				dh[i] += v
+			}
+		}
+
 		xt := g.lastInput.Data[t*inputSize : (t+1)*inputSize]
 		hPrev := g.hiddenSeq[t].Data
 		z := g.zSeq[t].Data
@@ -377,14 +324,15 @@ func (g *GRULayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []floa
 			dhCand[i] = dh[i] * z[i] * (1 - hCand[i]*hCand[i])
 		}
 
-		// Gradient of reset gate
+		// Gradient of reset gate: r[j] scales hPrev[j] inside Uh*(r*h), so it
+		// collects the candidate gradients of every unit through column j of Uh
 		dr := make([]float32, g.HiddenSize)
-		for i := 0; i < g.HiddenSize; i++ {
-			var urh float32
-			for j := 0; j < g.HiddenSize; j++ {
-				urh += g.Uh.Data[i*g.HiddenSize+j] * hPrev[j]
+		for j := 0; j < g.HiddenSize; j++ {
+			var dRH float32
+			for i := 0; i < g.HiddenSize; i++ {
+				dRH += dhCand[i] * g.Uh.Data[i*g.HiddenSize+j]
 			}
-			dr[i] = dhCand[i] * urh * r[i] * (1 - r[i])
+			dr[j] = dRH * hPrev[j] * r[j] * (1 - r[j])
 		}
 
 		// Accumulate weight gradients
@@ -538,6 +486,19 @@ func (g *GRULayer) Type() string {
 }
 
 // Helper functions
+
+// stackStates copies per-timestep states into a [seqLen, size] tensor, leaving
+// the rows of masked timesteps zero.
+func stackStates(states []*Tensor, size int, mask []bool) *Tensor {
+	out := NewTensor([]int{len(states), size})
+	for t, st := range states {
+		if !skipped(mask, t) {
+			copy(out.Data[t*size:(t+1)*size], st.Data)
+		}
+	}
+	return out
+}
+
 func sigmoid32(x float32) float32 {
 	return 1.0 / (1.0 + float32(math.Exp(float64(-x))))
 }
@@ -555,7 +516,8 @@ func randFloat32() float32 {
 	return float32(randState>>33) / float32(1<<31)
 }
 
-// ResetRand resets the random state for reproducible testing
+// ResetRand resets the random state used for weight initialization, for
+// reproducible training and testing
 func ResetRand(seed uint64) {
 	randState = seed
 }
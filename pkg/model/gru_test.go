@@ -1,6 +1,10 @@
 package model
 
 import (
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
 	"testing"
 )
 
@@ -140,6 +144,24 @@ func TestGRUBackward(t *testing.T) {
 	_ = output
 }
 
+// TestGRUResetGateGradient guards the reset gate gradient of a final-state GRU.
+// r[j] scales hPrev[j] inside Uh*(r*h), so dL/dr[j] sums the candidate gradients
+// through column j of Uh; an asymmetric Uh tells it apart from the row sum that
+// earlier versions computed.
+func TestGRUResetGateGradient(t *testing.T) {
+	rng := rand.New(rand.NewSource(43))
+	ResetRand(43)
+
+	gru := NewGRULayer(2, 3)
+	copy(gru.Uh.Data, []float32{
+		0, 1.2, -0.4,
+		-1.2, 0, 0.8,
+		0.4, -0.8, 0,
+	})
+	copy(gru.Br, []float32{0.3, -0.2, 0.1})
+	checkRecurrentGradients(t, []Layer{gru}, randomTensor([]int{4, 2}, rng), rng)
+}
+
 func TestGRUParams(t *testing.T) {
 	ResetRand(42)
 
@@ -189,3 +211,149 @@ func TestGRUType(t *testing.T) {
 		t.Errorf("Expected type 'gru', got '%s'", gru.Type())
 	}
 }
+
+// checkRecurrentGradients compares the analytic gradients of a stack of layers
+// with central differences of loss = sum(output * lossWeights). Parameters are
+// perturbed through SetParams since recurrent layers return copies from Params.
+func checkRecurrentGradients(t *testing.T, layers []Layer, input *Tensor, rng *rand.Rand) {
+	t.Helper()
+	forward := func() *Tensor {
+		x := input
+		for _, l := range layers {
+			x = l.Forward(x)
+		}
+		return x
+	}
+	lossWeights := randomTensor(forward().Shape, rng)
+	loss := func() float64 {
+		var sum float64
+		for i, v := range forward().Data {
+			sum += float64(v * lossWeights.Data[i])
+		}
+		return sum
+	}
+
+	// Backward through the stack, keeping the parameter gradients of every layer
+	inputs := []*Tensor{input}
+	for _, l := range layers[:len(layers)-1] {
+		inputs = append(inputs, l.Forward(inputs[len(inputs)-1]))
+	}
+	layers[len(layers)-1].Forward(inputs[len(inputs)-1])
+	grad := lossWeights
+	gradWeights := make([]*Tensor, len(layers))
+	gradBias := make([][]float32, len(layers))
+	for i := len(layers) - 1; i >= 0; i-- {
+		grad, gradWeights[i], gradBias[i] = layers[i].Backward(inputs[i], grad)
+	}
+
+	const eps = 1e-2
+	check := func(label string, values, analytic []float32, set func()) {
+		for i := range values {
+			orig := values[i]
+			values[i] = orig + eps
+			set()
+			plus := loss()
+			values[i] = orig - eps
+			set()
+			minus := loss()
+			values[i] = orig
+			set()
+			numeric := (plus - minus) / (2 * eps)
+			if math.Abs(numeric-float64(analytic[i])) > 1e-2*math.Max(1, math.Abs(numeric)) {
+				t.Errorf("%s[%d]: analytic %f, numeric %f", label, i, analytic[i], numeric)
+				return
+			}
+		}
+	}
+	check("input", input.Data, grad.Data, func() {})
+	for i, l := range layers {
+		weights, bias := l.Params()
+		set := func() { l.SetParams(weights, bias) }
+		check(l.Type()+" weights", weights.Data, gradWeights[i].Data, set)
+		check(l.Type()+" bias", bias, gradBias[i], set)
+	}
+}
+
+func TestStackedRecurrentLayers(t *testing.T) {
+	rng := rand.New(rand.NewSource(12))
+	ResetRand(12)
+
+	t.Run("Builder", func(t *testing.T) {
+		m, err := BuildModelFromConfig([]LayerConfig{
+			{Type: "gru", Units: 6, ReturnSequences: true},
+			{Type: "lstm", Units: 5, ReturnSequences: true},
+			{Type: "gru", Units: 4},
+			{Type: "dense", Units: 1},
+			{Type: "sigmoid"},
+		}, []int{2, 7, 3})
+		if err != nil {
+			t.Fatalf("Failed to build model: %v", err)
+		}
+		lstm := m.Layers[1].(*LSTMLayer)
+		if lstm.InputSize != 6 || !lstm.ReturnSequences {
+			t.Errorf("Expected a sequence LSTM with 6 inputs, got %d inputs (return_sequences %v)", lstm.InputSize, lstm.ReturnSequences)
+		}
+		if gru := m.Layers[2].(*GRULayer); gru.InputSize != 5 || gru.ReturnSequences {
+			t.Errorf("Expected a final-state GRU with 5 inputs, got %d inputs", gru.InputSize)
+		}
+		input := randomTensor([]int{2, 7, 3}, rng)
+		if out := m.Layers[0].Forward(input); !reflect.DeepEqual(out.Shape, []int{7, 6}) {
+			t.Errorf("Expected [7 6] sequence output, got %v", out.Shape)
+		}
+		expected := m.Forward(input).Data[0]
+
+		tmpFile := "test_model_stacked_rnn.bin"
+		if err := SaveModel(tmpFile, m); err != nil {
+			t.Fatalf("SaveModel failed: %v", err)
+		}
+		defer os.Remove(tmpFile)
+		loaded, err := LoadModel(tmpFile)
+		if err != nil {
+			t.Fatalf("LoadModel failed: %v", err)
+		}
+		if !loaded.GetLayers()[0].(*GRULayer).ReturnSequences || !loaded.GetLayers()[1].(*LSTMLayer).ReturnSequences {
+			t.Error("Expected return_sequences to survive a reload")
+		}
+		if got := loaded.Forward(input).Data[0]; got != expected {
+			t.Errorf("Expected %f after reload, got %f", expected, got)
+		}
+
+		if _, err := BuildModelFromConfig([]LayerConfig{{Type: "dense", Units: 4}, {Type: "gru", ReturnSequences: true}}, []int{3}); err == nil {
+			t.Error("Expected return_sequences to require a sequence input")
+		}
+	})
+
+	t.Run("BPTT", func(t *testing.T) {
+		gru := NewGRULayer(3, 4)
+		gru.ReturnSequences = true
+		lstm := NewLSTMLayer(4, 3)
+		lstm.ReturnSequences = true
+		checkRecurrentGradients(t, []Layer{gru}, randomTensor([]int{5, 3}, rng), rng)
+		checkRecurrentGradients(t, []Layer{lstm}, randomTensor([]int{5, 4}, rng), rng)
+		checkRecurrentGradients(t, []Layer{gru, lstm, NewGRULayer(3, 2)}, randomTensor([]int{5, 3}, rng), rng)
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		gru := NewGRULayer(3, 4)
+		gru.ReturnSequences = true
+		lstm := NewLSTMLayer(4, 2)
+		lstm.ReturnSequences = true
+		m := NewSequentialModel(gru, lstm)
+
+		input := randomTensor([]int{7, 3}, rng)
+		full := m.Forward(input)
+
+		// Feeding the sequence in chunks carries the state of every layer over
+		m.ResetState()
+		var streamed []float32
+		for _, chunk := range [][2]int{{0, 3}, {3, 4}, {4, 7}} {
+			part := &Tensor{Data: input.Data[chunk[0]*3 : chunk[1]*3], Shape: []int{chunk[1] - chunk[0], 3}}
+			streamed = append(streamed, m.ForwardStateful(part).Data...)
+		}
+		for i, v := range full.Data {
+			if math.Abs(float64(v-streamed[i])) > 1e-6 {
+				t.Fatalf("Index %d: expected %f from streaming, got %f", i, v, streamed[i])
+			}
+		}
+	})
+}
@@ -10,21 +10,27 @@ import (
 type Conv2DLayer struct {
 	Weights *Tensor
 	Bias    []float32
-	Stride  int
-	Padding int
+	ConvGeometry
 }
 
+// NewConv2DLayer creates a convolution with the same stride and padding along
+// height and width.
 func NewConv2DLayer(weights *Tensor, bias []float32, stride, padding int) *Conv2DLayer {
+	return NewConv2DLayerWithGeometry(weights, bias, SquareGeometry(stride, padding))
+}
+
+// NewConv2DLayerWithGeometry creates a convolution with separate strides and
+// padding along height and width. The kernel size is taken from the weights.
+func NewConv2DLayerWithGeometry(weights *Tensor, bias []float32, g ConvGeometry) *Conv2DLayer {
 	return &Conv2DLayer{
-		Weights: weights,
-		Bias:    bias,
-		Stride:  stride,
-		Padding: padding,
+		Weights:      weights,
+		Bias:         bias,
+		ConvGeometry: g,
 	}
 }
 
 func (l *Conv2DLayer) Forward(input *Tensor) *Tensor {
-	return Conv2D(input, l.Weights, l.Bias, l.Stride, l.Padding)
+	return Conv2DWithGeometry(input, l.Weights, l.Bias, l.ConvGeometry)
 }
 
 func (l *Conv2DLayer) ForwardStateful(input *Tensor) *Tensor {
@@ -34,7 +40,7 @@ func (l *Conv2DLayer) ForwardStateful(input *Tensor) *Tensor {
 func (l *Conv2DLayer) ResetState() {}
 
 func (l *Conv2DLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
-	return Conv2DBackward(input, l.Weights, l.Bias, gradOutput, l.Stride, l.Padding)
+	return Conv2DBackwardWithGeometry(input, l.Weights, l.Bias, gradOutput, l.ConvGeometry)
 }
 
 func (l *Conv2DLayer) Params() (*Tensor, []float32) {
@@ -118,6 +124,50 @@ func (l *SigmoidLayer) Type() string {
 	return "sigmoid"
 }
 
+// SoftmaxLayer turns the scores of a multi-class output into class probabilities.
+type SoftmaxLayer struct{}
+
+func NewSoftmaxLayer() *SoftmaxLayer {
+	return &SoftmaxLayer{}
+}
+
+func (l *SoftmaxLayer) Forward(input *Tensor) *Tensor {
+	return Softmax(input)
+}
+
+func (l *SoftmaxLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *SoftmaxLayer) ResetState() {}
+
+func (l *SoftmaxLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	// gradInput_i = y_i * (gradOutput_i - sum_j gradOutput_j * y_j) along each row
+	out := Softmax(input)
+	gradInput := NewTensor(input.Shape)
+	n := input.Shape[len(input.Shape)-1]
+	for start := 0; start < len(out.Data); start += n {
+		var dot float32
+		for i := start; i < start+n; i++ {
+			dot += gradOutput.Data[i] * out.Data[i]
+		}
+		for i := start; i < start+n; i++ {
+			gradInput.Data[i] = out.Data[i] * (gradOutput.Data[i] - dot)
+		}
+	}
+	return gradInput, nil, nil
+}
+
+func (l *SoftmaxLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *SoftmaxLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *SoftmaxLayer) Type() string {
+	return "softmax"
+}
+
 // MaxPool2DLayer represents a 2D max pooling layer.
 type MaxPool2DLayer struct {
 	KernelSize int
@@ -199,6 +249,12 @@ func (l *DenseLayer) Type() string {
 
 // Conv2D performs a 2D convolution operation.
 func Conv2D(input, weights *Tensor, bias []float32, stride, padding int) *Tensor {
+	return Conv2DWithGeometry(input, weights, bias, SquareGeometry(stride, padding))
+}
+
+// Conv2DWithGeometry performs a 2D convolution with separate strides and padding
+// along height and width.
+func Conv2DWithGeometry(input, weights *Tensor, bias []float32, g ConvGeometry) *Tensor {
 	inChannels := input.Shape[0]
 	inHeight := input.Shape[1]
 	inWidth := input.Shape[2]
@@ -207,8 +263,7 @@ func Conv2D(input, weights *Tensor, bias []float32, stride, padding int) *Tensor
 	kernelHeight := weights.Shape[2]
 	kernelWidth := weights.Shape[3]
 
-	outHeight := (inHeight+2*padding-kernelHeight)/stride + 1
-	outWidth := (inWidth+2*padding-kernelWidth)/stride + 1
+	outHeight, outWidth := g.OutputSize(inHeight, inWidth, kernelHeight, kernelWidth)
 
 	output := NewTensor([]int{numFilters, outHeight, outWidth})
 
@@ -224,8 +279,8 @@ func Conv2D(input, weights *Tensor, bias []float32, stride, padding int) *Tensor
 					for c := 0; c < inChannels; c++ {
 						for ki := 0; ki < kernelHeight; ki++ {
 							for kj := 0; kj < kernelWidth; kj++ {
-								ii := i*stride - padding + ki
-								jj := j*stride - padding + kj
+								ii := i*g.StrideH - g.PaddingH + ki
+								jj := j*g.StrideW - g.PaddingW + kj
 
 								if ii >= 0 && ii < inHeight && jj >= 0 && jj < inWidth {
 									val := input.Get([]int{c, ii, jj})
@@ -267,6 +322,32 @@ func Sigmoid(input *Tensor) *Tensor {
 	return output
 }
 
+// Softmax applies the softmax function along the last axis of the input.
+func Softmax(input *Tensor) *Tensor {
+	output := NewTensor(input.Shape)
+	n := input.Shape[len(input.Shape)-1]
+	for start := 0; start < len(input.Data); start += n {
+		row := input.Data[start : start+n]
+		// Subtract the maximum for numerical stability
+		maxVal := row[0]
+		for _, v := range row {
+			if v > maxVal {
+				maxVal = v
+			}
+		}
+		var sum float64
+		for i, v := range row {
+			e := math.Exp(float64(v - maxVal))
+			output.Data[start+i] = float32(e)
+			sum += e
+		}
+		for i := range row {
+			output.Data[start+i] = float32(float64(output.Data[start+i]) / sum)
+		}
+	}
+	return output
+}
+
 // MaxPool2D performs a 2D max pooling operation.
 func MaxPool2D(input *Tensor, kernelSize, stride int) *Tensor {
 	channels := input.Shape[0]
@@ -397,6 +478,11 @@ func ReLUBackward(input, gradOutput *Tensor) *Tensor {
 
 // Conv2DBackward calculates the gradients for the Conv2D layer.
 func Conv2DBackward(input, weights *Tensor, bias []float32, gradOutput *Tensor, stride, padding int) (*Tensor, *Tensor, []float32) {
+	return Conv2DBackwardWithGeometry(input, weights, bias, gradOutput, SquareGeometry(stride, padding))
+}
+
+// Conv2DBackwardWithGeometry calculates the gradients of Conv2DWithGeometry.
+func Conv2DBackwardWithGeometry(input, weights *Tensor, bias []float32, gradOutput *Tensor, g ConvGeometry) (*Tensor, *Tensor, []float32) {
 	inChannels := input.Shape[0]
 	inHeight := input.Shape[1]
 	inWidth := input.Shape[2]
@@ -426,8 +512,8 @@ func Conv2DBackward(input, weights *Tensor, bias []float32, gradOutput *Tensor,
 					for c := 0; c < inChannels; c++ {
 						for ki := 0; ki < kernelHeight; ki++ {
 							for kj := 0; kj < kernelWidth; kj++ {
-								ii := i*stride - padding + ki
-								jj := j*stride - padding + kj
+								ii := i*g.StrideH - g.PaddingH + ki
+								jj := j*g.StrideW - g.PaddingW + kj
 
 								if ii >= 0 && ii < inHeight && jj >= 0 && jj < inWidth {
 									inVal := input.Get([]int{c, ii, jj})
@@ -450,8 +536,8 @@ func Conv2DBackward(input, weights *Tensor, bias []float32, gradOutput *Tensor,
 				for c := 0; c < inChannels; c++ {
 					for ki := 0; ki < kernelHeight; ki++ {
 						for kj := 0; kj < kernelWidth; kj++ {
-							ii := i*stride - padding + ki
-							jj := j*stride - padding + kj
+							ii := i*g.StrideH - g.PaddingH + ki
+							jj := j*g.StrideW - g.PaddingW + kj
 							if ii >= 0 && ii < inHeight && jj >= 0 && jj < inWidth {
 								wVal := weights.Get([]int{f, c, ki, kj})
 								gradInput.Data[gradInput.getIndex([]int{c, ii, jj})] += wVal * goVal
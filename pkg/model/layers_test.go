@@ -1,6 +1,8 @@
 package model
 
 import (
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -90,3 +92,48 @@ func TestDense(t *testing.T) {
 		}
 	})
 }
+
+func TestSoftmax(t *testing.T) {
+	t.Run("Forward", func(t *testing.T) {
+		input := &Tensor{Data: []float32{1, 2, 3, 1000, 1000, 1000}, Shape: []int{2, 3}}
+		output := NewSoftmaxLayer().Forward(input)
+
+		// Each row is normalized on its own; large scores must not overflow
+		e1, e2, e3 := math.Exp(1), math.Exp(2), math.Exp(3)
+		expected := []float64{e1 / (e1 + e2 + e3), e2 / (e1 + e2 + e3), e3 / (e1 + e2 + e3), 1.0 / 3, 1.0 / 3, 1.0 / 3}
+		for i, v := range output.Data {
+			if math.Abs(float64(v)-expected[i]) > 1e-6 {
+				t.Errorf("Index %d: expected %f, got %f", i, expected[i], v)
+			}
+		}
+	})
+
+	t.Run("Backward", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(20))
+		layer := NewSoftmaxLayer()
+		input := randomTensor([]int{2, 4}, rng)
+		lossWeights := randomTensor([]int{2, 4}, rng)
+		loss := func() float64 {
+			var sum float64
+			for i, v := range layer.Forward(input).Data {
+				sum += float64(v * lossWeights.Data[i])
+			}
+			return sum
+		}
+
+		gradInput, _, _ := layer.Backward(input, lossWeights)
+		const eps = 1e-2
+		for i := range input.Data {
+			orig := input.Data[i]
+			input.Data[i] = orig + eps
+			plus := loss()
+			input.Data[i] = orig - eps
+			minus := loss()
+			input.Data[i] = orig
+			numeric := (plus - minus) / (2 * eps)
+			if math.Abs(numeric-float64(gradInput.Data[i])) > 1e-3 {
+				t.Errorf("Input %d: analytic %f, numeric %f", i, gradInput.Data[i], numeric)
+			}
+		}
+	})
+}
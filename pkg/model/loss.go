@@ -28,6 +28,41 @@ func BCELoss(yPred, yTrue []float32) float32 {
 	return float32(-totalLoss / n)
 }
 
+// CategoricalCELoss calculates the categorical cross-entropy loss of class
+// probabilities yPred (e.g. a softmax output) against one-hot targets yTrue.
+func CategoricalCELoss(yPred, yTrue []float32) float32 {
+	var totalLoss float64
+	for i := range yPred {
+		if yTrue[i] == 0 {
+			continue
+		}
+		pred := float64(yPred[i])
+		if pred < epsilon {
+			pred = epsilon
+		}
+		totalLoss += float64(yTrue[i]) * math.Log(pred)
+	}
+	return float32(-totalLoss)
+}
+
+// SoftmaxCEGradient calculates the gradient of the categorical cross-entropy
+// loss with respect to the softmax input: yPred - yTrue. Like the combined
+// BCE+sigmoid gradient it already accounts for the softmax derivative.
+func SoftmaxCEGradient(yPred, yTrue []float32) []float32 {
+	grad := make([]float32, len(yPred))
+	for i := range yPred {
+		grad[i] = yPred[i] - yTrue[i]
+	}
+	return grad
+}
+
+// OneHot returns the one-hot target vector of class out of numClasses.
+func OneHot(class, numClasses int) []float32 {
+	target := make([]float32, numClasses)
+	target[class] = 1
+	return target
+}
+
 // BCEGradient calculates the gradient of the Binary Cross-Entropy loss with respect to predictions.
 func BCEGradient(yPred, yTrue []float32) []float32 {
 	n := float32(len(yPred))
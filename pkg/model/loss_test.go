@@ -2,6 +2,7 @@ package model
 
 import (
 	"math"
+	"reflect"
 	"testing"
 )
 
@@ -48,3 +49,32 @@ func TestBCELoss(t *testing.T) {
 		}
 	})
 }
+
+func TestCategoricalCELoss(t *testing.T) {
+	yPred := []float32{0.7, 0.2, 0.1}
+	yTrue := OneHot(1, 3)
+	if !reflect.DeepEqual(yTrue, []float32{0, 1, 0}) {
+		t.Fatalf("Unexpected one-hot target %v", yTrue)
+	}
+
+	if loss, expected := CategoricalCELoss(yPred, yTrue), float32(-math.Log(0.2)); math.Abs(float64(loss-expected)) > 1e-6 {
+		t.Errorf("Expected loss %f, got %f", expected, loss)
+	}
+	if loss := CategoricalCELoss([]float32{1, 0}, []float32{0, 1}); math.IsInf(float64(loss), 0) || loss <= 0 {
+		t.Errorf("Expected a finite loss for a zero probability, got %f", loss)
+	}
+
+	// The combined gradient equals the CE gradient taken back through the softmax
+	logits := &Tensor{Data: []float32{0.5, -1, 2}, Shape: []int{3}}
+	probs := Softmax(logits)
+	gradProbs := &Tensor{Data: make([]float32, 3), Shape: []int{3}}
+	for i := range probs.Data {
+		gradProbs.Data[i] = -yTrue[i] / probs.Data[i]
+	}
+	expected, _, _ := NewSoftmaxLayer().Backward(logits, gradProbs)
+	for i, g := range SoftmaxCEGradient(probs.Data, yTrue) {
+		if math.Abs(float64(g-expected.Data[i])) > 1e-6 {
+			t.Errorf("Index %d: expected gradient %f, got %f", i, expected.Data[i], g)
+		}
+	}
+}
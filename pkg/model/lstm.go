@@ -4,15 +4,17 @@ import (
 	"math"
 )
 
-// LSTMLayer implements a Long Short-Term Memory layer.
+// LSTMLayer implements a Long Short-Term Memory layer. Like GRULayer it outputs
+// the final hidden state, or every hidden state with ReturnSequences.
 type LSTMLayer struct {
 	// Weights for gates: i (input), f (forget), o (output), g (cell candidate)
 	Wi, Wf, Wo, Wg *Tensor
 	Ui, Uf, Uo, Ug *Tensor
 	Bi, Bf, Bo, Bg []float32
 
-	InputSize  int
-	HiddenSize int
+	InputSize       int
+	HiddenSize      int
+	ReturnSequences bool
 
 	// Stateful inference
 	hiddenState []float32
@@ -23,6 +25,7 @@ type LSTMLayer struct {
 	hSeq, cSeq         []*Tensor
 	iSeq, fSeq, oSeq   []*Tensor
 	gSeq               []*Tensor
+	mask               []bool // Frames skipped as padding (nil = none)
 }
 
 func NewLSTMLayer(inputSize, hiddenSize int) *LSTMLayer {
@@ -56,7 +59,14 @@ func NewLSTMLayer(inputSize, hiddenSize int) *LSTMLayer {
 }
 
 func (l *LSTMLayer) Forward(input *Tensor) *Tensor {
-	return l.forwardInternal(input, nil, nil)
+	return l.forwardInternal(input, nil, nil, nil)
+}
+
+// ForwardMasked is Forward for a padded sequence: timesteps with mask[t] false
+// leave the hidden and cell states unchanged, output zeros with ReturnSequences
+// and are skipped by Backward.
+func (l *LSTMLayer) ForwardMasked(input *Tensor, mask []bool) *Tensor {
+	return l.forwardInternal(input, nil, nil, mask)
 }
 
 func (l *LSTMLayer) ForwardStateful(input *Tensor) *Tensor {
@@ -64,9 +74,9 @@ func (l *LSTMLayer) ForwardStateful(input *Tensor) *Tensor {
 		l.hiddenState = make([]float32, l.HiddenSize)
 		l.cellState = make([]float32, l.HiddenSize)
 	}
-	out := l.forwardInternal(input, l.hiddenState, l.cellState)
-	copy(l.hiddenState, out.Data)
-	// We need to capture the cell state from the last timestep of the internal forward pass
+	out := l.forwardInternal(input, l.hiddenState, l.cellState, nil)
+	// Capture the hidden and cell states from the last timestep of the internal forward pass
+	copy(l.hiddenState, l.hSeq[len(l.hSeq)-1].Data)
 	copy(l.cellState, l.cSeq[len(l.cSeq)-1].Data)
 	return out
 }
@@ -76,7 +86,7 @@ func (l *LSTMLayer) ResetState() {
 	l.cellState = nil
 }
 
-func (l *LSTMLayer) forwardInternal(input *Tensor, initialH, initialC []float32) *Tensor {
+func (l *LSTMLayer) forwardInternal(input *Tensor, initialH, initialC []float32, mask []bool) *Tensor {
 	var flatInput *Tensor
 	var seqLen int
 
@@ -100,6 +110,7 @@ func (l *LSTMLayer) forwardInternal(input *Tensor, initialH, initialC []float32)
 	}
 
 	l.lastInput = flatInput
+	l.mask = mask
 	l.hSeq = make([]*Tensor, seqLen+1)
 	l.cSeq = make([]*Tensor, seqLen+1)
 	l.iSeq = make([]*Tensor, seqLen)
@@ -119,6 +130,10 @@ func (l *LSTMLayer) forwardInternal(input *Tensor, initialH, initialC []float32)
 	inputDim := flatInput.Shape[1]
 
 	for t := 0; t < seqLen; t++ {
+		if skipped(mask, t) {
+			l.hSeq[t+1], l.cSeq[t+1] = l.hSeq[t], l.cSeq[t]
+			continue
+		}
 		xt := flatInput.Data[t*inputDim : (t+1)*inputDim]
 		h := l.hSeq[t].Data
 		c := l.cSeq[t].Data
@@ -154,6 +169,9 @@ func (l *LSTMLayer) forwardInternal(input *Tensor, initialH, initialC []float32)
 		l.iSeq[t], l.fSeq[t], l.oSeq[t], l.gSeq[t] = &Tensor{Data: iG, Shape: []int{l.HiddenSize}}, &Tensor{Data: fG, Shape: []int{l.HiddenSize}}, &Tensor{Data: oG, Shape: []int{l.HiddenSize}}, &Tensor{Data: gG, Shape: []int{l.HiddenSize}}
 		l.cSeq[t+1], l.hSeq[t+1] = &Tensor{Data: newC, Shape: []int{l.HiddenSize}}, &Tensor{Data: newH, Shape: []int{l.HiddenSize}}
 	}
+	if l.ReturnSequences {
+		return stackStates(l.hSeq[1:], l.HiddenSize, mask)
+	}
 	return l.hSeq[seqLen]
 }
 
@@ -165,11 +183,21 @@ func (l *LSTMLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []flo
 	dBi, dBf, dBo, dBg := make([]float32, l.HiddenSize), make([]float32, l.HiddenSize), make([]float32, l.HiddenSize), make([]float32, l.HiddenSize)
 
 	dh := make([]float32, l.HiddenSize)
-	copy(dh, gradOutput.Data)
+	if !l.ReturnSequences {
+		copy(dh, gradOutput.Data)
+	}
 	dc := make([]float32, l.HiddenSize)
 	dInput := NewTensor(l.lastInput.Shape)
 
 	for t := seqLen - 1; t >= 0; t-- {
+		if skipped(l.mask, t) {
+			continue // dh and dc pass through unchanged
+		}
+		if l.ReturnSequences {
+			for j, v := range gradOutput.Data[t*l.HiddenSize : (t+1)*l.HiddenSize] {
+				dh[j] += v
+			}
+		}
 		xt := l.lastInput.Data[t*inputDim : (t+1)*inputDim]
 		hPrev := l.hSeq[t].Data
 		cPrev := l.cSeq[t].Data
@@ -0,0 +1,202 @@
+package model
+
+import "fmt"
+
+// MaskedLayer is implemented by layers that can skip padded frames, such as
+// recurrent layers. A frame mask has one entry per frame of the input (axis 1
+// of [channels, frames, features], or the rows of [frames, features]); frames
+// with mask[t] false are padding.
+type MaskedLayer interface {
+	ForwardMasked(input *Tensor, mask []bool) *Tensor
+}
+
+// PrefixMask returns the mask of a sequence of numFrames frames whose first
+// valid frames hold data and the rest padding.
+func PrefixMask(numFrames, valid int) []bool {
+	mask := make([]bool, numFrames)
+	for t := 0; t < valid && t < numFrames; t++ {
+		mask[t] = true
+	}
+	return mask
+}
+
+// skipped reports whether timestep t is masked out.
+func skipped(mask []bool, t int) bool {
+	return mask != nil && !mask[t]
+}
+
+// CheckMask returns an error if the frame mask of a padded input of the given
+// shape cannot reach a layer that combines frames (a recurrent, global pooling
+// or attention pooling layer), because a layer before it mixes the frames in a
+// way the mask cannot follow (e.g. a dense layer, or a reshape that merges the
+// frame axis with another one). Such a layer would see the padding.
+func CheckMask(m Model, inputShape []int) error {
+	input := NewTensor(inputShape)
+	n := numFrames(input)
+	if n < 0 {
+		return fmt.Errorf("input %v has no frame axis", inputShape)
+	}
+	if l := m.ForwardTraceMasked(input, PrefixMask(n, n)).unmasked; l != nil {
+		return fmt.Errorf("the frame mask of padded inputs does not reach the %s layer: a layer before it mixes the frames", l.Type())
+	}
+	return nil
+}
+
+// frames is the frame mask of a tensor: valid[t] is false for the padding
+// frames along axis.
+type frames struct {
+	valid []bool
+	axis  int
+}
+
+// inputFrames returns the frame mask of a model input (see MaskedLayer), or nil
+// without a mask.
+func inputFrames(input *Tensor, mask []bool) *frames {
+	if mask == nil {
+		return nil
+	}
+	if len(input.Shape) == 2 {
+		return &frames{valid: mask, axis: 0}
+	}
+	return &frames{valid: mask, axis: 1}
+}
+
+// frameAxis returns the axis along which l reads the frames of input: recurrent
+// layers read the rows of [frames, features], the other layers the axis after
+// the channels.
+func frameAxis(l Layer, input *Tensor) int {
+	switch l.(type) {
+	case *GRULayer, *LSTMLayer, *BidirectionalLayer:
+		if len(input.Shape) == 2 {
+			return 0
+		}
+	}
+	return 1
+}
+
+// forwardMasked runs l on input, passing the frame mask to masked layers that
+// read the frames along its axis. It reports whether l received the mask.
+func forwardMasked(l Layer, input *Tensor, f *frames) (*Tensor, bool) {
+	if ml, ok := l.(MaskedLayer); ok && f != nil && f.axis == frameAxis(l, input) && len(input.Shape) > f.axis && input.Shape[f.axis] == len(f.valid) {
+		return ml.ForwardMasked(input, f.valid), true
+	}
+	return l.Forward(input), false
+}
+
+// maskLost reports whether the frame mask f of the input of l, which did or
+// did not receive it, is lost at l's output (propagated to out): l neither
+// consumed the mask nor passed it on.
+func maskLost(f, out *frames, received bool) bool {
+	return f != nil && out == nil && !received
+}
+
+// propagateMask returns the frame mask of the output of l given the mask of its
+// input, or nil when the output has no frame axis (e.g. the final state of a
+// recurrent layer, a pooled or a dense output) or the frames cannot be followed.
+// An output frame holds data if the input frames it is computed from do, so a
+// padded input keeps the frames of the unpadded one.
+func propagateMask(l Layer, f *frames, input, output *Tensor) *frames {
+	if f == nil {
+		return nil
+	}
+	var out *frames
+	switch l := l.(type) {
+	case *GRULayer, *LSTMLayer:
+		if returnsSequences(l) && f.axis == frameAxis(l, input) {
+			out = &frames{valid: f.valid, axis: 0}
+		}
+	case *BidirectionalLayer:
+		if l.ReturnSequences && f.axis == frameAxis(l, input) {
+			out = &frames{valid: f.valid, axis: 0}
+		}
+	case *Conv2DLayer:
+		out = windowFrames(f, output, l.Weights.Shape[2], l.StrideH, l.PaddingH, 1)
+	case *DepthwiseConv2DLayer:
+		out = windowFrames(f, output, l.Weights.Shape[2], l.StrideH, l.PaddingH, 1)
+	case *MaxPool2DLayer:
+		out = windowFrames(f, output, l.KernelSize, l.Stride, 0, 1)
+	case *Conv1DLayer:
+		out = windowFrames(f, output, l.Weights.Shape[2], l.Stride, l.Padding, l.Dilation)
+	case *Pool1DLayer:
+		out = windowFrames(f, output, l.KernelSize, l.Stride, 0, 1)
+	case *TransposeLayer:
+		for i, p := range l.Perm {
+			if p == f.axis {
+				out = &frames{valid: f.valid, axis: i}
+			}
+		}
+	case *ReshapeLayer:
+		if axis := reshapedAxis(input.Shape, output.Shape, f.axis); axis >= 0 {
+			out = &frames{valid: f.valid, axis: axis}
+		}
+	case *PointwiseConv2DLayer, *BatchNormLayer, *ReLULayer, *SigmoidLayer, *DropoutLayer:
+		out = f // Element or frame wise, the shape is unchanged
+	}
+	if out == nil || len(output.Shape) <= out.axis || output.Shape[out.axis] != len(out.valid) {
+		return nil
+	}
+	return out
+}
+
+// reshapedAxis returns the axis of a reshaped tensor that holds the given axis
+// of the original one, or -1 if the reshape merges or splits it.
+func reshapedAxis(from, to []int, axis int) int {
+	before := 1
+	for _, d := range from[:axis] {
+		before *= d
+	}
+	p := 1
+	for k, d := range to {
+		if p == before && d == from[axis] {
+			return k
+		}
+		p *= d
+	}
+	return -1
+}
+
+// numFrames returns the length of the frame axis of a masked model input: axis
+// 1 of [channels, frames, features] or axis 0 of [frames, features].
+func numFrames(x *Tensor) int {
+	switch len(x.Shape) {
+	case 3:
+		return x.Shape[1]
+	case 2:
+		return x.Shape[0]
+	}
+	return -1
+}
+
+// windowFrames maps the frame mask of a convolution or pooling input to its
+// output, whose frames run along axis 1 as well.
+func windowFrames(f *frames, output *Tensor, kernel, stride, padding, dilation int) *frames {
+	if f.axis != 1 || len(output.Shape) < 2 {
+		return nil
+	}
+	return &frames{valid: windowMask(f.valid, output.Shape[1], kernel, stride, padding, dilation), axis: 1}
+}
+
+// windowMask maps a frame mask through a sliding window of the given size,
+// stride, zero padding and dilation, giving the mask of outLen output frames.
+// An output frame holds data if every input frame of its window does, except
+// the frames in the last padding positions of the window: at the end of an
+// unpadded input they would be the zero padding of the convolution, so the
+// padded input keeps as many output frames as the unpadded one. Those last
+// frames still see the features of the padding instead of zeros (the log-mel
+// of silence is not 0); convolutions without time padding (train --causal)
+// match the unpadded input exactly.
+func windowMask(mask []bool, outLen, kernel, stride, padding, dilation int) []bool {
+	span := (kernel-1)*dilation + 1
+	out := make([]bool, outLen)
+	for j := range out {
+		out[j] = true
+		for k := 0; k < kernel && k*dilation < span-padding; k++ {
+			t := j*stride - padding + k*dilation
+			if t >= 0 && (t >= len(mask) || !mask[t]) {
+				out[j] = false
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,265 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// padFrames returns x [frames, features] followed by n frames of random values.
+func padFrames(x *Tensor, n int, rng *rand.Rand) *Tensor {
+	width := x.Shape[1]
+	out := randomTensor([]int{x.Shape[0] + n, width}, rng)
+	copy(out.Data, x.Data)
+	return out
+}
+
+func assertClose(t *testing.T, label string, got, expected []float32) {
+	t.Helper()
+	for i := range expected {
+		if math.Abs(float64(got[i]-expected[i])) > 1e-5 {
+			t.Errorf("%s[%d]: expected %f, got %f", label, i, expected[i], got[i])
+			return
+		}
+	}
+}
+
+func TestMaskedRecurrentLayers(t *testing.T) {
+	rng := rand.New(rand.NewSource(17))
+	ResetRand(17)
+	for _, tc := range []struct {
+		name  string
+		layer func(seq bool) Layer
+	}{
+		{"gru", func(seq bool) Layer { l := NewGRULayer(3, 4); l.ReturnSequences = seq; return l }},
+		{"lstm", func(seq bool) Layer { l := NewLSTMLayer(3, 4); l.ReturnSequences = seq; return l }},
+		{"bigru", func(seq bool) Layer { return NewBiGRULayer(3, 2, seq) }},
+		{"bilstm", func(seq bool) Layer { return NewBiLSTMLayer(3, 2, seq) }},
+	} {
+		for _, seq := range []bool{false, true} {
+			l := tc.layer(seq)
+			input := randomTensor([]int{5, 3}, rng)
+			out := l.Forward(input)
+			gradOut := randomTensor(out.Shape, rng)
+			gradIn, gradW, gradB := l.Backward(input, gradOut)
+
+			// The same frames followed by 3 padding frames of noise
+			padded := padFrames(input, 3, rng)
+			mask := PrefixMask(8, 5)
+			paddedOut := l.(MaskedLayer).ForwardMasked(padded, mask)
+			paddedGrad := gradOut
+			if seq {
+				// Padding rows are zero and their gradient is ignored
+				if !reflect.DeepEqual(paddedOut.Shape, []int{8, out.Shape[1]}) {
+					t.Fatalf("%s: expected %d output frames, got shape %v", tc.name, 8, paddedOut.Shape)
+				}
+				for _, v := range paddedOut.Data[len(out.Data):] {
+					if v != 0 {
+						t.Fatalf("%s: expected zero output for padding frames, got %v", tc.name, paddedOut.Data[len(out.Data):])
+					}
+				}
+				paddedGrad = padFrames(gradOut, 3, rng)
+			}
+			assertClose(t, tc.name+" output", paddedOut.Data, out.Data)
+
+			paddedGradIn, paddedGradW, paddedGradB := l.Backward(padded, paddedGrad)
+			assertClose(t, tc.name+" input grad", paddedGradIn.Data, gradIn.Data)
+			for i, v := range paddedGradIn.Data[len(gradIn.Data):] {
+				if v != 0 {
+					t.Errorf("%s: expected zero gradient for padding input %d, got %f", tc.name, i, v)
+					break
+				}
+			}
+			assertClose(t, tc.name+" weight grad", paddedGradW.Data, gradW.Data)
+			assertClose(t, tc.name+" bias grad", paddedGradB, gradB)
+		}
+	}
+}
+
+func TestWindowMask(t *testing.T) {
+	mask := PrefixMask(10, 7)
+	for _, tc := range []struct {
+		kernel, stride, padding, frames int
+		expected                        []bool
+	}{
+		// Causal conv: frames whose window holds only data
+		{3, 1, 0, 8, PrefixMask(8, 5)},
+		// Max pooling 2x2: 7 frames pool to 3 in an unpadded input
+		{2, 2, 0, 5, PrefixMask(5, 3)},
+		// Padding conv: 7 frames give 7, the last reaching into the padding
+		{3, 1, 1, 10, PrefixMask(10, 7)},
+	} {
+		out := windowMask(mask, tc.frames, tc.kernel, tc.stride, tc.padding, 1)
+		if !reflect.DeepEqual(out, tc.expected) {
+			t.Errorf("Kernel %d, stride %d, padding %d: expected %v, got %v", tc.kernel, tc.stride, tc.padding, tc.expected, out)
+		}
+	}
+	// Dilated conv: a kernel of 3 with dilation 2 spans 5 frames
+	if out := windowMask(mask, 6, 3, 1, 0, 2); !reflect.DeepEqual(out, PrefixMask(6, 3)) {
+		t.Errorf("Expected 3 valid frames after a dilated conv, got %v", out)
+	}
+
+	f := &frames{valid: mask, axis: 0}
+	if m := propagateMask(NewGRULayer(3, 2), f, NewTensor([]int{10, 3}), NewTensor([]int{2})); m != nil {
+		t.Errorf("Expected no mask after a final state, got %v", m)
+	}
+	if m := propagateMask(NewReLULayer(), f, NewTensor([]int{10, 4}), NewTensor([]int{10, 4})); m != f {
+		t.Errorf("Expected the mask through relu, got %v", m)
+	}
+	// [1, 10, 4] -> transpose [1, 4, 10] -> reshape [4, 10]: the frames move to axis 2, then 1
+	f = &frames{valid: mask, axis: 1}
+	transposed := propagateMask(NewTransposeLayer([]int{0, 2, 1}), f, NewTensor([]int{1, 10, 4}), NewTensor([]int{1, 4, 10}))
+	if transposed == nil || transposed.axis != 2 {
+		t.Fatalf("Expected the frames on axis 2 after transpose, got %v", transposed)
+	}
+	if m := propagateMask(NewReshapeLayer([]int{4, -1}), transposed, NewTensor([]int{1, 4, 10}), NewTensor([]int{4, 10})); m == nil || m.axis != 1 {
+		t.Errorf("Expected the frames on axis 1 after reshape, got %v", m)
+	}
+	// Merging the frames with the features loses them
+	if m := propagateMask(NewReshapeLayer([]int{-1}), f, NewTensor([]int{1, 10, 4}), NewTensor([]int{40})); m != nil {
+		t.Errorf("Expected no mask after flattening, got %v", m)
+	}
+}
+
+// checkPadded runs m on input and on input followed by pad frames of noise
+// with a frame mask, and checks that both give the same output and parameter
+// gradients.
+func checkPadded(t *testing.T, name string, m Model, input *Tensor, pad int, rng *rand.Rand) {
+	t.Helper()
+	grads := func(trace *Trace) [][]float32 {
+		var out [][]float32
+		gradOut := NewTensor(trace.Output().Shape)
+		for i := range gradOut.Data {
+			gradOut.Data[i] = 1
+		}
+		m.BackwardTrace(trace, gradOut, false, func(l Layer, gradWeights *Tensor, gradBias []float32) {
+			out = append(out, append(append([]float32{}, gradWeights.Data...), gradBias...))
+		})
+		return out
+	}
+
+	trace := m.ForwardTraceMasked(input, nil)
+	expected, expectedGrads := append([]float32(nil), trace.Output().Data...), grads(trace)
+
+	shape := append([]int(nil), input.Shape...)
+	shape[1] += pad
+	padded := randomTensor(shape, rng)
+	copy(padded.Data, input.Data)
+	trace = m.ForwardTraceMasked(padded, PrefixMask(shape[1], input.Shape[1]))
+	assertClose(t, name+" output", trace.Output().Data, expected)
+	paddedGrads := grads(trace)
+	if len(paddedGrads) != len(expectedGrads) {
+		t.Fatalf("%s: expected %d parameter gradients, got %d", name, len(expectedGrads), len(paddedGrads))
+	}
+	for i := range expectedGrads {
+		assertClose(t, name+" layer grad", paddedGrads[i], expectedGrads[i])
+	}
+}
+
+func TestMaskedPoolingHeads(t *testing.T) {
+	rng := rand.New(rand.NewSource(19))
+	ResetRand(19)
+	for _, head := range []string{"global_avgpool", "global_maxpool", "attention_pool"} {
+		// TC-ResNet style: the frames run along the last axis of the conv1d input
+		m, err := BuildModelFromConfig([]LayerConfig{
+			{Type: "transpose", Perm: []int{0, 2, 1}},
+			{Type: "reshape", Shape: []int{6, -1}},
+			{Type: "conv1d", Filters: 4, KernelSize: 3, Dilation: 2},
+			{Type: "relu"},
+			{Type: "maxpool1d", KernelSize: 2, Stride: 2},
+			{Type: head},
+			{Type: "dense", Units: 1},
+			{Type: "sigmoid"},
+		}, []int{1, 20, 6})
+		if err != nil {
+			t.Fatalf("Failed to build model: %v", err)
+		}
+		checkPadded(t, "tc-resnet "+head, m, randomTensor([]int{1, 20, 6}, rng), 9, rng)
+
+		// CNN: the frames run along axis 1 of the conv2d output
+		m, err = BuildModelFromConfig([]LayerConfig{
+			{Type: "conv2d", Filters: 2, KernelSize: 3},
+			{Type: "maxpool2d", KernelSize: 2, Stride: 2},
+			{Type: head},
+			{Type: "dense", Units: 1},
+			{Type: "sigmoid"},
+		}, []int{1, 20, 6})
+		if err != nil {
+			t.Fatalf("Failed to build model: %v", err)
+		}
+		checkPadded(t, "cnn "+head, m, randomTensor([]int{1, 20, 6}, rng), 9, rng)
+	}
+}
+
+func TestCheckMask(t *testing.T) {
+	for _, tc := range []struct {
+		configs []LayerConfig
+		errText string
+	}{
+		{streamingConfig("gru"), ""},
+		{[]LayerConfig{{Type: "transpose", Perm: []int{0, 2, 1}}, {Type: "reshape", Shape: []int{5, -1}}, {Type: "conv1d", Filters: 2, KernelSize: 3}, {Type: "attention_pool"}}, ""},
+		{[]LayerConfig{{Type: "conv2d", Filters: 2, KernelSize: 3}, {Type: "global_maxpool"}, {Type: "dense", Units: 1}}, ""},
+		{[]LayerConfig{{Type: "dense", Units: 1}}, ""}, // No frame-combining layer
+		{[]LayerConfig{{Type: "transpose", Perm: []int{0, 2, 1}}, {Type: "gru", Units: 2}}, "gru"},
+		{[]LayerConfig{{Type: "reshape", Shape: []int{-1, 5}}, {Type: "reshape", Shape: []int{5, -1}}, {Type: "global_avgpool"}}, "global_avgpool"},
+		{[]LayerConfig{{Type: "conv2d", Filters: 2, KernelSize: 3}, {Type: "transpose", Perm: []int{1, 0, 2}}, {Type: "attention_pool"}}, "attention_pool"},
+	} {
+		m, err := BuildModelFromConfig(tc.configs, []int{1, 8, 5})
+		if err != nil {
+			t.Fatalf("Failed to build %+v: %v", tc.configs, err)
+		}
+		err = CheckMask(m, []int{1, 8, 5})
+		if tc.errText == "" && err != nil {
+			t.Errorf("Expected no error for %+v, got %v", tc.configs, err)
+		}
+		if tc.errText != "" && (err == nil || !strings.Contains(err.Error(), tc.errText)) {
+			t.Errorf("Expected error containing %q for %+v, got %v", tc.errText, tc.configs, err)
+		}
+	}
+}
+
+func TestSequentialMasked(t *testing.T) {
+	rng := rand.New(rand.NewSource(18))
+	ResetRand(18)
+	for _, rnn := range []string{"gru", "bilstm"} {
+		configs := streamingConfig(rnn)
+		configs[5].Type, configs[6].Type = rnn, rnn
+		m, err := BuildModelFromConfig(configs, []int{1, 20, 6})
+		if err != nil {
+			t.Fatalf("Failed to build model: %v", err)
+		}
+
+		grads := func(trace *Trace) [][]float32 {
+			var out [][]float32
+			m.BackwardTrace(trace, &Tensor{Data: []float32{1}, Shape: []int{1}}, false, func(l Layer, gradWeights *Tensor, gradBias []float32) {
+				out = append(out, append(append([]float32{}, gradWeights.Data...), gradBias...))
+			})
+			return out
+		}
+
+		input := randomTensor([]int{1, 20, 6}, rng)
+		trace := m.ForwardTraceMasked(input, nil)
+		expected, expectedGrads := trace.Output().Data[0], grads(trace)
+
+		// 9 more frames of noise, masked
+		padded := randomTensor([]int{1, 29, 6}, rng)
+		copy(padded.Data, input.Data)
+		trace = m.ForwardTraceMasked(padded, PrefixMask(29, 20))
+		if got := trace.Output().Data[0]; math.Abs(float64(got-expected)) > 1e-5 {
+			t.Errorf("%s: expected %f for the padded input, got %f", rnn, expected, got)
+		}
+		paddedGrads := grads(trace)
+		if len(paddedGrads) != len(expectedGrads) {
+			t.Fatalf("%s: expected %d parameter gradients, got %d", rnn, len(expectedGrads), len(paddedGrads))
+		}
+		for i := range expectedGrads {
+			assertClose(t, rnn+" layer grad", paddedGrads[i], expectedGrads[i])
+		}
+
+		if got := m.ForwardTraceMasked(padded, nil).Output().Data[0]; got == expected {
+			t.Errorf("%s: expected the unmasked padding to change the output", rnn)
+		}
+	}
+}
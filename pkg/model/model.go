@@ -22,7 +22,39 @@ type Model interface {
 	Forward(input *Tensor) *Tensor
 	ForwardStateful(input *Tensor) *Tensor
 	ResetState()
+	// SetTraining switches layers such as batch normalization between training
+	// and inference behavior. Models start in inference mode.
+	SetTraining(training bool)
+	// GetLayers returns every layer of the model in a fixed order (execution
+	// order), which identifies layers across copies of the same model.
 	GetLayers() []Layer
+	// ForwardTrace performs a forward pass and records the activations needed
+	// for backpropagation.
+	ForwardTrace(input *Tensor) *Trace
+	// ForwardTraceMasked is ForwardTrace for a padded input whose frames with
+	// mask[t] false hold padding (see MaskedLayer). The mask follows the frames
+	// through the layers and recurrent layers skip the padded ones.
+	ForwardTraceMasked(input *Tensor, mask []bool) *Trace
+	// BackwardTrace propagates gradOutput, the gradient of the loss with respect
+	// to the model output, back through the layers of a trace and calls update
+	// with the parameter gradients of every layer that has parameters, right
+	// after that layer's backward pass. If skipActivation is set and the output
+	// layer is a sigmoid or softmax, gradOutput is taken as the gradient of its
+	// input instead (e.g. the combined BCE+sigmoid or CE+softmax gradient).
+	BackwardTrace(trace *Trace, gradOutput *Tensor, skipActivation bool, update func(l Layer, gradWeights *Tensor, gradBias []float32))
+}
+
+// Trace holds the activations of a forward pass.
+type Trace struct {
+	input    *Tensor
+	outputs  []*Tensor // Output of every layer, in GetLayers order
+	output   *Tensor
+	unmasked Layer // First layer combining frames that the frame mask did not reach
+}
+
+// Output returns the model output of the traced forward pass.
+func (t *Trace) Output() *Tensor {
+	return t.output
 }
 
 // SequentialModel is a model consisting of a sequence of layers.
@@ -60,6 +92,72 @@ func (m *SequentialModel) ResetState() {
 	}
 }
 
+// SetTraining switches all layers that distinguish training from inference.
+func (m *SequentialModel) SetTraining(training bool) {
+	for _, layer := range m.Layers {
+		if l, ok := layer.(TrainingModeSetter); ok {
+			l.SetTraining(training)
+		}
+	}
+}
+
 func (m *SequentialModel) GetLayers() []Layer {
 	return m.Layers
 }
+
+// ForwardTrace performs the forward pass, keeping the output of every layer.
+func (m *SequentialModel) ForwardTrace(input *Tensor) *Trace {
+	return m.ForwardTraceMasked(input, nil)
+}
+
+// ForwardTraceMasked performs the forward pass of a padded input, keeping the
+// output of every layer.
+func (m *SequentialModel) ForwardTraceMasked(input *Tensor, mask []bool) *Trace {
+	trace := &Trace{input: input, outputs: make([]*Tensor, len(m.Layers)), output: input}
+	f := inputFrames(input, mask)
+	lost := false
+	for i, layer := range m.Layers {
+		in := trace.output
+		var received bool
+		trace.output, received = forwardMasked(layer, in, f)
+		if _, ok := layer.(MaskedLayer); ok && !received && (f != nil || lost) && trace.unmasked == nil {
+			trace.unmasked = layer
+		}
+		next := propagateMask(layer, f, in, trace.output)
+		lost = lost || maskLost(f, next, received)
+		f = next
+		trace.outputs[i] = trace.output
+	}
+	return trace
+}
+
+// BackwardTrace backpropagates through the layers in reverse order.
+func (m *SequentialModel) BackwardTrace(trace *Trace, gradOutput *Tensor, skipActivation bool, update func(l Layer, gradWeights *Tensor, gradBias []float32)) {
+	startIdx := len(m.Layers) - 1
+	if skipActivation && startIdx >= 0 && isOutputActivation(m.Layers[startIdx]) {
+		startIdx--
+	}
+
+	grad := gradOutput
+	for i := startIdx; i >= 0; i-- {
+		input := trace.input
+		if i > 0 {
+			input = trace.outputs[i-1]
+		}
+		gradInput, gradWeights, gradBias := m.Layers[i].Backward(input, grad)
+		if gradWeights != nil {
+			update(m.Layers[i], gradWeights, gradBias)
+		}
+		grad = gradInput
+	}
+}
+
+// isOutputActivation reports whether l is an output activation whose derivative
+// is folded into the combined loss gradient (sigmoid for BCE, softmax for CE).
+func isOutputActivation(l Layer) bool {
+	switch l.Type() {
+	case "sigmoid", "softmax":
+		return true
+	}
+	return false
+}
@@ -5,22 +5,56 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 )
 
 const (
 	MagicBytes = "HWMD"
 	VersionV1  = uint16(1)
 	VersionV2  = uint16(2)
+	VersionV3  = uint16(3) // Adds a metadata section before the layers
+	VersionV4  = uint16(4) // Stores separate height and width strides and padding for convolutions
+	VersionV5  = uint16(5) // Stores the graph topology after the layers
+	VersionV6  = uint16(6) // Stores the return_sequences flag of recurrent layers
+	VersionV7  = uint16(7) // Adds int8 quantized layers
 )
 
+// Metadata holds string key/value pairs stored alongside the model weights,
+// such as the feature frontend settings the model was trained with.
+type Metadata map[string]string
+
 const (
-	LayerTypeConv2D    = uint32(1)
-	LayerTypeReLU      = uint32(2)
-	LayerTypeSigmoid   = uint32(3)
-	LayerTypeMaxPool2D = uint32(4)
-	LayerTypeDense     = uint32(5)
-	LayerTypeGRU       = uint32(6)
-	LayerTypeLSTM      = uint32(7)
+	LayerTypeConv2D         = uint32(1)
+	LayerTypeReLU           = uint32(2)
+	LayerTypeSigmoid        = uint32(3)
+	LayerTypeMaxPool2D      = uint32(4)
+	LayerTypeDense          = uint32(5)
+	LayerTypeGRU            = uint32(6)
+	LayerTypeLSTM           = uint32(7)
+	LayerTypeBatchNorm      = uint32(8)
+	LayerTypeDropout        = uint32(9)
+	LayerTypeSpatialDropout = uint32(10)
+	LayerTypeDepthwiseConv  = uint32(11)
+	LayerTypePointwiseConv  = uint32(12)
+	LayerTypeConv1D         = uint32(13)
+	LayerTypeMaxPool1D      = uint32(14)
+	LayerTypeAvgPool1D      = uint32(15)
+	LayerTypeReshape        = uint32(16)
+	LayerTypeTranspose      = uint32(17)
+	LayerTypeAdd            = uint32(18)
+	LayerTypeConcat         = uint32(19)
+	LayerTypeGlobalAvgPool  = uint32(20)
+	LayerTypeGlobalMaxPool  = uint32(21)
+	LayerTypeAttentionPool  = uint32(22)
+	LayerTypeBiGRU          = uint32(23)
+	LayerTypeBiLSTM         = uint32(24)
+	LayerTypeSoftmax        = uint32(25)
+	LayerTypeConv2DInt8     = uint32(26)
+	LayerTypeDenseInt8      = uint32(27)
+	LayerTypeGRUInt8        = uint32(28)
+	LayerTypeLSTMInt8       = uint32(29)
+	LayerTypeDenseSparse    = uint32(30)
+	LayerTypeGRUSparse      = uint32(31)
 )
 
 func layerToID(l Layer) uint32 {
@@ -39,13 +73,66 @@ func layerToID(l Layer) uint32 {
 		return LayerTypeGRU
 	case "lstm":
 		return LayerTypeLSTM
+	case "batchnorm":
+		return LayerTypeBatchNorm
+	case "dropout":
+		return LayerTypeDropout
+	case "spatial_dropout":
+		return LayerTypeSpatialDropout
+	case "depthwise_conv2d":
+		return LayerTypeDepthwiseConv
+	case "pointwise_conv2d":
+		return LayerTypePointwiseConv
+	case "conv1d":
+		return LayerTypeConv1D
+	case "maxpool1d":
+		return LayerTypeMaxPool1D
+	case "avgpool1d":
+		return LayerTypeAvgPool1D
+	case "reshape":
+		return LayerTypeReshape
+	case "transpose":
+		return LayerTypeTranspose
+	case "add":
+		return LayerTypeAdd
+	case "concat":
+		return LayerTypeConcat
+	case "global_avgpool":
+		return LayerTypeGlobalAvgPool
+	case "global_maxpool":
+		return LayerTypeGlobalMaxPool
+	case "attention_pool":
+		return LayerTypeAttentionPool
+	case "bigru":
+		return LayerTypeBiGRU
+	case "bilstm":
+		return LayerTypeBiLSTM
+	case "softmax":
+		return LayerTypeSoftmax
+	case "conv2d_int8":
+		return LayerTypeConv2DInt8
+	case "dense_int8":
+		return LayerTypeDenseInt8
+	case "gru_int8":
+		return LayerTypeGRUInt8
+	case "lstm_int8":
+		return LayerTypeLSTMInt8
+	case "dense_sparse":
+		return LayerTypeDenseSparse
+	case "gru_sparse":
+		return LayerTypeGRUSparse
 	default:
 		return 0
 	}
 }
 
-// SaveModel saves a Model to a file using the Version 2 format.
+// SaveModel saves a Model to a file without metadata.
 func SaveModel(path string, m Model) error {
+	return SaveModelWithMetadata(path, m, nil)
+}
+
+// SaveModelWithMetadata saves a Model and its metadata to a file using the Version 7 format.
+func SaveModelWithMetadata(path string, m Model, meta Metadata) error {
 	f, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("failed to create model file: %w", err)
@@ -58,17 +145,22 @@ func SaveModel(path string, m Model) error {
 	}
 
 	// 2. Version
-	if err := binary.Write(f, binary.LittleEndian, VersionV2); err != nil {
+	if err := binary.Write(f, binary.LittleEndian, VersionV7); err != nil {
+		return err
+	}
+
+	// 3. Metadata (sorted by key for reproducible files)
+	if err := saveMetadata(f, meta); err != nil {
 		return err
 	}
 
 	layers := m.GetLayers()
-	// 3. Layer Count
+	// 4. Layer Count
 	if err := binary.Write(f, binary.LittleEndian, uint32(len(layers))); err != nil {
 		return err
 	}
 
-	// 4. Layers
+	// 5. Layers
 	for _, l := range layers {
 		typeID := layerToID(l)
 		if err := binary.Write(f, binary.LittleEndian, typeID); err != nil {
@@ -84,14 +176,70 @@ func SaveModel(path string, m Model) error {
 			if err := saveBias(f, conv.Bias); err != nil {
 				return err
 			}
-			if err := binary.Write(f, binary.LittleEndian, uint32(conv.Stride)); err != nil {
+			if err := saveGeometry(f, conv.ConvGeometry); err != nil {
+				return err
+			}
+
+		case LayerTypeDepthwiseConv:
+			conv := l.(*DepthwiseConv2DLayer)
+			if err := saveTensor(f, conv.Weights); err != nil {
+				return err
+			}
+			if err := saveBias(f, conv.Bias); err != nil {
+				return err
+			}
+			if err := saveGeometry(f, conv.ConvGeometry); err != nil {
 				return err
 			}
-			if err := binary.Write(f, binary.LittleEndian, uint32(conv.Padding)); err != nil {
+
+		case LayerTypePointwiseConv:
+			conv := l.(*PointwiseConv2DLayer)
+			if err := saveTensor(f, conv.Weights); err != nil {
+				return err
+			}
+			if err := saveBias(f, conv.Bias); err != nil {
 				return err
 			}
 
-		case LayerTypeReLU, LayerTypeSigmoid:
+		case LayerTypeConv1D:
+			conv := l.(*Conv1DLayer)
+			if err := saveTensor(f, conv.Weights); err != nil {
+				return err
+			}
+			if err := saveBias(f, conv.Bias); err != nil {
+				return err
+			}
+			if err := saveInts(f, []int{conv.Stride, conv.Padding, conv.Dilation}); err != nil {
+				return err
+			}
+
+		case LayerTypeMaxPool1D, LayerTypeAvgPool1D:
+			pool := l.(*Pool1DLayer)
+			if err := saveInts(f, []int{pool.KernelSize, pool.Stride}); err != nil {
+				return err
+			}
+
+		case LayerTypeReshape:
+			if err := saveInts(f, l.(*ReshapeLayer).Shape); err != nil {
+				return err
+			}
+
+		case LayerTypeTranspose:
+			if err := saveInts(f, l.(*TransposeLayer).Perm); err != nil {
+				return err
+			}
+
+		case LayerTypeConcat:
+			if err := saveInts(f, []int{l.(*ConcatLayer).Axis}); err != nil {
+				return err
+			}
+
+		case LayerTypeAttentionPool:
+			if err := saveTensor(f, l.(*AttentionPoolLayer).Weights); err != nil {
+				return err
+			}
+
+		case LayerTypeReLU, LayerTypeSigmoid, LayerTypeSoftmax:
 			// No extra params
 
 		case LayerTypeMaxPool2D:
@@ -112,93 +260,152 @@ func SaveModel(path string, m Model) error {
 				return err
 			}
 
+		case LayerTypeBatchNorm:
+			bn := l.(*BatchNormLayer)
+			for _, v := range [][]float32{bn.Gamma, bn.Beta, bn.RunningMean, bn.RunningVar} {
+				if err := saveBias(f, v); err != nil {
+					return err
+				}
+			}
+			if err := binary.Write(f, binary.LittleEndian, bn.Momentum); err != nil {
+				return err
+			}
+			if err := binary.Write(f, binary.LittleEndian, bn.Epsilon); err != nil {
+				return err
+			}
+
+		case LayerTypeDropout, LayerTypeSpatialDropout:
+			d := l.(*DropoutLayer)
+			if err := binary.Write(f, binary.LittleEndian, d.Rate); err != nil {
+				return err
+			}
+			if err := binary.Write(f, binary.LittleEndian, d.Seed); err != nil {
+				return err
+			}
+
 		case LayerTypeGRU, LayerTypeLSTM:
-			var inputSize, hiddenSize int
-			var weights []*Tensor
-			var biases [][]float32
-
-			if typeID == LayerTypeGRU {
-				gru := l.(*GRULayer)
-				inputSize, hiddenSize = gru.InputSize, gru.HiddenSize
-				weights = []*Tensor{gru.Wz, gru.Wr, gru.Wh, gru.Uz, gru.Ur, gru.Uh}
-				biases = [][]float32{gru.Bz, gru.Br, gru.Bh}
-			} else {
-				lstm := l.(*LSTMLayer)
-				inputSize, hiddenSize = lstm.InputSize, lstm.HiddenSize
-				weights = []*Tensor{lstm.Wi, lstm.Wf, lstm.Wo, lstm.Wg, lstm.Ui, lstm.Uf, lstm.Uo, lstm.Ug}
-				biases = [][]float32{lstm.Bi, lstm.Bf, lstm.Bo, lstm.Bg}
+			if err := saveRecurrent(f, l); err != nil {
+				return err
 			}
 
-			if err := binary.Write(f, binary.LittleEndian, uint32(inputSize)); err != nil {
+		case LayerTypeBiGRU, LayerTypeBiLSTM:
+			// The forward layer followed by the reverse layer
+			bi := l.(*BidirectionalLayer)
+			if err := saveRecurrent(f, bi.Fwd); err != nil {
 				return err
 			}
-			if err := binary.Write(f, binary.LittleEndian, uint32(hiddenSize)); err != nil {
+			if err := saveRecurrent(f, bi.Bwd); err != nil {
 				return err
 			}
-			for _, w := range weights {
-				if err := saveTensor(f, w); err != nil {
-					return err
-				}
+
+		case LayerTypeConv2DInt8:
+			conv := l.(*QuantizedConv2DLayer)
+			if err := saveQuantized(f, conv.Weights, conv.Bias, conv.Input); err != nil {
+				return err
 			}
-			for _, b := range biases {
-				if err := saveBias(f, b); err != nil {
-					return err
-				}
+			if err := saveGeometry(f, conv.ConvGeometry); err != nil {
+				return err
+			}
+
+		case LayerTypeDenseInt8:
+			dense := l.(*QuantizedDenseLayer)
+			if err := saveQuantized(f, dense.Weights, dense.Bias, dense.Input); err != nil {
+				return err
+			}
+
+		case LayerTypeGRUInt8:
+			if err := saveQuantizedRecurrent(f, &l.(*QuantizedGRULayer).quantizedRecurrent); err != nil {
+				return err
+			}
+
+		case LayerTypeLSTMInt8:
+			if err := saveQuantizedRecurrent(f, &l.(*QuantizedLSTMLayer).quantizedRecurrent); err != nil {
+				return err
+			}
+
+		case LayerTypeDenseSparse:
+			dense := l.(*SparseDenseLayer)
+			if err := saveSparseMatrix(f, dense.Weights); err != nil {
+				return err
+			}
+			if err := saveBias(f, dense.Bias); err != nil {
+				return err
+			}
+
+		case LayerTypeGRUSparse:
+			if err := saveSparseGRU(f, l.(*SparseGRULayer)); err != nil {
+				return err
 			}
 		}
 	}
 
-	return nil
+	// 6. Graph topology (0 nodes for sequential models)
+	return saveTopology(f, m)
 }
 
 // LoadModel loads a Model from a file.
 func LoadModel(path string) (Model, error) {
+	m, _, err := LoadModelWithMetadata(path)
+	return m, err
+}
+
+// LoadModelWithMetadata loads a Model and its metadata from a file.
+// Files written before Version 3 have no metadata and return an empty map.
+func LoadModelWithMetadata(path string) (Model, Metadata, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open model file: %w", err)
+		return nil, nil, fmt.Errorf("failed to open model file: %w", err)
 	}
 	defer f.Close()
 
 	// 1. Magic Bytes
 	magic := make([]byte, 4)
 	if _, err := io.ReadFull(f, magic); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if string(magic) != MagicBytes {
-		return nil, fmt.Errorf("invalid magic bytes")
+		return nil, nil, fmt.Errorf("invalid magic bytes")
 	}
 
 	// 2. Version
 	var version uint16
 	if err := binary.Read(f, binary.LittleEndian, &version); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if version == VersionV1 {
 		// Legacy V1 loader (simple weights/bias)
 		w, b, err := loadLegacyV1(f)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		// Convert to SequentialModel
-		return NewSequentialModel(NewDenseLayer(w, b), NewSigmoidLayer()), nil
+		return NewSequentialModel(NewDenseLayer(w, b), NewSigmoidLayer()), Metadata{}, nil
 	}
 
-	if version != VersionV2 {
-		return nil, fmt.Errorf("unsupported model version: %d", version)
+	if version < VersionV2 || version > VersionV7 {
+		return nil, nil, fmt.Errorf("unsupported model version: %d", version)
 	}
 
-	// 3. Layer Count
+	// 3. Metadata (Version 3+)
+	meta := Metadata{}
+	if version >= VersionV3 {
+		if meta, err = loadMetadata(f); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 4. Layer Count
 	var layerCount uint32
 	if err := binary.Read(f, binary.LittleEndian, &layerCount); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var layers []Layer
 	for i := uint32(0); i < layerCount; i++ {
 		var typeID uint32
 		if err := binary.Read(f, binary.LittleEndian, &typeID); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		var l Layer
@@ -206,21 +413,118 @@ func LoadModel(path string) (Model, error) {
 		case LayerTypeConv2D:
 			w, err := loadTensor(f)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			b, err := loadBias(f)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
+			}
+			g, err := loadGeometry(f, version)
+			if err != nil {
+				return nil, nil, err
+			}
+			l = NewConv2DLayerWithGeometry(w, b, g)
+
+		case LayerTypeDepthwiseConv:
+			w, err := loadTensor(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			b, err := loadBias(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			g, err := loadGeometry(f, version)
+			if err != nil {
+				return nil, nil, err
+			}
+			l = NewDepthwiseConv2DLayer(w, b, g)
+
+		case LayerTypePointwiseConv:
+			w, err := loadTensor(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			b, err := loadBias(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			l = NewPointwiseConv2DLayer(w, b)
+
+		case LayerTypeConv1D:
+			w, err := loadTensor(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			b, err := loadBias(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			v, err := loadInts(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(v) != 3 {
+				return nil, nil, fmt.Errorf("invalid conv1d parameters: %v", v)
+			}
+			l = NewConv1DLayer(w, b, v[0], v[1], v[2])
+
+		case LayerTypeMaxPool1D, LayerTypeAvgPool1D:
+			v, err := loadInts(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(v) != 2 {
+				return nil, nil, fmt.Errorf("invalid pooling parameters: %v", v)
+			}
+			if typeID == LayerTypeMaxPool1D {
+				l = NewMaxPool1DLayer(v[0], v[1])
+			} else {
+				l = NewAvgPool1DLayer(v[0], v[1])
 			}
-			var stride, padding uint32
-			binary.Read(f, binary.LittleEndian, &stride)
-			binary.Read(f, binary.LittleEndian, &padding)
-			l = NewConv2DLayer(w, b, int(stride), int(padding))
+
+		case LayerTypeReshape, LayerTypeTranspose:
+			v, err := loadInts(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			if typeID == LayerTypeReshape {
+				l = NewReshapeLayer(v)
+			} else {
+				l = NewTransposeLayer(v)
+			}
+
+		case LayerTypeAdd:
+			l = NewAddLayer()
+
+		case LayerTypeConcat:
+			v, err := loadInts(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(v) != 1 {
+				return nil, nil, fmt.Errorf("invalid concat parameters: %v", v)
+			}
+			l = NewConcatLayer(v[0])
+
+		case LayerTypeGlobalAvgPool:
+			l = NewGlobalAvgPoolLayer()
+		case LayerTypeGlobalMaxPool:
+			l = NewGlobalMaxPoolLayer()
+
+		case LayerTypeAttentionPool:
+			w, err := loadTensor(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			l = NewAttentionPoolLayer(w)
 
 		case LayerTypeReLU:
 			l = NewReLULayer()
 		case LayerTypeSigmoid:
 			l = NewSigmoidLayer()
+		case LayerTypeSoftmax:
+			l = NewSoftmaxLayer()
 
 		case LayerTypeMaxPool2D:
 			var kernelSize, stride uint32
@@ -231,70 +535,617 @@ func LoadModel(path string) (Model, error) {
 		case LayerTypeDense:
 			w, err := loadTensor(f)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			b, err := loadBias(f)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			l = NewDenseLayer(w, b)
 
-		case LayerTypeGRU, LayerTypeLSTM:
-			var inputSize, hiddenSize uint32
-			binary.Read(f, binary.LittleEndian, &inputSize)
-			binary.Read(f, binary.LittleEndian, &hiddenSize)
-
-			var layer Layer
-			if typeID == LayerTypeGRU {
-				gru := NewGRULayer(int(inputSize), int(hiddenSize))
-				weights := []*Tensor{gru.Wz, gru.Wr, gru.Wh, gru.Uz, gru.Ur, gru.Uh}
-				for i := range weights {
-					w, err := loadTensor(f)
-					if err != nil {
-						return nil, err
-					}
-					copy(weights[i].Data, w.Data)
-				}
-				bz, _ := loadBias(f)
-				br, _ := loadBias(f)
-				bh, _ := loadBias(f)
-				copy(gru.Bz, bz)
-				copy(gru.Br, br)
-				copy(gru.Bh, bh)
-				layer = gru
-			} else {
-				lstm := NewLSTMLayer(int(inputSize), int(hiddenSize))
-				weights := []*Tensor{lstm.Wi, lstm.Wf, lstm.Wo, lstm.Wg, lstm.Ui, lstm.Uf, lstm.Uo, lstm.Ug}
-				for i := range weights {
-					w, err := loadTensor(f)
-					if err != nil {
-						return nil, err
-					}
-					copy(weights[i].Data, w.Data)
+		case LayerTypeBatchNorm:
+			var values [4][]float32
+			for j := range values {
+				if values[j], err = loadBias(f); err != nil {
+					return nil, nil, err
 				}
-				bi, _ := loadBias(f)
-				bf, _ := loadBias(f)
-				bo, _ := loadBias(f)
-				bg, _ := loadBias(f)
-				copy(lstm.Bi, bi)
-				copy(lstm.Bf, bf)
-				copy(lstm.Bo, bo)
-				copy(lstm.Bg, bg)
-				layer = lstm
+			}
+			bn := NewBatchNormLayer(len(values[0]))
+			bn.Gamma, bn.Beta, bn.RunningMean, bn.RunningVar = values[0], values[1], values[2], values[3]
+			if err := binary.Read(f, binary.LittleEndian, &bn.Momentum); err != nil {
+				return nil, nil, err
+			}
+			if err := binary.Read(f, binary.LittleEndian, &bn.Epsilon); err != nil {
+				return nil, nil, err
+			}
+			l = bn
+
+		case LayerTypeDropout, LayerTypeSpatialDropout:
+			d := &DropoutLayer{Spatial: typeID == LayerTypeSpatialDropout}
+			var seed uint64
+			if err := binary.Read(f, binary.LittleEndian, &d.Rate); err != nil {
+				return nil, nil, err
+			}
+			if err := binary.Read(f, binary.LittleEndian, &seed); err != nil {
+				return nil, nil, err
+			}
+			d.SetSeed(seed)
+			l = d
+
+		case LayerTypeGRU, LayerTypeLSTM:
+			layer, err := loadRecurrent(f, typeID, version)
+			if err != nil {
+				return nil, nil, err
 			}
 			l = layer
 
+		case LayerTypeBiGRU, LayerTypeBiLSTM:
+			inner := LayerTypeGRU
+			if typeID == LayerTypeBiLSTM {
+				inner = LayerTypeLSTM
+			}
+			fwd, err := loadRecurrent(f, inner, version)
+			if err != nil {
+				return nil, nil, err
+			}
+			bwd, err := loadRecurrent(f, inner, version)
+			if err != nil {
+				return nil, nil, err
+			}
+			l = &BidirectionalLayer{Fwd: fwd, Bwd: bwd, ReturnSequences: returnsSequences(fwd)}
+
+		case LayerTypeConv2DInt8:
+			w, b, in, err := loadQuantized(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			g, err := loadGeometry(f, version)
+			if err != nil {
+				return nil, nil, err
+			}
+			l = &QuantizedConv2DLayer{Weights: w, Bias: b, Input: in, ConvGeometry: g}
+
+		case LayerTypeDenseInt8:
+			w, b, in, err := loadQuantized(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			l = &QuantizedDenseLayer{Weights: w, Bias: b, Input: in}
+
+		case LayerTypeGRUInt8:
+			r, err := loadQuantizedRecurrent(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			l = &QuantizedGRULayer{quantizedRecurrent: r}
+
+		case LayerTypeLSTMInt8:
+			r, err := loadQuantizedRecurrent(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			l = &QuantizedLSTMLayer{quantizedRecurrent: r}
+
+		case LayerTypeDenseSparse:
+			w, err := loadSparseMatrix(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			b, err := loadBias(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(b) != w.Rows {
+				return nil, nil, fmt.Errorf("sparse dense bias has %d values for %d outputs", len(b), w.Rows)
+			}
+			l = &SparseDenseLayer{Weights: w, Bias: b}
+
+		case LayerTypeGRUSparse:
+			if l, err = loadSparseGRU(f); err != nil {
+				return nil, nil, err
+			}
+
 		default:
-			return nil, fmt.Errorf("unknown layer type ID: %d", typeID)
+			return nil, nil, fmt.Errorf("unknown layer type ID: %d", typeID)
 		}
 		layers = append(layers, l)
 	}
 
-	return NewSequentialModel(layers...), nil
+	// 6. Graph topology (Version 5+)
+	if version >= VersionV5 {
+		m, err := loadTopology(f, layers)
+		if err != nil {
+			return nil, nil, err
+		}
+		return m, meta, nil
+	}
+
+	return NewSequentialModel(layers...), meta, nil
+}
+
+// saveRecurrent writes the sizes, return_sequences flag, weights and biases of
+// a GRU or LSTM layer.
+func saveRecurrent(w io.Writer, l Layer) error {
+	var inputSize, hiddenSize int
+	var returnSequences bool
+	var weights []*Tensor
+	var biases [][]float32
+
+	switch r := l.(type) {
+	case *GRULayer:
+		inputSize, hiddenSize, returnSequences = r.InputSize, r.HiddenSize, r.ReturnSequences
+		weights = []*Tensor{r.Wz, r.Wr, r.Wh, r.Uz, r.Ur, r.Uh}
+		biases = [][]float32{r.Bz, r.Br, r.Bh}
+	case *LSTMLayer:
+		inputSize, hiddenSize, returnSequences = r.InputSize, r.HiddenSize, r.ReturnSequences
+		weights = []*Tensor{r.Wi, r.Wf, r.Wo, r.Wg, r.Ui, r.Uf, r.Uo, r.Ug}
+		biases = [][]float32{r.Bi, r.Bf, r.Bo, r.Bg}
+	default:
+		return ErrUnsupportedLayer{Type: l.Type()}
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(inputSize)); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(hiddenSize)); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, returnSequences); err != nil {
+		return err
+	}
+	for _, t := range weights {
+		if err := saveTensor(w, t); err != nil {
+			return err
+		}
+	}
+	for _, b := range biases {
+		if err := saveBias(w, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRecurrent reads a GRU (LayerTypeGRU) or LSTM (LayerTypeLSTM) record written
+// by saveRecurrent. Files older than Version 6 have no return_sequences flag.
+func loadRecurrent(r io.Reader, typeID uint32, version uint16) (Layer, error) {
+	var inputSize, hiddenSize uint32
+	if err := binary.Read(r, binary.LittleEndian, &inputSize); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hiddenSize); err != nil {
+		return nil, err
+	}
+	var returnSequences bool
+	if version >= VersionV6 {
+		if err := binary.Read(r, binary.LittleEndian, &returnSequences); err != nil {
+			return nil, err
+		}
+	}
+
+	var layer Layer
+	var weights []*Tensor
+	var biases [][]float32
+	if typeID == LayerTypeGRU {
+		gru := NewGRULayer(int(inputSize), int(hiddenSize))
+		gru.ReturnSequences = returnSequences
+		weights = []*Tensor{gru.Wz, gru.Wr, gru.Wh, gru.Uz, gru.Ur, gru.Uh}
+		biases = [][]float32{gru.Bz, gru.Br, gru.Bh}
+		layer = gru
+	} else {
+		lstm := NewLSTMLayer(int(inputSize), int(hiddenSize))
+		lstm.ReturnSequences = returnSequences
+		weights = []*Tensor{lstm.Wi, lstm.Wf, lstm.Wo, lstm.Wg, lstm.Ui, lstm.Uf, lstm.Uo, lstm.Ug}
+		biases = [][]float32{lstm.Bi, lstm.Bf, lstm.Bo, lstm.Bg}
+		layer = lstm
+	}
+	for _, t := range weights {
+		w, err := loadTensor(r)
+		if err != nil {
+			return nil, err
+		}
+		copy(t.Data, w.Data)
+	}
+	for _, b := range biases {
+		loaded, err := loadBias(r)
+		if err != nil {
+			return nil, err
+		}
+		copy(b, loaded)
+	}
+	return layer, nil
+}
+
+// saveQuantized writes the int8 weights, int32 bias and input quantization of
+// a quantized layer.
+func saveQuantized(w io.Writer, weights *QuantizedTensor, bias []int32, input QuantParams) error {
+	if err := saveQuantizedTensor(w, weights); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(bias))); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, bias); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, input)
+}
+
+// loadQuantized reads a record written by saveQuantized.
+func loadQuantized(r io.Reader) (*QuantizedTensor, []int32, QuantParams, error) {
+	var input QuantParams
+	weights, err := loadQuantizedTensor(r)
+	if err != nil {
+		return nil, nil, input, err
+	}
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, nil, input, err
+	}
+	if int(n) != weights.Shape[0] {
+		return nil, nil, input, fmt.Errorf("quantized bias has %d values for %d channels", n, weights.Shape[0])
+	}
+	bias := make([]int32, n)
+	if err := binary.Read(r, binary.LittleEndian, bias); err != nil {
+		return nil, nil, input, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &input); err != nil {
+		return nil, nil, input, err
+	}
+	return weights, bias, input, nil
+}
+
+// saveQuantizedRecurrent writes the sizes, return_sequences flag and quantized
+// gate weights of an int8 GRU or LSTM layer.
+func saveQuantizedRecurrent(w io.Writer, l *quantizedRecurrent) error {
+	if err := saveInts(w, []int{l.InputSize, l.HiddenSize}); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, l.ReturnSequences); err != nil {
+		return err
+	}
+	if err := saveQuantizedTensor(w, l.U); err != nil {
+		return err
+	}
+	return saveQuantized(w, l.W, l.Bias, l.Input)
+}
+
+// loadQuantizedRecurrent reads a record written by saveQuantizedRecurrent.
+func loadQuantizedRecurrent(r io.Reader) (quantizedRecurrent, error) {
+	var l quantizedRecurrent
+	sizes, err := loadInts(r)
+	if err != nil {
+		return l, err
+	}
+	if len(sizes) != 2 {
+		return l, fmt.Errorf("invalid recurrent layer sizes: %v", sizes)
+	}
+	l.InputSize, l.HiddenSize = sizes[0], sizes[1]
+	if err := binary.Read(r, binary.LittleEndian, &l.ReturnSequences); err != nil {
+		return l, err
+	}
+	if l.U, err = loadQuantizedTensor(r); err != nil {
+		return l, err
+	}
+	if l.W, l.Bias, l.Input, err = loadQuantized(r); err != nil {
+		return l, err
+	}
+	return l, nil
+}
+
+// saveSparseMatrix writes the size, row pointers, column indices and non-zero
+// values of a sparse matrix. Column indices are stored as 16-bit integers when
+// the matrix has at most 65536 columns.
+func saveSparseMatrix(w io.Writer, s *SparseMatrix) error {
+	if err := saveInts(w, []int{s.Rows, s.Cols, len(s.Values)}); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, s.RowPtr); err != nil {
+		return err
+	}
+	if s.Cols <= 1<<16 {
+		cols := make([]uint16, len(s.ColIdx))
+		for i, c := range s.ColIdx {
+			cols[i] = uint16(c)
+		}
+		if err := binary.Write(w, binary.LittleEndian, cols); err != nil {
+			return err
+		}
+	} else if err := binary.Write(w, binary.LittleEndian, s.ColIdx); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, s.Values)
+}
+
+// loadSparseMatrix reads a matrix written by saveSparseMatrix.
+func loadSparseMatrix(r io.Reader) (*SparseMatrix, error) {
+	sizes, err := loadInts(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(sizes) != 3 || sizes[0] <= 0 || sizes[1] <= 0 || sizes[2] < 0 || sizes[2] > sizes[0]*sizes[1] {
+		return nil, fmt.Errorf("invalid sparse matrix sizes: %v", sizes)
+	}
+	s := &SparseMatrix{Rows: sizes[0], Cols: sizes[1], RowPtr: make([]int32, sizes[0]+1),
+		ColIdx: make([]int32, sizes[2]), Values: make([]float32, sizes[2])}
+	if err := binary.Read(r, binary.LittleEndian, s.RowPtr); err != nil {
+		return nil, err
+	}
+	if s.Cols <= 1<<16 {
+		cols := make([]uint16, len(s.ColIdx))
+		if err := binary.Read(r, binary.LittleEndian, cols); err != nil {
+			return nil, err
+		}
+		for i, c := range cols {
+			s.ColIdx[i] = int32(c)
+		}
+	} else if err := binary.Read(r, binary.LittleEndian, s.ColIdx); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, s.Values); err != nil {
+		return nil, err
+	}
+
+	if s.RowPtr[0] != 0 || int(s.RowPtr[s.Rows]) != len(s.Values) {
+		return nil, fmt.Errorf("invalid sparse matrix row pointers")
+	}
+	for i := 0; i < s.Rows; i++ {
+		if s.RowPtr[i+1] < s.RowPtr[i] {
+			return nil, fmt.Errorf("invalid sparse matrix row pointers")
+		}
+	}
+	for _, c := range s.ColIdx {
+		if c < 0 || int(c) >= s.Cols {
+			return nil, fmt.Errorf("sparse matrix column index %d out of range for %d columns", c, s.Cols)
+		}
+	}
+	return s, nil
+}
+
+// saveSparseGRU writes the sizes, return_sequences flag, sparse gate weights
+// and biases of a sparse GRU layer.
+func saveSparseGRU(w io.Writer, g *SparseGRULayer) error {
+	if err := saveInts(w, []int{g.InputSize, g.HiddenSize}); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, g.ReturnSequences); err != nil {
+		return err
+	}
+	if err := saveSparseMatrix(w, g.W); err != nil {
+		return err
+	}
+	if err := saveSparseMatrix(w, g.U); err != nil {
+		return err
+	}
+	return saveBias(w, g.Bias)
+}
+
+// loadSparseGRU reads a layer written by saveSparseGRU.
+func loadSparseGRU(r io.Reader) (*SparseGRULayer, error) {
+	g := &SparseGRULayer{}
+	sizes, err := loadInts(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(sizes) != 2 {
+		return nil, fmt.Errorf("invalid recurrent layer sizes: %v", sizes)
+	}
+	g.InputSize, g.HiddenSize = sizes[0], sizes[1]
+	if err := binary.Read(r, binary.LittleEndian, &g.ReturnSequences); err != nil {
+		return nil, err
+	}
+	if g.W, err = loadSparseMatrix(r); err != nil {
+		return nil, err
+	}
+	if g.U, err = loadSparseMatrix(r); err != nil {
+		return nil, err
+	}
+	if g.Bias, err = loadBias(r); err != nil {
+		return nil, err
+	}
+	n := 3 * g.HiddenSize
+	if g.W.Rows != n || g.W.Cols != g.InputSize || g.U.Rows != n || g.U.Cols != g.HiddenSize || len(g.Bias) != n {
+		return nil, fmt.Errorf("sparse gru weights do not match input size %d and hidden size %d", g.InputSize, g.HiddenSize)
+	}
+	return g, nil
+}
+
+// saveTopology writes the name and inputs of every node of a graph model,
+// followed by the output name. Sequential models are stored as zero nodes.
+func saveTopology(w io.Writer, m Model) error {
+	g, ok := m.(*GraphModel)
+	if !ok {
+		return binary.Write(w, binary.LittleEndian, uint32(0))
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(g.Nodes))); err != nil {
+		return err
+	}
+	for _, n := range g.Nodes {
+		if err := saveString(w, n.Name); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(n.Inputs))); err != nil {
+			return err
+		}
+		for _, in := range n.Inputs {
+			if err := saveString(w, in); err != nil {
+				return err
+			}
+		}
+	}
+	return saveString(w, g.Output)
+}
+
+// loadTopology reads the graph topology and attaches it to the loaded layers,
+// which are stored in node order.
+func loadTopology(r io.Reader, layers []Layer) (Model, error) {
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return NewSequentialModel(layers...), nil
+	}
+	if int(count) != len(layers) {
+		return nil, fmt.Errorf("graph has %d nodes for %d layers", count, len(layers))
+	}
+
+	nodes := make([]*Node, count)
+	for i := range nodes {
+		name, err := loadString(r)
+		if err != nil {
+			return nil, err
+		}
+		var numInputs uint32
+		if err := binary.Read(r, binary.LittleEndian, &numInputs); err != nil {
+			return nil, err
+		}
+		if numInputs > 64 {
+			return nil, fmt.Errorf("invalid input count for node %q: %d", name, numInputs)
+		}
+		inputs := make([]string, numInputs)
+		for j := range inputs {
+			if inputs[j], err = loadString(r); err != nil {
+				return nil, err
+			}
+		}
+		nodes[i] = &Node{Name: name, Layer: layers[i], Inputs: inputs}
+	}
+	output, err := loadString(r)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := NewGraphModel(nodes, output)
+	if err != nil {
+		return nil, fmt.Errorf("invalid graph topology: %w", err)
+	}
+	return g, nil
 }
 
 // Helpers
 
+func saveString(w io.Writer, s string) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(s))); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte(s))
+	return err
+}
+
+func loadString(r io.Reader) (string, error) {
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return "", err
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func saveMetadata(w io.Writer, meta Metadata) error {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(keys))); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := saveString(w, k); err != nil {
+			return err
+		}
+		if err := saveString(w, meta[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadMetadata(r io.Reader) (Metadata, error) {
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+	meta := make(Metadata, count)
+	for i := uint32(0); i < count; i++ {
+		k, err := loadString(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata key: %w", err)
+		}
+		v, err := loadString(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata value for %s: %w", k, err)
+		}
+		meta[k] = v
+	}
+	return meta, nil
+}
+
+// saveInts writes a length-prefixed list of 32-bit integers.
+func saveInts(w io.Writer, values []int) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(values))); err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := binary.Write(w, binary.LittleEndian, int32(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadInts reads a list written by saveInts.
+func loadInts(r io.Reader) ([]int, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if n > 64 {
+		return nil, fmt.Errorf("invalid integer list length: %d", n)
+	}
+	raw := make([]int32, n)
+	if err := binary.Read(r, binary.LittleEndian, raw); err != nil {
+		return nil, err
+	}
+	values := make([]int, n)
+	for i, v := range raw {
+		values[i] = int(v)
+	}
+	return values, nil
+}
+
+// saveGeometry writes the strides and padding of a convolution.
+func saveGeometry(w io.Writer, g ConvGeometry) error {
+	for _, v := range []int{g.StrideH, g.StrideW, g.PaddingH, g.PaddingW} {
+		if err := binary.Write(w, binary.LittleEndian, uint32(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadGeometry reads the strides and padding of a convolution. Files written
+// before Version 4 store a single stride and padding for both axes.
+func loadGeometry(r io.Reader, version uint16) (ConvGeometry, error) {
+	n := 4
+	if version < VersionV4 {
+		n = 2
+	}
+	values := make([]uint32, n)
+	if err := binary.Read(r, binary.LittleEndian, values); err != nil {
+		return ConvGeometry{}, err
+	}
+	if n == 2 {
+		return SquareGeometry(int(values[0]), int(values[1])), nil
+	}
+	return ConvGeometry{StrideH: int(values[0]), StrideW: int(values[1]), PaddingH: int(values[2]), PaddingW: int(values[3])}, nil
+}
+
 func saveTensor(w io.Writer, t *Tensor) error {
 	if err := binary.Write(w, binary.LittleEndian, uint32(len(t.Shape))); err != nil {
 		return err
@@ -338,6 +1189,41 @@ func loadTensor(r io.Reader) (*Tensor, error) {
 	return t, nil
 }
 
+// saveQuantizedTensor writes the shape, per-channel quantization parameters
+// and int8 values of a quantized tensor.
+func saveQuantizedTensor(w io.Writer, q *QuantizedTensor) error {
+	if err := saveInts(w, q.Shape); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, q.Params); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, q.Data)
+}
+
+// loadQuantizedTensor reads a tensor written by saveQuantizedTensor.
+func loadQuantizedTensor(r io.Reader) (*QuantizedTensor, error) {
+	shape, err := loadInts(r)
+	if err != nil {
+		return nil, err
+	}
+	size := 1
+	for _, dim := range shape {
+		size *= dim
+	}
+	if len(shape) == 0 || size <= 0 {
+		return nil, fmt.Errorf("invalid quantized tensor shape: %v", shape)
+	}
+	q := &QuantizedTensor{Data: make([]int8, size), Shape: shape, Params: make([]QuantParams, shape[0])}
+	if err := binary.Read(r, binary.LittleEndian, q.Params); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, q.Data); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
 func saveBias(w io.Writer, b []float32) error {
 	if err := binary.Write(w, binary.LittleEndian, uint32(len(b))); err != nil {
 		return err
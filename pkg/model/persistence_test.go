@@ -95,3 +95,64 @@ func TestLoadLegacyV1(t *testing.T) {
 		t.Errorf("Wrong layer types: %s, %s", layers[0].Type(), layers[1].Type())
 	}
 }
+
+func TestPersistenceMetadata(t *testing.T) {
+	m := NewSequentialModel(NewDenseLayer(NewTensor([]int{1, 4}), []float32{0}), NewSigmoidLayer())
+	meta := Metadata{
+		"features.noise.method": "wiener",
+		"features.sample_rate":  "16000",
+	}
+
+	tmpFile := "test_model_meta.bin"
+	if err := SaveModelWithMetadata(tmpFile, m, meta); err != nil {
+		t.Fatalf("SaveModelWithMetadata failed: %v", err)
+	}
+	defer os.Remove(tmpFile)
+
+	loaded, loadedMeta, err := LoadModelWithMetadata(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadModelWithMetadata failed: %v", err)
+	}
+	if len(loaded.GetLayers()) != 2 {
+		t.Errorf("Expected 2 layers, got %d", len(loaded.GetLayers()))
+	}
+	if len(loadedMeta) != len(meta) {
+		t.Fatalf("Expected %d metadata entries, got %d", len(meta), len(loadedMeta))
+	}
+	for k, v := range meta {
+		if loadedMeta[k] != v {
+			t.Errorf("Metadata %s: expected %q, got %q", k, v, loadedMeta[k])
+		}
+	}
+
+	// Plain LoadModel ignores metadata
+	if _, err := LoadModel(tmpFile); err != nil {
+		t.Errorf("LoadModel failed on V3 file: %v", err)
+	}
+}
+
+func TestLoadV3Conv(t *testing.T) {
+	// Version 3 files store one stride and padding for both axes
+	tmpFile := "test_model_v3.bin"
+	f, _ := os.Create(tmpFile)
+	f.Write([]byte("HWMD"))
+	binary.Write(f, binary.LittleEndian, VersionV3)
+	binary.Write(f, binary.LittleEndian, uint32(0)) // No metadata
+	binary.Write(f, binary.LittleEndian, uint32(1)) // One layer
+	binary.Write(f, binary.LittleEndian, LayerTypeConv2D)
+	saveTensor(f, NewTensor([]int{2, 1, 3, 3}))
+	saveBias(f, []float32{0, 0})
+	binary.Write(f, binary.LittleEndian, uint32(2)) // Stride
+	binary.Write(f, binary.LittleEndian, uint32(1)) // Padding
+	f.Close()
+	defer os.Remove(tmpFile)
+
+	m, err := LoadModel(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadModel V3 failed: %v", err)
+	}
+	conv := m.GetLayers()[0].(*Conv2DLayer)
+	if conv.ConvGeometry != SquareGeometry(2, 1) {
+		t.Errorf("Expected square stride 2 and padding 1, got %+v", conv.ConvGeometry)
+	}
+}
@@ -0,0 +1,296 @@
+package model
+
+import "math"
+
+// GlobalPoolLayer reduces every channel of a [channels, ...] input to a single
+// value, the mean (global_avgpool) or the maximum (global_maxpool), giving a
+// [channels] output whose size does not depend on the input length. With a
+// frame mask (axis 1 of the input) it pools the valid frames only.
+type GlobalPoolLayer struct {
+	Max  bool
+	mask []bool // Frame mask of the last forward pass, nil for none
+}
+
+// NewGlobalAvgPoolLayer creates a global average pooling layer.
+func NewGlobalAvgPoolLayer() *GlobalPoolLayer {
+	return &GlobalPoolLayer{}
+}
+
+// NewGlobalMaxPoolLayer creates a global max pooling layer.
+func NewGlobalMaxPoolLayer() *GlobalPoolLayer {
+	return &GlobalPoolLayer{Max: true}
+}
+
+func (l *GlobalPoolLayer) Forward(input *Tensor) *Tensor {
+	l.mask = nil
+	return l.pool(input)
+}
+
+// ForwardMasked pools the frames with mask[t] true. A mask without any valid
+// frame is ignored.
+func (l *GlobalPoolLayer) ForwardMasked(input *Tensor, mask []bool) *Tensor {
+	l.mask = validMask(mask)
+	return l.pool(input)
+}
+
+func (l *GlobalPoolLayer) pool(input *Tensor) *Tensor {
+	channels := input.Shape[0]
+	size := len(input.Data) / channels
+	output := NewTensor([]int{channels})
+	for c := 0; c < channels; c++ {
+		in := input.Data[c*size : (c+1)*size]
+		if l.Max {
+			output.Data[c] = in[l.maxIndex(in)]
+			continue
+		}
+		var sum float32
+		for i, v := range in {
+			if l.valid(i, size) {
+				sum += v
+			}
+		}
+		output.Data[c] = sum / float32(l.count(size))
+	}
+	return output
+}
+
+// valid reports whether position i of a channel of the given size belongs to a
+// valid frame.
+func (l *GlobalPoolLayer) valid(i, size int) bool {
+	return l.mask == nil || l.mask[i/(size/len(l.mask))]
+}
+
+// count returns the number of valid positions of a channel of the given size.
+func (l *GlobalPoolLayer) count(size int) int {
+	if l.mask == nil {
+		return size
+	}
+	n := 0
+	for _, v := range l.mask {
+		if v {
+			n++
+		}
+	}
+	return n * (size / len(l.mask))
+}
+
+// maxIndex returns the index of the first maximum of the valid positions of a
+// channel.
+func (l *GlobalPoolLayer) maxIndex(values []float32) int {
+	if l.mask == nil {
+		return argmax(values)
+	}
+	best := -1
+	for i, v := range values {
+		if l.valid(i, len(values)) && (best < 0 || v > values[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+func (l *GlobalPoolLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *GlobalPoolLayer) ResetState() {}
+
+// Backward routes each channel gradient to the maximum of the channel, or
+// spreads it evenly over the channel for average pooling. Masked frames of the
+// last forward pass get no gradient.
+func (l *GlobalPoolLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	channels := input.Shape[0]
+	size := len(input.Data) / channels
+	gradInput := NewTensor(input.Shape)
+	for c := 0; c < channels; c++ {
+		dIn := gradInput.Data[c*size : (c+1)*size]
+		if l.Max {
+			dIn[l.maxIndex(input.Data[c*size:(c+1)*size])] = gradOutput.Data[c]
+			continue
+		}
+		g := gradOutput.Data[c] / float32(l.count(size))
+		for i := range dIn {
+			if l.valid(i, size) {
+				dIn[i] = g
+			}
+		}
+	}
+	return gradInput, nil, nil
+}
+
+func (l *GlobalPoolLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *GlobalPoolLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *GlobalPoolLayer) Type() string {
+	if l.Max {
+		return "global_maxpool"
+	}
+	return "global_avgpool"
+}
+
+// validMask returns mask, or nil if it has no valid frame.
+func validMask(mask []bool) []bool {
+	for _, v := range mask {
+		if v {
+			return mask
+		}
+	}
+	return nil
+}
+
+// argmax returns the index of the first maximum of values.
+func argmax(values []float32) int {
+	best := 0
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// AttentionPoolLayer replaces the frames of a CNN output by their weighted sum.
+// Every frame gets the score w·x_t, and a softmax over the scores gives the
+// weights, so the model learns which frames matter. Frames run along axis 1 of
+// [channels, frames] (conv1d) and [channels, frames, width] (conv2d) inputs; a
+// frame holds channels*width features. Weights have shape [1, features]. With a
+// frame mask the padding frames get no weight.
+type AttentionPoolLayer struct {
+	Weights *Tensor
+	mask    []bool // Frame mask of the last forward pass, nil for none
+}
+
+func NewAttentionPoolLayer(weights *Tensor) *AttentionPoolLayer {
+	return &AttentionPoolLayer{Weights: weights}
+}
+
+// frames returns the input as a [frames, features] tensor.
+func (l *AttentionPoolLayer) frames(input *Tensor) *Tensor {
+	t := Transpose(input, framePerm(input))
+	return &Tensor{Data: t.Data, Shape: []int{t.Shape[0], len(t.Data) / t.Shape[0]}}
+}
+
+// framePerm returns the permutation that moves the frame axis first. It is its
+// own inverse.
+func framePerm(input *Tensor) []int {
+	if len(input.Shape) == 3 {
+		return []int{1, 0, 2}
+	}
+	return []int{1, 0}
+}
+
+// attention returns the softmax weight of every frame of x.
+func (l *AttentionPoolLayer) attention(x *Tensor) []float32 {
+	numFrames, dim := x.Shape[0], x.Shape[1]
+	alpha := make([]float32, numFrames)
+	maxScore := float32(math.Inf(-1))
+	for t := range alpha {
+		if skipped(l.mask, t) {
+			continue
+		}
+		var s float32
+		for d, w := range l.Weights.Data {
+			s += w * x.Data[t*dim+d]
+		}
+		alpha[t] = s
+		if s > maxScore {
+			maxScore = s
+		}
+	}
+	var sum float32
+	for t, s := range alpha {
+		if skipped(l.mask, t) {
+			continue
+		}
+		alpha[t] = float32(math.Exp(float64(s - maxScore)))
+		sum += alpha[t]
+	}
+	for t := range alpha {
+		alpha[t] /= sum
+	}
+	return alpha
+}
+
+func (l *AttentionPoolLayer) Forward(input *Tensor) *Tensor {
+	l.mask = nil
+	return l.pool(input)
+}
+
+// ForwardMasked pools the frames with mask[t] true. A mask without any valid
+// frame is ignored.
+func (l *AttentionPoolLayer) ForwardMasked(input *Tensor, mask []bool) *Tensor {
+	l.mask = validMask(mask)
+	return l.pool(input)
+}
+
+func (l *AttentionPoolLayer) pool(input *Tensor) *Tensor {
+	x := l.frames(input)
+	dim := x.Shape[1]
+	output := NewTensor([]int{dim})
+	for t, a := range l.attention(x) {
+		for d, v := range x.Data[t*dim : (t+1)*dim] {
+			output.Data[d] += a * v
+		}
+	}
+	return output
+}
+
+func (l *AttentionPoolLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *AttentionPoolLayer) ResetState() {}
+
+// Backward calculates the gradients through the weighted sum and the softmax.
+// The gradient of frame t's score is alpha_t * (g·x_t - g·output); masked
+// frames of the last forward pass have alpha_t = 0 and get no gradient.
+func (l *AttentionPoolLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	x := l.frames(input)
+	numFrames, dim := x.Shape[0], x.Shape[1]
+	alpha := l.attention(x)
+	g := gradOutput.Data
+
+	// dL/dalpha_t = g·x_t and its alpha-weighted mean
+	dAlpha := make([]float32, numFrames)
+	var mean float32
+	for t := range dAlpha {
+		for d, v := range x.Data[t*dim : (t+1)*dim] {
+			dAlpha[t] += g[d] * v
+		}
+		mean += alpha[t] * dAlpha[t]
+	}
+
+	gradX := NewTensor(x.Shape)
+	gradWeights := NewTensor(l.Weights.Shape)
+	for t, a := range alpha {
+		dScore := a * (dAlpha[t] - mean)
+		xt := x.Data[t*dim : (t+1)*dim]
+		dxt := gradX.Data[t*dim : (t+1)*dim]
+		for d, w := range l.Weights.Data {
+			dxt[d] = a*g[d] + dScore*w
+			gradWeights.Data[d] += dScore * xt[d]
+		}
+	}
+
+	perm := framePerm(input)
+	shape := make([]int, len(perm))
+	for i, p := range perm {
+		shape[i] = input.Shape[p]
+	}
+	return Transpose(&Tensor{Data: gradX.Data, Shape: shape}, perm), gradWeights, nil
+}
+
+func (l *AttentionPoolLayer) Params() (*Tensor, []float32) {
+	return l.Weights, nil
+}
+
+func (l *AttentionPoolLayer) SetParams(weights *Tensor, bias []float32) {
+	l.Weights = weights
+}
+
+func (l *AttentionPoolLayer) Type() string {
+	return "attention_pool"
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestGlobalPool(t *testing.T) {
+	input := &Tensor{Data: []float32{1, 3, 2, 0, 5, 4, 2, 8, 6, 0, -1, -2}, Shape: []int{2, 2, 3}}
+
+	t.Run("Average", func(t *testing.T) {
+		l := NewGlobalAvgPoolLayer()
+		out := l.Forward(input)
+		if !reflect.DeepEqual(out.Shape, []int{2}) || !reflect.DeepEqual(out.Data, []float32{2.5, 2.1666667}) {
+			t.Fatalf("Expected [2.5 2.1666667] with shape [2], got %v with shape %v", out.Data, out.Shape)
+		}
+		grad, _, _ := l.Backward(input, &Tensor{Data: []float32{6, 12}, Shape: []int{2}})
+		expected := []float32{1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 2}
+		if !reflect.DeepEqual(grad.Shape, input.Shape) || !reflect.DeepEqual(grad.Data, expected) {
+			t.Errorf("Expected gradient %v, got %v", expected, grad.Data)
+		}
+	})
+
+	t.Run("Max", func(t *testing.T) {
+		l := NewGlobalMaxPoolLayer()
+		out := l.Forward(input)
+		if !reflect.DeepEqual(out.Data, []float32{5, 8}) {
+			t.Fatalf("Expected [5 8], got %v", out.Data)
+		}
+		grad, _, _ := l.Backward(input, &Tensor{Data: []float32{1, 2}, Shape: []int{2}})
+		expected := []float32{0, 0, 0, 0, 1, 0, 0, 2, 0, 0, 0, 0}
+		if !reflect.DeepEqual(grad.Data, expected) {
+			t.Errorf("Expected gradient %v, got %v", expected, grad.Data)
+		}
+	})
+}
+
+func TestAttentionPool(t *testing.T) {
+	rng := rand.New(rand.NewSource(10))
+
+	t.Run("Uniform Attention", func(t *testing.T) {
+		// Zero weights give every frame the same score, i.e. the mean over time
+		input := &Tensor{Data: []float32{1, 2, 3, 4, 5, 6}, Shape: []int{2, 3}}
+		out := NewAttentionPoolLayer(NewTensor([]int{1, 2})).Forward(input)
+		if !reflect.DeepEqual(out.Data, []float32{2, 5}) {
+			t.Errorf("Expected [2 5], got %v", out.Data)
+		}
+	})
+
+	t.Run("Frame Selection", func(t *testing.T) {
+		// A large weight on the first feature attends to the frame where it peaks
+		input := &Tensor{Data: []float32{0, 9, 1, 1, 2, 3}, Shape: []int{2, 3}}
+		out := NewAttentionPoolLayer(&Tensor{Data: []float32{10, 0}, Shape: []int{1, 2}}).Forward(input)
+		if math.Abs(float64(out.Data[0]-9)) > 1e-3 || math.Abs(float64(out.Data[1]-2)) > 1e-3 {
+			t.Errorf("Expected about [9 2], got %v", out.Data)
+		}
+	})
+
+	t.Run("Gradients", func(t *testing.T) {
+		checkGradients(t, NewAttentionPoolLayer(randomTensor([]int{1, 4}, rng)), randomTensor([]int{4, 6}, rng), rng)
+		checkGradients(t, NewAttentionPoolLayer(randomTensor([]int{1, 6}, rng)), randomTensor([]int{2, 5, 3}, rng), rng)
+	})
+}
+
+func TestLengthIndependentHeads(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	for _, head := range []string{"global_avgpool", "global_maxpool", "attention_pool"} {
+		t.Run(head, func(t *testing.T) {
+			m, err := BuildModelFromConfig([]LayerConfig{
+				{Type: "transpose", Perm: []int{0, 2, 1}},
+				{Type: "reshape", Shape: []int{40, -1}},
+				{Type: "conv1d", Filters: 4, KernelSize: 3, Padding: 1},
+				{Type: "relu"},
+				{Type: head},
+				{Type: "dense", Units: 1},
+				{Type: "sigmoid"},
+			}, []int{1, 61, 40})
+			if err != nil {
+				t.Fatalf("Failed to build model: %v", err)
+			}
+			if dense := m.Layers[5].(*DenseLayer); dense.Weights.Shape[1] != 4 {
+				t.Errorf("Expected 4 dense inputs, got %d", dense.Weights.Shape[1])
+			}
+
+			// A model built for 1 s also runs on 1.5 s
+			long := randomTensor([]int{1, 92, 40}, rng)
+			expected := m.Forward(long).Data[0]
+
+			tmpFile := "test_model_pool.bin"
+			if err := SaveModel(tmpFile, m); err != nil {
+				t.Fatalf("SaveModel failed: %v", err)
+			}
+			defer os.Remove(tmpFile)
+			loaded, err := LoadModel(tmpFile)
+			if err != nil {
+				t.Fatalf("LoadModel failed: %v", err)
+			}
+			for i, l := range loaded.GetLayers() {
+				if !reflect.DeepEqual(l, m.Layers[i]) {
+					t.Errorf("Layer %d (%s) differs after reload", i, l.Type())
+				}
+			}
+			if got := loaded.Forward(long).Data[0]; got != expected {
+				t.Errorf("Expected %f after reload, got %f", expected, got)
+			}
+		})
+	}
+
+	for _, bad := range []LayerConfig{{Type: "global_avgpool"}, {Type: "attention_pool"}} {
+		if _, err := BuildModelFromConfig([]LayerConfig{{Type: "dense", Units: 4}, bad}, []int{1, 61, 40}); err == nil {
+			t.Errorf("Expected error for %s on a [4] input", bad.Type)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+// QuantParams maps int8 values to real values: x = Scale * (q - ZeroPoint).
+type QuantParams struct {
+	Scale     float32
+	ZeroPoint int8
+}
+
+// stateQuant quantizes the hidden state of recurrent layers, which stays within
+// [-1, 1] (a tanh output, or a mix of tanh outputs), so it needs no calibration.
+var stateQuant = QuantParams{Scale: 1.0 / 127}
+
+// NewQuantParams returns the parameters that map [min, max] onto the int8
+// range. The range is extended to include 0, so that zero (e.g. the padding of
+// a convolution) is represented exactly.
+func NewQuantParams(min, max float32) QuantParams {
+	min = float32(math.Min(float64(min), 0))
+	max = float32(math.Max(float64(max), 0))
+	if max == min {
+		return QuantParams{Scale: 1}
+	}
+	scale := (max - min) / 255
+	zeroPoint := math.Round(-128 - float64(min/scale))
+	return QuantParams{Scale: scale, ZeroPoint: int8(math.Max(-128, math.Min(127, zeroPoint)))}
+}
+
+// Quantize returns the int8 value closest to x, saturating at the int8 range.
+func (p QuantParams) Quantize(x float32) int8 {
+	q := math.Round(float64(x/p.Scale)) + float64(p.ZeroPoint)
+	return int8(math.Max(-128, math.Min(127, q)))
+}
+
+// Dequantize returns the real value of q.
+func (p QuantParams) Dequantize(q int8) float32 {
+	return p.Scale * float32(int32(q)-int32(p.ZeroPoint))
+}
+
+// quantizeCentered quantizes x into dst as q - ZeroPoint, the operands of the
+// int8 kernels, so that real zeros are 0.
+func (p QuantParams) quantizeCentered(x []float32, dst []int32) {
+	for i, v := range x {
+		dst[i] = int32(p.Quantize(v)) - int32(p.ZeroPoint)
+	}
+}
+
+// QuantizedTensor holds int8 weights with a scale and zero point per output
+// channel, the first axis of the weights of every quantized layer.
+type QuantizedTensor struct {
+	Data   []int8
+	Shape  []int
+	Params []QuantParams // One per output channel
+}
+
+// QuantizeTensor quantizes t per output channel, mapping the range of every
+// channel onto the int8 range.
+func QuantizeTensor(t *Tensor) *QuantizedTensor {
+	channels := t.Shape[0]
+	size := len(t.Data) / channels
+	q := &QuantizedTensor{Data: make([]int8, len(t.Data)), Shape: append([]int{}, t.Shape...), Params: make([]QuantParams, channels)}
+	for c := 0; c < channels; c++ {
+		values := t.Data[c*size : (c+1)*size]
+		var min, max float32
+		for _, v := range values {
+			min = float32(math.Min(float64(min), float64(v)))
+			max = float32(math.Max(float64(max), float64(v)))
+		}
+		q.Params[c] = NewQuantParams(min, max)
+		for j, v := range values {
+			q.Data[c*size+j] = q.Params[c].Quantize(v)
+		}
+	}
+	return q
+}
+
+// Dequantize returns the real values of the weights.
+func (q *QuantizedTensor) Dequantize() *Tensor {
+	t := NewTensor(q.Shape)
+	size := q.channelSize()
+	for i, v := range q.Data {
+		t.Data[i] = q.Params[i/size].Dequantize(v)
+	}
+	return t
+}
+
+// channelSize returns the number of weights of one output channel.
+func (q *QuantizedTensor) channelSize() int {
+	return len(q.Data) / q.Shape[0]
+}
+
+// dot returns the int32 sum of x[j] * (w[j] - zero point) over the weights w
+// of channel c, for centered input values x.
+func (q *QuantizedTensor) dot(c int, x []int32) int32 {
+	zero := int32(q.Params[c].ZeroPoint)
+	w := q.Data[c*q.channelSize():]
+	var acc int32
+	for j, v := range x {
+		acc += v * (int32(w[j]) - zero)
+	}
+	return acc
+}
+
+// matVec computes y[c] = inScale * scale[c] * (bias[c] + w[c] . x) for every
+// output channel c of a [channels, inputs] weight matrix, accumulating in
+// int32. x holds centered input values with scale inScale; bias may be nil.
+func (q *QuantizedTensor) matVec(x []int32, inScale float32, bias []int32, y []float32) {
+	for c := range y {
+		acc := q.dot(c, x)
+		if bias != nil {
+			acc += bias[c]
+		}
+		y[c] = float32(acc) * inScale * q.Params[c].Scale
+	}
+}
+
+// quantizeBias quantizes a bias to int32 in units of the product of the input
+// scale and the weight scale of every output channel, so that it can be added
+// to the int32 accumulator.
+func quantizeBias(bias []float32, input QuantParams, weights *QuantizedTensor) []int32 {
+	q := make([]int32, len(bias))
+	for c, b := range bias {
+		v := math.Round(float64(b / (input.Scale * weights.Params[c].Scale)))
+		q[c] = int32(math.Max(math.MinInt32, math.Min(math.MaxInt32, v)))
+	}
+	return q
+}
+
+// Calibrator records the range of the input of every layer that Quantize
+// converts to int8, over a sample of model inputs (e.g. part of the training
+// set).
+type Calibrator struct {
+	model   Model
+	ranges  map[Layer][2]float32
+	samples int
+}
+
+// NewCalibrator creates a calibrator for the layers of m.
+func NewCalibrator(m Model) *Calibrator {
+	return &Calibrator{model: m, ranges: make(map[Layer][2]float32)}
+}
+
+// Observe runs the model on input and widens the recorded ranges to the values
+// seen by every quantizable layer.
+func (c *Calibrator) Observe(input *Tensor) {
+	trace := c.model.ForwardTrace(input)
+	for i, l := range c.model.GetLayers() {
+		if !quantizable(l) {
+			continue
+		}
+		r := c.ranges[l] // Ranges always include 0, see NewQuantParams
+		for _, v := range layerInput(c.model, trace, i).Data {
+			r[0] = float32(math.Min(float64(r[0]), float64(v)))
+			r[1] = float32(math.Max(float64(r[1]), float64(v)))
+		}
+		c.ranges[l] = r
+	}
+	c.samples++
+}
+
+// Samples returns the number of observed inputs.
+func (c *Calibrator) Samples() int {
+	return c.samples
+}
+
+// layerInput returns the input of layer i (in GetLayers order) of a traced
+// forward pass of m.
+func layerInput(m Model, trace *Trace, i int) *Tensor {
+	if g, ok := m.(*GraphModel); ok {
+		return g.nodeInputs(i, trace.input, trace.outputs)[0]
+	}
+	if i == 0 {
+		return trace.input
+	}
+	return trace.outputs[i-1]
+}
+
+// quantizable reports whether Quantize converts l to int8.
+func quantizable(l Layer) bool {
+	switch l.(type) {
+	case *Conv2DLayer, *DenseLayer, *GRULayer, *LSTMLayer, *SparseDenseLayer, *SparseGRULayer:
+		return true
+	}
+	return false
+}
+
+// Quantize returns a copy of the model calibrated by c in which the conv2d,
+// dense, gru and lstm layers are replaced by their int8 versions, with weights
+// quantized per output channel and inputs quantized to the calibrated ranges.
+// Sparse layers are quantized as dense ones. Other layers are shared with the
+// original model and run in float32. It also returns the number of quantized
+// layers.
+func Quantize(m Model, c *Calibrator) (Model, int, error) {
+	if c.samples == 0 {
+		return nil, 0, fmt.Errorf("quantization needs at least one calibration input")
+	}
+	layers := m.GetLayers()
+	out := make([]Layer, len(layers))
+	quantized := 0
+	for i, l := range layers {
+		out[i] = l
+		if !quantizable(l) {
+			continue
+		}
+		r := c.ranges[l]
+		input := NewQuantParams(r[0], r[1])
+		switch f := l.(type) {
+		case *SparseDenseLayer:
+			out[i] = QuantizeDense(f.Dense(), input)
+		case *SparseGRULayer:
+			out[i] = QuantizeGRU(f.GRU(), input)
+		case *Conv2DLayer:
+			out[i] = QuantizeConv2D(f, input)
+		case *DenseLayer:
+			out[i] = QuantizeDense(f, input)
+		case *GRULayer:
+			out[i] = QuantizeGRU(f, input)
+		case *LSTMLayer:
+			out[i] = QuantizeLSTM(f, input)
+		}
+		quantized++
+	}
+	return WithLayers(m, out), quantized, nil
+}
+
+// IsQuantized reports whether the model has int8 layers.
+func IsQuantized(m Model) bool {
+	for _, l := range m.GetLayers() {
+		switch l.(type) {
+		case *QuantizedConv2DLayer, *QuantizedDenseLayer, *QuantizedGRULayer, *QuantizedLSTMLayer:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestQuantParams(t *testing.T) {
+	p := NewQuantParams(-1, 3)
+	if p.Quantize(-1) != -128 || p.Quantize(3) != 127 {
+		t.Errorf("Expected the range to map onto [-128, 127], got %d and %d", p.Quantize(-1), p.Quantize(3))
+	}
+	if v := p.Dequantize(p.Quantize(0)); v != 0 {
+		t.Errorf("Expected zero to be exact, got %f", v)
+	}
+	if p.Quantize(10) != 127 || p.Quantize(-10) != -128 {
+		t.Error("Expected values outside the range to saturate")
+	}
+	for _, x := range []float32{-0.7, 0.01, 1.234, 2.9} {
+		if v := p.Dequantize(p.Quantize(x)); math.Abs(float64(v-x)) > float64(p.Scale)/2+1e-6 {
+			t.Errorf("Round trip of %f: got %f", x, v)
+		}
+	}
+
+	// Positive-only ranges are extended to 0
+	if p := NewQuantParams(2, 4); p.Quantize(0) != -128 {
+		t.Errorf("Expected 0 at the bottom of the range, got %d", p.Quantize(0))
+	}
+}
+
+func TestQuantizeTensor(t *testing.T) {
+	w := &Tensor{Data: []float32{-0.5, 0.25, 0.5, 10, 20, 30}, Shape: []int{2, 3}}
+	q := QuantizeTensor(w)
+	if len(q.Params) != 2 || q.Params[0].Scale >= q.Params[1].Scale {
+		t.Fatalf("Expected a finer scale for the first channel, got %+v", q.Params)
+	}
+	d := q.Dequantize()
+	for i := range w.Data {
+		if math.Abs(float64(d.Data[i]-w.Data[i])) > float64(q.Params[i/3].Scale)/2+1e-5 {
+			t.Errorf("Weight %d: expected %f, got %f", i, w.Data[i], d.Data[i])
+		}
+	}
+}
+
+func TestQuantizeModel(t *testing.T) {
+	rng := rand.New(rand.NewSource(20))
+	ResetRand(20)
+	m, err := BuildModelFromConfig(streamingConfig("gru"), []int{1, 20, 6})
+	if err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+
+	c := NewCalibrator(m)
+	if _, _, err := Quantize(m, c); err == nil {
+		t.Error("Expected an error without calibration inputs")
+	}
+	var inputs []*Tensor
+	for i := 0; i < 10; i++ {
+		inputs = append(inputs, randomTensor([]int{1, 20, 6}, rng))
+		c.Observe(inputs[i])
+	}
+	q, n, err := Quantize(m, c)
+	if err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	// conv2d, 2 gru and dense
+	if n != 4 || !IsQuantized(q) || IsQuantized(m) {
+		t.Fatalf("Expected 4 quantized layers, got %d", n)
+	}
+	for _, in := range inputs {
+		expected, got := m.Forward(in).Data[0], q.Forward(in).Data[0]
+		if math.Abs(float64(got-expected)) > 0.02 {
+			t.Errorf("Expected %f, got %f", expected, got)
+		}
+	}
+
+	t.Run("Persistence", func(t *testing.T) {
+		tmpFile := "test_model_int8.bin"
+		if err := SaveModel(tmpFile, q); err != nil {
+			t.Fatalf("SaveModel failed: %v", err)
+		}
+		defer os.Remove(tmpFile)
+		loaded, err := LoadModel(tmpFile)
+		if err != nil {
+			t.Fatalf("LoadModel failed: %v", err)
+		}
+		for i, l := range loaded.GetLayers() {
+			if l.Type() != q.GetLayers()[i].Type() {
+				t.Errorf("Layer %d: expected %s, got %s", i, q.GetLayers()[i].Type(), l.Type())
+			}
+		}
+		if got, expected := loaded.Forward(inputs[0]).Data[0], q.Forward(inputs[0]).Data[0]; got != expected {
+			t.Errorf("Expected %f after loading, got %f", expected, got)
+		}
+
+		// The int8 weights take a quarter of the space of the float32 ones,
+		// which outweighs the quantization parameters
+		floatFile := "test_model_float.bin"
+		if err := SaveModel(floatFile, m); err != nil {
+			t.Fatalf("SaveModel failed: %v", err)
+		}
+		defer os.Remove(floatFile)
+		qi, _ := os.Stat(tmpFile)
+		fi, _ := os.Stat(floatFile)
+		if qi.Size() >= fi.Size()*2/3 {
+			t.Errorf("Expected the int8 model to be smaller: %d vs %d bytes", qi.Size(), fi.Size())
+		}
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		s, err := NewFrameStream(q)
+		if err != nil {
+			t.Fatalf("NewFrameStream failed: %v", err)
+		}
+		input := inputs[0]
+		scores := s.Push(input)
+		last := len(scores) - 1
+		expected := q.Forward(sliceFrames(input, 0, s.Context()+last*2)).Data[0]
+		if math.Abs(float64(scores[last]-expected)) > 1e-5 {
+			t.Errorf("Expected the streamed score %f, got %f", expected, scores[last])
+		}
+	})
+}
@@ -0,0 +1,377 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+)
+
+// The int8 layers below are created by Quantize from trained float32 layers.
+// They quantize their input to int8 with the calibrated input parameters,
+// multiply it with the int8 weights accumulating in int32, and return float32
+// outputs, so they can be mixed freely with float32 layers. They are
+// inference-only: Backward panics.
+
+// notTrainable panics for the Backward of an int8 layer.
+func notTrainable(l Layer) {
+	panic(fmt.Sprintf("%s layers are inference-only and cannot be trained", l.Type()))
+}
+
+// QuantizedDenseLayer is the int8 version of DenseLayer.
+type QuantizedDenseLayer struct {
+	Weights *QuantizedTensor // [outputs, inputs]
+	Bias    []int32          // In units of Input.Scale times the weight scale of every output
+	Input   QuantParams      // Calibrated input range
+}
+
+// QuantizeDense quantizes a dense layer whose input is quantized with input.
+func QuantizeDense(l *DenseLayer, input QuantParams) *QuantizedDenseLayer {
+	w := QuantizeTensor(l.Weights)
+	return &QuantizedDenseLayer{Weights: w, Bias: quantizeBias(l.Bias, input, w), Input: input}
+}
+
+func (l *QuantizedDenseLayer) Forward(input *Tensor) *Tensor {
+	if len(input.Data) != l.Weights.Shape[1] {
+		panic("Quantized dense layer: input size mismatch")
+	}
+	x := make([]int32, len(input.Data))
+	l.Input.quantizeCentered(input.Data, x)
+	output := NewTensor([]int{l.Weights.Shape[0]})
+	l.Weights.matVec(x, l.Input.Scale, l.Bias, output.Data)
+	return output
+}
+
+func (l *QuantizedDenseLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *QuantizedDenseLayer) ResetState() {}
+
+func (l *QuantizedDenseLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	notTrainable(l)
+	return nil, nil, nil
+}
+
+func (l *QuantizedDenseLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *QuantizedDenseLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *QuantizedDenseLayer) Type() string {
+	return "dense_int8"
+}
+
+// QuantizedConv2DLayer is the int8 version of Conv2DLayer.
+type QuantizedConv2DLayer struct {
+	Weights *QuantizedTensor // [filters, channels, kernelHeight, kernelWidth]
+	Bias    []int32          // In units of Input.Scale times the weight scale of every filter
+	Input   QuantParams      // Calibrated input range
+	ConvGeometry
+}
+
+// QuantizeConv2D quantizes a convolution whose input is quantized with input.
+func QuantizeConv2D(l *Conv2DLayer, input QuantParams) *QuantizedConv2DLayer {
+	w := QuantizeTensor(l.Weights)
+	return &QuantizedConv2DLayer{Weights: w, Bias: quantizeBias(l.Bias, input, w), Input: input, ConvGeometry: l.ConvGeometry}
+}
+
+func (l *QuantizedConv2DLayer) Forward(input *Tensor) *Tensor {
+	inChannels, inHeight, inWidth := input.Shape[0], input.Shape[1], input.Shape[2]
+	numFilters, kernelHeight, kernelWidth := l.Weights.Shape[0], l.Weights.Shape[2], l.Weights.Shape[3]
+	outHeight, outWidth := l.OutputSize(inHeight, inWidth, kernelHeight, kernelWidth)
+
+	x := make([]int32, len(input.Data))
+	l.Input.quantizeCentered(input.Data, x)
+	output := NewTensor([]int{numFilters, outHeight, outWidth})
+
+	var wg sync.WaitGroup
+	wg.Add(numFilters)
+	for f := 0; f < numFilters; f++ {
+		go func(f int) {
+			defer wg.Done()
+			w := l.Weights.Data[f*l.Weights.channelSize():]
+			zero := int32(l.Weights.Params[f].ZeroPoint)
+			scale := l.Input.Scale * l.Weights.Params[f].Scale
+			for i := 0; i < outHeight; i++ {
+				for j := 0; j < outWidth; j++ {
+					acc := l.Bias[f]
+					for c := 0; c < inChannels; c++ {
+						for ki := 0; ki < kernelHeight; ki++ {
+							ii := i*l.StrideH - l.PaddingH + ki
+							if ii < 0 || ii >= inHeight {
+								continue // Padding is zero
+							}
+							for kj := 0; kj < kernelWidth; kj++ {
+								jj := j*l.StrideW - l.PaddingW + kj
+								if jj < 0 || jj >= inWidth {
+									continue
+								}
+								weight := int32(w[(c*kernelHeight+ki)*kernelWidth+kj]) - zero
+								acc += x[(c*inHeight+ii)*inWidth+jj] * weight
+							}
+						}
+					}
+					output.Data[(f*outHeight+i)*outWidth+j] = float32(acc) * scale
+				}
+			}
+		}(f)
+	}
+	wg.Wait()
+	return output
+}
+
+func (l *QuantizedConv2DLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *QuantizedConv2DLayer) ResetState() {}
+
+func (l *QuantizedConv2DLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	notTrainable(l)
+	return nil, nil, nil
+}
+
+func (l *QuantizedConv2DLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *QuantizedConv2DLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *QuantizedConv2DLayer) Type() string {
+	return "conv2d_int8"
+}
+
+// quantizedRecurrent holds the int8 weights shared by the quantized GRU and
+// LSTM layers. The weights of all gates are stacked along the output axis.
+// The input is quantized with the calibrated Input parameters and the hidden
+// state, which stays within [-1, 1], with a fixed scale of 1/127.
+type quantizedRecurrent struct {
+	W     *QuantizedTensor // [gates*hiddenSize, inputSize]
+	U     *QuantizedTensor // [gates*hiddenSize, hiddenSize]
+	Bias  []int32          // [gates*hiddenSize] in units of the input path (Input.Scale times the W scales)
+	Input QuantParams      // Calibrated input range
+
+	InputSize       int
+	HiddenSize      int
+	ReturnSequences bool
+}
+
+// newQuantizedRecurrent quantizes the stacked gate weights of a GRU or LSTM.
+func newQuantizedRecurrent(w, u []*Tensor, b [][]float32, input QuantParams, inputSize, hiddenSize int, returnSequences bool) quantizedRecurrent {
+	var bias []float32
+	for _, v := range b {
+		bias = append(bias, v...)
+	}
+	qw := QuantizeTensor(stackRows(w))
+	return quantizedRecurrent{
+		W:               qw,
+		U:               QuantizeTensor(stackRows(u)),
+		Bias:            quantizeBias(bias, input, qw),
+		Input:           input,
+		InputSize:       inputSize,
+		HiddenSize:      hiddenSize,
+		ReturnSequences: returnSequences,
+	}
+}
+
+// stackRows concatenates [rows, cols] matrices along the rows.
+func stackRows(ts []*Tensor) *Tensor {
+	rows := 0
+	for _, t := range ts {
+		rows += t.Shape[0]
+	}
+	out := NewTensor([]int{rows, ts[0].Shape[1]})
+	offset := 0
+	for _, t := range ts {
+		offset += copy(out.Data[offset:], t.Data)
+	}
+	return out
+}
+
+// inputGates returns the input contribution W*x + b of every gate at every
+// timestep of a [seqLen, inputSize] sequence, computed with int8 kernels.
+func (r *quantizedRecurrent) inputGates(seq *Tensor) [][]float32 {
+	seqLen := seq.Shape[0]
+	x := make([]int32, len(seq.Data))
+	r.Input.quantizeCentered(seq.Data, x)
+	gates := make([][]float32, seqLen)
+	for t := range gates {
+		gates[t] = make([]float32, r.W.Shape[0])
+		r.W.matVec(x[t*r.InputSize:(t+1)*r.InputSize], r.Input.Scale, r.Bias, gates[t])
+	}
+	return gates
+}
+
+// hiddenGate returns the hidden contribution U*h of the gate rows starting at
+// row for a state h, computed with int8 kernels.
+func (r *quantizedRecurrent) hiddenGate(row int, h []float32, out []float32) {
+	hq := make([]int32, len(h))
+	stateQuant.quantizeCentered(h, hq)
+	for i := range out {
+		out[i] = float32(r.U.dot(row+i, hq)) * stateQuant.Scale * r.U.Params[row+i].Scale
+	}
+}
+
+// output returns the final hidden state h, or all hidden states with
+// ReturnSequences.
+func (r *quantizedRecurrent) output(states [][]float32, h []float32) *Tensor {
+	if !r.ReturnSequences {
+		return &Tensor{Data: h, Shape: []int{r.HiddenSize}}
+	}
+	out := NewTensor([]int{len(states), r.HiddenSize})
+	for t, h := range states {
+		copy(out.Data[t*r.HiddenSize:], h)
+	}
+	return out
+}
+
+// QuantizedGRULayer is the int8 version of GRULayer.
+type QuantizedGRULayer struct {
+	quantizedRecurrent
+
+	hiddenState []float32 // Stateful inference
+}
+
+// QuantizeGRU quantizes a GRU whose input is quantized with input.
+func QuantizeGRU(l *GRULayer, input QuantParams) *QuantizedGRULayer {
+	return &QuantizedGRULayer{quantizedRecurrent: newQuantizedRecurrent(
+		[]*Tensor{l.Wz, l.Wr, l.Wh}, []*Tensor{l.Uz, l.Ur, l.Uh}, [][]float32{l.Bz, l.Br, l.Bh},
+		input, l.InputSize, l.HiddenSize, l.ReturnSequences)}
+}
+
+func (g *QuantizedGRULayer) Forward(input *Tensor) *Tensor {
+	out, _ := g.forward(input, make([]float32, g.HiddenSize))
+	return out
+}
+
+func (g *QuantizedGRULayer) ForwardStateful(input *Tensor) *Tensor {
+	if g.hiddenState == nil {
+		g.hiddenState = make([]float32, g.HiddenSize)
+	}
+	out, h := g.forward(input, g.hiddenState)
+	g.hiddenState = h
+	return out
+}
+
+func (g *QuantizedGRULayer) ResetState() {
+	g.hiddenState = nil
+}
+
+// forward runs the GRU from state h and returns the output and the final state.
+func (g *QuantizedGRULayer) forward(input *Tensor, h []float32) (*Tensor, []float32) {
+	n := g.HiddenSize
+	gates := g.inputGates(sequence(input))
+	states := make([][]float32, len(gates))
+	zr := make([]float32, 2*n)
+	cand := make([]float32, n)
+	rh := make([]float32, n)
+	for t, x := range gates {
+		// Update and reset gates: sigmoid(W*x + U*h + b)
+		g.hiddenGate(0, h, zr)
+		for i := range zr {
+			zr[i] = sigmoid32(zr[i] + x[i])
+		}
+		// Candidate: tanh(Wh*x + Uh*(r*h) + bh)
+		for i := range rh {
+			rh[i] = zr[n+i] * h[i]
+		}
+		g.hiddenGate(2*n, rh, cand)
+		newH := make([]float32, n)
+		for i := range newH {
+			z := zr[i]
+			newH[i] = (1-z)*h[i] + z*tanh32(cand[i]+x[2*n+i])
+		}
+		states[t], h = newH, newH
+	}
+	return g.output(states, h), h
+}
+
+func (g *QuantizedGRULayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	notTrainable(g)
+	return nil, nil, nil
+}
+
+func (g *QuantizedGRULayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (g *QuantizedGRULayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (g *QuantizedGRULayer) Type() string {
+	return "gru_int8"
+}
+
+// QuantizedLSTMLayer is the int8 version of LSTMLayer. The cell state stays
+// float32.
+type QuantizedLSTMLayer struct {
+	quantizedRecurrent
+
+	hiddenState []float32 // Stateful inference
+	cellState   []float32
+}
+
+// QuantizeLSTM quantizes an LSTM whose input is quantized with input.
+func QuantizeLSTM(l *LSTMLayer, input QuantParams) *QuantizedLSTMLayer {
+	return &QuantizedLSTMLayer{quantizedRecurrent: newQuantizedRecurrent(
+		[]*Tensor{l.Wi, l.Wf, l.Wo, l.Wg}, []*Tensor{l.Ui, l.Uf, l.Uo, l.Ug}, [][]float32{l.Bi, l.Bf, l.Bo, l.Bg},
+		input, l.InputSize, l.HiddenSize, l.ReturnSequences)}
+}
+
+func (l *QuantizedLSTMLayer) Forward(input *Tensor) *Tensor {
+	out, _, _ := l.forward(input, make([]float32, l.HiddenSize), make([]float32, l.HiddenSize))
+	return out
+}
+
+func (l *QuantizedLSTMLayer) ForwardStateful(input *Tensor) *Tensor {
+	if l.hiddenState == nil {
+		l.hiddenState = make([]float32, l.HiddenSize)
+		l.cellState = make([]float32, l.HiddenSize)
+	}
+	out, h, c := l.forward(input, l.hiddenState, l.cellState)
+	l.hiddenState, l.cellState = h, c
+	return out
+}
+
+func (l *QuantizedLSTMLayer) ResetState() {
+	l.hiddenState = nil
+	l.cellState = nil
+}
+
+// forward runs the LSTM from states h and c and returns the output and the
+// final states.
+func (l *QuantizedLSTMLayer) forward(input *Tensor, h, c []float32) (*Tensor, []float32, []float32) {
+	n := l.HiddenSize
+	gates := l.inputGates(sequence(input))
+	states := make([][]float32, len(gates))
+	pre := make([]float32, 4*n)
+	for t, x := range gates {
+		// Input, forget, output and cell candidate gates
+		l.hiddenGate(0, h, pre)
+		newC, newH := make([]float32, n), make([]float32, n)
+		for j := 0; j < n; j++ {
+			i := sigmoid32(pre[j] + x[j])
+			f := sigmoid32(pre[n+j] + x[n+j])
+			o := sigmoid32(pre[2*n+j] + x[2*n+j])
+			g := tanh32(pre[3*n+j] + x[3*n+j])
+			newC[j] = f*c[j] + i*g
+			newH[j] = o * tanh32(newC[j])
+		}
+		states[t], h, c = newH, newH, newC
+	}
+	return l.output(states, h), h, c
+}
+
+func (l *QuantizedLSTMLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	notTrainable(l)
+	return nil, nil, nil
+}
+
+func (l *QuantizedLSTMLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *QuantizedLSTMLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *QuantizedLSTMLayer) Type() string {
+	return "lstm_int8"
+}
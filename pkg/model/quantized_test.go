@@ -0,0 +1,106 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// assertQuantized checks that an int8 output is within tol of the largest
+// float32 output value of expected.
+func assertQuantized(t *testing.T, label string, got, expected *Tensor, tol float64) {
+	t.Helper()
+	if !reflect.DeepEqual(got.Shape, expected.Shape) {
+		t.Fatalf("%s: expected shape %v, got %v", label, expected.Shape, got.Shape)
+	}
+	var peak float64
+	for _, v := range expected.Data {
+		peak = math.Max(peak, math.Abs(float64(v)))
+	}
+	for i := range expected.Data {
+		if diff := math.Abs(float64(got.Data[i] - expected.Data[i])); diff > tol*peak {
+			t.Errorf("%s[%d]: expected %f, got %f", label, i, expected.Data[i], got.Data[i])
+			return
+		}
+	}
+}
+
+// inputQuant returns the quantization of the range of x.
+func inputQuant(x *Tensor) QuantParams {
+	var min, max float32
+	for _, v := range x.Data {
+		min = float32(math.Min(float64(min), float64(v)))
+		max = float32(math.Max(float64(max), float64(v)))
+	}
+	return NewQuantParams(min, max)
+}
+
+func TestQuantizedLayers(t *testing.T) {
+	rng := rand.New(rand.NewSource(19))
+	ResetRand(19)
+
+	t.Run("Dense", func(t *testing.T) {
+		l := NewDenseLayer(randomTensor([]int{4, 30}, rng), []float32{0.5, -1, 0, 2})
+		input := randomTensor([]int{30}, rng)
+		q := QuantizeDense(l, inputQuant(input))
+		assertQuantized(t, "dense", q.Forward(input), l.Forward(input), 0.02)
+	})
+
+	t.Run("Conv2D", func(t *testing.T) {
+		for _, g := range []ConvGeometry{SquareGeometry(1, 1), {StrideH: 2, StrideW: 1, PaddingH: 0, PaddingW: 1}} {
+			l := NewConv2DLayerWithGeometry(randomTensor([]int{3, 2, 3, 3}, rng), []float32{0.1, -0.2, 0.3}, g)
+			input := randomTensor([]int{2, 8, 6}, rng)
+			q := QuantizeConv2D(l, inputQuant(input))
+			assertQuantized(t, "conv2d", q.Forward(input), l.Forward(input), 0.02)
+		}
+	})
+
+	for _, rnn := range []string{"gru", "lstm"} {
+		for _, seq := range []bool{false, true} {
+			name := rnn
+			if seq {
+				name += " Sequences"
+			}
+			t.Run(name, func(t *testing.T) {
+				var l, q Layer
+				input := randomTensor([]int{2, 10, 3}, rng)
+				if rnn == "gru" {
+					gru := NewGRULayer(6, 5)
+					gru.ReturnSequences = seq
+					gru.Bz[1], gru.Bh[2] = 0.5, -0.3
+					l, q = gru, QuantizeGRU(gru, inputQuant(input))
+				} else {
+					lstm := NewLSTMLayer(6, 5)
+					lstm.ReturnSequences = seq
+					lstm.Bf[0], lstm.Bg[3] = 1, -0.4
+					l, q = lstm, QuantizeLSTM(lstm, inputQuant(input))
+				}
+				expected := l.Forward(input)
+				assertQuantized(t, rnn, q.Forward(input), expected, 0.05)
+
+				// Streaming in two chunks carries the state over
+				first := q.ForwardStateful(sliceFrames(input, 0, 4))
+				second := q.ForwardStateful(sliceFrames(input, 4, 10))
+				whole := q.Forward(input)
+				if seq {
+					assertClose(t, rnn+" stateful", append(first.Data, second.Data...), whole.Data)
+				} else {
+					assertClose(t, rnn+" stateful", second.Data, whole.Data)
+				}
+				q.ResetState()
+				assertClose(t, rnn+" reset", q.ForwardStateful(input).Data, whole.Data)
+			})
+		}
+	}
+
+	t.Run("Inference Only", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected a panic for the backward pass of an int8 layer")
+			}
+		}()
+		q := QuantizeDense(NewDenseLayer(randomTensor([]int{1, 2}, rng), []float32{0}), NewQuantParams(-1, 1))
+		q.Backward(NewTensor([]int{2}), NewTensor([]int{1}))
+	})
+}
@@ -0,0 +1,121 @@
+package model
+
+import "fmt"
+
+// ReshapeLayer changes the shape of its input without moving any values. The
+// output shares its data with the input. A -1 in Shape is inferred from the
+// size of each input, so the layer accepts inputs of varying length.
+type ReshapeLayer struct {
+	Shape []int
+}
+
+func NewReshapeLayer(shape []int) *ReshapeLayer {
+	return &ReshapeLayer{Shape: shape}
+}
+
+func (l *ReshapeLayer) Forward(input *Tensor) *Tensor {
+	shape, err := reshapeTarget(l.Shape, input.Shape)
+	if err != nil {
+		panic(fmt.Sprintf("ReshapeLayer: %v", err))
+	}
+	return &Tensor{Data: input.Data, Shape: shape}
+}
+
+func (l *ReshapeLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *ReshapeLayer) ResetState() {}
+
+func (l *ReshapeLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	return &Tensor{Data: gradOutput.Data, Shape: input.Shape}, nil, nil
+}
+
+func (l *ReshapeLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *ReshapeLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *ReshapeLayer) Type() string {
+	return "reshape"
+}
+
+// TransposeLayer permutes the axes of its input: output axis i is input axis
+// Perm[i]. For example Perm [0, 2, 1] turns [1, frames, mels] features into
+// [1, mels, frames], ready to be reshaped to [mels, frames] for conv1d.
+type TransposeLayer struct {
+	Perm []int
+}
+
+func NewTransposeLayer(perm []int) *TransposeLayer {
+	return &TransposeLayer{Perm: perm}
+}
+
+func (l *TransposeLayer) Forward(input *Tensor) *Tensor {
+	return Transpose(input, l.Perm)
+}
+
+func (l *TransposeLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *TransposeLayer) ResetState() {}
+
+// Backward applies the inverse permutation to the gradient.
+func (l *TransposeLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	inverse := make([]int, len(l.Perm))
+	for i, p := range l.Perm {
+		inverse[p] = i
+	}
+	return Transpose(gradOutput, inverse), nil, nil
+}
+
+func (l *TransposeLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *TransposeLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *TransposeLayer) Type() string {
+	return "transpose"
+}
+
+// Transpose returns a copy of input with its axes permuted by perm.
+func Transpose(input *Tensor, perm []int) *Tensor {
+	dims := len(perm)
+	outShape := make([]int, dims)
+	for i, p := range perm {
+		outShape[i] = input.Shape[p]
+	}
+	output := NewTensor(outShape)
+
+	// Input strides, reordered to follow the output axes
+	inStrides := make([]int, dims)
+	stride := 1
+	for i := dims - 1; i >= 0; i-- {
+		inStrides[i] = stride
+		stride *= input.Shape[i]
+	}
+	strides := make([]int, dims)
+	for i, p := range perm {
+		strides[i] = inStrides[p]
+	}
+
+	// Walk the output in order, advancing a multi-dimensional counter
+	index := make([]int, dims)
+	src := 0
+	for o := range output.Data {
+		output.Data[o] = input.Data[src]
+		for d := dims - 1; d >= 0; d-- {
+			index[d]++
+			src += strides[d]
+			if index[d] < outShape[d] {
+				break
+			}
+			src -= strides[d] * outShape[d]
+			index[d] = 0
+		}
+	}
+	return output
+}
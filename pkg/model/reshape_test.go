@@ -0,0 +1,140 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTranspose(t *testing.T) {
+	// [2, 3, 4] with values equal to their flat index
+	input := NewTensor([]int{2, 3, 4})
+	for i := range input.Data {
+		input.Data[i] = float32(i)
+	}
+
+	l := NewTransposeLayer([]int{2, 0, 1})
+	out := l.Forward(input)
+	if !reflect.DeepEqual(out.Shape, []int{4, 2, 3}) {
+		t.Fatalf("Expected shape [4 2 3], got %v", out.Shape)
+	}
+	for a := 0; a < 2; a++ {
+		for b := 0; b < 3; b++ {
+			for c := 0; c < 4; c++ {
+				if got, want := out.Get([]int{c, a, b}), input.Get([]int{a, b, c}); got != want {
+					t.Fatalf("Output [%d %d %d]: expected %f, got %f", c, a, b, want, got)
+				}
+			}
+		}
+	}
+
+	// The gradient is transposed back
+	grad, _, _ := l.Backward(input, out)
+	if !reflect.DeepEqual(grad.Shape, input.Shape) || !reflect.DeepEqual(grad.Data, input.Data) {
+		t.Error("Expected Backward to invert the permutation")
+	}
+}
+
+func TestReshapeTarget(t *testing.T) {
+	tests := []struct {
+		shape    []int
+		expected []int
+	}{
+		{[]int{40, -1}, []int{40, 61}},
+		{[]int{-1}, []int{2440}},
+		{[]int{1, 40, 61}, []int{1, 40, 61}},
+		{[]int{30, -1}, nil},
+		{[]int{-1, -1}, nil},
+		{[]int{0, 2440}, nil},
+		{nil, nil},
+	}
+	for _, tc := range tests {
+		got, err := reshapeTarget(tc.shape, []int{1, 40, 61})
+		if tc.expected == nil {
+			if err == nil {
+				t.Errorf("Shape %v: expected error, got %v", tc.shape, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Shape %v: expected %v, got %v (%v)", tc.shape, tc.expected, got, err)
+		}
+	}
+}
+
+func TestTemporalConvModel(t *testing.T) {
+	configs := []LayerConfig{
+		{Type: "transpose", Perm: []int{0, 2, 1}},
+		{Type: "reshape", Shape: []int{40, -1}},
+		{Type: "conv1d", Filters: 8, KernelSize: 3, Padding: 2, Dilation: 2},
+		{Type: "batchnorm"},
+		{Type: "relu"},
+		{Type: "maxpool1d", KernelSize: 2},
+		{Type: "conv1d", Filters: 4, KernelSize: 3, Stride: 2},
+		{Type: "avgpool1d", KernelSize: 3, Stride: 1},
+		{Type: "dense", Units: 1},
+		{Type: "sigmoid"},
+	}
+	m, err := BuildModelFromConfig(configs, []int{1, 61, 40})
+	if err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(6))
+	input := randomTensor([]int{1, 61, 40}, rng)
+
+	// [40, 61] -> conv1d [8, 61] -> maxpool1d [8, 30] -> conv1d [4, 14] -> avgpool1d [4, 12]
+	x := input
+	for _, l := range m.Layers[:8] {
+		x = l.Forward(x)
+	}
+	if !reflect.DeepEqual(x.Shape, []int{4, 12}) {
+		t.Fatalf("Expected [4 12] before dense, got %v", x.Shape)
+	}
+
+	bn := m.Layers[3].(*BatchNormLayer)
+	for c := range bn.RunningMean {
+		bn.RunningMean[c] = 0.05 * float32(c)
+		bn.RunningVar[c] = 1 + 0.1*float32(c)
+	}
+	expected := m.Forward(input).Data[0]
+
+	tmpFile := "test_model_tcnn.bin"
+	if err := SaveModel(tmpFile, m); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+	defer os.Remove(tmpFile)
+	loaded, err := LoadModel(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadModel failed: %v", err)
+	}
+	for i, l := range loaded.GetLayers() {
+		if !reflect.DeepEqual(l, m.Layers[i]) {
+			t.Errorf("Layer %d (%s) differs after reload", i, l.Type())
+		}
+	}
+	if got := loaded.Forward(input).Data[0]; got != expected {
+		t.Errorf("Expected %f after reload, got %f", expected, got)
+	}
+
+	folded, n := FoldBatchNorm(m)
+	if n != 1 {
+		t.Errorf("Expected 1 folded layer, got %d", n)
+	}
+	if got := folded.Forward(input).Data[0]; math.Abs(float64(got-expected)) > 1e-5 {
+		t.Errorf("Expected %f from folded model, got %f", expected, got)
+	}
+
+	for _, bad := range [][]LayerConfig{
+		{{Type: "conv1d", Filters: 2, KernelSize: 3}},                                            // 3D input
+		{{Type: "reshape", Shape: []int{40, -1}}, {Type: "conv1d", Filters: 2, KernelSize: 100}}, // too long
+		{{Type: "transpose", Perm: []int{0, 1}}},                                                 // wrong rank
+		{{Type: "reshape", Shape: []int{40, -1}}, {Type: "maxpool1d", KernelSize: 62}},
+	} {
+		if _, err := BuildModelFromConfig(bad, []int{1, 61, 40}); err == nil {
+			t.Errorf("Expected error for %+v", bad)
+		}
+	}
+}
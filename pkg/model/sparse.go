@@ -0,0 +1,279 @@
+package model
+
+// SparseMatrix is a [Rows, Cols] matrix in compressed sparse row (CSR) format:
+// the non-zero values of row i are Values[RowPtr[i]:RowPtr[i+1]], in the
+// columns ColIdx[RowPtr[i]:RowPtr[i+1]].
+type SparseMatrix struct {
+	Rows, Cols int
+	RowPtr     []int32
+	ColIdx     []int32
+	Values     []float32
+}
+
+// NewSparseMatrix compresses a [rows, cols] tensor, dropping its zeros.
+func NewSparseMatrix(t *Tensor) *SparseMatrix {
+	rows, cols := t.Shape[0], len(t.Data)/t.Shape[0]
+	s := &SparseMatrix{Rows: rows, Cols: cols, RowPtr: make([]int32, rows+1)}
+	for i := 0; i < rows; i++ {
+		for j, v := range t.Data[i*cols : (i+1)*cols] {
+			if v != 0 {
+				s.ColIdx = append(s.ColIdx, int32(j))
+				s.Values = append(s.Values, v)
+			}
+		}
+		s.RowPtr[i+1] = int32(len(s.Values))
+	}
+	return s
+}
+
+// Dense returns the matrix as a [Rows, Cols] tensor.
+func (s *SparseMatrix) Dense() *Tensor {
+	t := NewTensor([]int{s.Rows, s.Cols})
+	for i := 0; i < s.Rows; i++ {
+		for k := s.RowPtr[i]; k < s.RowPtr[i+1]; k++ {
+			t.Data[i*s.Cols+int(s.ColIdx[k])] = s.Values[k]
+		}
+	}
+	return t
+}
+
+// Density returns the fraction of non-zero entries.
+func (s *SparseMatrix) Density() float32 {
+	return float32(len(s.Values)) / float32(s.Rows*s.Cols)
+}
+
+// mulRows sets y[i-from] to row i of the matrix times x plus bias[i] (if bias is
+// not nil), for the rows from <= i < from+len(y). Only the non-zero weights are
+// visited.
+func (s *SparseMatrix) mulRows(from int, x, bias, y []float32) {
+	for r := range y {
+		i := from + r
+		var sum float32
+		if bias != nil {
+			sum = bias[i]
+		}
+		for k := s.RowPtr[i]; k < s.RowPtr[i+1]; k++ {
+			sum += s.Values[k] * x[s.ColIdx[k]]
+		}
+		y[r] = sum
+	}
+}
+
+// Sparsity returns the fraction of zero weights of a layer, or 0 for layers
+// without weights.
+func Sparsity(l Layer) float32 {
+	switch s := l.(type) {
+	case *SparseDenseLayer:
+		return 1 - s.Weights.Density()
+	case *SparseGRULayer:
+		nonZero := len(s.W.Values) + len(s.U.Values)
+		return 1 - float32(nonZero)/float32(s.W.Rows*s.W.Cols+s.U.Rows*s.U.Cols)
+	}
+	w, _ := l.Params()
+	if w == nil || len(w.Data) == 0 {
+		return 0
+	}
+	zeros := 0
+	for _, v := range w.Data {
+		if v == 0 {
+			zeros++
+		}
+	}
+	return float32(zeros) / float32(len(w.Data))
+}
+
+// Sparsify returns a copy of the model in which every dense and gru layer with
+// at least minSparsity zero weights (e.g. after pruning) is replaced by its
+// sparse version, which stores only the non-zero weights and skips the zeros in
+// its matrix-vector products. Other layers are shared with the original model.
+// It also returns the number of replaced layers.
+func Sparsify(m Model, minSparsity float32) (Model, int) {
+	layers := m.GetLayers()
+	out := make([]Layer, len(layers))
+	replaced := 0
+	for i, l := range layers {
+		out[i] = l
+		if Sparsity(l) < minSparsity {
+			continue
+		}
+		switch f := l.(type) {
+		case *DenseLayer:
+			out[i] = NewSparseDenseLayer(f)
+			replaced++
+		case *GRULayer:
+			out[i] = NewSparseGRULayer(f)
+			replaced++
+		}
+	}
+	return WithLayers(m, out), replaced
+}
+
+// SparseDenseLayer is an inference-only DenseLayer with sparse weights.
+type SparseDenseLayer struct {
+	Weights *SparseMatrix // [outputs, inputs]
+	Bias    []float32
+}
+
+// NewSparseDenseLayer compresses the weights of a dense layer.
+func NewSparseDenseLayer(l *DenseLayer) *SparseDenseLayer {
+	return &SparseDenseLayer{Weights: NewSparseMatrix(l.Weights), Bias: l.Bias}
+}
+
+// Dense returns the layer with dense weights.
+func (l *SparseDenseLayer) Dense() *DenseLayer {
+	return NewDenseLayer(l.Weights.Dense(), l.Bias)
+}
+
+func (l *SparseDenseLayer) Forward(input *Tensor) *Tensor {
+	if len(input.Data) != l.Weights.Cols {
+		panic("Sparse dense layer: input size mismatch")
+	}
+	output := NewTensor([]int{l.Weights.Rows})
+	l.Weights.mulRows(0, input.Data, l.Bias, output.Data)
+	return output
+}
+
+func (l *SparseDenseLayer) ForwardStateful(input *Tensor) *Tensor {
+	return l.Forward(input)
+}
+
+func (l *SparseDenseLayer) ResetState() {}
+
+func (l *SparseDenseLayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	notTrainable(l)
+	return nil, nil, nil
+}
+
+func (l *SparseDenseLayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (l *SparseDenseLayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (l *SparseDenseLayer) Type() string {
+	return "dense_sparse"
+}
+
+// SparseGRULayer is an inference-only GRULayer with sparse weights. The weights
+// of the z, r and candidate gates are stacked along the output axis.
+type SparseGRULayer struct {
+	W    *SparseMatrix // [3*hiddenSize, inputSize]
+	U    *SparseMatrix // [3*hiddenSize, hiddenSize]
+	Bias []float32     // [3*hiddenSize]
+
+	InputSize       int
+	HiddenSize      int
+	ReturnSequences bool
+
+	hiddenState []float32 // Stateful inference
+}
+
+// NewSparseGRULayer compresses the weights of a GRU.
+func NewSparseGRULayer(g *GRULayer) *SparseGRULayer {
+	var bias []float32
+	for _, b := range [][]float32{g.Bz, g.Br, g.Bh} {
+		bias = append(bias, b...)
+	}
+	return &SparseGRULayer{
+		W:               NewSparseMatrix(stackRows([]*Tensor{g.Wz, g.Wr, g.Wh})),
+		U:               NewSparseMatrix(stackRows([]*Tensor{g.Uz, g.Ur, g.Uh})),
+		Bias:            bias,
+		InputSize:       g.InputSize,
+		HiddenSize:      g.HiddenSize,
+		ReturnSequences: g.ReturnSequences,
+	}
+}
+
+// GRU returns the layer with dense weights.
+func (g *SparseGRULayer) GRU() *GRULayer {
+	n := g.HiddenSize
+	gru := NewGRULayer(g.InputSize, n)
+	gru.ReturnSequences = g.ReturnSequences
+	w, u := g.W.Dense(), g.U.Dense()
+	for k, t := range []*Tensor{gru.Wz, gru.Wr, gru.Wh} {
+		copy(t.Data, w.Data[k*n*g.InputSize:])
+	}
+	for k, t := range []*Tensor{gru.Uz, gru.Ur, gru.Uh} {
+		copy(t.Data, u.Data[k*n*n:])
+	}
+	for k, b := range [][]float32{gru.Bz, gru.Br, gru.Bh} {
+		copy(b, g.Bias[k*n:])
+	}
+	return gru
+}
+
+func (g *SparseGRULayer) Forward(input *Tensor) *Tensor {
+	out, _ := g.forward(input, make([]float32, g.HiddenSize))
+	return out
+}
+
+func (g *SparseGRULayer) ForwardStateful(input *Tensor) *Tensor {
+	if g.hiddenState == nil {
+		g.hiddenState = make([]float32, g.HiddenSize)
+	}
+	out, h := g.forward(input, g.hiddenState)
+	g.hiddenState = h
+	return out
+}
+
+func (g *SparseGRULayer) ResetState() {
+	g.hiddenState = nil
+}
+
+// forward runs the GRU from state h and returns the output and the final state.
+func (g *SparseGRULayer) forward(input *Tensor, h []float32) (*Tensor, []float32) {
+	n := g.HiddenSize
+	seq := sequence(input)
+	seqLen := seq.Shape[0]
+	var out *Tensor
+	if g.ReturnSequences {
+		out = NewTensor([]int{seqLen, n})
+	}
+
+	x := make([]float32, 3*n) // W*x + b of all gates
+	zr := make([]float32, 2*n)
+	cand := make([]float32, n)
+	rh := make([]float32, n)
+	for t := 0; t < seqLen; t++ {
+		g.W.mulRows(0, seq.Data[t*g.InputSize:(t+1)*g.InputSize], g.Bias, x)
+
+		// Update and reset gates: sigmoid(W*x + U*h + b)
+		g.U.mulRows(0, h, nil, zr)
+		for i := range zr {
+			zr[i] = sigmoid32(zr[i] + x[i])
+		}
+		// Candidate: tanh(Wh*x + Uh*(r*h) + bh)
+		for i := range rh {
+			rh[i] = zr[n+i] * h[i]
+		}
+		g.U.mulRows(2*n, rh, nil, cand)
+		newH := make([]float32, n)
+		for i := range newH {
+			z := zr[i]
+			newH[i] = (1-z)*h[i] + z*tanh32(cand[i]+x[2*n+i])
+		}
+		h = newH
+		if out != nil {
+			copy(out.Data[t*n:], h)
+		}
+	}
+	if out == nil {
+		out = &Tensor{Data: h, Shape: []int{n}}
+	}
+	return out, h
+}
+
+func (g *SparseGRULayer) Backward(input, gradOutput *Tensor) (*Tensor, *Tensor, []float32) {
+	notTrainable(g)
+	return nil, nil, nil
+}
+
+func (g *SparseGRULayer) Params() (*Tensor, []float32) {
+	return nil, nil
+}
+
+func (g *SparseGRULayer) SetParams(weights *Tensor, bias []float32) {}
+
+func (g *SparseGRULayer) Type() string {
+	return "gru_sparse"
+}
@@ -0,0 +1,175 @@
+package model
+
+import (
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// zeroWeights sets a random fraction of the weights of l to zero.
+func zeroWeights(l Layer, fraction float32, rng *rand.Rand) {
+	weights, bias := l.Params()
+	for i := range weights.Data {
+		if rng.Float32() < fraction {
+			weights.Data[i] = 0
+		}
+	}
+	l.SetParams(weights, bias)
+}
+
+func TestSparseMatrix(t *testing.T) {
+	w := &Tensor{Data: []float32{0, 2, 0, 0, 0, 0, 0, 0, -1, 3, 0, 4}, Shape: []int{3, 4}}
+	s := NewSparseMatrix(w)
+	if !reflect.DeepEqual(s.RowPtr, []int32{0, 1, 1, 4}) || !reflect.DeepEqual(s.ColIdx, []int32{1, 0, 1, 3}) {
+		t.Errorf("Unexpected CSR layout: %v %v", s.RowPtr, s.ColIdx)
+	}
+	if s.Density() != float32(4)/12 {
+		t.Errorf("Expected density 1/3, got %f", s.Density())
+	}
+	if !reflect.DeepEqual(s.Dense(), w) {
+		t.Errorf("Expected the dense matrix back, got %v", s.Dense().Data)
+	}
+
+	y := make([]float32, 2)
+	s.mulRows(1, []float32{1, 2, 3, 4}, []float32{10, 20, 30}, y)
+	if !reflect.DeepEqual(y, []float32{20, 51}) {
+		t.Errorf("Expected [20 51], got %v", y)
+	}
+}
+
+func TestSparseLayers(t *testing.T) {
+	rng := rand.New(rand.NewSource(21))
+	ResetRand(21)
+
+	t.Run("Dense", func(t *testing.T) {
+		l := NewDenseLayer(randomTensor([]int{4, 30}, rng), []float32{0.5, -1, 0, 2})
+		zeroWeights(l, 0.7, rng)
+		s := NewSparseDenseLayer(l)
+		input := randomTensor([]int{30}, rng)
+		assertClose(t, "dense", s.Forward(input).Data, l.Forward(input).Data)
+		if !reflect.DeepEqual(s.Dense().Weights, l.Weights) {
+			t.Error("Expected the dense weights back")
+		}
+	})
+
+	for _, seq := range []bool{false, true} {
+		name := "gru"
+		if seq {
+			name += " Sequences"
+		}
+		t.Run(name, func(t *testing.T) {
+			gru := NewGRULayer(6, 5)
+			gru.ReturnSequences = seq
+			gru.Bz[1], gru.Bh[2] = 0.5, -0.3
+			zeroWeights(gru, 0.7, rng)
+			s := NewSparseGRULayer(gru)
+			input := randomTensor([]int{2, 10, 3}, rng)
+			whole := s.Forward(input)
+			assertClose(t, "gru", whole.Data, gru.Forward(input).Data)
+			if !reflect.DeepEqual(whole.Shape, gru.Forward(input).Shape) {
+				t.Errorf("Expected shape %v, got %v", gru.Forward(input).Shape, whole.Shape)
+			}
+			back, _ := s.GRU().Params()
+			orig, _ := gru.Params()
+			if !reflect.DeepEqual(back, orig) {
+				t.Error("Expected the dense weights back")
+			}
+
+			// Streaming in two chunks carries the state over
+			first := s.ForwardStateful(sliceFrames(input, 0, 4))
+			second := s.ForwardStateful(sliceFrames(input, 4, 10))
+			if seq {
+				assertClose(t, "gru stateful", append(first.Data, second.Data...), whole.Data)
+			} else {
+				assertClose(t, "gru stateful", second.Data, whole.Data)
+			}
+			s.ResetState()
+			assertClose(t, "gru reset", s.ForwardStateful(input).Data, whole.Data)
+		})
+	}
+}
+
+func TestSparsify(t *testing.T) {
+	rng := rand.New(rand.NewSource(22))
+	ResetRand(22)
+	m, err := BuildModelFromConfig(streamingConfig("gru"), []int{1, 20, 6})
+	if err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+	// Prune the first gru and the dense layer, leave the second gru dense
+	zeroWeights(m.Layers[5], 0.8, rng)
+	zeroWeights(m.Layers[7], 0.8, rng)
+
+	s, n := Sparsify(m, 0.5)
+	if n != 2 {
+		t.Fatalf("Expected 2 sparse layers, got %d", n)
+	}
+	layers := s.GetLayers()
+	if layers[5].Type() != "gru_sparse" || layers[6].Type() != "gru" || layers[7].Type() != "dense_sparse" {
+		t.Errorf("Unexpected layer types: %s, %s, %s", layers[5].Type(), layers[6].Type(), layers[7].Type())
+	}
+	if sp := Sparsity(layers[7]); sp < 0.7 || sp != Sparsity(m.Layers[7]) {
+		t.Errorf("Expected the sparsity of the pruned layer, got %f", sp)
+	}
+	input := randomTensor([]int{1, 20, 6}, rng)
+	assertClose(t, "sparse model", s.Forward(input).Data, m.Forward(input).Data)
+
+	t.Run("Persistence", func(t *testing.T) {
+		tmpFile := "test_model_sparse.bin"
+		if err := SaveModel(tmpFile, s); err != nil {
+			t.Fatalf("SaveModel failed: %v", err)
+		}
+		defer os.Remove(tmpFile)
+		loaded, err := LoadModel(tmpFile)
+		if err != nil {
+			t.Fatalf("LoadModel failed: %v", err)
+		}
+		for i, l := range loaded.GetLayers() {
+			if l.Type() != layers[i].Type() {
+				t.Errorf("Layer %d: expected %s, got %s", i, layers[i].Type(), l.Type())
+			}
+		}
+		gru, _ := loaded.GetLayers()[5].(*SparseGRULayer).GRU().Params()
+		expected, _ := layers[5].(*SparseGRULayer).GRU().Params()
+		if !reflect.DeepEqual(gru, expected) {
+			t.Error("Expected the sparse gru weights to survive the round trip")
+		}
+		assertClose(t, "loaded", loaded.Forward(input).Data, s.Forward(input).Data)
+
+		// Only the non-zero weights of the pruned layers are stored
+		floatFile := "test_model_pruned.bin"
+		if err := SaveModel(floatFile, m); err != nil {
+			t.Fatalf("SaveModel failed: %v", err)
+		}
+		defer os.Remove(floatFile)
+		si, _ := os.Stat(tmpFile)
+		fi, _ := os.Stat(floatFile)
+		if si.Size() >= fi.Size() {
+			t.Errorf("Expected the sparse model to be smaller: %d vs %d bytes", si.Size(), fi.Size())
+		}
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		stream, err := NewFrameStream(s)
+		if err != nil {
+			t.Fatalf("NewFrameStream failed: %v", err)
+		}
+		scores := stream.Push(input)
+		last := len(scores) - 1
+		expected := s.Forward(sliceFrames(input, 0, stream.Context()+last*2)).Data[0]
+		assertClose(t, "streamed", scores[last:], []float32{expected})
+	})
+
+	t.Run("Quantize", func(t *testing.T) {
+		c := NewCalibrator(s)
+		c.Observe(input)
+		q, n, err := Quantize(s, c)
+		if err != nil {
+			t.Fatalf("Quantize failed: %v", err)
+		}
+		if n != 4 || q.GetLayers()[5].Type() != "gru_int8" || q.GetLayers()[7].Type() != "dense_int8" {
+			t.Errorf("Expected the sparse layers to be quantized, got %d layers", n)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package model
+
+import "fmt"
+
+// FrameStream runs a sequential model on a live stream of feature frames, one
+// hop at a time, instead of re-running it on a full window for every chunk.
+//
+// The model is split into a front of frame-aligned layers (convolutions,
+// max pooling, batch normalization, activations), a stack of GRU/LSTM layers
+// and a head. The front runs on only as many buffered input frames as the new
+// output frames need (its receptive field along time), the recurrent layers
+// advance their hidden state by one front frame at a time, and the head scores
+// every new hidden state. A score is produced for every input frame, or every
+// stride frames if the front downsamples time.
+//
+// Convolutions must not pad the time axis (see LayerConfig.Causal), so that
+// the streamed front computes exactly the frames it computes for a window.
+// Streaming a sequence from the start then scores each frame as Forward scores
+// the frames up to it.
+type FrameStream struct {
+	front     []Layer
+	recurrent []Layer
+	head      []Layer
+
+	context int // Input frames needed for one front output frame
+	stride  int // Input frames between consecutive front output frames
+
+	buffer *Tensor // [channels, frames, features] input not yet consumed
+}
+
+// NewFrameStream prepares m for frame-by-frame streaming. It returns an error
+// if m is not a sequential model of the form front, recurrent layers, head.
+func NewFrameStream(m Model) (*FrameStream, error) {
+	seq, ok := m.(*SequentialModel)
+	if !ok {
+		return nil, fmt.Errorf("frame streaming requires a sequential model")
+	}
+	if err := CheckStreamable(m); err != nil {
+		return nil, err
+	}
+
+	first := -1
+	for i, l := range seq.Layers {
+		if isRecurrent(l) {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return nil, fmt.Errorf("frame streaming requires a gru or lstm layer")
+	}
+
+	s := &FrameStream{context: 1, stride: 1}
+	for _, l := range seq.Layers[:first] {
+		kernel, stride, err := timeGeometry(l)
+		if err != nil {
+			return nil, err
+		}
+		s.context += (kernel - 1) * s.stride
+		s.stride *= stride
+		s.front = append(s.front, l)
+	}
+	i := first
+	for ; i < len(seq.Layers) && isRecurrent(seq.Layers[i]); i++ {
+		s.recurrent = append(s.recurrent, seq.Layers[i])
+	}
+	s.head = seq.Layers[i:]
+
+	for j, l := range s.recurrent {
+		if last := j == len(s.recurrent)-1; returnsSequences(l) == last {
+			if last {
+				return nil, fmt.Errorf("the last %s layer must output its final state for frame streaming", l.Type())
+			}
+			return nil, fmt.Errorf("stacked %s layers need return_sequences for frame streaming", l.Type())
+		}
+	}
+	for _, l := range s.head {
+		if isRecurrent(l) {
+			return nil, fmt.Errorf("frame streaming requires the recurrent layers to be consecutive")
+		}
+	}
+	return s, nil
+}
+
+// isRecurrent reports whether l is a GRU or LSTM layer (float32, int8 or sparse).
+func isRecurrent(l Layer) bool {
+	switch l.(type) {
+	case *GRULayer, *LSTMLayer, *QuantizedGRULayer, *QuantizedLSTMLayer, *SparseGRULayer:
+		return true
+	}
+	return false
+}
+
+// timeGeometry returns the kernel size and stride of a front layer along the
+// time axis of a [channels, frames, features] input.
+func timeGeometry(l Layer) (int, int, error) {
+	switch f := l.(type) {
+	case *Conv2DLayer:
+		if f.PaddingH != 0 {
+			return 0, 0, fmt.Errorf("conv2d pads the time axis and cannot stream frame by frame (train with causal convolutions)")
+		}
+		return f.Weights.Shape[2], f.StrideH, nil
+	case *QuantizedConv2DLayer:
+		if f.PaddingH != 0 {
+			return 0, 0, fmt.Errorf("conv2d_int8 pads the time axis and cannot stream frame by frame (train with causal convolutions)")
+		}
+		return f.Weights.Shape[2], f.StrideH, nil
+	case *DepthwiseConv2DLayer:
+		if f.PaddingH != 0 {
+			return 0, 0, fmt.Errorf("depthwise_conv2d pads the time axis and cannot stream frame by frame (train with causal convolutions)")
+		}
+		return f.Weights.Shape[2], f.StrideH, nil
+	case *MaxPool2DLayer:
+		return f.KernelSize, f.Stride, nil
+	case *PointwiseConv2DLayer, *BatchNormLayer, *ReLULayer, *SigmoidLayer, *DropoutLayer:
+		return 1, 1, nil
+	}
+	return 0, 0, fmt.Errorf("%s layers cannot run before the recurrent layers in frame streaming", l.Type())
+}
+
+// Context returns the number of input frames the front needs for its first
+// output frame, i.e. the delay before the first score.
+func (s *FrameStream) Context() int {
+	return s.context
+}
+
+// Push appends input frames of shape [channels, frames, features] and returns
+// the score of every front frame that became complete, in order.
+func (s *FrameStream) Push(frames *Tensor) []float32 {
+	if s.buffer == nil {
+		s.buffer = frames
+	} else {
+		s.buffer = concatFrames(s.buffer, frames)
+	}
+
+	available := s.buffer.Shape[1]
+	if available < s.context {
+		return nil
+	}
+	n := (available-s.context)/s.stride + 1
+
+	x := sliceFrames(s.buffer, 0, s.context+(n-1)*s.stride)
+	for _, l := range s.front {
+		x = l.Forward(x)
+	}
+
+	scores := make([]float32, n)
+	for t := 0; t < n; t++ {
+		h := sliceFrames(x, t, t+1)
+		for _, l := range s.recurrent {
+			h = l.ForwardStateful(h)
+		}
+		for _, l := range s.head {
+			h = l.Forward(h)
+		}
+		scores[t] = h.Data[0]
+	}
+
+	s.buffer = sliceFrames(s.buffer, n*s.stride, available)
+	return scores
+}
+
+// Reset clears the buffered frames and the recurrent state.
+func (s *FrameStream) Reset() {
+	s.buffer = nil
+	for _, l := range s.recurrent {
+		l.ResetState()
+	}
+}
+
+// sliceFrames returns frames [from, to) of a [channels, frames, features] tensor.
+func sliceFrames(x *Tensor, from, to int) *Tensor {
+	channels, numFrames, width := x.Shape[0], x.Shape[1], x.Shape[2]
+	out := NewTensor([]int{channels, to - from, width})
+	for c := 0; c < channels; c++ {
+		copy(out.Data[c*(to-from)*width:(c+1)*(to-from)*width], x.Data[(c*numFrames+from)*width:(c*numFrames+to)*width])
+	}
+	return out
+}
+
+// concatFrames appends the frames of b to those of a along the time axis.
+func concatFrames(a, b *Tensor) *Tensor {
+	channels, width := a.Shape[0], a.Shape[2]
+	na, nb := a.Shape[1], b.Shape[1]
+	out := NewTensor([]int{channels, na + nb, width})
+	for c := 0; c < channels; c++ {
+		dst := out.Data[c*(na+nb)*width:]
+		copy(dst, a.Data[c*na*width:(c+1)*na*width])
+		copy(dst[na*width:], b.Data[c*nb*width:(c+1)*nb*width])
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// streamingConfig is the default CRNN with causal convolutions.
+func streamingConfig(rnn string) []LayerConfig {
+	return CausalConfigs([]LayerConfig{
+		{Type: "conv2d", Filters: 3, KernelSize: 3, Padding: 1},
+		{Type: "batchnorm"},
+		{Type: "relu"},
+		{Type: "maxpool2d", KernelSize: 2, Stride: 2},
+		{Type: "depthwise_conv2d", KernelH: 2, KernelW: 3, Padding: 1},
+		{Type: rnn, Units: 4, ReturnSequences: true},
+		{Type: rnn, Units: 3},
+		{Type: "dense", Units: 1},
+		{Type: "sigmoid"},
+	})
+}
+
+func TestFrameStream(t *testing.T) {
+	rng := rand.New(rand.NewSource(16))
+	ResetRand(16)
+	for _, rnn := range []string{"gru", "lstm"} {
+		t.Run(rnn, func(t *testing.T) {
+			m, err := BuildModelFromConfig(streamingConfig(rnn), []int{1, 20, 6})
+			if err != nil {
+				t.Fatalf("Failed to build model: %v", err)
+			}
+			if conv := m.Layers[0].(*Conv2DLayer); conv.PaddingH != 0 || conv.PaddingW != 1 {
+				t.Errorf("Expected causal padding 0x1, got %dx%d", conv.PaddingH, conv.PaddingW)
+			}
+			bn := m.Layers[1].(*BatchNormLayer)
+			bn.RunningMean[1], bn.RunningVar[2] = 0.2, 1.5
+
+			s, err := NewFrameStream(m)
+			if err != nil {
+				t.Fatalf("NewFrameStream failed: %v", err)
+			}
+			// conv (3) -> maxpool (2, stride 2) -> depthwise (2): 3 + 1 + 1*2 frames
+			if s.Context() != 6 || s.stride != 2 {
+				t.Fatalf("Expected context 6 and stride 2, got %d and %d", s.Context(), s.stride)
+			}
+
+			// Push 30 frames in uneven chunks
+			input := randomTensor([]int{1, 30, 6}, rng)
+			var scores []float32
+			for from := 0; from < 30; {
+				to := from + 1 + rng.Intn(5)
+				if to > 30 {
+					to = 30
+				}
+				scores = append(scores, s.Push(sliceFrames(input, from, to))...)
+				from = to
+			}
+			if len(scores) != 13 {
+				t.Fatalf("Expected 13 scores, got %d", len(scores))
+			}
+
+			// Score j is the window score of the frames up to it
+			for j, score := range scores {
+				expected := m.Forward(sliceFrames(input, 0, s.Context()+j*2)).Data[0]
+				if math.Abs(float64(score-expected)) > 1e-5 {
+					t.Errorf("Score %d: expected %f, got %f", j, expected, score)
+				}
+			}
+
+			s.Reset()
+			if again := s.Push(input); math.Abs(float64(again[12]-scores[12])) > 1e-5 {
+				t.Errorf("Expected %f after Reset, got %f", scores[12], again[12])
+			}
+		})
+	}
+}
+
+func TestFrameStreamRejects(t *testing.T) {
+	for _, tc := range []struct {
+		configs []LayerConfig
+		errText string
+	}{
+		{[]LayerConfig{{Type: "conv2d", Filters: 2, KernelSize: 3, Padding: 1}, {Type: "gru", Units: 2}}, "causal"},
+		{[]LayerConfig{{Type: "conv2d", Filters: 2, KernelSize: 3}, {Type: "dense", Units: 1}}, "gru or lstm"},
+		{[]LayerConfig{{Type: "gru", Units: 2}, {Type: "gru", Units: 2}}, "return_sequences"},
+		{[]LayerConfig{{Type: "gru", Units: 2, ReturnSequences: true}, {Type: "dense", Units: 1}}, "final state"},
+		{[]LayerConfig{{Type: "transpose", Perm: []int{0, 2, 1}}, {Type: "gru", Units: 2}}, "transpose"},
+		{[]LayerConfig{{Type: "bigru", Units: 2}}, "streaming"},
+	} {
+		m, err := BuildModelFromConfig(tc.configs, []int{1, 8, 5})
+		if err != nil {
+			t.Fatalf("Failed to build %+v: %v", tc.configs, err)
+		}
+		if _, err := NewFrameStream(m); err == nil || !strings.Contains(err.Error(), tc.errText) {
+			t.Errorf("Expected error containing %q for %+v, got %v", tc.errText, tc.configs, err)
+		}
+	}
+
+	g, err := BuildModel(residualConfig(), []int{1, 4, 5})
+	if err != nil {
+		t.Fatalf("Failed to build graph: %v", err)
+	}
+	if _, err := NewFrameStream(g); err == nil {
+		t.Error("Expected error for a graph model")
+	}
+}
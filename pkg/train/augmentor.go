@@ -1,6 +1,7 @@
 package train
 
 import (
+	"fmt"
 	"math/rand"
 	"time"
 
@@ -13,6 +14,7 @@ type AugmentorConfig struct {
 	MaxNoiseRatio float32 `mapstructure:"max_noise_ratio"`
 	MaxShiftMs    int     `mapstructure:"max_shift_ms"`
 	MaxGainScale  float32 `mapstructure:"max_gain_scale"`
+	SampleRate    int     `mapstructure:"sample_rate"` // Sample rate of the audio in Hz, needed by MaxShiftMs
 }
 
 // Augmentor handles the on-the-fly augmentation of audio samples.
@@ -24,6 +26,9 @@ type Augmentor struct {
 
 // NewAugmentor creates a new Augmentor with the provided config and noise samples.
 func NewAugmentor(config AugmentorConfig, noisePool []Sample) *Augmentor {
+	if config.MaxShiftMs > 0 && config.SampleRate <= 0 {
+		panic(fmt.Sprintf("Augmentor: a time shift of %d ms needs the sample rate, got %d", config.MaxShiftMs, config.SampleRate))
+	}
 	return &Augmentor{
 		config:    config,
 		noisePool: noisePool,
@@ -33,8 +38,24 @@ func NewAugmentor(config AugmentorConfig, noisePool []Sample) *Augmentor {
 
 // Augment applies a sequence of random transformations to the provided samples.
 func (a *Augmentor) Augment(samples []float32) []float32 {
-	if a.rng.Float32() > a.config.AugmentProb {
-		return samples
+	out, _ := a.Apply(samples)
+	return out
+}
+
+// Apply is like Augment but also reports whether the samples were augmented.
+// Unaugmented samples are returned as is.
+func (a *Augmentor) Apply(samples []float32) ([]float32, bool) {
+	out, _, augmented := a.apply(samples, len(samples), a.rng)
+	return out, augmented
+}
+
+// apply augments samples using the given random source, so concurrent callers
+// can each use their own. Only the first actualLen samples hold audio, the rest
+// is zero padding: the padding stays silent and apply returns the length of the
+// audio after the time shift, clamped to the buffer.
+func (a *Augmentor) apply(samples []float32, actualLen int, rng *rand.Rand) ([]float32, int, bool) {
+	if rng.Float32() > a.config.AugmentProb {
+		return samples, actualLen, false
 	}
 
 	out := make([]float32, len(samples))
@@ -42,32 +63,46 @@ func (a *Augmentor) Augment(samples []float32) []float32 {
 
 	// 1. Time Shifting
 	if a.config.MaxShiftMs > 0 {
-		maxShiftSamples := (a.config.MaxShiftMs * 16000) / 1000
+		maxShiftSamples := (a.config.MaxShiftMs * a.config.SampleRate) / 1000
 		if maxShiftSamples > 0 {
-			offset := a.rng.Intn(maxShiftSamples*2) - maxShiftSamples
+			offset := rng.Intn(maxShiftSamples*2) - maxShiftSamples
 			out = audio.Shift(out, offset)
+			if actualLen < len(out) {
+				// A delayed clip extends into the padding; the start of an
+				// advanced one would wrap into it and is dropped instead
+				actualLen = min(max(actualLen+offset, 1), len(out))
+				clear(out[actualLen:])
+			}
 		}
 	}
 
 	// 2. Volume Scaling
 	if a.config.MaxGainScale > 0 {
-		gain := 1.0 + (a.rng.Float32()*2-1)*a.config.MaxGainScale
+		gain := 1.0 + (rng.Float32()*2-1)*a.config.MaxGainScale
 		out = audio.Scale(out, gain)
 	}
 
 	// 3. Noise Mixing
 	if a.config.MaxNoiseRatio > 0 && len(a.noisePool) > 0 {
-		noiseSample := a.noisePool[a.rng.Intn(len(a.noisePool))].Audio
-		ratio := a.rng.Float32() * a.config.MaxNoiseRatio
+		noiseSample := a.noisePool[rng.Intn(len(a.noisePool))].Audio
+		ratio := rng.Float32() * a.config.MaxNoiseRatio
 		
 		// Pick a random segment from the noise sample if it's longer
 		start := 0
-		if len(noiseSample) > len(out) {
-			start = a.rng.Intn(len(noiseSample) - len(out))
+		if len(noiseSample) > actualLen {
+			start = rng.Intn(len(noiseSample) - actualLen)
 		}
 		
-		out = audio.MixNoise(out, noiseSample[start:], ratio)
+		mixed := audio.MixNoise(out[:actualLen], noiseSample[start:], ratio)
+		if actualLen < len(out) {
+			// Keep the padded length with silent padding
+			copy(out, mixed)
+			clear(out[len(mixed):actualLen])
+		} else {
+			out = mixed
+		}
+		actualLen = len(mixed)
 	}
 
-	return out
+	return out, actualLen, true
 }
@@ -1,6 +1,7 @@
 package train
 
 import (
+	"math/rand"
 	"testing"
 )
 
@@ -9,6 +10,7 @@ func TestAugmentor(t *testing.T) {
 		AugmentProb:   1.0, // Always augment
 		MaxNoiseRatio: 0.5,
 		MaxShiftMs:    100,
+		SampleRate:    16000,
 		MaxGainScale:  0.2,
 	}
 	
@@ -39,3 +41,37 @@ func TestAugmentor(t *testing.T) {
 		t.Error("Expected augmented audio to be different from original")
 	}
 }
+
+func TestAugmentorShiftSampleRate(t *testing.T) {
+	// 100 ms at 8 kHz is 800 samples, not the 1600 of 16 kHz
+	aug := NewAugmentor(AugmentorConfig{AugmentProb: 1, MaxShiftMs: 100, SampleRate: 8000}, nil)
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float32, 4000)
+	samples[2000] = 1
+	largest := 0
+	for i := 0; i < 200; i++ {
+		out, _, _ := aug.apply(samples, len(samples), rng)
+		for j, v := range out {
+			if v != 0 {
+				largest = max(largest, abs(j-2000))
+			}
+		}
+	}
+	if largest > 800 || largest < 600 {
+		t.Errorf("Expected shifts of up to 800 samples, got %d", largest)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a time shift without a sample rate")
+		}
+	}()
+	NewAugmentor(AugmentorConfig{MaxShiftMs: 100}, nil)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -1,62 +1,83 @@
 package train
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/tomkiv/hotword/pkg/audio"
+	"github.com/tomkiv/hotword/pkg/model"
 )
 
 // Sample represents a single training sample.
 type Sample struct {
 	Audio     []float32
 	IsHotword bool
+	Label     int // Class index in Dataset.Classes (multi-class datasets only)
 	ActualLen int // Original length before padding (for masking in variable-length mode)
+
+	// Source is the SHA-256 of the WAV file the sample was loaded from and Offset
+	// the position of its first sample in that file. Together they identify the
+	// sample for feature caching; synthetic samples have no Source.
+	Source string
+	Offset int
 }
 
 // Dataset contains all loaded training samples.
 type Dataset struct {
 	Samples []Sample
+
+	// Classes holds the class names of a multi-class dataset, indexed by
+	// Sample.Label. It is nil for hotword/background datasets.
+	Classes []string
 }
 
 // extractWindows extracts overlapping windows from audio data.
 // If audio is shorter than windowLen, returns a single zero-padded window.
 // stride determines the overlap between consecutive windows.
 func extractWindows(audioData []float32, windowLen, stride int) [][]float32 {
-	if len(audioData) == 0 {
+	starts := windowStarts(len(audioData), windowLen, stride)
+	windows := make([][]float32, len(starts))
+	for i, start := range starts {
+		window := make([]float32, windowLen)
+		copy(window, audioData[start:])
+		windows[i] = window
+	}
+	return windows
+}
+
+// windowStarts returns the start offsets of the windows extractWindows produces
+// for audio of the given length.
+func windowStarts(length, windowLen, stride int) []int {
+	if length == 0 {
 		return nil
 	}
 
 	// If audio is shorter than window, return single padded window
-	if len(audioData) <= windowLen {
-		window := make([]float32, windowLen)
-		copy(window, audioData)
-		return [][]float32{window}
+	if length <= windowLen {
+		return []int{0}
 	}
 
-	var windows [][]float32
-	for start := 0; start+windowLen <= len(audioData); start += stride {
-		window := make([]float32, windowLen)
-		copy(window, audioData[start:start+windowLen])
-		windows = append(windows, window)
+	var starts []int
+	for start := 0; start+windowLen <= length; start += stride {
+		starts = append(starts, start)
 	}
 
 	// Handle remainder: if there's leftover audio, include a final window from the end
-	lastStart := len(audioData) - windowLen
-	if len(windows) > 0 {
-		prevStart := (len(windows) - 1) * stride
-		if lastStart > prevStart {
-			window := make([]float32, windowLen)
-			copy(window, audioData[lastStart:])
-			windows = append(windows, window)
-		}
+	lastStart := length - windowLen
+	if len(starts) > 0 && lastStart > starts[len(starts)-1] {
+		starts = append(starts, lastStart)
 	}
 
-	return windows
+	return starts
 }
 
 // findOnset detects the start of audio activity using energy-based onset detection.
@@ -153,23 +174,23 @@ func CropToOnset(audioData []float32, sampleRate, targetLen int, threshold float
 }
 
 // LoadDataset loads WAV files from the hotword and background directories.
-// It normalizes all samples to a fixed length (16000 samples / 1 second).
+// It normalizes all samples to a fixed length of 1 second at sampleRate.
 // It also generates synthetic noise samples to improve robustness.
-func LoadDataset(hotwordDir, backgroundDir string) (*Dataset, error) {
+func LoadDataset(hotwordDir, backgroundDir string, sampleRate int) (*Dataset, error) {
 	ds := &Dataset{}
-	const targetLength = 16000 // 1 second at 16kHz
+	targetLength := sampleRate // 1 second
 
 	pb := NewProgressBar(0, "Loading Dataset")
 
 	// Load hotwords
-	hotSamples, err := loadFromDir(hotwordDir, true, targetLength, pb)
+	hotSamples, err := loadFromDir(hotwordDir, true, targetLength, sampleRate, pb)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load hotwords: %w", err)
 	}
 	ds.Samples = append(ds.Samples, hotSamples...)
 
 	// Load background
-	bgSamples, err := loadFromDir(backgroundDir, false, targetLength, pb)
+	bgSamples, err := loadFromDir(backgroundDir, false, targetLength, sampleRate, pb)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load background: %w", err)
 	}
@@ -192,19 +213,19 @@ func LoadDataset(hotwordDir, backgroundDir string) (*Dataset, error) {
 // This extracts multiple 1-second windows from each audio file with the specified stride.
 // windowLen is the window size in samples (e.g., 16000 for 1 second at 16kHz).
 // stride is the step size between windows (e.g., 8000 for 50% overlap).
-func LoadDatasetWindowed(hotwordDir, backgroundDir string, windowLen, stride int) (*Dataset, error) {
+func LoadDatasetWindowed(hotwordDir, backgroundDir string, windowLen, stride, sampleRate int) (*Dataset, error) {
 	ds := &Dataset{}
 	pb := NewProgressBar(0, "Loading Dataset (Windowed)")
 
 	// Load hotwords with windowing
-	hotSamples, err := loadFromDirWindowed(hotwordDir, true, windowLen, stride, pb)
+	hotSamples, err := loadFromDirWindowed(hotwordDir, true, windowLen, stride, sampleRate, pb)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load hotwords: %w", err)
 	}
 	ds.Samples = append(ds.Samples, hotSamples...)
 
 	// Load background with windowing
-	bgSamples, err := loadFromDirWindowed(backgroundDir, false, windowLen, stride, pb)
+	bgSamples, err := loadFromDirWindowed(backgroundDir, false, windowLen, stride, sampleRate, pb)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load background: %w", err)
 	}
@@ -224,19 +245,19 @@ func LoadDatasetWindowed(hotwordDir, backgroundDir string, windowLen, stride int
 }
 
 // LoadDatasetWithPadding loads WAV files with variable length support.
-func LoadDatasetWithPadding(hotwordDir, backgroundDir string, maxLen int) (*Dataset, error) {
+func LoadDatasetWithPadding(hotwordDir, backgroundDir string, maxLen, sampleRate int) (*Dataset, error) {
 	ds := &Dataset{}
 	pb := NewProgressBar(0, "Loading Dataset (Padded)")
 
 	// Load hotwords with padding
-	hotSamples, err := loadFromDirPadded(hotwordDir, true, maxLen, pb)
+	hotSamples, err := loadFromDirPadded(hotwordDir, true, maxLen, sampleRate, pb)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load hotwords: %w", err)
 	}
 	ds.Samples = append(ds.Samples, hotSamples...)
 
 	// Load background with padding
-	bgSamples, err := loadFromDirPadded(backgroundDir, false, maxLen, pb)
+	bgSamples, err := loadFromDirPadded(backgroundDir, false, maxLen, sampleRate, pb)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load background: %w", err)
 	}
@@ -319,6 +340,73 @@ func LoadDatasetWithOnsetAndStride(hotwordDir, backgroundDir string, windowLen,
 	return ds, nil
 }
 
+// LoadClassDataset loads a multi-class dataset: the WAV files of class i are read
+// from dataDir/<classes[i]> and labeled i. Every file gives a zero-padded window
+// of windowLen samples or, with a stride, overlapping windows. Keyword classes
+// are marked as hotwords (so augmentation applies to them) and the "unknown" and
+// "silence" classes as background. Without a silence directory the silence class
+// is filled with synthetic noise samples.
+func LoadClassDataset(dataDir string, classes []string, windowLen, stride, sampleRate int) (*Dataset, error) {
+	if len(classes) < 2 {
+		return nil, fmt.Errorf("a multi-class dataset needs at least 2 classes, got %d", len(classes))
+	}
+	ds := &Dataset{Classes: classes}
+	pb := NewProgressBar(0, "Loading Dataset (Classes)")
+
+	synthesizeSilence := -1
+	largest := 0
+	for label, class := range classes {
+		dir := filepath.Join(dataDir, class)
+		if _, err := os.Stat(dir); os.IsNotExist(err) && class == model.ClassSilence {
+			synthesizeSilence = label
+			continue
+		}
+
+		isHotword := !model.IsBackgroundClass(class)
+		var samples []Sample
+		var err error
+		if stride > 0 {
+			samples, err = loadFromDirWindowed(dir, isHotword, windowLen, stride, sampleRate, pb)
+		} else {
+			samples, err = loadFromDirPadded(dir, isHotword, windowLen, sampleRate, pb)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load class %q: %w", class, err)
+		}
+		for i := range samples {
+			samples[i].Label = label
+		}
+		if len(samples) > largest {
+			largest = len(samples)
+		}
+		ds.Samples = append(ds.Samples, samples...)
+	}
+
+	pb.Finish()
+
+	if synthesizeSilence >= 0 {
+		numNoiseSamples := largest
+		if numNoiseSamples < 100 {
+			numNoiseSamples = 100
+		}
+		noiseSamples := generateNoiseSamples(numNoiseSamples, windowLen)
+		for i := range noiseSamples {
+			noiseSamples[i].Label = synthesizeSilence
+		}
+		ds.Samples = append(ds.Samples, noiseSamples...)
+	}
+
+	return ds, nil
+}
+
+// noiseRand generates the synthetic noise samples.
+var noiseRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// SeedNoise seeds the generator of synthetic noise samples, for reproducible datasets.
+func SeedNoise(seed int64) {
+	noiseRand = rand.New(rand.NewSource(seed))
+}
+
 // generateNoiseSamples creates synthetic noise samples for training robustness.
 // Includes: white noise, onset patterns, silence, and low-amplitude random signals.
 func generateNoiseSamples(count, length int) []Sample {
@@ -333,20 +421,20 @@ func generateNoiseSamples(count, length int) []Sample {
 		case 0:
 			// White noise (full amplitude)
 			for j := range audio {
-				audio[j] = (rand.Float32()*2 - 1) * 0.5
+				audio[j] = (noiseRand.Float32()*2 - 1) * 0.5
 			}
 		case 1:
 			// Low amplitude white noise
 			for j := range audio {
-				audio[j] = (rand.Float32()*2 - 1) * 0.1
+				audio[j] = (noiseRand.Float32()*2 - 1) * 0.1
 			}
 		case 2:
 			// Near silence with occasional spikes
 			for j := range audio {
-				if rand.Float32() < 0.01 {
-					audio[j] = (rand.Float32()*2 - 1) * 0.3
+				if noiseRand.Float32() < 0.01 {
+					audio[j] = (noiseRand.Float32()*2 - 1) * 0.3
 				} else {
-					audio[j] = (rand.Float32()*2 - 1) * 0.01
+					audio[j] = (noiseRand.Float32()*2 - 1) * 0.01
 				}
 			}
 		case 3:
@@ -356,23 +444,23 @@ func generateNoiseSamples(count, length int) []Sample {
 			// ONSET PATTERN: First half silence, second half noise
 			// This is critical - prevents false positives on audio onset
 			for j := length / 2; j < length; j++ {
-				audio[j] = (rand.Float32()*2 - 1) * 0.5
+				audio[j] = (noiseRand.Float32()*2 - 1) * 0.5
 			}
 		case 5:
 			// ONSET PATTERN: Gradual fade in of noise
 			for j := range audio {
 				fadeIn := float32(j) / float32(length)
-				audio[j] = (rand.Float32()*2 - 1) * 0.5 * fadeIn
+				audio[j] = (noiseRand.Float32()*2 - 1) * 0.5 * fadeIn
 			}
 		case 6:
 			// ONSET PATTERN: First 75% silence, last 25% noise
 			for j := length * 3 / 4; j < length; j++ {
-				audio[j] = (rand.Float32()*2 - 1) * 0.5
+				audio[j] = (noiseRand.Float32()*2 - 1) * 0.5
 			}
 		case 7:
 			// Very low amplitude continuous noise
 			for j := range audio {
-				audio[j] = (rand.Float32()*2 - 1) * 0.02
+				audio[j] = (noiseRand.Float32()*2 - 1) * 0.02
 			}
 		}
 
@@ -385,6 +473,29 @@ func generateNoiseSamples(count, length int) []Sample {
 	return samples
 }
 
+// errSampleRate reports a WAV file recorded at another rate than the feature
+// frontend expects. It fails the whole load instead of skipping the file.
+var errSampleRate = errors.New("sample rate mismatch")
+
+// readWAVFile loads a WAV file recorded at sampleRate and returns its samples with
+// the hex SHA-256 of the file content, which identifies the source of the samples
+// for feature caching.
+func readWAVFile(path string, sampleRate int) ([]float32, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	audioData, rate, err := audio.LoadWAV(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if rate != sampleRate {
+		return nil, "", fmt.Errorf("%w: %s is %d Hz, the feature frontend expects %d Hz", errSampleRate, path, rate, sampleRate)
+	}
+	sum := sha256.Sum256(data)
+	return audioData, hex.EncodeToString(sum[:]), nil
+}
+
 type fileProcessor func(path string) ([]Sample, error)
 
 func parallelLoadFromDir(dir string, proc fileProcessor, pb *ProgressBar) ([]Sample, error) {
@@ -420,66 +531,57 @@ func parallelLoadFromDir(dir string, proc fileProcessor, pb *ProgressBar) ([]Sam
 		numWorkers = numFiles
 	}
 
-	pathsChan := make(chan string, numFiles)
-	resultsChan := make(chan []Sample, numFiles)
+	// Results are collected per file so the samples keep the directory order
+	// regardless of which worker finishes first.
+	indexChan := make(chan int, numFiles)
+	results := make([][]Sample, numFiles)
+	var mu sync.Mutex
 	var wg sync.WaitGroup
+	var fatal error
 
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for path := range pathsChan {
+			for idx := range indexChan {
+				path := wavFiles[idx]
 				samples, err := proc(path)
-				if err != nil {
+				if err != nil && !errors.Is(err, errSampleRate) {
 					fmt.Printf("\nError loading %s: %v\n", path, err)
-					resultsChan <- nil
-					continue
 				}
-				resultsChan <- samples
+				mu.Lock()
+				if errors.Is(err, errSampleRate) && fatal == nil {
+					fatal = err
+				}
+				results[idx] = samples
+				if pb != nil {
+					pb.Update(pb.Current + 1)
+				}
+				mu.Unlock()
 			}
 		}()
 	}
 
-	for _, path := range wavFiles {
-		pathsChan <- path
+	for idx := range wavFiles {
+		indexChan <- idx
+	}
+	close(indexChan)
+	wg.Wait()
+	if fatal != nil {
+		return nil, fatal
 	}
-	close(pathsChan)
 
-	// Result collector
 	var allSamples []Sample
-	done := make(chan bool)
-	go func() {
-		count := 0
-		for samples := range resultsChan {
-			if samples != nil {
-				allSamples = append(allSamples, samples...)
-			}
-			count++
-			if pb != nil {
-				pb.Update(pb.Current + 1)
-			}
-			if count == numFiles {
-				break
-			}
-		}
-		done <- true
-	}()
-
-	wg.Wait()
-	<-done
+	for _, samples := range results {
+		allSamples = append(allSamples, samples...)
+	}
 
 	return allSamples, nil
 }
 
-func loadFromDir(dir string, isHotword bool, targetLength int, pb *ProgressBar) ([]Sample, error) {
+func loadFromDir(dir string, isHotword bool, targetLength, sampleRate int, pb *ProgressBar) ([]Sample, error) {
 	proc := func(path string) ([]Sample, error) {
-		file, err := os.Open(path)
-		if err != nil {
-			return nil, err
-		}
-		defer file.Close()
-
-		audioData, _, err := audio.LoadWAV(file)
+		audioData, source, err := readWAVFile(path, sampleRate)
 		if err != nil {
 			return nil, err
 		}
@@ -497,32 +599,29 @@ func loadFromDir(dir string, isHotword bool, targetLength int, pb *ProgressBar)
 			Audio:     normalized,
 			IsHotword: isHotword,
 			ActualLen: actualLen,
+			Source:    source,
 		}}, nil
 	}
 	return parallelLoadFromDir(dir, proc, pb)
 }
 
 // loadFromDirWindowed loads audio files and extracts multiple overlapping windows.
-func loadFromDirWindowed(dir string, isHotword bool, windowLen, stride int, pb *ProgressBar) ([]Sample, error) {
+func loadFromDirWindowed(dir string, isHotword bool, windowLen, stride, sampleRate int, pb *ProgressBar) ([]Sample, error) {
 	proc := func(path string) ([]Sample, error) {
-		file, err := os.Open(path)
+		audioData, source, err := readWAVFile(path, sampleRate)
 		if err != nil {
 			return nil, err
 		}
-		defer file.Close()
 
-		audioData, _, err := audio.LoadWAV(file)
-		if err != nil {
-			return nil, err
-		}
-
-		windows := extractWindows(audioData, windowLen, stride)
+		starts := windowStarts(len(audioData), windowLen, stride)
 		var samples []Sample
-		for _, window := range windows {
+		for i, window := range extractWindows(audioData, windowLen, stride) {
 			samples = append(samples, Sample{
 				Audio:     window,
 				IsHotword: isHotword,
 				ActualLen: windowLen,
+				Source:    source,
+				Offset:    starts[i],
 			})
 		}
 		return samples, nil
@@ -531,15 +630,9 @@ func loadFromDirWindowed(dir string, isHotword bool, windowLen, stride int, pb *
 }
 
 // loadFromDirPadded loads audio files with variable length support.
-func loadFromDirPadded(dir string, isHotword bool, maxLen int, pb *ProgressBar) ([]Sample, error) {
+func loadFromDirPadded(dir string, isHotword bool, maxLen, sampleRate int, pb *ProgressBar) ([]Sample, error) {
 	proc := func(path string) ([]Sample, error) {
-		file, err := os.Open(path)
-		if err != nil {
-			return nil, err
-		}
-		defer file.Close()
-
-		audioData, _, err := audio.LoadWAV(file)
+		audioData, source, err := readWAVFile(path, sampleRate)
 		if err != nil {
 			return nil, err
 		}
@@ -556,6 +649,7 @@ func loadFromDirPadded(dir string, isHotword bool, maxLen int, pb *ProgressBar)
 			Audio:     padded,
 			IsHotword: isHotword,
 			ActualLen: actualLen,
+			Source:    source,
 		}}, nil
 	}
 	return parallelLoadFromDir(dir, proc, pb)
@@ -564,23 +658,22 @@ func loadFromDirPadded(dir string, isHotword bool, maxLen int, pb *ProgressBar)
 // loadFromDirWithOnset loads audio files using onset detection.
 func loadFromDirWithOnset(dir string, isHotword bool, targetLen, sampleRate int, threshold float32, pb *ProgressBar) ([]Sample, error) {
 	proc := func(path string) ([]Sample, error) {
-		file, err := os.Open(path)
-		if err != nil {
-			return nil, err
-		}
-		defer file.Close()
-
-		audioData, _, err := audio.LoadWAV(file)
+		audioData, source, err := readWAVFile(path, sampleRate)
 		if err != nil {
 			return nil, err
 		}
 
-		cropped := CropToOnset(audioData, sampleRate, targetLen, threshold)
+		// Same window as CropToOnset, keeping the onset as the sample offset
+		onsetIdx := findOnset(audioData, sampleRate, threshold, sampleRate/20)
+		cropped := make([]float32, targetLen)
+		copy(cropped, audioData[onsetIdx:])
 
 		return []Sample{{
 			Audio:     cropped,
 			IsHotword: isHotword,
 			ActualLen: targetLen,
+			Source:    source,
+			Offset:    onsetIdx,
 		}}, nil
 	}
 	return parallelLoadFromDir(dir, proc, pb)
@@ -589,13 +682,7 @@ func loadFromDirWithOnset(dir string, isHotword bool, targetLen, sampleRate int,
 // loadFromDirWithOnsetAndStride combines onset detection with window extraction.
 func loadFromDirWithOnsetAndStride(dir string, isHotword bool, windowLen, stride, sampleRate int, threshold float32, pb *ProgressBar) ([]Sample, error) {
 	proc := func(path string) ([]Sample, error) {
-		file, err := os.Open(path)
-		if err != nil {
-			return nil, err
-		}
-		defer file.Close()
-
-		audioData, _, err := audio.LoadWAV(file)
+		audioData, source, err := readWAVFile(path, sampleRate)
 		if err != nil {
 			return nil, err
 		}
@@ -604,13 +691,15 @@ func loadFromDirWithOnsetAndStride(dir string, isHotword bool, windowLen, stride
 		onsetIdx := findOnset(audioData, sampleRate, threshold, leadTimeSamples)
 		audioFromOnset := audioData[onsetIdx:]
 
-		windows := extractWindows(audioFromOnset, windowLen, stride)
+		starts := windowStarts(len(audioFromOnset), windowLen, stride)
 		var samples []Sample
-		for _, window := range windows {
+		for i, window := range extractWindows(audioFromOnset, windowLen, stride) {
 			samples = append(samples, Sample{
 				Audio:     window,
 				IsHotword: isHotword,
 				ActualLen: windowLen,
+				Source:    source,
+				Offset:    onsetIdx + starts[i],
 			})
 		}
 		return samples, nil
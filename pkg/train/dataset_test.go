@@ -1,8 +1,13 @@
 package train
 
 import (
+	"encoding/binary"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -35,6 +40,31 @@ func createTestWAV(path string) error {
 	return nil
 }
 
+// createSizedTestWAV writes a 16kHz mono WAV file with numSamples silent samples.
+func createSizedTestWAV(path string, numSamples int) error {
+	return createRateTestWAV(path, numSamples, 16000)
+}
+
+// createRateTestWAV writes a mono WAV file at sampleRate with numSamples silent samples.
+func createRateTestWAV(path string, numSamples, sampleRate int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.Write([]byte("RIFF"))
+	binary.Write(f, binary.LittleEndian, uint32(36+numSamples*2))
+	f.Write([]byte("WAVEfmt "))
+	f.Write([]byte{16, 0, 0, 0, 1, 0, 1, 0})
+	binary.Write(f, binary.LittleEndian, []uint32{uint32(sampleRate), uint32(sampleRate * 2)})
+	f.Write([]byte{2, 0, 16, 0})
+	f.Write([]byte("data"))
+	binary.Write(f, binary.LittleEndian, uint32(numSamples*2))
+	_, err = f.Write(make([]byte, numSamples*2))
+	return err
+}
+
 func TestDataset(t *testing.T) {
 	// Create temporary directory structure
 	tmpDir, _ := os.MkdirTemp("", "hotword_test")
@@ -49,7 +79,7 @@ func TestDataset(t *testing.T) {
 	createTestWAV(filepath.Join(bgDir, "b1.wav"))
 
 	t.Run("Load Dataset", func(t *testing.T) {
-		ds, err := LoadDataset(hotwordDir, bgDir)
+		ds, err := LoadDataset(hotwordDir, bgDir, 16000)
 		if err != nil {
 			t.Fatalf("Failed to load dataset: %v", err)
 		}
@@ -70,4 +100,124 @@ func TestDataset(t *testing.T) {
 			t.Errorf("Expected 1 hotword sample, got %d", hotCount)
 		}
 	})
+	t.Run("Sources And Offsets", func(t *testing.T) {
+		dir, _ := os.MkdirTemp("", "hotword_sources")
+		defer os.RemoveAll(dir)
+		hDir := filepath.Join(dir, "hotword")
+		bDir := filepath.Join(dir, "background")
+		os.Mkdir(hDir, 0755)
+		os.Mkdir(bDir, 0755)
+		createSizedTestWAV(filepath.Join(hDir, "h1.wav"), 500)
+		createSizedTestWAV(filepath.Join(bDir, "b1.wav"), 500)
+
+		// Windows of 200 with stride 100 start at 0, 100, 200, 300
+		ds, err := LoadDatasetWindowed(hDir, bDir, 200, 100, 16000)
+		if err != nil {
+			t.Fatalf("Failed to load dataset: %v", err)
+		}
+
+		var source string
+		var offsets []int
+		for _, s := range ds.Samples {
+			if !s.IsHotword {
+				continue
+			}
+			if s.Source == "" || (source != "" && s.Source != source) {
+				t.Fatalf("Expected all windows to share a non-empty source, got %q", s.Source)
+			}
+			source = s.Source
+			offsets = append(offsets, s.Offset)
+		}
+		sort.Ints(offsets)
+		expected := []int{0, 100, 200, 300}
+		if !reflect.DeepEqual(offsets, expected) {
+			t.Errorf("Expected offsets %v, got %v", expected, offsets)
+		}
+
+		// Identical files have the same content hash; synthetic noise has none
+		for _, s := range ds.Samples {
+			if !s.IsHotword && s.Source != "" && s.Source != source {
+				t.Errorf("Expected identical files to share a source hash")
+			}
+		}
+		if ds.Samples[len(ds.Samples)-1].Source != "" {
+			t.Error("Expected synthetic samples to have no source")
+		}
+	})
+
+	t.Run("Classes", func(t *testing.T) {
+		dir, _ := os.MkdirTemp("", "hotword_classes")
+		defer os.RemoveAll(dir)
+		for class, files := range map[string]int{"yes": 2, "no": 1, "unknown": 3} {
+			os.Mkdir(filepath.Join(dir, class), 0755)
+			for i := 0; i < files; i++ {
+				createSizedTestWAV(filepath.Join(dir, class, fmt.Sprintf("%d.wav", i)), 300)
+			}
+		}
+
+		classes := []string{"yes", "no", "unknown", "silence"}
+		ds, err := LoadClassDataset(dir, classes, 400, 0, 16000)
+		if err != nil {
+			t.Fatalf("Failed to load dataset: %v", err)
+		}
+		if !reflect.DeepEqual(ds.Classes, classes) {
+			t.Errorf("Expected classes %v, got %v", classes, ds.Classes)
+		}
+
+		// The missing silence directory is filled with synthetic noise
+		counts := make([]int, len(classes))
+		for _, s := range ds.Samples {
+			counts[s.Label]++
+			if s.IsHotword != (s.Label < 2) {
+				t.Errorf("Class %s: unexpected IsHotword %v", classes[s.Label], s.IsHotword)
+			}
+			if len(s.Audio) != 400 {
+				t.Errorf("Expected 400 samples, got %d", len(s.Audio))
+			}
+		}
+		if !reflect.DeepEqual(counts, []int{2, 1, 3, 100}) {
+			t.Errorf("Expected class counts [2 1 3 100], got %v", counts)
+		}
+
+		if _, err := LoadClassDataset(dir, []string{"yes", "maybe"}, 400, 0, 16000); err == nil {
+			t.Error("Expected an error for a missing class directory")
+		}
+		if _, err := LoadClassDataset(dir, []string{"yes"}, 400, 0, 16000); err == nil {
+			t.Error("Expected an error for a single class")
+		}
+	})
+
+	t.Run("Sample Rate", func(t *testing.T) {
+		dir, _ := os.MkdirTemp("", "hotword_rate")
+		defer os.RemoveAll(dir)
+		hDir := filepath.Join(dir, "hotword")
+		bDir := filepath.Join(dir, "background")
+		os.Mkdir(hDir, 0755)
+		os.Mkdir(bDir, 0755)
+		createRateTestWAV(filepath.Join(hDir, "h1.wav"), 500, 8000)
+		createRateTestWAV(filepath.Join(bDir, "b1.wav"), 500, 8000)
+
+		// One second is as many samples as the rate
+		ds, err := LoadDataset(hDir, bDir, 8000)
+		if err != nil {
+			t.Fatalf("Failed to load dataset: %v", err)
+		}
+		for _, s := range ds.Samples {
+			if len(s.Audio) != 8000 {
+				t.Fatalf("Expected 8000 samples, got %d", len(s.Audio))
+			}
+		}
+
+		loaders := map[string]func() (*Dataset, error){
+			"LoadDataset":            func() (*Dataset, error) { return LoadDataset(hDir, bDir, 16000) },
+			"LoadDatasetWindowed":    func() (*Dataset, error) { return LoadDatasetWindowed(hDir, bDir, 200, 100, 16000) },
+			"LoadDatasetWithPadding": func() (*Dataset, error) { return LoadDatasetWithPadding(hDir, bDir, 1000, 16000) },
+			"LoadDatasetWithOnset":   func() (*Dataset, error) { return LoadDatasetWithOnset(hDir, bDir, 400, 16000, 0.1) },
+		}
+		for name, load := range loaders {
+			if _, err := load(); err == nil || !strings.Contains(err.Error(), "8000 Hz") {
+				t.Errorf("%s: expected a sample rate error, got %v", name, err)
+			}
+		}
+	})
 }
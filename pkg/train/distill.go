@@ -0,0 +1,134 @@
+package train
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+// DistillConfig defines knowledge distillation from a teacher model.
+type DistillConfig struct {
+	Alpha       float32 `mapstructure:"alpha"`       // Weight of the soft-target loss, 1-Alpha weighs the hard-label loss
+	Temperature float32 `mapstructure:"temperature"` // Softens the teacher and student outputs, 1 = unchanged
+}
+
+// Validate checks the distillation parameters.
+func (c DistillConfig) Validate() error {
+	if c.Alpha < 0 || c.Alpha > 1 {
+		return fmt.Errorf("distillation alpha must be in [0, 1], got %g", c.Alpha)
+	}
+	if c.Temperature <= 0 {
+		return fmt.Errorf("distillation temperature must be positive, got %g", c.Temperature)
+	}
+	return nil
+}
+
+// Teacher provides soft targets for knowledge distillation: the outputs of a
+// trained (usually larger) model on the audio of every training sample, after
+// waveform augmentation, softened by the temperature. The student trains on a
+// weighted mix of the hard-label loss and the cross-entropy against the soft
+// targets (Hinton et al., 2015).
+//
+// The teacher reads the audio through its own feature frontend, so it may use
+// other features than the student; SpecAugment only applies to the student. It
+// is safe for concurrent use: up to one caller per CPU core runs its own copy
+// of the model.
+type Teacher struct {
+	config  DistillConfig
+	extract func([]float32) *model.Tensor
+	models  chan model.Model // Idle copies of the teacher model
+}
+
+// NewTeacher creates a teacher running m on the features computed by extract.
+func NewTeacher(m model.Model, extract func([]float32) *model.Tensor, config DistillConfig) (*Teacher, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	// Layers keep the state of their last forward pass, so concurrent callers
+	// need separate copies. They are made up front because layer constructors
+	// draw from the shared model initialization source.
+	t := &Teacher{config: config, extract: extract, models: make(chan model.Model, runtime.NumCPU())}
+	m.SetTraining(false)
+	t.models <- m
+	for i := 1; i < cap(t.models); i++ {
+		c := cloneModel(m)
+		c.SetTraining(false)
+		t.models <- c
+	}
+	return t, nil
+}
+
+// Outputs returns the output probabilities of the teacher for a sample.
+func (t *Teacher) Outputs(audio []float32) []float32 {
+	input := t.extract(audio)
+	m := <-t.models
+	defer func() { t.models <- m }()
+	return m.Forward(input).Data
+}
+
+// SoftTargets returns the teacher outputs for a sample, softened by the temperature.
+func (t *Teacher) SoftTargets(audio []float32) []float32 {
+	return soften(t.Outputs(audio), t.config.Temperature)
+}
+
+// loss wraps the hard-label loss of a training step into the distillation loss
+// (1-Alpha)*hard + Alpha*T²*CE(student_T, soft). The T² factor keeps the
+// magnitude of the soft gradient, which shrinks by 1/T², comparable to the hard
+// one for any temperature.
+func (t *Teacher) loss(hard func(output []float32) (float32, []float32), soft []float32) func(output []float32) (float32, []float32) {
+	alpha, temp := t.config.Alpha, t.config.Temperature
+	return func(output []float32) (float32, []float32) {
+		if len(soft) != len(output) {
+			panic(fmt.Sprintf("Trainer: teacher output size %d does not match the model output size %d", len(soft), len(output)))
+		}
+		hardLoss, grad := hard(output)
+
+		student := soften(output, temp)
+		var softLoss float32
+		if len(output) == 1 {
+			softLoss = model.BCELoss(student, soft)
+		} else {
+			softLoss = model.CategoricalCELoss(student, soft)
+		}
+
+		// dL/dz of the softened cross-entropy is (student_T - soft)/T, as for
+		// the combined sigmoid/softmax gradient of the hard loss
+		for i := range grad {
+			grad[i] = (1-alpha)*grad[i] + alpha*temp*(student[i]-soft[i])
+		}
+		return (1-alpha)*hardLoss + alpha*temp*temp*softLoss, grad
+	}
+}
+
+// soften returns the output of a sigmoid (one output) or softmax (several
+// outputs) with its input divided by the temperature, computed from the output
+// probabilities.
+func soften(probs []float32, temp float32) []float32 {
+	if temp == 1 {
+		return append([]float32(nil), probs...)
+	}
+	clamp := func(p float32) float64 {
+		return math.Min(math.Max(float64(p), 1e-7), 1-1e-7)
+	}
+	out := make([]float32, len(probs))
+	if len(probs) == 1 {
+		p := clamp(probs[0])
+		logit := math.Log(p / (1 - p))
+		out[0] = float32(1 / (1 + math.Exp(-logit/float64(temp))))
+		return out
+	}
+
+	// log p equals the softmax input up to a constant, which softmax ignores
+	var sum float64
+	for i, p := range probs {
+		v := math.Exp(math.Log(clamp(p)) / float64(temp))
+		out[i] = float32(v)
+		sum += v
+	}
+	for i := range out {
+		out[i] = float32(float64(out[i]) / sum)
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+package train
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+func TestSoften(t *testing.T) {
+	if got := soften([]float32{0.9}, 2); math.Abs(float64(got[0]-0.75)) > 1e-6 {
+		t.Errorf("Expected sigmoid(logit(0.9)/2) = 0.75, got %f", got[0])
+	}
+
+	probs := []float32{0.7, 0.2, 0.1}
+	if got := soften(probs, 1); !floatsEqual(got, probs) {
+		t.Errorf("Expected a temperature of 1 to keep the probabilities, got %v", got)
+	}
+	// softmax(z/2) is proportional to sqrt(softmax(z))
+	got := soften(probs, 2)
+	var sum float64
+	for _, p := range probs {
+		sum += math.Sqrt(float64(p))
+	}
+	for i, p := range probs {
+		if expected := math.Sqrt(float64(p)) / sum; math.Abs(float64(got[i])-expected) > 1e-6 {
+			t.Errorf("Class %d: expected %f, got %f", i, expected, got[i])
+		}
+	}
+	if got[0] >= probs[0] || got[2] <= probs[2] {
+		t.Errorf("Expected a flatter distribution, got %v", got)
+	}
+}
+
+func TestDistillLoss(t *testing.T) {
+	teacher, err := NewTeacher(model.NewSequentialModel(model.NewSigmoidLayer()), nil, DistillConfig{Alpha: 0.3, Temperature: 3})
+	if err != nil {
+		t.Fatalf("NewTeacher failed: %v", err)
+	}
+
+	// The gradient with respect to the input z of the output activation
+	// matches the finite difference of the loss
+	check := func(name string, hard func([]float32) (float32, []float32), soft []float32, activation func(z []float32) []float32, z []float32) {
+		loss := teacher.loss(hard, soft)
+		_, grad := loss(activation(z))
+		for i := range z {
+			const h = 1e-3
+			plus := append([]float32(nil), z...)
+			minus := append([]float32(nil), z...)
+			plus[i] += h
+			minus[i] -= h
+			lp, _ := loss(activation(plus))
+			lm, _ := loss(activation(minus))
+			if numeric := (lp - lm) / (2 * h); math.Abs(float64(numeric-grad[i])) > 2e-3 {
+				t.Errorf("%s[%d]: expected gradient %f, got %f", name, i, numeric, grad[i])
+			}
+		}
+	}
+	sigmoid := func(z []float32) []float32 {
+		return []float32{float32(1 / (1 + math.Exp(-float64(z[0]))))}
+	}
+	softmax := func(z []float32) []float32 {
+		return model.Softmax(&model.Tensor{Data: z, Shape: []int{len(z)}}).Data
+	}
+	check("binary", binaryLoss(1), soften([]float32{0.8}, 3), sigmoid, []float32{-0.5})
+	check("class", classLoss(2), soften([]float32{0.1, 0.6, 0.3}, 3), softmax, []float32{0.2, -0.4, 1.1})
+
+	for _, c := range []DistillConfig{{Alpha: 1.5, Temperature: 1}, {Alpha: 0.5}} {
+		if _, err := NewTeacher(model.NewSequentialModel(model.NewSigmoidLayer()), nil, c); err == nil {
+			t.Errorf("Expected an error for %+v", c)
+		}
+	}
+}
+
+func TestTeacherConcurrent(t *testing.T) {
+	model.ResetRand(24)
+	m, err := model.BuildModelFromConfig([]model.LayerConfig{
+		{Type: "gru", Units: 4},
+		{Type: "dense", Units: 1},
+		{Type: "sigmoid"},
+	}, []int{1, 2, 4})
+	if err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+	teacher, err := NewTeacher(m, copyExtractor, DistillConfig{Alpha: 0.5, Temperature: 1})
+	if err != nil {
+		t.Fatalf("NewTeacher failed: %v", err)
+	}
+
+	ds := indexedDataset(16)
+	expected := make([]float32, len(ds.Samples))
+	for i, s := range ds.Samples {
+		expected[i] = m.Forward(copyExtractor(s.Audio)).Data[0]
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, s := range ds.Samples {
+				if got := teacher.Outputs(s.Audio)[0]; got != expected[i] {
+					t.Errorf("Sample %d: expected %f, got %f", i, expected[i], got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDistillTrainers(t *testing.T) {
+	newModel := func(w float32) *model.SequentialModel {
+		weights := model.NewTensor([]int{1, 8})
+		for i := range weights.Data {
+			weights.Data[i] = w * float32(i%3-1)
+		}
+		return model.NewSequentialModel(model.NewDenseLayer(weights, []float32{0}), model.NewSigmoidLayer())
+	}
+	teacherModel := newModel(0.3)
+	ds := indexedDataset(8)
+	distance := func(student model.Model) float64 {
+		var d float64
+		for _, s := range ds.Samples {
+			x := copyExtractor(s.Audio)
+			d += math.Abs(float64(student.Forward(x).Data[0] - teacherModel.Forward(x).Data[0]))
+		}
+		return d
+	}
+
+	trainers := map[string]func(m model.Model) AugmentorTrainer{
+		"Trainer":         func(m model.Model) AugmentorTrainer { return NewTrainer(m, 0.05) },
+		"ParallelTrainer": func(m model.Model) AugmentorTrainer { return NewParallelTrainer(m, 0.05, 2) },
+	}
+	for name, newTrainer := range trainers {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			extract := func(audio []float32) *model.Tensor {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				return copyExtractor(audio)
+			}
+			// Soft targets only: the student learns the teacher outputs
+			teacher, err := NewTeacher(teacherModel, extract, DistillConfig{Alpha: 1, Temperature: 1})
+			if err != nil {
+				t.Fatalf("NewTeacher failed: %v", err)
+			}
+
+			student := newModel(0)
+			before := distance(student)
+			tr := newTrainer(student)
+			tr.SetTeacher(teacher)
+			tr.SetAugmentor(NewAugmentor(AugmentorConfig{AugmentProb: 1, MaxGainScale: 0.01}, nil))
+			tr.SetPipelineConfig(PipelineConfig{Workers: 2, Seed: 1})
+			tr.Train(ds, 20, copyExtractor)
+
+			if after := distance(student); after >= before/2 {
+				t.Errorf("Expected the student to approach the teacher: distance %f before, %f after", before, after)
+			}
+			// The teacher runs on every sample of every epoch, augmented or not
+			if calls != 20*len(ds.Samples) {
+				t.Errorf("Expected %d teacher runs, got %d", 20*len(ds.Samples), calls)
+			}
+		})
+	}
+}
@@ -5,19 +5,25 @@ import (
 	"runtime"
 	"sync"
 
+	"github.com/tomkiv/hotword/pkg/features"
 	"github.com/tomkiv/hotword/pkg/model"
 )
 
 // ParallelTrainer manages training across multiple CPU cores by sharding the dataset.
 type ParallelTrainer struct {
-	masterModel *model.SequentialModel
+	masterModel model.Model
 	lr          float32
 	numThreads  int
 	augmentor   *Augmentor
+	specAugment *SpecAugmenter
+	cache       *features.Cache
+	pipeline    PipelineConfig
+	pruner      *Pruner
+	teacher     *Teacher
 }
 
 // NewParallelTrainer creates a new ParallelTrainer.
-func NewParallelTrainer(m *model.SequentialModel, lr float32, threads int) *ParallelTrainer {
+func NewParallelTrainer(m model.Model, lr float32, threads int) *ParallelTrainer {
 	if threads <= 0 {
 		threads = runtime.NumCPU()
 	}
@@ -33,7 +39,37 @@ func (p *ParallelTrainer) SetAugmentor(a *Augmentor) {
 	p.augmentor = a
 }
 
-// Train runs the sharded training loop.
+// SetSpecAugmenter sets the feature-domain augmenter for the trainer.
+func (p *ParallelTrainer) SetSpecAugmenter(s *SpecAugmenter) {
+	p.specAugment = s
+}
+
+// SetFeatureCache sets the cache used for the features of unaugmented samples.
+// The cache is shared by all workers.
+func (p *ParallelTrainer) SetFeatureCache(c *features.Cache) {
+	p.cache = c
+}
+
+// SetPipelineConfig sets the data pipeline options (workers, prefetch depth, seed).
+func (p *ParallelTrainer) SetPipelineConfig(c PipelineConfig) {
+	p.pipeline = c
+}
+
+// SetPruner sets the pruner applied to the master model at the start of every
+// epoch. Every shard keeps the pruned weights at zero, so they stay zero after
+// averaging.
+func (p *ParallelTrainer) SetPruner(pr *Pruner) {
+	p.pruner = pr
+}
+
+// SetTeacher sets the teacher for knowledge distillation. The soft targets are
+// computed by the data pipeline and every shard trains on the distillation loss.
+func (p *ParallelTrainer) SetTeacher(t *Teacher) {
+	p.teacher = t
+}
+
+// Train runs the sharded training loop. Every epoch the data pipeline shuffles the
+// dataset and deals the prepared examples round-robin to the shards.
 func (p *ParallelTrainer) Train(ds *Dataset, epochs int, featureExtractor func([]float32) *model.Tensor) {
 	numSamples := len(ds.Samples)
 	if numSamples == 0 {
@@ -49,55 +85,60 @@ func (p *ParallelTrainer) Train(ds *Dataset, epochs int, featureExtractor func([
 
 	fmt.Printf("Starting parallel training with %d threads (Shard size: %d)\n", actualThreads, shardSize)
 
+	pipeline := newPipeline(ds, featureExtractor, p.pipeline, p.augmentor, p.specAugment, p.cache, p.teacher)
+
 	for epoch := 1; epoch <= epochs; epoch++ {
 		var wg sync.WaitGroup
 		var mu sync.Mutex
 		var totalLoss float32
 		var completedSamples int
 
+		if p.pruner != nil {
+			fmt.Printf("Pruned to %.1f%% sparsity\n", p.pruner.Prune(p.masterModel, epoch)*100)
+		}
+
 		pb := NewProgressBar(numSamples, fmt.Sprintf("Epoch %d/%d", epoch, epochs))
 
 		// 1. Create local model copies for each shard
-		shardModels := make([]*model.SequentialModel, actualThreads)
+		shardModels := make([]model.Model, actualThreads)
 		for i := 0; i < actualThreads; i++ {
-			shardModels[i] = p.cloneModel(p.masterModel)
+			shardModels[i] = cloneModel(p.masterModel)
+			shardModels[i].SetTraining(true)
+			seedDropout(shardModels[i], p.masterModel, epoch, i)
 		}
 
-		// 2. Launch workers
+		// 2. Deal examples to the shards: example i goes to shard i % threads
+		shardQueues := make([]chan Example, actualThreads)
+		for i := range shardQueues {
+			shardQueues[i] = make(chan Example, 1)
+		}
+		go func() {
+			i := 0
+			for ex := range pipeline.Epoch(epoch) {
+				shardQueues[i%actualThreads] <- ex
+				i++
+			}
+			for _, q := range shardQueues {
+				close(q)
+			}
+		}()
+
+		// 3. Launch workers
 		for t := 0; t < actualThreads; t++ {
 			wg.Add(1)
 			go func(threadIdx int) {
 				defer wg.Done()
-				
-				start := threadIdx * shardSize
-				end := start + shardSize
-				if end > numSamples {
-					end = numSamples
-				}
-				if start >= end {
-					return
-				}
 
 				trainer := NewTrainer(shardModels[threadIdx], p.lr)
-				trainer.SetAugmentor(p.augmentor)
+				if p.pruner != nil {
+					trainer.SetPruner(p.pruner)
+				}
+				trainer.SetTeacher(p.teacher)
 
 				var shardLoss float32
-				shardSamples := ds.Samples[start:end]
-				
-				for _, sample := range shardSamples {
-					audioData := sample.Audio
-					if sample.IsHotword && p.augmentor != nil {
-						audioData = p.augmentor.Augment(audioData)
-					}
-					
-					features := featureExtractor(audioData)
-					target := float32(0.0)
-					if sample.IsHotword {
-						target = 1.0
-					}
-					
-					shardLoss += trainer.TrainStep(features, target)
-					
+				for ex := range shardQueues[threadIdx] {
+					shardLoss += trainer.trainExample(ex, len(ds.Classes) > 0)
+
 					mu.Lock()
 					completedSamples++
 					pb.Update(completedSamples)
@@ -111,7 +152,7 @@ func (p *ParallelTrainer) Train(ds *Dataset, epochs int, featureExtractor func([
 		}
 		wg.Wait()
 
-		// 3. Weight Averaging
+		// 4. Weight Averaging
 		p.averageWeights(shardModels)
 
 		pb.Finish()
@@ -119,8 +160,8 @@ func (p *ParallelTrainer) Train(ds *Dataset, epochs int, featureExtractor func([
 	}
 }
 
-// cloneModel creates a deep copy of a sequential model.
-func (p *ParallelTrainer) cloneModel(m *model.SequentialModel) *model.SequentialModel {
+// cloneModel creates a deep copy of a model, keeping its graph structure.
+func cloneModel(m model.Model) model.Model {
 	layers := m.GetLayers()
 	newLayers := make([]model.Layer, len(layers))
 	
@@ -134,22 +175,78 @@ func (p *ParallelTrainer) cloneModel(m *model.SequentialModel) *model.Sequential
 		switch l.Type() {
 		case "conv2d":
 			orig := l.(*model.Conv2DLayer)
-			newLayer = model.NewConv2DLayer(model.NewTensor(weights.Shape), make([]float32, len(bias)), orig.Stride, orig.Padding)
+			newLayer = model.NewConv2DLayerWithGeometry(model.NewTensor(weights.Shape), make([]float32, len(bias)), orig.ConvGeometry)
+		case "depthwise_conv2d":
+			orig := l.(*model.DepthwiseConv2DLayer)
+			newLayer = model.NewDepthwiseConv2DLayer(model.NewTensor(weights.Shape), make([]float32, len(bias)), orig.ConvGeometry)
+		case "pointwise_conv2d":
+			newLayer = model.NewPointwiseConv2DLayer(model.NewTensor(weights.Shape), make([]float32, len(bias)))
+		case "conv1d":
+			orig := l.(*model.Conv1DLayer)
+			newLayer = model.NewConv1DLayer(model.NewTensor(weights.Shape), make([]float32, len(bias)), orig.Stride, orig.Padding, orig.Dilation)
+		case "maxpool1d", "avgpool1d":
+			orig := *l.(*model.Pool1DLayer)
+			newLayer = &orig
+		case "reshape":
+			newLayer = model.NewReshapeLayer(l.(*model.ReshapeLayer).Shape)
+		case "transpose":
+			newLayer = model.NewTransposeLayer(l.(*model.TransposeLayer).Perm)
 		case "dense":
 			newLayer = model.NewDenseLayer(model.NewTensor(weights.Shape), make([]float32, len(bias)))
 		case "gru":
 			orig := l.(*model.GRULayer)
-			newLayer = model.NewGRULayer(orig.InputSize, orig.HiddenSize)
+			gru := model.NewGRULayer(orig.InputSize, orig.HiddenSize)
+			gru.ReturnSequences = orig.ReturnSequences
+			newLayer = gru
 		case "lstm":
 			orig := l.(*model.LSTMLayer)
-			newLayer = model.NewLSTMLayer(orig.InputSize, orig.HiddenSize)
+			lstm := model.NewLSTMLayer(orig.InputSize, orig.HiddenSize)
+			lstm.ReturnSequences = orig.ReturnSequences
+			newLayer = lstm
+		case "bigru", "bilstm":
+			orig := l.(*model.BidirectionalLayer)
+			if fwd, ok := orig.Fwd.(*model.GRULayer); ok {
+				newLayer = model.NewBiGRULayer(fwd.InputSize, fwd.HiddenSize, orig.ReturnSequences)
+			} else {
+				fwd := orig.Fwd.(*model.LSTMLayer)
+				newLayer = model.NewBiLSTMLayer(fwd.InputSize, fwd.HiddenSize, orig.ReturnSequences)
+			}
 		case "relu":
 			newLayer = model.NewReLULayer()
 		case "sigmoid":
 			newLayer = model.NewSigmoidLayer()
+		case "softmax":
+			newLayer = model.NewSoftmaxLayer()
 		case "maxpool2d":
 			orig := l.(*model.MaxPool2DLayer)
 			newLayer = model.NewMaxPool2DLayer(orig.KernelSize, orig.Stride)
+		case "batchnorm":
+			orig := l.(*model.BatchNormLayer)
+			bn := model.NewBatchNormLayer(len(orig.Gamma))
+			copy(bn.RunningMean, orig.RunningMean)
+			copy(bn.RunningVar, orig.RunningVar)
+			bn.Momentum, bn.Epsilon = orig.Momentum, orig.Epsilon
+			bn.WarmupSteps, bn.Updates = orig.WarmupSteps, orig.Updates
+			newLayer = bn
+		case "dropout", "spatial_dropout":
+			orig := l.(*model.DropoutLayer)
+			newLayer = &model.DropoutLayer{Rate: orig.Rate, Spatial: orig.Spatial}
+		case "add":
+			newLayer = model.NewAddLayer()
+		case "concat":
+			newLayer = model.NewConcatLayer(l.(*model.ConcatLayer).Axis)
+		case "global_avgpool":
+			newLayer = model.NewGlobalAvgPoolLayer()
+		case "global_maxpool":
+			newLayer = model.NewGlobalMaxPoolLayer()
+		case "attention_pool":
+			newLayer = model.NewAttentionPoolLayer(model.NewTensor(weights.Shape))
+		case "conv2d_int8", "dense_int8", "gru_int8", "lstm_int8", "dense_sparse", "gru_sparse":
+			// Inference-only layers keep no state in Forward and are shared
+			// (e.g. by the copies of a teacher model)
+			newLayer = l
+		default:
+			panic(fmt.Sprintf("ParallelTrainer: cannot clone layer type %q", l.Type()))
 		}
 		
 		if weights != nil {
@@ -162,11 +259,11 @@ func (p *ParallelTrainer) cloneModel(m *model.SequentialModel) *model.Sequential
 		newLayers[i] = newLayer
 	}
 	
-	return model.NewSequentialModel(newLayers...)
+	return model.WithLayers(m, newLayers)
 }
 
 // averageWeights averages parameters across all shard models and updates the master model.
-func (p *ParallelTrainer) averageWeights(shardModels []*model.SequentialModel) {
+func (p *ParallelTrainer) averageWeights(shardModels []model.Model) {
 	numShards := float32(len(shardModels))
 	masterLayers := p.masterModel.GetLayers()
 	
@@ -205,5 +302,43 @@ func (p *ParallelTrainer) averageWeights(shardModels []*model.SequentialModel) {
 		
 		// Update master layer
 		masterLayer.SetParams(mWeights, mBias)
+
+		// Running statistics are not trained but diverge per shard as well
+		if bn, ok := masterLayer.(*model.BatchNormLayer); ok {
+			averageRunningStats(bn, shardModels, i)
+		}
+	}
+}
+
+// averageRunningStats sets the running statistics of a master batch normalization
+// layer to the average of the corresponding shard layers. The update count is
+// the largest of the shards, so the warm-up ends once any shard has finished it.
+func averageRunningStats(bn *model.BatchNormLayer, shardModels []model.Model, layerIdx int) {
+	numShards := float32(len(shardModels))
+	for _, sm := range shardModels {
+		bn.Updates = max(bn.Updates, sm.GetLayers()[layerIdx].(*model.BatchNormLayer).Updates)
 	}
-}
\ No newline at end of file
+	for c := range bn.RunningMean {
+		var mean, variance float32
+		for _, sm := range shardModels {
+			shard := sm.GetLayers()[layerIdx].(*model.BatchNormLayer)
+			mean += shard.RunningMean[c]
+			variance += shard.RunningVar[c]
+		}
+		bn.RunningMean[c] = mean / numShards
+		bn.RunningVar[c] = variance / numShards
+	}
+}
+
+// seedDropout gives every dropout layer of a shard model its own random source,
+// derived from the master layer's seed, the epoch and the shard index, so shards
+// draw different masks and runs remain reproducible.
+func seedDropout(shard, master model.Model, epoch, shardIdx int) {
+	masterLayers := master.GetLayers()
+	for i, l := range shard.GetLayers() {
+		if d, ok := l.(*model.DropoutLayer); ok {
+			seed := masterLayers[i].(*model.DropoutLayer).Seed
+			d.SetSeed(uint64(deriveSeed(int64(seed), int64(epoch), int64(shardIdx))))
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package train
 
 import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/tomkiv/hotword/pkg/model"
@@ -30,3 +35,249 @@ func TestParallelTrainer(t *testing.T) {
 	// Should run without error
 	trainer.Train(ds, 2, extractor)
 }
+
+func TestTrainersBatchNorm(t *testing.T) {
+	ds := indexedDataset(12)
+	newModel := func() *model.SequentialModel {
+		m, err := model.BuildModelFromConfig([]model.LayerConfig{
+			{Type: "conv2d", Filters: 2, KernelSize: 1, Stride: 1},
+			{Type: "batchnorm"},
+			{Type: "dense", Units: 1},
+			{Type: "sigmoid"},
+		}, []int{1, 2, 4})
+		if err != nil {
+			t.Fatalf("Failed to build model: %v", err)
+		}
+		return m
+	}
+
+	trainers := map[string]func(m *model.SequentialModel) AugmentorTrainer{
+		"Trainer":         func(m *model.SequentialModel) AugmentorTrainer { return NewTrainer(m, 0.05) },
+		"ParallelTrainer": func(m *model.SequentialModel) AugmentorTrainer { return NewParallelTrainer(m, 0.05, 2) },
+	}
+	for name, newTrainer := range trainers {
+		t.Run(name, func(t *testing.T) {
+			m := newModel()
+			tr := newTrainer(m)
+			tr.SetPipelineConfig(PipelineConfig{Workers: 2, Seed: 1})
+			tr.Train(ds, 2, copyExtractor)
+
+			bn := m.GetLayers()[1].(*model.BatchNormLayer)
+			if bn.Training() {
+				t.Error("Expected the model to be back in inference mode after training")
+			}
+			if bn.RunningMean[0] == 0 && bn.RunningMean[1] == 0 {
+				t.Error("Expected running statistics to be updated during training")
+			}
+			// Every shard sees half of the 12 samples per epoch, and the count
+			// carries over to the next epoch
+			if bn.Updates < 12 {
+				t.Errorf("Expected at least 12 running statistics updates over 2 epochs, got %d", bn.Updates)
+			}
+		})
+	}
+}
+
+func TestTrainersGraphModel(t *testing.T) {
+	newModel := func() model.Model {
+		model.ResetRand(2)
+		m, err := model.BuildModel([]model.LayerConfig{
+			{Type: "conv2d", Name: "stem", Filters: 2, KernelSize: 1},
+			{Type: "batchnorm"},
+			{Type: "relu"},
+			{Type: "add", Inputs: []string{"stem", "relu_2"}},
+			{Type: "concat", Name: "merged", Inputs: []string{"add_3", "input"}},
+			{Type: "dense", Units: 1},
+			{Type: "sigmoid"},
+		}, []int{1, 2, 4})
+		if err != nil {
+			t.Fatalf("Failed to build model: %v", err)
+		}
+		return m
+	}
+
+	trainers := map[string]func(m model.Model) AugmentorTrainer{
+		"Trainer":         func(m model.Model) AugmentorTrainer { return NewTrainer(m, 0.1) },
+		"ParallelTrainer": func(m model.Model) AugmentorTrainer { return NewParallelTrainer(m, 0.1, 2) },
+	}
+	for name, newTrainer := range trainers {
+		t.Run(name, func(t *testing.T) {
+			m := newModel()
+			stem := m.GetLayers()[0].(*model.Conv2DLayer)
+			before := append([]float32(nil), stem.Weights.Data...)
+
+			tr := newTrainer(m)
+			tr.SetPipelineConfig(PipelineConfig{Workers: 2, Seed: 1})
+			tr.Train(indexedDataset(8), 1, copyExtractor)
+
+			g, ok := m.(*model.GraphModel)
+			if !ok || g.Nodes[0].Layer != model.Layer(stem) {
+				t.Fatal("Expected the trainer to update the graph model in place")
+			}
+			if reflect.DeepEqual(before, stem.Weights.Data) {
+				t.Error("Expected the stem weights to be trained through both branches")
+			}
+			if bn := g.Nodes[1].Layer.(*model.BatchNormLayer); bn.RunningMean[0] == 0 && bn.RunningMean[1] == 0 {
+				t.Error("Expected running statistics to be updated during training")
+			}
+		})
+	}
+}
+
+func TestCloneModel(t *testing.T) {
+	dense := []model.LayerConfig{{Type: "dense", Units: 2}, {Type: "softmax"}}
+	cases := []struct {
+		name   string
+		layers []model.LayerConfig
+		quant  bool
+	}{
+		{"CNN", append([]model.LayerConfig{
+			{Type: "conv2d", Filters: 2, KernelH: 2, KernelW: 1, StrideW: 2},
+			{Type: "batchnorm"},
+			{Type: "relu"},
+			{Type: "maxpool2d", KernelSize: 1, Stride: 1},
+			{Type: "dropout", Rate: 0.5},
+			{Type: "global_maxpool"},
+		}, dense...), false},
+		{"Separable Conv", append([]model.LayerConfig{
+			{Type: "conv2d", Filters: 2, KernelSize: 1},
+			{Type: "depthwise_conv2d", KernelSize: 2, Padding: 1},
+			{Type: "pointwise_conv2d", Filters: 3},
+			{Type: "spatial_dropout", Rate: 0.2},
+		}, dense...), false},
+		{"Temporal Conv", append([]model.LayerConfig{
+			{Type: "transpose", Perm: []int{0, 2, 1}},
+			{Type: "reshape", Shape: []int{6, -1}},
+			{Type: "conv1d", Filters: 3, KernelSize: 2, Dilation: 1},
+			{Type: "batchnorm"},
+			{Type: "maxpool1d", KernelSize: 2, Stride: 1},
+			{Type: "avgpool1d", KernelSize: 2},
+		}, dense...), false},
+		{"Recurrent", append([]model.LayerConfig{
+			{Type: "gru", Units: 3, ReturnSequences: true},
+			{Type: "lstm", Units: 3, ReturnSequences: true},
+			{Type: "global_avgpool"},
+		}, dense...), false},
+		{"Bidirectional", append([]model.LayerConfig{
+			{Type: "bigru", Units: 3, ReturnSequences: true},
+			{Type: "bilstm", Units: 2},
+		}, dense...), false},
+		{"Attention Pool", append([]model.LayerConfig{
+			{Type: "conv2d", Filters: 2, KernelSize: 1},
+			{Type: "attention_pool"},
+		}, dense...), false},
+		{"Graph", append([]model.LayerConfig{
+			{Type: "conv2d", Name: "stem", Filters: 1, KernelSize: 1},
+			{Type: "relu"},
+			{Type: "add", Inputs: []string{"stem", "relu_1"}},
+			{Type: "concat", Inputs: []string{"add_2", "input"}, Axis: 0},
+		}, dense...), false},
+		{"Quantized", append([]model.LayerConfig{
+			{Type: "conv2d", Filters: 2, KernelSize: 1},
+			{Type: "gru", Units: 3},
+		}, dense...), true},
+	}
+
+	rng := rand.New(rand.NewSource(25))
+	input := model.NewTensor([]int{1, 4, 6})
+	for i := range input.Data {
+		input.Data[i] = rng.Float32()*2 - 1
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			model.ResetRand(25)
+			m, err := model.BuildModel(tc.layers, input.Shape)
+			if err != nil {
+				t.Fatalf("Failed to build model: %v", err)
+			}
+			for _, l := range m.GetLayers() {
+				if bn, ok := l.(*model.BatchNormLayer); ok {
+					for c := range bn.RunningMean {
+						bn.RunningMean[c], bn.RunningVar[c] = 0.1*float32(c), 1+0.5*float32(c)
+					}
+				}
+			}
+			if tc.quant {
+				c := model.NewCalibrator(m)
+				c.Observe(input)
+				if m, _, err = model.Quantize(m, c); err != nil {
+					t.Fatalf("Quantize failed: %v", err)
+				}
+			}
+			m.SetTraining(false)
+
+			shards := []model.Model{cloneModel(m), cloneModel(m)}
+			expected := m.Forward(input)
+			for _, shard := range shards {
+				if got := shard.Forward(input); !reflect.DeepEqual(got, expected) {
+					t.Fatalf("Expected the clone to compute %v, got %v", expected.Data, got.Data)
+				}
+			}
+			if tc.quant {
+				return
+			}
+
+			// Shard k moves every parameter by k+1, so the average moves it by 1.5
+			var want [][]float32
+			for i, l := range m.GetLayers() {
+				weights, bias := l.Params()
+				if weights == nil {
+					want = append(want, nil)
+					continue
+				}
+				want = append(want, append(shiftParams(weights.Data, 1.5), shiftParams(bias, 1.5)...))
+				for k, shard := range shards {
+					sl := shard.GetLayers()[i]
+					sw, sb := sl.Params()
+					sl.SetParams(&model.Tensor{Data: shiftParams(sw.Data, float32(k+1)), Shape: sw.Shape}, shiftParams(sb, float32(k+1)))
+					if bn, ok := sl.(*model.BatchNormLayer); ok {
+						bn.RunningMean[0] = float32(2 * k)
+					}
+				}
+			}
+			p := NewParallelTrainer(m, 0.1, len(shards))
+			p.averageWeights(shards)
+
+			for i, l := range m.GetLayers() {
+				if want[i] == nil {
+					continue
+				}
+				weights, bias := l.Params()
+				got := append(append([]float32(nil), weights.Data...), bias...)
+				for j := range got {
+					if math.Abs(float64(got[j]-want[i][j])) > 1e-5 {
+						t.Fatalf("Layer %d (%s): expected the averaged parameter %f at %d, got %f", i, l.Type(), want[i][j], j, got[j])
+					}
+				}
+				if bn, ok := l.(*model.BatchNormLayer); ok && bn.RunningMean[0] != 1 {
+					t.Errorf("Layer %d: expected the averaged running mean 1, got %f", i, bn.RunningMean[0])
+				}
+			}
+		})
+	}
+
+	t.Run("Unknown Layer", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "custom") {
+				t.Errorf("Expected a panic naming the layer type, got %v", r)
+			}
+		}()
+		cloneModel(model.NewSequentialModel(customLayer{model.NewReLULayer()}))
+	})
+}
+
+// shiftParams returns a copy of params with delta added to every value.
+func shiftParams(params []float32, delta float32) []float32 {
+	out := make([]float32, len(params))
+	for i, v := range params {
+		out[i] = v + delta
+	}
+	return out
+}
+
+// customLayer is a layer type cloneModel does not know.
+type customLayer struct {
+	model.Layer
+}
+
+func (customLayer) Type() string { return "custom" }
@@ -0,0 +1,198 @@
+package train
+
+import (
+	"math/rand"
+	"runtime"
+	"time"
+
+	"github.com/tomkiv/hotword/pkg/features"
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+// PipelineConfig configures the training data pipeline.
+type PipelineConfig struct {
+	Workers  int   // Feature extraction workers, 0 = number of CPU cores
+	Prefetch int   // Examples prepared ahead of the trainer, 0 = 2 per worker
+	Seed     int64 // Seed for shuffling and augmentation, 0 = random
+
+	// FrameMask, if set, gives the frame mask of the features of a padded
+	// sample from its ActualLen and padded length (see features.Config.FrameMask),
+	// so recurrent layers skip the padding
+	FrameMask func(actualLen, numSamples int) []bool
+}
+
+// Example is a training example produced by the pipeline.
+type Example struct {
+	Features *model.Tensor
+	Mask     []bool // Frames holding audio, nil if the sample is not padded
+	Target   float32
+	Label    int       // Class index (multi-class datasets)
+	Soft     []float32 // Soft targets of the teacher, nil without distillation
+}
+
+// Pipeline prepares training examples in the background: every epoch it shuffles
+// the dataset and a pool of workers augments the audio, extracts features and
+// applies SpecAugment, while the trainer consumes finished examples.
+//
+// Sample i of an epoch is always prepared by worker i % Workers, which uses its
+// own random source seeded from the pipeline seed, the epoch and the worker index.
+// Examples are delivered in order, so a fixed seed and worker count produce the
+// same examples in the same order on every run.
+type Pipeline struct {
+	ds               *Dataset
+	featureExtractor func([]float32) *model.Tensor
+	config           PipelineConfig
+
+	augmentor   *Augmentor
+	specAugment *SpecAugmenter
+	cache       *features.Cache
+	teacher     *Teacher
+}
+
+// NewPipeline creates a data pipeline over the dataset.
+func NewPipeline(ds *Dataset, featureExtractor func([]float32) *model.Tensor, config PipelineConfig) *Pipeline {
+	if config.Workers <= 0 {
+		config.Workers = runtime.NumCPU()
+	}
+	if config.Prefetch <= 0 {
+		config.Prefetch = 2 * config.Workers
+	}
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	return &Pipeline{
+		ds:               ds,
+		featureExtractor: featureExtractor,
+		config:           config,
+	}
+}
+
+// SetAugmentor sets the waveform augmentor applied to hotword samples.
+func (p *Pipeline) SetAugmentor(a *Augmentor) {
+	p.augmentor = a
+}
+
+// SetSpecAugmenter sets the feature-domain augmenter applied to every sample.
+func (p *Pipeline) SetSpecAugmenter(s *SpecAugmenter) {
+	p.specAugment = s
+}
+
+// SetFeatureCache sets the cache used for the features of unaugmented samples.
+func (p *Pipeline) SetFeatureCache(c *features.Cache) {
+	p.cache = c
+}
+
+// SetTeacher sets the teacher providing the soft targets of every example.
+func (p *Pipeline) SetTeacher(t *Teacher) {
+	p.teacher = t
+}
+
+// Epoch starts preparing the examples of the given epoch (1-based) in a freshly
+// shuffled order and returns the channel delivering them. The channel is closed
+// after the last example and must be drained.
+func (p *Pipeline) Epoch(epoch int) <-chan Example {
+	numSamples := len(p.ds.Samples)
+	order := rand.New(rand.NewSource(deriveSeed(p.config.Seed, int64(epoch), -1))).Perm(numSamples)
+
+	if numSamples == 0 {
+		out := make(chan Example)
+		close(out)
+		return out
+	}
+
+	numWorkers := p.config.Workers
+	if numWorkers > numSamples {
+		numWorkers = numSamples
+	}
+	depth := p.config.Prefetch / numWorkers
+	if depth < 1 {
+		depth = 1
+	}
+
+	// One bounded channel per worker keeps the delivery order deterministic
+	queues := make([]chan Example, numWorkers)
+	for w := range queues {
+		queues[w] = make(chan Example, depth)
+		go func(w int) {
+			defer close(queues[w])
+			rng := rand.New(rand.NewSource(deriveSeed(p.config.Seed, int64(epoch), int64(w))))
+			for i := w; i < numSamples; i += numWorkers {
+				queues[w] <- p.prepare(p.ds.Samples[order[i]], rng)
+			}
+		}(w)
+	}
+
+	out := make(chan Example)
+	go func() {
+		defer close(out)
+		for i := 0; i < numSamples; i++ {
+			out <- <-queues[i%numWorkers]
+		}
+	}()
+	return out
+}
+
+// prepare turns a sample into a training example.
+func (p *Pipeline) prepare(sample Sample, rng *rand.Rand) Example {
+	audioData := sample.Audio
+	augmented := false
+
+	// Audio length of samples padded by padded loading
+	actualLen := len(audioData)
+	if sample.ActualLen > 0 && sample.ActualLen < len(audioData) {
+		actualLen = sample.ActualLen
+	}
+
+	// Apply dynamic augmentation only to hotwords
+	if p.augmentor != nil && sample.IsHotword {
+		audioData, actualLen, augmented = p.augmentor.apply(audioData, actualLen, rng)
+	}
+
+	// Convert raw audio to features (e.g. Mel-Spectrogram). Unaugmented samples
+	// loaded from files are served from the feature cache.
+	var feats *model.Tensor
+	if p.cache != nil && !augmented && sample.Source != "" {
+		feats = p.cache.Extract(sample.Source, sample.Offset, audioData)
+	} else {
+		feats = p.featureExtractor(audioData)
+	}
+
+	// Feature-domain augmentation applies to every sample
+	if p.specAugment != nil {
+		feats = p.specAugment.augment(feats, rng)
+	}
+
+	// The teacher sees the same (augmented) audio as the student
+	var soft []float32
+	if p.teacher != nil {
+		soft = p.teacher.SoftTargets(audioData)
+	}
+
+	// Frames of the zero padding added by padded loading
+	var mask []bool
+	if p.config.FrameMask != nil && actualLen < len(audioData) {
+		mask = p.config.FrameMask(actualLen, len(audioData))
+		if len(feats.Shape) != 3 || len(mask) != feats.Shape[1] {
+			mask = nil
+		}
+	}
+
+	target := float32(0.0)
+	if sample.IsHotword {
+		target = 1.0
+	}
+	return Example{Features: feats, Mask: mask, Target: target, Label: sample.Label, Soft: soft}
+}
+
+// deriveSeed mixes a base seed with an epoch and worker index into a new seed.
+func deriveSeed(seed int64, parts ...int64) int64 {
+	h := uint64(seed)
+	for _, part := range parts {
+		// splitmix64 step
+		h += uint64(part) + 0x9e3779b97f4a7c15
+		h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+		h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+		h ^= h >> 31
+	}
+	return int64(h)
+}
@@ -0,0 +1,238 @@
+package train
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+// indexedDataset returns samples whose audio encodes their index.
+func indexedDataset(n int) *Dataset {
+	ds := &Dataset{}
+	for i := 0; i < n; i++ {
+		audio := make([]float32, 8)
+		for j := range audio {
+			audio[j] = float32(i) + 0.1*float32(j)
+		}
+		ds.Samples = append(ds.Samples, Sample{Audio: audio, IsHotword: i%2 == 0})
+	}
+	return ds
+}
+
+func copyExtractor(s []float32) *model.Tensor {
+	t := model.NewTensor([]int{1, 2, len(s) / 2})
+	copy(t.Data, s)
+	return t
+}
+
+func collectEpoch(p *Pipeline, epoch int) [][]float32 {
+	var out [][]float32
+	for ex := range p.Epoch(epoch) {
+		out = append(out, ex.Features.Data)
+	}
+	return out
+}
+
+func TestPipeline(t *testing.T) {
+	ds := indexedDataset(20)
+
+	t.Run("Every Sample Once Per Epoch", func(t *testing.T) {
+		p := NewPipeline(ds, copyExtractor, PipelineConfig{Workers: 3, Seed: 1})
+		var orders [][]int
+		for epoch := 1; epoch <= 2; epoch++ {
+			seen := make(map[int]bool)
+			var order []int
+			for ex := range p.Epoch(epoch) {
+				idx := int(ex.Features.Data[0])
+				if seen[idx] {
+					t.Fatalf("Sample %d delivered twice in epoch %d", idx, epoch)
+				}
+				seen[idx] = true
+				order = append(order, idx)
+				if want := float32(1 - idx%2); ex.Target != want {
+					t.Errorf("Sample %d: expected target %f, got %f", idx, want, ex.Target)
+				}
+			}
+			if len(seen) != len(ds.Samples) {
+				t.Fatalf("Expected %d samples in epoch %d, got %d", len(ds.Samples), epoch, len(seen))
+			}
+			orders = append(orders, order)
+		}
+		if reflect.DeepEqual(orders[0], orders[1]) {
+			t.Error("Expected the dataset to be reshuffled between epochs")
+		}
+	})
+
+	t.Run("Reproducible With Seed", func(t *testing.T) {
+		newPipeline := func(seed int64, prefetch int) *Pipeline {
+			p := NewPipeline(ds, copyExtractor, PipelineConfig{Workers: 4, Prefetch: prefetch, Seed: seed})
+			p.SetAugmentor(NewAugmentor(AugmentorConfig{AugmentProb: 0.5, MaxGainScale: 0.3, MaxShiftMs: 1, SampleRate: 16000}, nil))
+			p.SetSpecAugmenter(NewSpecAugmenter(SpecAugmentConfig{Prob: 0.5, TimeMasks: 1, TimeMaskWidth: 1, FreqMasks: 1, FreqMaskWidth: 2}))
+			return p
+		}
+
+		a := collectEpoch(newPipeline(42, 1), 3)
+		b := collectEpoch(newPipeline(42, 16), 3)
+		if !reflect.DeepEqual(a, b) {
+			t.Error("Expected identical examples for the same seed")
+		}
+		if c := collectEpoch(newPipeline(43, 1), 3); reflect.DeepEqual(a, c) {
+			t.Error("Expected different examples for a different seed")
+		}
+	})
+
+	t.Run("Bounded Prefetch", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := 0
+		extractor := func(s []float32) *model.Tensor {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			return copyExtractor(s)
+		}
+		p := NewPipeline(ds, extractor, PipelineConfig{Workers: 2, Prefetch: 4, Seed: 1})
+		examples := p.Epoch(1)
+		time.Sleep(50 * time.Millisecond)
+
+		// Each worker fills its queue (2) and holds one more; the collector holds one
+		mu.Lock()
+		prepared := calls
+		mu.Unlock()
+		if prepared > 7 {
+			t.Errorf("Expected at most 7 prepared examples before consumption, got %d", prepared)
+		}
+
+		count := 0
+		for range examples {
+			count++
+		}
+		if count != len(ds.Samples) {
+			t.Errorf("Expected %d examples, got %d", len(ds.Samples), count)
+		}
+	})
+
+	t.Run("Frame Mask", func(t *testing.T) {
+		padded := &Dataset{Samples: []Sample{
+			{Audio: make([]float32, 8), ActualLen: 3},
+			{Audio: make([]float32, 8), ActualLen: 8},
+			{Audio: make([]float32, 8)},
+		}}
+		frameMask := func(actualLen, numSamples int) []bool {
+			return model.PrefixMask(2, (actualLen+3)/4)
+		}
+		p := NewPipeline(padded, copyExtractor, PipelineConfig{FrameMask: frameMask, Seed: 1})
+		masks := 0
+		for ex := range p.Epoch(1) {
+			if ex.Mask != nil {
+				masks++
+				if !reflect.DeepEqual(ex.Mask, []bool{true, false}) {
+					t.Errorf("Expected mask [true false], got %v", ex.Mask)
+				}
+			}
+		}
+		// Only the padded sample is masked
+		if masks != 1 {
+			t.Errorf("Expected 1 masked example, got %d", masks)
+		}
+	})
+
+	t.Run("Shifted Padding", func(t *testing.T) {
+		// 30 samples of audio padded to 64, framed into 16 frames of 4 samples
+		padded := &Dataset{}
+		for i := 0; i < 40; i++ {
+			audio := make([]float32, 64)
+			for j := 0; j < 30; j++ {
+				audio[j] = 1
+			}
+			padded.Samples = append(padded.Samples, Sample{Audio: audio, ActualLen: 30, IsHotword: true})
+		}
+		noise := make([]float32, 100)
+		for i := range noise {
+			noise[i] = 0.5
+		}
+		frameMask := func(actualLen, numSamples int) []bool {
+			return model.PrefixMask(16, (actualLen+3)/4)
+		}
+		extract := func(s []float32) *model.Tensor {
+			return &model.Tensor{Data: append([]float32(nil), s...), Shape: []int{1, 16, 4}}
+		}
+		p := NewPipeline(padded, extract, PipelineConfig{FrameMask: frameMask, Seed: 1})
+		p.SetAugmentor(NewAugmentor(AugmentorConfig{AugmentProb: 1, MaxShiftMs: 1, MaxNoiseRatio: 0.5, SampleRate: 16000}, []Sample{{Audio: noise}}))
+
+		longer, shorter := 0, 0
+		for ex := range p.Epoch(1) {
+			end := 0
+			for i, v := range ex.Features.Data {
+				if v != 0 {
+					end = i + 1
+				}
+			}
+			// The mask ends with the shifted audio and the padding stays silent
+			valid := 16
+			if ex.Mask != nil {
+				valid = 0
+				for _, m := range ex.Mask {
+					if m {
+						valid++
+					}
+				}
+			}
+			if expected := (end + 3) / 4; valid != expected {
+				t.Errorf("Audio ends at %d: expected %d valid frames, got %d", end, expected, valid)
+			}
+			switch {
+			case end > 30:
+				longer++
+			case end < 30:
+				shorter++
+			}
+		}
+		if longer == 0 || shorter == 0 {
+			t.Errorf("Expected clips shifted both ways, got %d later and %d earlier", longer, shorter)
+		}
+	})
+
+	t.Run("Empty Dataset", func(t *testing.T) {
+		p := NewPipeline(&Dataset{}, copyExtractor, PipelineConfig{})
+		for range p.Epoch(1) {
+			t.Error("Expected no examples")
+		}
+	})
+}
+
+func TestTrainersReproducible(t *testing.T) {
+	ds := indexedDataset(16)
+	newModel := func() *model.SequentialModel {
+		w := model.NewTensor([]int{1, 8})
+		for i := range w.Data {
+			w.Data[i] = 0.01 * float32(i)
+		}
+		return model.NewSequentialModel(model.NewDenseLayer(w, []float32{0}), model.NewSigmoidLayer())
+	}
+	train := func(newTrainer func(m *model.SequentialModel) AugmentorTrainer) []float32 {
+		m := newModel()
+		tr := newTrainer(m)
+		tr.SetPipelineConfig(PipelineConfig{Workers: 3, Seed: 7})
+		tr.SetAugmentor(NewAugmentor(AugmentorConfig{AugmentProb: 0.5, MaxGainScale: 0.3}, nil))
+		tr.Train(ds, 3, copyExtractor)
+		w, _ := m.GetLayers()[0].Params()
+		return w.Data
+	}
+
+	trainers := map[string]func(m *model.SequentialModel) AugmentorTrainer{
+		"Trainer":         func(m *model.SequentialModel) AugmentorTrainer { return NewTrainer(m, 0.1) },
+		"ParallelTrainer": func(m *model.SequentialModel) AugmentorTrainer { return NewParallelTrainer(m, 0.1, 2) },
+	}
+	for name, newTrainer := range trainers {
+		t.Run(name, func(t *testing.T) {
+			a := train(newTrainer)
+			b := train(newTrainer)
+			if !reflect.DeepEqual(a, b) {
+				t.Errorf("Expected identical weights for the same seed:\n%v\n%v", a, b)
+			}
+		})
+	}
+}
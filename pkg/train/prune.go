@@ -0,0 +1,171 @@
+package train
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+// PruneConfig defines iterative magnitude pruning during training. The sparsity
+// of every prunable layer grows from 0 at StartEpoch to its target at EndEpoch
+// and stays there for the remaining epochs.
+type PruneConfig struct {
+	Sparsity   float32            `mapstructure:"sparsity"`    // Target fraction of zero weights, 0 = off
+	StartEpoch int                `mapstructure:"start_epoch"` // First pruned epoch (default 1)
+	EndEpoch   int                `mapstructure:"end_epoch"`   // Epoch reaching the target (default the last epoch)
+	Layers     map[string]float32 `mapstructure:"layers"`      // Per-layer targets by layer index or type ("dense", "gru")
+}
+
+// Enabled reports whether the configuration prunes any layer.
+func (c PruneConfig) Enabled() bool {
+	if c.Sparsity > 0 {
+		return true
+	}
+	for _, s := range c.Layers {
+		if s > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SparsityAt returns the sparsity of a layer with the given target at the start
+// of an epoch, following the cubic schedule of Zhu & Gupta (2017): pruning is
+// fast while the network has many redundant weights and slows down as it
+// approaches the target.
+func (c PruneConfig) SparsityAt(target float32, epoch int) float32 {
+	if epoch < c.StartEpoch {
+		return 0
+	}
+	if epoch >= c.EndEpoch {
+		return target
+	}
+	progress := float64(epoch-c.StartEpoch) / float64(c.EndEpoch-c.StartEpoch)
+	return target * float32(1-math.Pow(1-progress, 3))
+}
+
+// prunable reports whether Pruner can prune l.
+func prunable(l model.Layer) bool {
+	switch l.(type) {
+	case *model.DenseLayer, *model.GRULayer:
+		return true
+	}
+	return false
+}
+
+// Pruner prunes the dense and gru layers of a model to a target sparsity by
+// zeroing their smallest weights, and keeps the pruned weights at zero through
+// training. Masks are indexed by layer position, so a pruner works with any
+// copy of the model it was created for. The masks are read concurrently by
+// training workers and must only change between epochs.
+type Pruner struct {
+	config  PruneConfig
+	targets []float32 // Target sparsity per layer, 0 = not pruned
+	masks   [][]bool  // Pruned weights per layer
+}
+
+// NewPruner creates a pruner for m trained for a number of epochs, applying
+// the per-layer overrides of the configuration: an index beats a layer type,
+// which beats the default sparsity.
+func NewPruner(m model.Model, config PruneConfig, epochs int) (*Pruner, error) {
+	if config.StartEpoch == 0 {
+		config.StartEpoch = 1
+	}
+	if config.EndEpoch == 0 {
+		config.EndEpoch = epochs
+	}
+	if config.StartEpoch < 1 || config.EndEpoch < config.StartEpoch || config.EndEpoch > epochs {
+		return nil, fmt.Errorf("invalid pruning schedule: epochs %d to %d of %d", config.StartEpoch, config.EndEpoch, epochs)
+	}
+	if config.Sparsity < 0 || config.Sparsity >= 1 {
+		return nil, fmt.Errorf("sparsity must be in [0, 1), got %g", config.Sparsity)
+	}
+
+	layers := m.GetLayers()
+	byType := make(map[string]float32)
+	byIndex := make(map[int]float32)
+	for key, s := range config.Layers {
+		if s < 0 || s >= 1 {
+			return nil, fmt.Errorf("sparsity of layer %q must be in [0, 1), got %g", key, s)
+		}
+		if idx, err := strconv.Atoi(key); err == nil {
+			if idx < 0 || idx >= len(layers) || !prunable(layers[idx]) {
+				return nil, fmt.Errorf("layer %q is not a dense or gru layer of the model", key)
+			}
+			byIndex[idx] = s
+		} else if key == "dense" || key == "gru" {
+			byType[key] = s
+		} else {
+			return nil, fmt.Errorf("unknown layer %q: expected a layer index, \"dense\" or \"gru\"", key)
+		}
+	}
+
+	p := &Pruner{config: config, targets: make([]float32, len(layers)), masks: make([][]bool, len(layers))}
+	for i, l := range layers {
+		if !prunable(l) {
+			continue
+		}
+		p.targets[i] = config.Sparsity
+		if s, ok := byType[l.Type()]; ok {
+			p.targets[i] = s
+		}
+		if s, ok := byIndex[i]; ok {
+			p.targets[i] = s
+		}
+	}
+	return p, nil
+}
+
+// Prune zeroes the smallest weights of every pruned layer of m until it reaches
+// its scheduled sparsity for the epoch. Weights pruned in earlier epochs stay
+// pruned. It returns the fraction of zero weights over all pruned layers.
+func (p *Pruner) Prune(m model.Model, epoch int) float32 {
+	var zeros, total int
+	for i, l := range m.GetLayers() {
+		if p.targets[i] == 0 {
+			continue
+		}
+		weights, bias := l.Params()
+		if p.masks[i] == nil {
+			p.masks[i] = make([]bool, len(weights.Data))
+		}
+		mask := p.masks[i]
+
+		k := int(p.config.SparsityAt(p.targets[i], epoch) * float32(len(weights.Data)))
+		order := make([]int, len(weights.Data))
+		for j := range order {
+			order[j] = j
+		}
+		sort.Slice(order, func(a, b int) bool {
+			return math.Abs(float64(weights.Data[order[a]])) < math.Abs(float64(weights.Data[order[b]]))
+		})
+		for _, j := range order[:k] {
+			mask[j] = true
+		}
+		p.apply(i, weights.Data)
+		l.SetParams(weights, bias)
+
+		for _, pruned := range mask {
+			if pruned {
+				zeros++
+			}
+		}
+		total += len(mask)
+	}
+	if total == 0 {
+		return 0
+	}
+	return float32(zeros) / float32(total)
+}
+
+// apply zeroes the pruned weights of layer idx.
+func (p *Pruner) apply(idx int, weights []float32) {
+	for j, pruned := range p.masks[idx] {
+		if pruned {
+			weights[j] = 0
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package train
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+func newPruneModel(t *testing.T) model.Model {
+	t.Helper()
+	model.ResetRand(23)
+	m, err := model.BuildModelFromConfig([]model.LayerConfig{
+		{Type: "gru", Units: 6},
+		{Type: "dense", Units: 4},
+		{Type: "relu"},
+		{Type: "dense", Units: 1},
+		{Type: "sigmoid"},
+	}, []int{1, 2, 4})
+	if err != nil {
+		t.Fatalf("Failed to build model: %v", err)
+	}
+	return m
+}
+
+func TestPruneSchedule(t *testing.T) {
+	c := PruneConfig{StartEpoch: 2, EndEpoch: 6}
+	for _, tc := range []struct {
+		epoch    int
+		expected float32
+	}{
+		{1, 0},
+		{2, 0},
+		{4, 0.8 * 0.875}, // 1 - (1 - 0.5)^3
+		{6, 0.8},
+		{9, 0.8},
+	} {
+		if got := c.SparsityAt(0.8, tc.epoch); math.Abs(float64(got-tc.expected)) > 1e-6 {
+			t.Errorf("Epoch %d: expected %f, got %f", tc.epoch, tc.expected, got)
+		}
+	}
+	// The sparsity grows fastest at the start
+	if c.SparsityAt(0.8, 3)-c.SparsityAt(0.8, 2) <= c.SparsityAt(0.8, 6)-c.SparsityAt(0.8, 5) {
+		t.Error("Expected the schedule to slow down towards the target")
+	}
+}
+
+func TestNewPruner(t *testing.T) {
+	m := newPruneModel(t)
+
+	p, err := NewPruner(m, PruneConfig{Sparsity: 0.5, Layers: map[string]float32{"dense": 0.7, "3": 0}}, 4)
+	if err != nil {
+		t.Fatalf("NewPruner failed: %v", err)
+	}
+	if expected := []float32{0.5, 0.7, 0, 0, 0}; !floatsEqual(p.targets, expected) {
+		t.Errorf("Expected targets %v, got %v", expected, p.targets)
+	}
+	if p.config.StartEpoch != 1 || p.config.EndEpoch != 4 {
+		t.Errorf("Expected the default schedule 1 to 4, got %d to %d", p.config.StartEpoch, p.config.EndEpoch)
+	}
+
+	for _, c := range []PruneConfig{
+		{Sparsity: 1},
+		{Sparsity: 0.5, EndEpoch: 5},
+		{Sparsity: 0.5, StartEpoch: 3, EndEpoch: 2},
+		{Layers: map[string]float32{"2": 0.5}},
+		{Layers: map[string]float32{"conv2d": 0.5}},
+		{Layers: map[string]float32{"gru": -0.1}},
+	} {
+		if _, err := NewPruner(m, c, 4); err == nil {
+			t.Errorf("Expected an error for %+v", c)
+		}
+	}
+}
+
+func floatsEqual(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPrunerMasksTrainStep(t *testing.T) {
+	m := newPruneModel(t)
+	p, err := NewPruner(m, PruneConfig{Sparsity: 0.6}, 1)
+	if err != nil {
+		t.Fatalf("NewPruner failed: %v", err)
+	}
+	if s := p.Prune(m, 1); s < 0.59 {
+		t.Fatalf("Expected 60%% sparsity, got %f", s)
+	}
+
+	tr := NewTrainer(m, 0.5)
+	tr.SetPruner(p)
+	for i := 0; i < 10; i++ {
+		tr.TrainStep(copyExtractor(indexedDataset(2).Samples[i%2].Audio), float32(i%2))
+	}
+	for _, i := range []int{0, 1, 3} {
+		weights, _ := m.GetLayers()[i].Params()
+		zeros := 0
+		for j, pruned := range p.masks[i] {
+			if pruned && weights.Data[j] != 0 {
+				t.Fatalf("Layer %d: pruned weight %d was updated to %f", i, j, weights.Data[j])
+			}
+			if pruned {
+				zeros++
+			}
+		}
+		if expected := int(0.6 * float32(len(weights.Data))); zeros != expected {
+			t.Errorf("Layer %d: expected %d pruned weights, got %d", i, expected, zeros)
+		}
+	}
+}
+
+func TestPruneTrainers(t *testing.T) {
+	trainers := map[string]func(m model.Model) AugmentorTrainer{
+		"Trainer":         func(m model.Model) AugmentorTrainer { return NewTrainer(m, 0.1) },
+		"ParallelTrainer": func(m model.Model) AugmentorTrainer { return NewParallelTrainer(m, 0.1, 2) },
+	}
+	for name, newTrainer := range trainers {
+		t.Run(name, func(t *testing.T) {
+			m := newPruneModel(t)
+			p, err := NewPruner(m, PruneConfig{Sparsity: 0.8, EndEpoch: 2, Layers: map[string]float32{"gru": 0.5}}, 3)
+			if err != nil {
+				t.Fatalf("NewPruner failed: %v", err)
+			}
+			tr := newTrainer(m)
+			tr.SetPruner(p)
+			tr.SetPipelineConfig(PipelineConfig{Workers: 2, Seed: 1})
+			tr.Train(indexedDataset(8), 3, copyExtractor)
+
+			layers := m.GetLayers()
+			for i, expected := range []float32{0.5, 0.8} {
+				if s := model.Sparsity(layers[i]); math.Abs(float64(s-expected)) > 0.02 {
+					t.Errorf("Layer %d: expected %.0f%% sparsity, got %f", i, expected*100, s)
+				}
+			}
+		})
+	}
+}
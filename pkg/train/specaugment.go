@@ -0,0 +1,177 @@
+package train
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+// SpecAugmentConfig defines the feature-domain augmentation parameters.
+// Widths are maximums: each mask has a random width in [0, width].
+type SpecAugmentConfig struct {
+	Prob          float32 `mapstructure:"prob"`            // Probability of augmenting a sample
+	TimeMasks     int     `mapstructure:"time_masks"`      // Number of time masks
+	TimeMaskWidth int     `mapstructure:"time_mask_width"` // Maximum time mask width in frames
+	FreqMasks     int     `mapstructure:"freq_masks"`      // Number of frequency masks
+	FreqMaskWidth int     `mapstructure:"freq_mask_width"` // Maximum frequency mask width in bins
+	TimeWarp      int     `mapstructure:"time_warp"`       // Maximum time warp distance in frames, 0 = off
+}
+
+// Enabled reports whether the configuration changes any sample.
+func (c SpecAugmentConfig) Enabled() bool {
+	return c.Prob > 0 && (c.TimeMasks > 0 && c.TimeMaskWidth > 0 || c.FreqMasks > 0 && c.FreqMaskWidth > 0 || c.TimeWarp > 0)
+}
+
+// SpecAugmenter applies SpecAugment (time warping, time and frequency masking) to
+// feature tensors of shape [channels, frames, coefficients]. Masks cover all
+// channels and are filled with the channel mean, which is zero for normalized
+// features. It is safe for concurrent use.
+type SpecAugmenter struct {
+	config SpecAugmentConfig
+	mu     sync.Mutex
+	rng    *rand.Rand
+}
+
+// NewSpecAugmenter creates a new SpecAugmenter with the provided config.
+func NewSpecAugmenter(config SpecAugmentConfig) *SpecAugmenter {
+	return NewSpecAugmenterWithSeed(config, time.Now().UnixNano())
+}
+
+// NewSpecAugmenterWithSeed creates a SpecAugmenter with a deterministic random source.
+func NewSpecAugmenterWithSeed(config SpecAugmentConfig, seed int64) *SpecAugmenter {
+	return &SpecAugmenter{
+		config: config,
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Augment returns an augmented copy of the features. Tensors that are not 3D, or
+// that are not selected by the probability, are returned unchanged.
+func (s *SpecAugmenter) Augment(features *model.Tensor) *model.Tensor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.augment(features, s.rng)
+}
+
+// augment is Augment with the given random source and without locking, for
+// callers that keep their own random source per goroutine.
+func (s *SpecAugmenter) augment(features *model.Tensor, rng *rand.Rand) *model.Tensor {
+	if features == nil || len(features.Shape) != 3 {
+		return features
+	}
+	if rng.Float32() > s.config.Prob {
+		return features
+	}
+
+	out := model.NewTensor(features.Shape)
+	copy(out.Data, features.Data)
+
+	// 1. Time Warping
+	if s.config.TimeWarp > 0 {
+		s.timeWarp(out, rng)
+	}
+
+	// 2. Frequency Masking
+	numChannels, numFrames, numCoeffs := out.Shape[0], out.Shape[1], out.Shape[2]
+	means := channelMeans(out)
+	for m := 0; m < s.config.FreqMasks; m++ {
+		start, width := s.maskRange(rng, s.config.FreqMaskWidth, numCoeffs)
+		for ch := 0; ch < numChannels; ch++ {
+			for i := 0; i < numFrames; i++ {
+				row := out.Data[(ch*numFrames+i)*numCoeffs:]
+				for d := start; d < start+width; d++ {
+					row[d] = means[ch]
+				}
+			}
+		}
+	}
+
+	// 3. Time Masking
+	for m := 0; m < s.config.TimeMasks; m++ {
+		start, width := s.maskRange(rng, s.config.TimeMaskWidth, numFrames)
+		for ch := 0; ch < numChannels; ch++ {
+			for i := start; i < start+width; i++ {
+				row := out.Data[(ch*numFrames+i)*numCoeffs : (ch*numFrames+i+1)*numCoeffs]
+				for d := range row {
+					row[d] = means[ch]
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// maskRange picks a random mask of width [0, maxWidth] (clamped to size) and its start.
+func (s *SpecAugmenter) maskRange(rng *rand.Rand, maxWidth, size int) (start, width int) {
+	if maxWidth > size {
+		maxWidth = size
+	}
+	if maxWidth <= 0 {
+		return 0, 0
+	}
+	width = rng.Intn(maxWidth + 1)
+	start = rng.Intn(size - width + 1)
+	return start, width
+}
+
+// timeWarp moves a random anchor frame by up to TimeWarp frames and stretches the
+// frames on either side to match, interpolating linearly between source frames.
+func (s *SpecAugmenter) timeWarp(t *model.Tensor, rng *rand.Rand) {
+	numChannels, numFrames, numCoeffs := t.Shape[0], t.Shape[1], t.Shape[2]
+	w := s.config.TimeWarp
+	if numFrames <= 2*w+1 {
+		return
+	}
+	anchor := w + rng.Intn(numFrames-2*w)
+	dest := anchor + rng.Intn(2*w+1) - w
+	if dest <= 0 || dest >= numFrames-1 || dest == anchor {
+		return
+	}
+
+	src := make([]float32, len(t.Data))
+	copy(src, t.Data)
+	last := float32(numFrames - 1)
+	for i := 0; i < numFrames; i++ {
+		// Map output frame i back to a (fractional) source frame
+		var pos float32
+		if i <= dest {
+			pos = float32(i) * float32(anchor) / float32(dest)
+		} else {
+			pos = float32(anchor) + float32(i-dest)*(last-float32(anchor))/(last-float32(dest))
+		}
+		lo := int(pos)
+		if lo >= numFrames-1 {
+			lo = numFrames - 2
+		}
+		frac := pos - float32(lo)
+		for ch := 0; ch < numChannels; ch++ {
+			a := src[(ch*numFrames+lo)*numCoeffs:]
+			b := src[(ch*numFrames+lo+1)*numCoeffs:]
+			row := t.Data[(ch*numFrames+i)*numCoeffs : (ch*numFrames+i+1)*numCoeffs]
+			for d := range row {
+				row[d] = a[d]*(1-frac) + b[d]*frac
+			}
+		}
+	}
+}
+
+// channelMeans returns the mean value of each channel of a [C, F, D] tensor.
+func channelMeans(t *model.Tensor) []float32 {
+	numChannels := t.Shape[0]
+	size := t.Shape[1] * t.Shape[2]
+	means := make([]float32, numChannels)
+	if size == 0 {
+		return means
+	}
+	for ch := range means {
+		var sum float64
+		for _, v := range t.Data[ch*size : (ch+1)*size] {
+			sum += float64(v)
+		}
+		means[ch] = float32(sum / float64(size))
+	}
+	return means
+}
@@ -0,0 +1,157 @@
+package train
+
+import (
+	"testing"
+
+	"github.com/tomkiv/hotword/pkg/model"
+)
+
+// rampFeatures returns a [1, frames, coeffs] tensor whose values are all distinct.
+func rampFeatures(frames, coeffs int) *model.Tensor {
+	t := model.NewTensor([]int{1, frames, coeffs})
+	for i := range t.Data {
+		t.Data[i] = float32(i + 1)
+	}
+	return t
+}
+
+func TestSpecAugmenter(t *testing.T) {
+	t.Run("Frequency Masks", func(t *testing.T) {
+		aug := NewSpecAugmenterWithSeed(SpecAugmentConfig{Prob: 1, FreqMasks: 2, FreqMaskWidth: 5}, 1)
+		in := rampFeatures(20, 10)
+		out := aug.Augment(in)
+		mean := channelMeans(in)[0]
+
+		// A masked bin is masked in every frame
+		masked := 0
+		for d := 0; d < 10; d++ {
+			if out.Data[d] == mean {
+				masked++
+				for i := 0; i < 20; i++ {
+					if out.Data[i*10+d] != mean {
+						t.Fatalf("Bin %d masked in frame 0 but not in frame %d", d, i)
+					}
+				}
+			}
+		}
+		if masked == 0 || masked > 10 {
+			t.Errorf("Expected between 1 and 10 masked bins, got %d", masked)
+		}
+		if in.Data[0] != 1 {
+			t.Error("Input tensor was modified")
+		}
+	})
+
+	t.Run("Time Masks", func(t *testing.T) {
+		aug := NewSpecAugmenterWithSeed(SpecAugmentConfig{Prob: 1, TimeMasks: 1, TimeMaskWidth: 8}, 3)
+		in := rampFeatures(20, 10)
+		out := aug.Augment(in)
+		mean := channelMeans(in)[0]
+
+		masked := 0
+		for i := 0; i < 20; i++ {
+			row := out.Data[i*10 : (i+1)*10]
+			if row[0] == mean {
+				masked++
+				for _, v := range row {
+					if v != mean {
+						t.Fatalf("Frame %d only partially masked", i)
+					}
+				}
+			}
+		}
+		if masked == 0 || masked > 8 {
+			t.Errorf("Expected between 1 and 8 masked frames, got %d", masked)
+		}
+	})
+
+	t.Run("Time Warp", func(t *testing.T) {
+		aug := NewSpecAugmenterWithSeed(SpecAugmentConfig{Prob: 1, TimeWarp: 5}, 7)
+		in := rampFeatures(40, 4)
+
+		changed := false
+		for trial := 0; trial < 10; trial++ {
+			out := aug.Augment(in)
+			// The first and last frames stay in place
+			for d := 0; d < 4; d++ {
+				if out.Data[d] != in.Data[d] || out.Data[39*4+d] != in.Data[39*4+d] {
+					t.Fatalf("Edge frames moved by time warp")
+				}
+			}
+			// Frames stay ordered in time (the ramp remains increasing)
+			for i := 1; i < 40; i++ {
+				if out.Data[i*4] < out.Data[(i-1)*4] {
+					t.Fatalf("Warped frames out of order at %d", i)
+				}
+			}
+			for i := range in.Data {
+				if out.Data[i] != in.Data[i] {
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			t.Error("Expected time warp to change some frames")
+		}
+	})
+
+	t.Run("Probability And Shapes", func(t *testing.T) {
+		off := NewSpecAugmenterWithSeed(SpecAugmentConfig{Prob: 0, TimeMasks: 2, TimeMaskWidth: 5}, 1)
+		in := rampFeatures(20, 10)
+		if out := off.Augment(in); out != in {
+			t.Error("Expected unchanged tensor with zero probability")
+		}
+
+		on := NewSpecAugmenterWithSeed(SpecAugmentConfig{Prob: 1, TimeMasks: 2, TimeMaskWidth: 50, FreqMasks: 2, FreqMaskWidth: 50}, 1)
+		out := on.Augment(in)
+		if len(out.Shape) != 3 || out.Shape[1] != 20 || out.Shape[2] != 10 {
+			t.Errorf("Unexpected shape %v", out.Shape)
+		}
+
+		flat := &model.Tensor{Data: []float32{1, 2}, Shape: []int{2}}
+		if on.Augment(flat) != flat {
+			t.Error("Expected non-3D tensors to pass through")
+		}
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		if (SpecAugmentConfig{Prob: 1}).Enabled() {
+			t.Error("Config without masks or warp should be disabled")
+		}
+		if !(SpecAugmentConfig{Prob: 0.5, FreqMasks: 1, FreqMaskWidth: 3}).Enabled() {
+			t.Error("Config with frequency masks should be enabled")
+		}
+	})
+}
+
+func TestTrainersUseSpecAugment(t *testing.T) {
+	extractor := func(s []float32) *model.Tensor {
+		out := rampFeatures(8, 5)
+		out.Data[0] = s[0]
+		return out
+	}
+	newModel := func() *model.SequentialModel {
+		return model.NewSequentialModel(
+			model.NewDenseLayer(model.NewTensor([]int{1, 40}), []float32{0}),
+			model.NewSigmoidLayer(),
+		)
+	}
+	ds := &Dataset{Samples: []Sample{
+		{Audio: []float32{1}, IsHotword: true},
+		{Audio: []float32{0}, IsHotword: false},
+		{Audio: []float32{1}, IsHotword: true},
+		{Audio: []float32{0}, IsHotword: false},
+	}}
+	cfg := SpecAugmentConfig{Prob: 1, TimeMasks: 1, TimeMaskWidth: 3, FreqMasks: 1, FreqMaskWidth: 2, TimeWarp: 1}
+
+	trainers := map[string]AugmentorTrainer{
+		"Trainer":         NewTrainer(newModel(), 0.1),
+		"ParallelTrainer": NewParallelTrainer(newModel(), 0.1, 2),
+	}
+	for name, tr := range trainers {
+		t.Run(name, func(t *testing.T) {
+			tr.SetSpecAugmenter(NewSpecAugmenterWithSeed(cfg, 1))
+			tr.Train(ds, 2, extractor)
+		})
+	}
+}
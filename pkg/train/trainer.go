@@ -3,12 +3,18 @@ package train
 import (
 	"fmt"
 
+	"github.com/tomkiv/hotword/pkg/features"
 	"github.com/tomkiv/hotword/pkg/model"
 )
 
 // AugmentorTrainer is an interface for trainers that support dynamic augmentation.
 type AugmentorTrainer interface {
 	SetAugmentor(a *Augmentor)
+	SetSpecAugmenter(s *SpecAugmenter)
+	SetFeatureCache(c *features.Cache)
+	SetPipelineConfig(c PipelineConfig)
+	SetPruner(p *Pruner)
+	SetTeacher(t *Teacher)
 	Train(ds *Dataset, epochs int, featureExtractor func([]float32) *model.Tensor)
 }
 
@@ -17,6 +23,12 @@ type Trainer struct {
 	model        model.Model
 	learningRate float32
 	augmentor    *Augmentor
+	specAugment  *SpecAugmenter
+	cache        *features.Cache
+	pipeline     PipelineConfig
+	pruner       *Pruner
+	layerIndex   map[model.Layer]int // Position of every layer, for the pruning masks
+	teacher      *Teacher
 }
 
 // NewTrainer creates a new Trainer.
@@ -32,84 +44,164 @@ func (t *Trainer) SetAugmentor(a *Augmentor) {
 	t.augmentor = a
 }
 
+// SetSpecAugmenter sets the feature-domain augmenter for the trainer.
+func (t *Trainer) SetSpecAugmenter(s *SpecAugmenter) {
+	t.specAugment = s
+}
+
+// SetFeatureCache sets the cache used for the features of unaugmented samples.
+func (t *Trainer) SetFeatureCache(c *features.Cache) {
+	t.cache = c
+}
+
+// SetPipelineConfig sets the data pipeline options (workers, prefetch depth, seed).
+func (t *Trainer) SetPipelineConfig(c PipelineConfig) {
+	t.pipeline = c
+}
+
+// SetPruner sets the pruner applied at the start of every epoch. The pruned
+// weights of the model stay at zero through all parameter updates.
+func (t *Trainer) SetPruner(p *Pruner) {
+	t.pruner = p
+	t.layerIndex = make(map[model.Layer]int)
+	for i, l := range t.model.GetLayers() {
+		t.layerIndex[l] = i
+	}
+}
+
+// SetTeacher sets the teacher for knowledge distillation. Every example is
+// trained on the distillation loss against the soft targets of the teacher.
+func (t *Trainer) SetTeacher(teacher *Teacher) {
+	t.teacher = teacher
+}
+
+// newPipeline creates the data pipeline feeding a trainer.
+func newPipeline(ds *Dataset, featureExtractor func([]float32) *model.Tensor, config PipelineConfig, aug *Augmentor, spec *SpecAugmenter, cache *features.Cache, teacher *Teacher) *Pipeline {
+	p := NewPipeline(ds, featureExtractor, config)
+	p.SetAugmentor(aug)
+	p.SetSpecAugmenter(spec)
+	p.SetFeatureCache(cache)
+	p.SetTeacher(teacher)
+	return p
+}
+
 // TrainStep performs a single training iteration on a single sample.
 // Returns the loss before the update.
 func (t *Trainer) TrainStep(input *model.Tensor, target float32) float32 {
-	// 1. Forward Pass (storing intermediate inputs for backward pass)
-	layers := t.model.GetLayers()
-	inputs := make([]*model.Tensor, len(layers)+1)
-	inputs[0] = input
-
-	for i, layer := range layers {
-		inputs[i+1] = layer.Forward(inputs[i])
-	}
+	return t.TrainStepMasked(input, nil, target)
+}
 
-	finalOutput := inputs[len(inputs)-1]
-	if len(finalOutput.Data) != 1 {
-		panic(fmt.Sprintf("Trainer: model output size mismatch. Expected 1 (binary classification), got %d. Multi-output models are not currently supported by Trainer.", len(finalOutput.Data)))
-	}
+// TrainStepMasked is TrainStep for a padded sample whose feature frames with
+// mask[t] false are padding (nil = no padding). Recurrent layers skip them in
+// the forward pass and in BPTT.
+func (t *Trainer) TrainStepMasked(input *model.Tensor, mask []bool, target float32) float32 {
+	return t.step(input, mask, binaryLoss(target))
+}
 
-	prediction := finalOutput.Data[0]
-	loss := model.BCELoss([]float32{prediction}, []float32{target})
+// binaryLoss returns the BCE loss and gradient of a binary model output.
+func binaryLoss(target float32) func(output []float32) (float32, []float32) {
+	return func(output []float32) (float32, []float32) {
+		if len(output) != 1 {
+			panic(fmt.Sprintf("Trainer: model output size mismatch. Expected 1 (binary classification), got %d. Multi-class models are trained with TrainStepClass.", len(output)))
+		}
 
-	// 2. Backward Pass
-	// dL/dz = prediction - target (numerically stable BCE+Sigmoid gradient)
-	// This combined gradient already accounts for the sigmoid derivative,
-	// so we skip the sigmoid layer and start from the layer before it.
-	grad := &model.Tensor{Data: []float32{prediction - target}, Shape: []int{1}}
+		prediction := output[0]
+		loss := model.BCELoss([]float32{prediction}, []float32{target})
 
-	// Determine starting index for backward pass
-	// If last layer is sigmoid, skip it (gradient already incorporates sigmoid derivative)
-	startIdx := len(layers) - 1
-	if startIdx >= 0 && layers[startIdx].Type() == "sigmoid" {
-		startIdx--
+		// dL/dz = prediction - target (numerically stable BCE+Sigmoid gradient)
+		// This combined gradient already accounts for the sigmoid derivative,
+		// so the output sigmoid layer is skipped.
+		return loss, []float32{prediction - target}
 	}
+}
 
-	for i := startIdx; i >= 0; i-- {
-		gradInput, gradWeights, gradBias := layers[i].Backward(inputs[i], grad)
+// TrainStepClass performs a single training iteration on a sample of class label
+// for a multi-class model whose output layer is a softmax, minimizing the
+// categorical cross-entropy. mask is as for TrainStepMasked.
+// Returns the loss before the update.
+func (t *Trainer) TrainStepClass(input *model.Tensor, mask []bool, label int) float32 {
+	return t.step(input, mask, classLoss(label))
+}
 
-		// If the layer has parameters, update them
-		if gradWeights != nil {
-			weights, bias := layers[i].Params()
-			model.SGDUpdate(weights, gradWeights, t.learningRate)
-			model.SGDBiasUpdate(bias, gradBias, t.learningRate)
-			// Push updated params back to the layer (essential for GRU/LSTM which return copies)
-			layers[i].SetParams(weights, bias)
+// classLoss returns the categorical cross-entropy loss and gradient of a
+// multi-class model output.
+func classLoss(label int) func(output []float32) (float32, []float32) {
+	return func(output []float32) (float32, []float32) {
+		if label < 0 || label >= len(output) {
+			panic(fmt.Sprintf("Trainer: class label %d out of range for a model with %d outputs", label, len(output)))
 		}
 
-		grad = gradInput
+		// dL/dz = probabilities - one-hot target (combined softmax+CE gradient),
+		// so the output softmax layer is skipped.
+		target := model.OneHot(label, len(output))
+		return model.CategoricalCELoss(output, target), model.SoftmaxCEGradient(output, target)
 	}
+}
+
+// trainExample trains on a pipeline example, using its class label for
+// multi-class datasets and its binary target otherwise, mixed with the soft
+// targets of the teacher when distilling.
+func (t *Trainer) trainExample(ex Example, multiClass bool) float32 {
+	loss := binaryLoss(ex.Target)
+	if multiClass {
+		loss = classLoss(ex.Label)
+	}
+	if t.teacher != nil && ex.Soft != nil {
+		loss = t.teacher.loss(loss, ex.Soft)
+	}
+	return t.step(ex.Features, ex.Mask, loss)
+}
+
+// step runs the forward pass, computes the loss and the gradient of the output
+// activation's input with lossGrad, and updates the parameters by backpropagation.
+func (t *Trainer) step(input *model.Tensor, mask []bool, lossGrad func(output []float32) (float32, []float32)) float32 {
+	// 1. Forward Pass (storing intermediate outputs for backward pass)
+	trace := t.model.ForwardTraceMasked(input, mask)
+	finalOutput := trace.Output()
+	loss, gradOutput := lossGrad(finalOutput.Data)
+
+	// 2. Backward Pass, skipping the output activation
+	grad := &model.Tensor{Data: gradOutput, Shape: finalOutput.Shape}
+
+	t.model.BackwardTrace(trace, grad, true, func(l model.Layer, gradWeights *model.Tensor, gradBias []float32) {
+		weights, bias := l.Params()
+		model.SGDUpdate(weights, gradWeights, t.learningRate)
+		model.SGDBiasUpdate(bias, gradBias, t.learningRate)
+		if idx, ok := t.layerIndex[l]; ok && t.pruner != nil {
+			t.pruner.apply(idx, weights.Data)
+		}
+		// Push updated params back to the layer (essential for GRU/LSTM which return copies)
+		l.SetParams(weights, bias)
+	})
 
 	return loss
 }
 
 // Train runs the training loop over the provided dataset for a number of epochs.
+// Samples are shuffled every epoch and prepared in the background by the data pipeline.
 func (t *Trainer) Train(ds *Dataset, epochs int, featureExtractor func([]float32) *model.Tensor) {
-	for epoch := 1; epoch <= epochs; epoch++ {
-		var totalLoss float32
-
-		pb := NewProgressBar(len(ds.Samples), fmt.Sprintf("Epoch %d/%d", epoch, epochs))
+	pipeline := newPipeline(ds, featureExtractor, t.pipeline, t.augmentor, t.specAugment, t.cache, t.teacher)
 
-		for i, sample := range ds.Samples {
-			audioData := sample.Audio
+	// Layers such as batch normalization use training behavior until Train returns
+	t.model.SetTraining(true)
+	defer t.model.SetTraining(false)
 
-			// Apply dynamic augmentation only to hotwords
-			if t.augmentor != nil && sample.IsHotword {
-				audioData = t.augmentor.Augment(audioData)
-			}
+	for epoch := 1; epoch <= epochs; epoch++ {
+		var totalLoss float32
 
-			// Convert raw audio to features (e.g. Mel-Spectrogram)
-			features := featureExtractor(audioData)
+		if t.pruner != nil {
+			fmt.Printf("Pruned to %.1f%% sparsity\n", t.pruner.Prune(t.model, epoch)*100)
+		}
 
-			target := float32(0.0)
-			if sample.IsHotword {
-				target = 1.0
-			}
+		pb := NewProgressBar(len(ds.Samples), fmt.Sprintf("Epoch %d/%d", epoch, epochs))
 
-			loss := t.TrainStep(features, target)
+		i := 0
+		for ex := range pipeline.Epoch(epoch) {
+			loss := t.trainExample(ex, len(ds.Classes) > 0)
 			totalLoss += loss
 
-			pb.Update(i + 1)
+			i++
+			pb.Update(i)
 		}
 		pb.Finish()
 		fmt.Printf("Average Loss: %.4f\n", totalLoss/float32(len(ds.Samples)))
@@ -1,8 +1,12 @@
 package train
 
 import (
+	"math"
+	"reflect"
+	"sync"
 	"testing"
 
+	"github.com/tomkiv/hotword/pkg/features"
 	"github.com/tomkiv/hotword/pkg/model"
 )
 
@@ -60,3 +64,240 @@ func TestTrainLoop(t *testing.T) {
 	// Just check if it runs without panic
 	trainer.Train(ds, 2, extractor)
 }
+
+func TestTrainFeatureCache(t *testing.T) {
+	cfg := features.DefaultConfig()
+	audioLen := 1000 // 2 frames
+	newModel := func() *model.SequentialModel {
+		return model.NewSequentialModel(
+			model.NewDenseLayer(model.NewTensor([]int{1, 2 * cfg.NumMelFilters}), []float32{0}),
+			model.NewSigmoidLayer(),
+		)
+	}
+	newDataset := func() *Dataset {
+		ds := &Dataset{}
+		for i := 0; i < 4; i++ {
+			ds.Samples = append(ds.Samples, Sample{
+				Audio:     make([]float32, audioLen),
+				IsHotword: i%2 == 0,
+				Source:    "file",
+				Offset:    i,
+			})
+		}
+		// Synthetic samples bypass the cache
+		ds.Samples = append(ds.Samples, Sample{Audio: make([]float32, audioLen)})
+		return ds
+	}
+
+	var mu sync.Mutex
+	calls := 0
+	extractor := func(s []float32) *model.Tensor {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return features.ExtractWithConfig(s, cfg)
+	}
+
+	trainers := map[string]func() AugmentorTrainer{
+		"Trainer":         func() AugmentorTrainer { return NewTrainer(newModel(), 0.1) },
+		"ParallelTrainer": func() AugmentorTrainer { return NewParallelTrainer(newModel(), 0.1, 2) },
+	}
+	for name, newTrainer := range trainers {
+		t.Run(name, func(t *testing.T) {
+			calls = 0
+			cache := features.NewCache(cfg)
+			tr := newTrainer()
+			tr.SetFeatureCache(cache)
+			tr.Train(newDataset(), 3, extractor)
+
+			// Four cached samples are extracted once, the synthetic one every epoch
+			if hits, misses := cache.Stats(); misses != 4 || hits != 8 {
+				t.Errorf("Expected 8 hits and 4 misses, got %d and %d", hits, misses)
+			}
+			if calls != 3 {
+				t.Errorf("Expected 3 extractor calls, got %d", calls)
+			}
+		})
+	}
+
+	t.Run("Augmented Samples Bypass Cache", func(t *testing.T) {
+		calls = 0
+		cache := features.NewCache(cfg)
+		tr := NewTrainer(newModel(), 0.1)
+		tr.SetFeatureCache(cache)
+		tr.SetAugmentor(NewAugmentor(AugmentorConfig{AugmentProb: 1, MaxGainScale: 0.2}, nil))
+		tr.Train(newDataset(), 2, extractor)
+
+		// Two hotwords are augmented every epoch, plus the synthetic sample
+		if calls != 6 {
+			t.Errorf("Expected 6 extractor calls, got %d", calls)
+		}
+		if cache.Len() != 2 {
+			t.Errorf("Expected only the 2 background samples to be cached, got %d", cache.Len())
+		}
+	})
+}
+
+func TestTrainersDropout(t *testing.T) {
+	ds := indexedDataset(12)
+	train := func(newTrainer func(m *model.SequentialModel) AugmentorTrainer, rate float32) []float32 {
+		model.ResetRand(1)
+		m, err := model.BuildModelFromConfig([]model.LayerConfig{
+			{Type: "dropout", Rate: rate},
+			{Type: "dense", Units: 1},
+			{Type: "sigmoid"},
+		}, []int{1, 2, 4})
+		if err != nil {
+			t.Fatalf("Failed to build model: %v", err)
+		}
+		tr := newTrainer(m)
+		tr.SetPipelineConfig(PipelineConfig{Workers: 2, Seed: 3})
+		tr.Train(ds, 2, copyExtractor)
+		if m.GetLayers()[0].(*model.DropoutLayer).Training() {
+			t.Error("Expected dropout to be disabled after training")
+		}
+		w, _ := m.GetLayers()[1].Params()
+		return append([]float32(nil), w.Data...)
+	}
+
+	trainers := map[string]func(m *model.SequentialModel) AugmentorTrainer{
+		"Trainer":         func(m *model.SequentialModel) AugmentorTrainer { return NewTrainer(m, 0.1) },
+		"ParallelTrainer": func(m *model.SequentialModel) AugmentorTrainer { return NewParallelTrainer(m, 0.1, 2) },
+	}
+	for name, newTrainer := range trainers {
+		t.Run(name, func(t *testing.T) {
+			a := train(newTrainer, 0.5)
+			if b := train(newTrainer, 0.5); !reflect.DeepEqual(a, b) {
+				t.Errorf("Expected identical weights for the same seed:\n%v\n%v", a, b)
+			}
+			if c := train(newTrainer, 0); reflect.DeepEqual(a, c) {
+				t.Error("Expected dropout to change the trained weights")
+			}
+		})
+	}
+}
+
+func TestTrainersMaskPadding(t *testing.T) {
+	// Frames of two samples each: [1, frames, 2]
+	frameExtractor := func(s []float32) *model.Tensor {
+		t := model.NewTensor([]int{1, len(s) / 2, 2})
+		copy(t.Data, s)
+		return t
+	}
+	frameMask := func(actualLen, numSamples int) []bool {
+		return model.PrefixMask(numSamples/2, (actualLen+1)/2)
+	}
+
+	// The same samples, unpadded and padded with noise
+	short, padded := &Dataset{}, &Dataset{}
+	for i, s := range indexedDataset(8).Samples {
+		short.Samples = append(short.Samples, s)
+		audio := append(append([]float32{}, s.Audio...), 5, -3, float32(i), 2)
+		padded.Samples = append(padded.Samples, Sample{Audio: audio, IsHotword: s.IsHotword, ActualLen: len(s.Audio)})
+	}
+
+	trainers := map[string]func(m model.Model) AugmentorTrainer{
+		"Trainer":         func(m model.Model) AugmentorTrainer { return NewTrainer(m, 0.1) },
+		"ParallelTrainer": func(m model.Model) AugmentorTrainer { return NewParallelTrainer(m, 0.1, 2) },
+	}
+	for name, newTrainer := range trainers {
+		t.Run(name, func(t *testing.T) {
+			train := func(ds *Dataset) model.Model {
+				model.ResetRand(19)
+				m, err := model.BuildModelFromConfig([]model.LayerConfig{
+					{Type: "gru", Units: 3, ReturnSequences: true},
+					{Type: "lstm", Units: 2},
+					{Type: "dense", Units: 1},
+					{Type: "sigmoid"},
+				}, []int{1, 4, 2})
+				if err != nil {
+					t.Fatalf("Failed to build model: %v", err)
+				}
+				tr := newTrainer(m)
+				tr.SetPipelineConfig(PipelineConfig{Workers: 2, Seed: 1, FrameMask: frameMask})
+				tr.Train(ds, 2, frameExtractor)
+				return m
+			}
+
+			expected, got := train(short), train(padded)
+			for i, l := range got.GetLayers() {
+				w, b := l.Params()
+				expW, expB := expected.GetLayers()[i].Params()
+				if w == nil {
+					continue
+				}
+				for j := range expW.Data {
+					if d := w.Data[j] - expW.Data[j]; d > 1e-5 || d < -1e-5 {
+						t.Fatalf("Layer %d (%s) weight %d: expected %f, got %f", i, l.Type(), j, expW.Data[j], w.Data[j])
+					}
+				}
+				for j := range expB {
+					if d := b[j] - expB[j]; d > 1e-5 || d < -1e-5 {
+						t.Fatalf("Layer %d (%s) bias %d: expected %f, got %f", i, l.Type(), j, expB[j], b[j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTrainersMultiClass(t *testing.T) {
+	// Class c has its energy in value c of the audio
+	ds := &Dataset{Classes: []string{"yes", "no", "unknown"}}
+	for i := 0; i < 12; i++ {
+		audio := []float32{0.1, 0.1, 0.1, 0.1 * float32(i%4)}
+		audio[i%3] = 1
+		ds.Samples = append(ds.Samples, Sample{Audio: audio, Label: i % 3, IsHotword: i%3 != 2})
+	}
+
+	trainers := map[string]func(m model.Model) AugmentorTrainer{
+		"Trainer":         func(m model.Model) AugmentorTrainer { return NewTrainer(m, 0.5) },
+		"ParallelTrainer": func(m model.Model) AugmentorTrainer { return NewParallelTrainer(m, 0.5, 2) },
+	}
+	for name, newTrainer := range trainers {
+		t.Run(name, func(t *testing.T) {
+			model.ResetRand(21)
+			m, err := model.BuildModelFromConfig([]model.LayerConfig{
+				{Type: "dense", Units: 3},
+				{Type: "softmax"},
+			}, []int{1, 2, 2})
+			if err != nil {
+				t.Fatalf("Failed to build model: %v", err)
+			}
+			tr := newTrainer(m)
+			tr.SetPipelineConfig(PipelineConfig{Workers: 2, Seed: 1})
+			tr.Train(ds, 30, copyExtractor)
+
+			for _, s := range ds.Samples {
+				probs := m.Forward(copyExtractor(s.Audio)).Data
+				var sum float32
+				predicted := 0
+				for c, p := range probs {
+					sum += p
+					if p > probs[predicted] {
+						predicted = c
+					}
+				}
+				if sum < 0.999 || sum > 1.001 {
+					t.Errorf("Expected probabilities summing to 1, got %v", probs)
+				}
+				if predicted != s.Label {
+					t.Errorf("Sample %v: expected class %d, got %d (%v)", s.Audio, s.Label, predicted, probs)
+				}
+			}
+		})
+	}
+
+	t.Run("Loss Decreases", func(t *testing.T) {
+		m := model.NewSequentialModel(model.NewDenseLayer(model.NewTensor([]int{3, 2}), make([]float32, 3)), model.NewSoftmaxLayer())
+		tr := NewTrainer(m, 0.5)
+		input := &model.Tensor{Data: []float32{1, -1}, Shape: []int{2}}
+		first := tr.TrainStepClass(input, nil, 2)
+		if math.Abs(float64(first)-math.Log(3)) > 1e-5 {
+			t.Errorf("Expected initial loss ln(3), got %f", first)
+		}
+		if second := tr.TrainStepClass(input, nil, 2); second >= first {
+			t.Errorf("Expected the loss to decrease, got %f then %f", first, second)
+		}
+	})
+}
@@ -20,7 +20,7 @@ func main() {
 
 	fmt.Printf("Crawling directories:\n  Hotword: %s\n  Background: %s\n", hotwordDir, bgDir)
 
-	ds, err := train.LoadDataset(hotwordDir, bgDir)
+	ds, err := train.LoadDataset(hotwordDir, bgDir, 16000)
 	if err != nil {
 		fmt.Printf("Error loading dataset: %v\n", err)
 		return
@@ -11,7 +11,7 @@ func main() {
 	fmt.Println("Starting Training Pipeline Integration Verification...")
 
 	// 1. Prepare dummy dataset
-	// In a real scenario, use train.LoadDataset("data/hotword", "data/background")
+	// In a real scenario, use train.LoadDataset("data/hotword", "data/background", 16000)
 	ds := &train.Dataset{
 		Samples: []train.Sample{
 			{Audio: make([]float32, 16000), IsHotword: true},